@@ -0,0 +1,127 @@
+package blockchain
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// 链码事件的处理函数，event.Payload为事件对应类型的JSON
+type EventHandler func(event *fab.CCEvent)
+
+var (
+	eventHandlers = make(map[string][]EventHandler)
+	eventMutex    sync.RWMutex
+)
+
+// 为链码事件名称注册处理函数，同一事件可以注册多个，按注册顺序调用
+func RegisterEventHandler(eventName string, handler EventHandler) {
+	eventMutex.Lock()
+	defer eventMutex.Unlock()
+	eventHandlers[eventName] = append(eventHandlers[eventName], handler)
+}
+
+// 最后处理完的链码事件，每个交易最多一个链码事件，区块号和交易ID可以确定事件的位置
+type EventCheckpoint struct {
+	BlockNumber uint64 `json:"blockNumber"`
+	TxID        string `json:"txId"`
+}
+
+// 订阅链码的所有事件并分发给注册的处理函数，阻塞直到订阅断开
+// 每处理完一个事件就把位置写入checkpointPath，重新订阅时从该区块开始，跳过已经处理的事件，断开期间的事件不会丢失
+// checkpointPath不存在时从最新区块开始订阅；处理函数返回后、写入位置前退出时，该事件在重新订阅后会再处理一次
+func ListenEvents(checkpointPath string) error {
+	checkpoint, err := readEventCheckpoint(checkpointPath)
+	if err != nil {
+		return err
+	}
+	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(User))
+	// 过滤区块中不包含事件内容，需要订阅完整区块
+	opts := []event.ClientOption{event.WithBlockEvents()}
+	if checkpoint != nil {
+		opts = append(opts, event.WithSeekType(seek.FromBlock), event.WithBlockNum(checkpoint.BlockNumber))
+	}
+	cli, err := event.New(ctx, opts...)
+	if err != nil {
+		return err
+	}
+	reg, notifier, err := cli.RegisterChaincodeEvent(ChainCodeName, ".*")
+	if err != nil {
+		return err
+	}
+	defer cli.Unregister(reg)
+	if checkpoint != nil {
+		log.Printf("已订阅链码%s的事件，从区块%d的交易%s之后继续", ChainCodeName, checkpoint.BlockNumber, checkpoint.TxID)
+	} else {
+		log.Printf("已订阅链码%s的事件，从最新区块开始", ChainCodeName)
+	}
+
+	//从记录的区块开始订阅会重新收到该区块中已经处理的事件，跳过直到记录的交易
+	skipping := checkpoint != nil
+	for e := range notifier {
+		if skipping && e.BlockNumber == checkpoint.BlockNumber {
+			skipping = e.TxID != checkpoint.TxID
+			continue
+		}
+		skipping = false
+		dispatchEvent(e)
+		if err := writeEventCheckpoint(checkpointPath, EventCheckpoint{BlockNumber: e.BlockNumber, TxID: e.TxID}); err != nil {
+			return err
+		}
+	}
+	return errors.New("链码事件订阅已断开")
+}
+
+// 读取最后处理完的事件位置，文件不存在时返回nil
+func readEventCheckpoint(path string) (*EventCheckpoint, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var checkpoint EventCheckpoint
+	if err := json.Unmarshal(data, &checkpoint); err != nil {
+		return nil, err
+	}
+	return &checkpoint, nil
+}
+
+// 写入最后处理完的事件位置，先写临时文件再重命名，避免中途退出留下不完整的文件
+func writeEventCheckpoint(path string, checkpoint EventCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func dispatchEvent(e *fab.CCEvent) {
+	eventMutex.RLock()
+	handlers := eventHandlers[e.EventName]
+	eventMutex.RUnlock()
+	if len(handlers) == 0 {
+		log.Printf("链码事件%s(交易%s)没有处理函数", e.EventName, e.TxID)
+		return
+	}
+	for _, handler := range handlers {
+		handler(e)
+	}
+}
@@ -24,16 +24,27 @@ func Init() {
 }
 
 func ChannelExecute(fcn string, args [][]byte) (channel.Response, error) {
-	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(User))
+	return ChannelExecuteAs(User, fcn, args)
+}
+
+// 以指定用户的身份提交交易，链码会校验交易提交者是否为所操作账户的持有人
+func ChannelExecuteAs(user string, fcn string, args [][]byte) (channel.Response, error) {
+	return ChannelExecuteWithTransient(user, fcn, args, nil)
+}
+
+// 提交带transient的交易，transient只传给背书节点，不会写入交易，用于传递私有数据
+func ChannelExecuteWithTransient(user string, fcn string, args [][]byte, transient map[string][]byte) (channel.Response, error) {
+	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(user))
 	cli, err := channel.New(ctx)
 	if err != nil {
 		return channel.Response{}, err
 	}
 
 	resp, err := cli.Execute(channel.Request{
-		ChaincodeID: ChainCodeName,
-		Fcn:         fcn,
-		Args:        args,
+		ChaincodeID:  ChainCodeName,
+		Fcn:          fcn,
+		Args:         args,
+		TransientMap: transient,
 	}, channel.WithTargetEndpoints("peer0.org1.blockchainrealestate.com"))
 	if err != nil {
 		return channel.Response{}, err
@@ -42,7 +53,12 @@ func ChannelExecute(fcn string, args [][]byte) (channel.Response, error) {
 }
 
 func ChannelQuery(fcn string, args [][]byte) (channel.Response, error) {
-	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(User))
+	return ChannelQueryAs(User, fcn, args)
+}
+
+// 以指定用户的身份查询，链码按查询者的身份决定能否看到余额、成交价等私有数据
+func ChannelQueryAs(user string, fcn string, args [][]byte) (channel.Response, error) {
+	ctx := SDK.ChannelContext(ChannelName, fabsdk.WithOrg(Org), fabsdk.WithUser(user))
 	cli, err := channel.New(ctx)
 	if err != nil {
 		return channel.Response{}, err
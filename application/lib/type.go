@@ -1,13 +1,19 @@
 package lib
 
+//链码写入私有数据集合的记录，账本和事件中的公开记录不含敏感字段，PrivateHash为完整记录的哈希
+type Hashed struct {
+	PrivateHash string `json:"privateHash,omitempty"` //私有数据集合中完整记录的哈希
+}
+
 type Selling struct {
-	ObjectOfSale  string  `json:"objectOfSale"`  //销售对象(正在出售的房地产RealEstateID)
-	Seller        string  `json:"seller"`        //发起销售人、卖家(卖家AccountId)
-	Buyer         string  `json:"buyer"`         //参与销售人、买家(买家AccountId)
-	Price         float64 `json:"price"`         //价格
-	CreateTime    string  `json:"createTime"`    //创建时间
-	SalePeriod    int     `json:"salePeriod"`    //智能合约的有效期(单位为天)
-	SellingStatus string  `json:"sellingStatus"` //销售状态
+	ObjectOfSale  string `json:"objectOfSale"`  //销售对象(正在出售的房地产RealEstateID)
+	Seller        string `json:"seller"`        //发起销售人、卖家(卖家AccountId)
+	Buyer         string `json:"buyer"`         //参与销售人、买家(买家AccountId)
+	Price         Amount `json:"price"`         //价格
+	CreateTime    string `json:"createTime"`    //创建时间
+	SalePeriod    int    `json:"salePeriod"`    //智能合约的有效期(单位为天)
+	SellingStatus string `json:"sellingStatus"` //销售状态
+	Hashed
 }
 
 var SellingStatusConstant = func() map[string]string {
@@ -25,5 +31,191 @@ type Donating struct {
 	Donor            string `json:"donor"`            //捐赠人(捐赠人AccountId)
 	Grantee          string `json:"grantee"`          //受赠人(受赠人AccountId)
 	CreateTime       string `json:"createTime"`       //创建时间
+	DonatingPeriod   int    `json:"donatingPeriod"`   //受赠人确认接收的期限(单位为天)
 	DonatingStatus   string `json:"donatingStatus"`   //捐赠状态
+	Hashed
+}
+
+type Offer struct {
+	OfferID      string `json:"offerId"`      //报价ID
+	ObjectOfSale string `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
+	Seller       string `json:"seller"`       //卖家(卖家AccountId)
+	Buyer        string `json:"buyer"`        //买家(买家AccountId)
+	Price        Amount `json:"price"`        //当前价格，卖家还价后为还价
+	OfferPrice   Amount `json:"offerPrice"`   //买家最初的报价
+	ExpireTime   string `json:"expireTime"`   //报价有效期
+	CreateTime   string `json:"createTime"`   //创建时间
+	UpdateTime   string `json:"updateTime"`   //最后一次更新时间
+	OfferStatus  string `json:"offerStatus"`  //报价状态
+	Hashed
+}
+
+type Auction struct {
+	AuctionID     string `json:"auctionId"`               //拍卖ID
+	AuctionType   string `json:"auctionType"`             //拍卖方式，英式拍卖或密封拍卖
+	ObjectOfSale  string `json:"objectOfSale"`            //拍卖对象(房地产RealEstateID)
+	Seller        string `json:"seller"`                  //卖家(卖家AccountId)
+	ReservePrice  Amount `json:"reservePrice"`            //保留价
+	MinIncrement  Amount `json:"minIncrement"`            //最小加价幅度
+	HighestBid    Amount `json:"highestBid"`              //当前最高出价
+	HighestBidder string `json:"highestBidder"`           //当前最高出价人(竞拍人AccountId)
+	EndTime       string `json:"endTime"`                 //结束时间
+	RevealEndTime string `json:"revealEndTime,omitempty"` //密封拍卖的揭示截止时间
+	CreateTime    string `json:"createTime"`              //创建时间
+	AuctionStatus string `json:"auctionStatus"`           //拍卖状态
+	Hashed
+}
+
+type Pledge struct {
+	PledgeID       string `json:"pledgeId"`       //质押ID
+	ObjectOfPledge string `json:"objectOfPledge"` //质押对象(房地产RealEstateID)
+	Pledgor        string `json:"pledgor"`        //出质人(业主AccountId)
+	Lender         string `json:"lender"`         //出借人AccountId
+	LoanAmount     Amount `json:"loanAmount"`     //贷款金额
+	Repaid         Amount `json:"repaid"`         //已还金额
+	DueDate        string `json:"dueDate"`        //到期时间
+	CreateTime     string `json:"createTime"`     //创建时间
+	CloseTime      string `json:"closeTime"`      //结束时间
+	PledgeStatus   string `json:"pledgeStatus"`   //质押状态
+	Hashed
+}
+
+type Lease struct {
+	LeaseID       string `json:"leaseId"`       //租约ID
+	ObjectOfLease string `json:"objectOfLease"` //出租对象(房地产RealEstateID)
+	Landlord      string `json:"landlord"`      //出租人(业主AccountId)
+	Tenant        string `json:"tenant"`        //承租人AccountId
+	Rent          Amount `json:"rent"`          //每期租金
+	RentPeriod    int    `json:"rentPeriod"`    //每期天数
+	Deposit       Amount `json:"deposit"`       //押金
+	EndTime       string `json:"endTime"`       //租期结束时间
+	PaidUntil     string `json:"paidUntil"`     //租金已付清到的时间
+	RentOverdue   bool   `json:"rentOverdue"`   //是否被标记为欠租
+	CreateTime    string `json:"createTime"`    //创建时间
+	SignTime      string `json:"signTime"`      //承租人签署时间
+	CloseTime     string `json:"closeTime"`     //取消或终止的时间
+	DepositStatus string `json:"depositStatus"` //押金的托管状态
+	LeaseStatus   string `json:"leaseStatus"`   //租约状态
+	Hashed
+}
+
+type RealEstate struct {
+	RealEstateAttributes
+	RealEstateID     string   `json:"realEstateId"`     //房地产ID
+	Proprietor       string   `json:"proprietor"`       //所有者(业主)(业主AccountId)，共有时为代表业主
+	Owners           []Owner  `json:"owners"`           //共有人及份额
+	ConsentThreshold int      `json:"consentThreshold"` //处分需要同意的份额合计
+	Encumbrance      bool     `json:"encumbrance"`      //是否作为担保
+	TotalArea        float64  `json:"totalArea"`        //总面积
+	LivingSpace      float64  `json:"livingSpace"`      //生活空间
+	Parents          []string `json:"parents"`          //拆分或合并前的房地产ID
+	Children         []string `json:"children"`         //拆分或合并后的房地产ID
+	Retired          bool     `json:"retired"`          //是否已拆分或合并，不再有效
+}
+
+//房地产的一个共有人及其份额
+type Owner struct {
+	AccountId string `json:"accountId"` //共有人(业主AccountId)
+	Share     int    `json:"share"`     //份额，单位为万分之一，所有共有人合计为10000
+}
+
+//房地产的登记信息
+type RealEstateAttributes struct {
+	Address       string `json:"address"`       //地址
+	District      string `json:"district"`      //所在行政区
+	PropertyType  string `json:"propertyType"`  //房屋用途，住宅"residential"、商业"commercial"、办公"office"、工业"industrial"
+	Floor         int    `json:"floor"`         //所在楼层，地下为负数
+	BuildYear     int    `json:"buildYear"`     //建成年份
+	CertificateNo string `json:"certificateNo"` //不动产权证书号
+	UsageTerm     int    `json:"usageTerm"`     //土地使用权年限(年)
+}
+
+//拆分房地产时一个部分的面积
+type RealEstatePart struct {
+	TotalArea   float64 `json:"totalArea"`   //总面积
+	LivingSpace float64 `json:"livingSpace"` //生活空间
+}
+
+//房地产事件的内容，事件realEstateCreated
+type RealEstateEvent struct {
+	TxID       string     `json:"txId"`       //交易ID
+	RealEstate RealEstate `json:"realEstate"` //变更后的房地产
+}
+
+//房地产、销售或捐赠关联的文档在链上的登记记录，文件按哈希保存在应用本地
+type Document struct {
+	ObjectType string   `json:"objectType"` //关联对象的类型，realEstate、selling或donating
+	ObjectKey  []string `json:"objectKey"`  //关联对象的复合键
+	Hash       string   `json:"hash"`       //文件内容的SHA-256哈希
+	MediaType  string   `json:"mediaType"`  //文件的媒体类型
+	Name       string   `json:"name"`       //文件名
+	Uploader   string   `json:"uploader"`   //上传人AccountId
+	CreateTime string   `json:"createTime"` //登记时间
+}
+
+//文档事件的内容，事件documentAnchored
+type DocumentEvent struct {
+	TxID     string   `json:"txId"`     //交易ID
+	Document Document `json:"document"` //登记的文档
+}
+
+//拆分合并房地产事件的内容，事件realEstatesSplit、realEstatesMerged
+type RealEstateListEvent struct {
+	TxID        string       `json:"txId"`        //交易ID
+	RealEstates []RealEstate `json:"realEstates"` //拆分或合并前后的房地产
+}
+
+//销售事件的内容，事件sellingCreated、sellingPurchased、sellingDone、sellingCancelled、sellingExpired
+type SellingEvent struct {
+	TxID    string  `json:"txId"`    //交易ID
+	Selling Selling `json:"selling"` //变更后的销售
+}
+
+//批量过期的事件内容，事件sellingsExpired
+type SellingListEvent struct {
+	TxID     string    `json:"txId"`     //交易ID
+	Sellings []Selling `json:"sellings"` //过期后的销售
+}
+
+//捐赠事件的内容，事件donatingCreated、donatingDone、donatingCancelled、donatingExpired
+type DonatingEvent struct {
+	TxID     string   `json:"txId"`     //交易ID
+	Donating Donating `json:"donating"` //变更后的捐赠
+}
+
+//批量过期的事件内容，事件donatingsExpired
+type DonatingListEvent struct {
+	TxID      string     `json:"txId"`      //交易ID
+	Donatings []Donating `json:"donatings"` //过期后的捐赠
+}
+
+//拍卖事件的内容，事件auctionCreated、auctionBid、auctionCommitted、auctionRevealed、auctionDone、auctionFailed、auctionCancelled
+type AuctionEvent struct {
+	TxID    string  `json:"txId"`    //交易ID
+	Auction Auction `json:"auction"` //变更后的拍卖
+}
+
+//报价事件的内容，事件offerCreated、offerCountered、offerAccepted、offerRejected、offerWithdrawn
+type OfferEvent struct {
+	TxID    string   `json:"txId"`              //交易ID
+	Offer   Offer    `json:"offer"`             //变更后的报价
+	Selling *Selling `json:"selling,omitempty"` //接受报价时变更后的销售
+}
+
+//质押事件的内容，事件pledgeCreated、pledgeActivated、pledgeCancelled、pledgeRepaid、pledgeReleased、pledgeDefaulted
+type PledgeEvent struct {
+	TxID   string `json:"txId"`   //交易ID
+	Pledge Pledge `json:"pledge"` //变更后的质押
+}
+
+//租约事件的内容，事件leaseCreated、leaseSigned、leaseRentPaid、leaseCancelled、leaseTerminated
+type LeaseEvent struct {
+	TxID  string `json:"txId"`  //交易ID
+	Lease Lease  `json:"lease"` //变更后的租约
+}
+
+//批量标记欠租或关闭到期租约的事件内容，事件leasesOverdue、leasesExpired
+type LeaseListEvent struct {
+	TxID   string  `json:"txId"`   //交易ID
+	Leases []Lease `json:"leases"` //新标记欠租或到期关闭的租约，按LeaseStatus区分
 }
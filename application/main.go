@@ -24,6 +24,7 @@ func main() {
 	time.Local = timeLocal
 	blockchain.Init()
 	go service.Init()
+	go service.InitEvents()
 	routersInit := routers.InitRouter()
 	readTimeout := setting.ServerSetting.ReadTimeout
 	writeTimeout := setting.ServerSetting.WriteTimeout
@@ -0,0 +1,77 @@
+package session
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"transaction/application/setting"
+)
+
+// Session 登录会话，请求以会话绑定的Fabric用户的身份签名，链码再校验该身份是否为所操作账户的持有人
+type Session struct {
+	AccountId  string    //登录的账户ID
+	FabricUser string    //签名交易和查询的Fabric用户
+	ExpireTime time.Time //过期时间，每次访问后顺延
+}
+
+var (
+	mu       sync.Mutex
+	sessions = make(map[string]*Session)
+	//账户不存在时也比较一次哈希，响应时间不暴露账户是否存在
+	dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+)
+
+// Login 按conf/app.ini的[users]校验账户和密码，成功后创建会话并返回令牌
+func Login(accountId string, password string) (string, *Session, error) {
+	user, ok := setting.Users[accountId]
+	hash := []byte(user.PasswordHash)
+	if !ok {
+		hash = dummyHash
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || !ok {
+		return "", nil, errors.New("账户或密码错误")
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, err
+	}
+	token := hex.EncodeToString(buf)
+	s := &Session{
+		AccountId:  accountId,
+		FabricUser: user.FabricUser,
+		ExpireTime: time.Now().Add(setting.AuthSetting.SessionTimeout),
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	sessions[token] = s
+	return token, s, nil
+}
+
+// Get 根据令牌获取未过期的会话，并顺延有效期
+func Get(token string) (*Session, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	s, ok := sessions[token]
+	if !ok {
+		return nil, false
+	}
+	now := time.Now()
+	if now.After(s.ExpireTime) {
+		delete(sessions, token)
+		return nil, false
+	}
+	s.ExpireTime = now.Add(setting.AuthSetting.SessionTimeout)
+	return s, true
+}
+
+// Logout 删除会话
+func Logout(token string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(sessions, token)
+}
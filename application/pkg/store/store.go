@@ -0,0 +1,134 @@
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+
+	"transaction/application/setting"
+)
+
+// 文档按内容寻址，文件名为内容的SHA-256哈希(64位小写十六进制)，与链码校验的格式一致
+var hashPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// hashLocks 同一哈希的保存、登记和登记失败时的删除串行执行
+// 否则一次上传登记失败删除文件时，可能删掉同时上传相同内容并登记成功的文件
+var hashLocks = struct {
+	sync.Mutex
+	m map[string]*hashLock
+}{m: make(map[string]*hashLock)}
+
+type hashLock struct {
+	sync.Mutex
+	refs int //等待和持有该锁的上传数，为0时从hashLocks中删除
+}
+
+// lock 锁定hash，返回解锁函数
+func lock(hash string) func() {
+	hashLocks.Lock()
+	l, ok := hashLocks.m[hash]
+	if !ok {
+		l = &hashLock{}
+		hashLocks.m[hash] = l
+	}
+	l.refs++
+	hashLocks.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		hashLocks.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(hashLocks.m, hash)
+		}
+		hashLocks.Unlock()
+	}
+}
+
+// Save 边写入边计算哈希，写完后按哈希移动到存储目录并调用anchor在链上登记，相同内容只保存一份
+// anchor返回错误时删除本次新保存的文件，之前已经保存的相同内容保留，返回anchor的错误
+// 同一哈希从保存到登记完成期间加锁，避免删除同时上传的相同内容
+func Save(r io.Reader, anchor func(hash string) error) (hash string, size int64, err error) {
+	root := setting.DocumentSetting.StoragePath
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return "", 0, err
+	}
+	tmp, err := ioutil.TempFile(root, "upload-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, h), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	hash = hex.EncodeToString(h.Sum(nil))
+	path, err := Path(hash)
+	if err != nil {
+		return "", 0, err
+	}
+	unlock := lock(hash)
+	defer unlock()
+	created := false
+	if _, err := os.Stat(path); err != nil {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return "", 0, err
+		}
+		if err := os.Rename(tmp.Name(), path); err != nil {
+			return "", 0, err
+		}
+		created = true
+	}
+	if err := anchor(hash); err != nil {
+		if created {
+			if removeErr := os.Remove(path); removeErr != nil {
+				log.Printf("Save-删除未登记的文件%s出错%s", hash, removeErr.Error())
+			}
+		}
+		return hash, size, err
+	}
+	return hash, size, nil
+}
+
+// Path 文档在存储目录中的路径，按哈希前两位分目录
+func Path(hash string) (string, error) {
+	if !hashPattern.MatchString(hash) {
+		return "", errors.New(fmt.Sprintf("hash%s不是64位小写十六进制的SHA-256", hash))
+	}
+	return filepath.Join(setting.DocumentSetting.StoragePath, hash[:2], hash), nil
+}
+
+// Hash 计算内容的SHA-256哈希
+func Hash(r io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// HashFile 重新计算已保存文档的哈希，用于校验本地文件未被篡改
+func HashFile(hash string) (string, error) {
+	path, err := Path(hash)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return Hash(f)
+}
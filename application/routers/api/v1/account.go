@@ -15,9 +15,17 @@ type AccountIdBody struct {
 }
 
 type AccountRequestBody struct {
+	PageRequestBody
 	Args []AccountIdBody `json:"args"`
 }
 
+type BindAccountRequestBody struct {
+	AccountId     string `json:"accountId"`     //操作人ID
+	BindAccountId string `json:"bindAccountId"` //需要绑定身份的账户ID
+	MspId         string `json:"mspId"`         //所属组织的MSP ID
+	Subject       string `json:"subject"`       //X.509证书主题
+}
+
 func QueryAccountList(c *gin.Context) {
 	appG := app.Gin{C: c}
 	body := new(AccountRequestBody)
@@ -25,20 +33,242 @@ func QueryAccountList(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
+	bodyBytes := body.pageArgs()
 	for _, val := range body.Args {
 		bodyBytes = append(bodyBytes, []byte(val.AccountId))
 	}
-	resp, err := blockchain.ChannelQuery("queryAccountList", bodyBytes)
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryAccountList", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
 	// 反序列化json
-	var data []map[string]interface{}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+type UpdateAccountRolesRequestBody struct {
+	AccountId       string   `json:"accountId"`       //操作人ID
+	UpdateAccountId string   `json:"updateAccountId"` //需要修改角色的账户ID
+	Roles           []string `json:"roles"`           //角色 registrar/owner/bank/auditor
+}
+
+type CreateAccountRequestBody struct {
+	AccountId string   `json:"accountId"` //操作人ID
+	UserName  string   `json:"userName"`  //账号名
+	MspId     string   `json:"mspId"`     //所属组织的MSP ID
+	Subject   string   `json:"subject"`   //X.509证书主题
+	Roles     []string `json:"roles"`     //角色，默认为业主
+}
+
+type UpdateAccountRequestBody struct {
+	AccountId       string `json:"accountId"`       //操作人ID
+	UpdateAccountId string `json:"updateAccountId"` //需要修改的账户ID
+	UserName        string `json:"userName"`        //新的账号名
+}
+
+type FreezeAccountRequestBody struct {
+	AccountId       string `json:"accountId"`       //操作人ID
+	FreezeAccountId string `json:"freezeAccountId"` //需要冻结或解冻的账户ID
+	Status          string `json:"status"`          //frozen冻结 active解冻
+}
+
+type CloseAccountRequestBody struct {
+	AccountId      string `json:"accountId"`      //操作人ID
+	CloseAccountId string `json:"closeAccountId"` //需要注销的账户ID
+}
+
+func BindAccount(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(BindAccountRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.BindAccountId == "" || body.MspId == "" || body.Subject == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.BindAccountId))
+	bodyBytes = append(bodyBytes, []byte(body.MspId))
+	bodyBytes = append(bodyBytes, []byte(body.Subject))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "bindAccount", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func UpdateAccountRoles(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(UpdateAccountRolesRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.UpdateAccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.UpdateAccountId))
+	for _, role := range body.Roles {
+		bodyBytes = append(bodyBytes, []byte(role))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "updateAccountRoles", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
 	appG.Response(http.StatusOK, "成功", data)
 }
+
+func CreateAccount(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(CreateAccountRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.UserName == "" || body.MspId == "" || body.Subject == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.UserName))
+	bodyBytes = append(bodyBytes, []byte(body.MspId))
+	bodyBytes = append(bodyBytes, []byte(body.Subject))
+	for _, role := range body.Roles {
+		bodyBytes = append(bodyBytes, []byte(role))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "createAccount", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func UpdateAccount(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(UpdateAccountRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.UpdateAccountId == "" || body.UserName == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.UpdateAccountId))
+	bodyBytes = append(bodyBytes, []byte(body.UserName))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "updateAccount", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func FreezeAccount(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(FreezeAccountRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.FreezeAccountId == "" || body.Status == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.FreezeAccountId))
+	bodyBytes = append(bodyBytes, []byte(body.Status))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "freezeAccount", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func CloseAccount(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(CloseAccountRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.CloseAccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.CloseAccountId))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "closeAccount", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// 链码根据交易提交者的证书校验账户归属，交易和查询以登录会话绑定的Fabric用户签名，见Auth
+func fabricUser(c *gin.Context) string {
+	return c.GetString(fabricUserKey)
+}
@@ -0,0 +1,316 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+type AuctionRequestBody struct {
+	ObjectOfSale string     `json:"objectOfSale"` //拍卖对象(房地产RealEstateID)
+	Seller       string     `json:"seller"`       //卖家(卖家AccountId)
+	ReservePrice lib.Amount `json:"reservePrice"` //保留价(元)，最多两位小数，可以是字符串或数字
+	MinIncrement lib.Amount `json:"minIncrement"` //最小加价幅度(元)
+	EndTime      string     `json:"endTime"`      //结束时间，RFC3339格式
+}
+
+type BidRequestBody struct {
+	ObjectOfSale string     `json:"objectOfSale"` //拍卖对象(房地产RealEstateID)
+	Seller       string     `json:"seller"`       //卖家(卖家AccountId)
+	Bidder       string     `json:"bidder"`       //竞拍人(竞拍人AccountId)
+	Amount       lib.Amount `json:"amount"`       //出价(元)
+}
+
+type SealedAuctionRequestBody struct {
+	ObjectOfSale  string     `json:"objectOfSale"`  //拍卖对象(房地产RealEstateID)
+	Seller        string     `json:"seller"`        //卖家(卖家AccountId)
+	ReservePrice  lib.Amount `json:"reservePrice"`  //保留价(元)
+	EndTime       string     `json:"endTime"`       //结束时间，之前提交出价，RFC3339格式
+	RevealEndTime string     `json:"revealEndTime"` //揭示截止时间，结束时间到揭示截止时间之间揭示出价
+}
+
+type CommitBidRequestBody struct {
+	ObjectOfSale string     `json:"objectOfSale"` //拍卖对象(房地产RealEstateID)
+	Seller       string     `json:"seller"`       //卖家(卖家AccountId)
+	Bidder       string     `json:"bidder"`       //竞拍人(竞拍人AccountId)
+	Amount       lib.Amount `json:"amount"`       //出价(元)，通过transient传给链码，不会写入交易
+	Salt         string     `json:"salt"`         //盐值，随机字符串，防止通过穷举出价反推哈希
+}
+
+type RevealBidRequestBody struct {
+	ObjectOfSale string `json:"objectOfSale"` //拍卖对象(房地产RealEstateID)
+	Seller       string `json:"seller"`       //卖家(卖家AccountId)
+	Bidder       string `json:"bidder"`       //竞拍人(竞拍人AccountId)
+}
+
+type UpdateAuctionRequestBody struct {
+	ObjectOfSale string `json:"objectOfSale"` //拍卖对象(房地产RealEstateID)
+	Seller       string `json:"seller"`       //卖家(卖家AccountId)
+}
+
+type AuctionListQueryRequestBody struct {
+	PageRequestBody
+	Seller string `json:"seller"` //卖家(卖家AccountId)
+}
+
+type AuctionBidsQueryRequestBody struct {
+	PageRequestBody
+	Seller       string `json:"seller"`       //卖家(卖家AccountId)，queryAuctionBids使用
+	ObjectOfSale string `json:"objectOfSale"` //拍卖对象(房地产RealEstateID)，queryAuctionBids使用
+	AuctionID    string `json:"auctionId"`    //拍卖ID，querySealedBids使用
+}
+
+// CreateAuction 卖家发起英式拍卖
+func CreateAuction(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(AuctionRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.EndTime == "" {
+		appG.Response(http.StatusBadRequest, "失败", "ObjectOfSale拍卖对象、Seller卖家和EndTime结束时间不能为空")
+		return
+	}
+	if body.ReservePrice <= 0 || body.MinIncrement <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "ReservePrice保留价和MinIncrement最小加价幅度必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.ReservePrice.String()))
+	bodyBytes = append(bodyBytes, []byte(body.MinIncrement.String()))
+	bodyBytes = append(bodyBytes, []byte(body.EndTime))
+	executeAuction(c, appG, "createAuction", bodyBytes, nil)
+}
+
+// PlaceBid 竞拍人出价，出价转入托管，被超过时退还
+func PlaceBid(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(BidRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.Bidder == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Amount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Amount出价必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.Bidder))
+	bodyBytes = append(bodyBytes, []byte("")) //出价通过transient传入，不写入区块
+	executeAuction(c, appG, "placeBid", bodyBytes, map[string][]byte{"amount": []byte(body.Amount.String())})
+}
+
+// CreateSealedAuction 卖家发起密封拍卖
+func CreateSealedAuction(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(SealedAuctionRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.EndTime == "" || body.RevealEndTime == "" {
+		appG.Response(http.StatusBadRequest, "失败", "ObjectOfSale拍卖对象、Seller卖家、EndTime结束时间和RevealEndTime揭示截止时间不能为空")
+		return
+	}
+	if body.ReservePrice <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "ReservePrice保留价必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.ReservePrice.String()))
+	bodyBytes = append(bodyBytes, []byte(body.EndTime))
+	bodyBytes = append(bodyBytes, []byte(body.RevealEndTime))
+	executeAuction(c, appG, "createSealedAuction", bodyBytes, nil)
+}
+
+// CommitBid 竞拍人提交密封出价，出价和盐值通过transient传给链码，账本中只记录哈希
+func CommitBid(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(CommitBidRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.Bidder == "" || body.Salt == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Amount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Amount出价必须大于0")
+		return
+	}
+	bid, err := json.Marshal(map[string]string{"amount": body.Amount.String(), "salt": body.Salt})
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.Bidder))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "commitBid", bodyBytes, map[string][]byte{"bid": bid})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// RevealBid 拍卖结束后竞拍人揭示密封出价
+func RevealBid(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RevealBidRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.Bidder == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.Bidder))
+	executeAuction(c, appG, "revealBid", bodyBytes, nil)
+}
+
+// QuerySealedBids 查询一次密封拍卖的所有出价承诺，揭示前只有哈希
+func QuerySealedBids(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(AuctionBidsQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AuctionID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "AuctionID拍卖ID不能为空")
+		return
+	}
+	queryAuction(appG, "querySealedBids", append(body.pageArgs(), []byte(body.AuctionID)))
+}
+
+// SettleAuction 拍卖结束后结算，成交或流拍
+func SettleAuction(c *gin.Context) {
+	updateAuction(c, "settleAuction")
+}
+
+// CancelAuction 卖家在无人出价前取消拍卖
+func CancelAuction(c *gin.Context) {
+	updateAuction(c, "cancelAuction")
+}
+
+// QueryAuctionList 查询拍卖(可查询所有，也可根据卖家查询)
+func QueryAuctionList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(AuctionListQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	bodyBytes := body.pageArgs()
+	if body.Seller != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Seller))
+	}
+	queryAuction(appG, "queryAuctionList", bodyBytes)
+}
+
+// QueryAuctionBids 按时间查询一次拍卖的所有出价，竞拍人只能看到自己的出价金额
+func QueryAuctionBids(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(AuctionBidsQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Seller == "" || body.ObjectOfSale == "" {
+		appG.Response(http.StatusBadRequest, "失败", "Seller卖家和ObjectOfSale拍卖对象不能为空")
+		return
+	}
+	queryAuction(appG, "queryAuctionBids", append(body.pageArgs(), []byte(body.Seller), []byte(body.ObjectOfSale)))
+}
+
+func updateAuction(c *gin.Context, fcn string) {
+	appG := app.Gin{C: c}
+	body := new(UpdateAuctionRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" {
+		appG.Response(http.StatusBadRequest, "失败", "ObjectOfSale拍卖对象和Seller卖家不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	executeAuction(c, appG, fcn, bodyBytes, nil)
+}
+
+// executeAuction 以请求者身份调用智能合约，返回变更后的拍卖或出价承诺，transient中的出价不会写入交易
+// 拍卖成交时与确认收款一样附上逐项列出税费的收据
+func executeAuction(c *gin.Context, appG app.Gin, fcn string, bodyBytes [][]byte, transient map[string][]byte) {
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), fcn, bodyBytes, transient)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if fcn == "settleAuction" && data["auctionStatus"] == "成交" {
+		attachReceipt(fabricUser(c), data, string(resp.TransactionID))
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func queryAuction(appG app.Gin, fcn string, bodyBytes [][]byte) {
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(appG.C), fcn, bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
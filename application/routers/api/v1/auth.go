@@ -0,0 +1,74 @@
+package v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/pkg/app"
+	"transaction/application/pkg/session"
+)
+
+type LoginRequestBody struct {
+	AccountId string `json:"accountId"` //登录的账户ID
+	Password  string `json:"password"`  //密码
+}
+
+// Login 校验账户和密码并创建会话，之后的请求在请求头Authorization中携带"Bearer 令牌"
+func Login(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(LoginRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.Password == "" {
+		appG.Response(http.StatusBadRequest, "失败", "AccountId账户和Password密码不能为空")
+		return
+	}
+	token, s, err := session.Login(body.AccountId, body.Password)
+	if err != nil {
+		appG.Response(http.StatusUnauthorized, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", map[string]interface{}{
+		"token":     token,
+		"accountId": s.AccountId,
+	})
+}
+
+// Logout 删除当前会话
+func Logout(c *gin.Context) {
+	appG := app.Gin{C: c}
+	session.Logout(c.GetString(sessionTokenKey))
+	appG.Response(http.StatusOK, "成功", nil)
+}
+
+// Auth 校验会话令牌，通过后将会话绑定的Fabric用户记录到请求上下文中，见fabricUser
+// 令牌放在请求头Authorization中，浏览器直接打开的下载地址可以用查询参数token
+func Auth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" {
+			token = c.Query("token")
+		}
+		s, ok := session.Get(token)
+		if token == "" || !ok {
+			appG := app.Gin{C: c}
+			appG.Response(http.StatusUnauthorized, "失败", "未登录或登录已过期")
+			c.Abort()
+			return
+		}
+		c.Set(sessionTokenKey, token)
+		c.Set(fabricUserKey, s.FabricUser)
+		c.Next()
+	}
+}
+
+const (
+	sessionTokenKey = "sessionToken"
+	fabricUserKey   = "fabricUser"
+)
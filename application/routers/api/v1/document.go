@@ -0,0 +1,231 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+	"transaction/application/pkg/store"
+	"transaction/application/setting"
+)
+
+type DocumentQueryRequestBody struct {
+	ObjectType string   `json:"objectType" form:"objectType"` //关联对象的类型，房地产"realEstate"、销售"selling"、捐赠"donating"
+	ObjectKey  []string `json:"objectKey" form:"objectKey"`   //关联对象的复合键，房地产为[realEstateId]，销售为[seller, objectOfSale]，捐赠为[objectOfDonating, createTime]
+	Hash       string   `json:"hash" form:"hash"`             //文档的SHA-256哈希，查询时可以不指定
+}
+
+type DocumentVerifyResponse struct {
+	Hash     string        `json:"hash"`     //重新计算的SHA-256哈希
+	Matched  bool          `json:"matched"`  //是否与链上登记的记录一致
+	Document *lib.Document `json:"document"` //链上登记的记录，没有登记时为空
+}
+
+// UploadDocument 上传房地产、销售或捐赠关联的文档，文件按内容寻址保存在本地，链上只登记哈希、媒体类型和上传人
+// multipart表单字段为file、accountId、objectType和objectKey(可以重复)，可以再指定mediaType
+func UploadDocument(c *gin.Context) {
+	appG := app.Gin{C: c}
+	accountId := c.PostForm("accountId")
+	objectType := c.PostForm("objectType")
+	objectKey := c.PostFormArray("objectKey")
+	if accountId == "" || objectType == "" || len(objectKey) == 0 {
+		appG.Response(http.StatusBadRequest, "失败", "accountId、objectType和objectKey不能为空")
+		return
+	}
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("file文件出错%s", err.Error()))
+		return
+	}
+	if fileHeader.Size > setting.DocumentSetting.MaxSize {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("文件不能超过%dMB", setting.DocumentSetting.MaxSize/1024/1024))
+		return
+	}
+	mediaType := c.PostForm("mediaType")
+	if mediaType == "" {
+		mediaType = fileHeader.Header.Get("Content-Type")
+	}
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	file, err := fileHeader.Open()
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("file文件出错%s", err.Error()))
+		return
+	}
+	defer file.Close()
+	//保存后调用智能合约登记，登记失败时删除本次新保存的文件，之前已经保存的相同内容保留
+	var payload []byte
+	var anchorErr error
+	_, _, err = store.Save(file, func(hash string) error {
+		var bodyBytes [][]byte
+		bodyBytes = append(bodyBytes, []byte(accountId))
+		bodyBytes = append(bodyBytes, []byte(objectType))
+		bodyBytes = append(bodyBytes, []byte(hash))
+		bodyBytes = append(bodyBytes, []byte(mediaType))
+		bodyBytes = append(bodyBytes, []byte(fileHeader.Filename))
+		for _, val := range objectKey {
+			bodyBytes = append(bodyBytes, []byte(val))
+		}
+		resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "anchorDocument", bodyBytes)
+		if err != nil {
+			anchorErr = err
+			return err
+		}
+		payload = resp.Payload
+		return nil
+	})
+	if anchorErr != nil {
+		appG.Response(http.StatusInternalServerError, "失败", anchorErr.Error())
+		return
+	}
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", fmt.Sprintf("保存文件出错%s", err.Error()))
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(payload, &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryDocuments 查询一个对象关联的文档登记记录
+func QueryDocuments(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DocumentQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	documentList, err := queryDocuments(fabricUser(c), body)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", documentList)
+}
+
+// DownloadDocument 下载已在链上登记的文档，GET参数为accountId、objectType、objectKey(可以重复)和hash
+// 智能合约校验accountId是关联对象的当事人或者是登记员、审计员，与登记文档的权限一致
+func DownloadDocument(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DocumentQueryRequestBody)
+	if err := c.ShouldBindQuery(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	accountId := c.Query("accountId")
+	if accountId == "" || body.Hash == "" || body.ObjectType == "" || len(body.ObjectKey) == 0 {
+		appG.Response(http.StatusBadRequest, "失败", "accountId、objectType、objectKey和hash不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(accountId))
+	bodyBytes = append(bodyBytes, []byte(body.ObjectType))
+	bodyBytes = append(bodyBytes, []byte(body.Hash))
+	for _, val := range body.ObjectKey {
+		bodyBytes = append(bodyBytes, []byte(val))
+	}
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryDocument", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var document lib.Document
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &document); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	path, err := store.Path(body.Hash)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		appG.Response(http.StatusNotFound, "失败", fmt.Sprintf("文档%s的文件不存在", body.Hash))
+		return
+	}
+	c.Header("Content-Type", document.MediaType)
+	c.FileAttachment(path, document.Name)
+}
+
+// VerifyDocument 重新计算文件的哈希并与链上登记的记录比对
+// multipart表单指定file时校验上传的文件，否则校验本地保存的hash对应的文件是否被篡改
+func VerifyDocument(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(DocumentQueryRequestBody)
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	var hash string
+	if fileHeader, err := c.FormFile("file"); err == nil {
+		file, err := fileHeader.Open()
+		if err != nil {
+			appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("file文件出错%s", err.Error()))
+			return
+		}
+		defer file.Close()
+		if hash, err = store.Hash(file); err != nil {
+			appG.Response(http.StatusInternalServerError, "失败", fmt.Sprintf("计算哈希出错%s", err.Error()))
+			return
+		}
+	} else if body.Hash != "" {
+		if hash, err = store.HashFile(body.Hash); err != nil {
+			appG.Response(http.StatusNotFound, "失败", fmt.Sprintf("读取文档%s的文件出错%s", body.Hash, err.Error()))
+			return
+		}
+	} else {
+		appG.Response(http.StatusBadRequest, "失败", "必须上传file或指定hash")
+		return
+	}
+	//指定hash时按其查询链上记录，本地文件被篡改或上传的文件不同时重新计算的哈希与记录不一致
+	if body.Hash == "" {
+		body.Hash = hash
+	}
+	documentList, err := queryDocuments(fabricUser(c), body)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	result := DocumentVerifyResponse{Hash: hash, Matched: len(documentList) == 1 && documentList[0].Hash == hash}
+	if len(documentList) == 1 {
+		result.Document = &documentList[0]
+	}
+	appG.Response(http.StatusOK, "成功", result)
+}
+
+// queryDocuments 以user的身份调用智能合约查询对象关联的文档，指定hash时只查询该文档
+func queryDocuments(user string, body *DocumentQueryRequestBody) ([]lib.Document, error) {
+	if body.ObjectType == "" || len(body.ObjectKey) == 0 {
+		return nil, errors.New("objectType和objectKey不能为空")
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectType))
+	for _, val := range body.ObjectKey {
+		bodyBytes = append(bodyBytes, []byte(val))
+	}
+	if body.Hash != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Hash))
+	}
+	resp, err := blockchain.ChannelQueryAs(user, "queryDocuments", bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	var documentList []lib.Document
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &documentList); err != nil {
+		return nil, err
+	}
+	return documentList, nil
+}
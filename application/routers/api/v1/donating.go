@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strconv"
 	"transaction/application/blockchain"
 	"transaction/application/pkg/app"
 
@@ -15,13 +16,16 @@ type DonatingRequestBody struct {
 	ObjectOfDonating string `json:"objectOfDonating"` //捐赠对象
 	Donor            string `json:"donor"`            //捐赠人
 	Grantee          string `json:"grantee"`          //受赠人
+	DonatingPeriod   int    `json:"donatingPeriod"`   //受赠人确认接收的期限(单位为天)
 }
 
 type DonatingListQueryRequestBody struct {
-	Donor string `json:"donor"`
+	PageRequestBody
+	Donor string `json:"donor"` //捐赠人，只有捐赠人本人、登记员、银行和审计员可以按捐赠人查询
 }
 
 type DonatingListQueryByGranteeRequestBody struct {
+	PageRequestBody
 	Grantee string `json:"grantee"`
 }
 
@@ -29,7 +33,7 @@ type UpdateDonatingRequestBody struct {
 	ObjectOfDonating string `json:"objectOfDonating"` //捐赠对象
 	Donor            string `json:"donor"`            //捐赠人
 	Grantee          string `json:"grantee"`          //受赠人
-	Status           string `json:"status"`           //需要更改的状态
+	Status           string `json:"status"`           //需要更改的状态，done确认接收、cancelled取消、expired过期
 }
 
 func CreateDonating(c *gin.Context) {
@@ -44,12 +48,17 @@ func CreateDonating(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", "ObjectOfDonating捐赠对象和Donor捐赠人和Grantee受赠人不能为空")
 		return
 	}
+	if body.DonatingPeriod <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "DonatingPeriod受赠人确认接收的期限(单位为天)必须大于0")
+		return
+	}
 	var bodyBytes [][]byte
 	bodyBytes = append(bodyBytes, []byte(body.ObjectOfDonating))
 	bodyBytes = append(bodyBytes, []byte(body.Donor))
-	bodyBytes = append(bodyBytes, []byte(body.Grantee))
+	bodyBytes = append(bodyBytes, []byte("")) //受赠人通过transient传入，不写入区块
+	bodyBytes = append(bodyBytes, []byte(strconv.Itoa(body.DonatingPeriod)))
 	//调用智能合约
-	resp, err := blockchain.ChannelExecute("createDonating", bodyBytes)
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "createDonating", bodyBytes, map[string][]byte{"grantee": []byte(body.Grantee)})
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -70,18 +79,18 @@ func QueryDonatingList(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
+	bodyBytes := body.pageArgs()
 	if body.Donor != "" {
 		bodyBytes = append(bodyBytes, []byte(body.Donor))
 	}
 	//调用智能合约
-	resp, err := blockchain.ChannelQuery("queryDonatingList", bodyBytes)
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryDonatingList", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
 	// 反序列化json
-	var data []map[string]interface{}
+	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -101,16 +110,16 @@ func QueryDonatingListByGrantee(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", "必须指定AccountId查询")
 		return
 	}
-	var bodyBytes [][]byte
+	bodyBytes := body.pageArgs()
 	bodyBytes = append(bodyBytes, []byte(body.Grantee))
 	//调用智能合约
-	resp, err := blockchain.ChannelQuery("queryDonatingListByGrantee", bodyBytes)
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryDonatingListByGrantee", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
 	// 反序列化json
-	var data []map[string]interface{}
+	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -133,10 +142,10 @@ func UpdateDonating(c *gin.Context) {
 	var bodyBytes [][]byte
 	bodyBytes = append(bodyBytes, []byte(body.ObjectOfDonating))
 	bodyBytes = append(bodyBytes, []byte(body.Donor))
-	bodyBytes = append(bodyBytes, []byte(body.Grantee))
+	bodyBytes = append(bodyBytes, []byte("")) //受赠人通过transient传入，不写入区块
 	bodyBytes = append(bodyBytes, []byte(body.Status))
 	//调用智能合约
-	resp, err := blockchain.ChannelExecute("updateDonating", bodyBytes)
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "updateDonating", bodyBytes, map[string][]byte{"grantee": []byte(body.Grantee)})
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -146,5 +155,9 @@ func UpdateDonating(c *gin.Context) {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
+	//确认接收后附上逐项列出税费的收据
+	if body.Status == "done" {
+		attachReceipt(fabricUser(c), data, string(resp.TransactionID))
+	}
 	appG.Response(http.StatusOK, "成功", data)
 }
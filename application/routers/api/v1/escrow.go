@@ -0,0 +1,72 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/pkg/app"
+)
+
+type EscrowListQueryRequestBody struct {
+	PageRequestBody
+	Seller       string `json:"seller"`       //卖家AccountId
+	ObjectOfSale string `json:"objectOfSale"` //销售对象(RealEstateID)，需要同时指定卖家
+}
+
+// QueryEscrowList 查询购房资金托管记录(可查询所有，也可根据卖家或卖家和销售对象查询)
+func QueryEscrowList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(EscrowListQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Seller == "" && body.ObjectOfSale != "" {
+		appG.Response(http.StatusBadRequest, "失败", "按销售对象查询时必须指定卖家")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	if body.Seller != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Seller))
+	}
+	if body.ObjectOfSale != "" {
+		bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryEscrowList", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryFundsSummary 汇总账户余额和托管中的资金，用于对账
+func QueryFundsSummary(c *gin.Context) {
+	appG := app.Gin{C: c}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryFundsSummary", [][]byte{})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
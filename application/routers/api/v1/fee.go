@@ -0,0 +1,129 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+type FeeScheduleRequestBody struct {
+	AccountId          string     `json:"accountId"`          //操作人ID(登记员)
+	DeedTaxRate        int        `json:"deedTaxRate"`        //契税税率，单位为万分之一，由买家缴纳
+	TransactionFeeRate int        `json:"transactionFeeRate"` //交易手续费率，单位为万分之一，从卖家的售房资金中扣除
+	GiftTaxPerArea     lib.Amount `json:"giftTaxPerArea"`     //赠与税每平方米金额(元)，由受赠人缴纳
+	Treasury           string     `json:"treasury"`           //收取税费的国库账户ID，不能是业主，不收取税费时可以为空
+}
+
+type ReceiptQueryRequestBody struct {
+	TxID string `json:"txId"` //确认收款或确认接收捐赠的交易ID
+}
+
+// SetFeeSchedule 登记员设置税费标准，销售和捐赠完成时按此收取税费
+func SetFeeSchedule(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(FeeScheduleRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "AccountId操作人不能为空")
+		return
+	}
+	if body.DeedTaxRate < 0 || body.TransactionFeeRate < 0 || body.GiftTaxPerArea < 0 {
+		appG.Response(http.StatusBadRequest, "失败", "税率和赠与税金额不能小于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(fmt.Sprintf("%d", body.DeedTaxRate)))
+	bodyBytes = append(bodyBytes, []byte(fmt.Sprintf("%d", body.TransactionFeeRate)))
+	bodyBytes = append(bodyBytes, []byte(body.GiftTaxPerArea.String()))
+	bodyBytes = append(bodyBytes, []byte(body.Treasury))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "setFeeSchedule", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryFeeSchedule 查询当前的税费标准
+func QueryFeeSchedule(c *gin.Context) {
+	appG := app.Gin{C: c}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryFeeSchedule", [][]byte{})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryReceipt 根据交易ID查询销售或捐赠完成时的税费收据
+func QueryReceipt(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ReceiptQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.TxID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "TxID交易ID不能为空")
+		return
+	}
+	data, err := queryReceipt(fabricUser(c), body.TxID)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// queryReceipt 以user的身份查询交易的税费收据，只有当事人等可以查询
+// 收据包含成交价，只写入私有数据集合，不在交易的返回值中，完成交易后用交易ID查询
+func queryReceipt(user string, txID string) (map[string]interface{}, error) {
+	resp, err := blockchain.ChannelQueryAs(user, "queryReceipt", [][]byte{[]byte(txID)})
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// attachReceipt 交易已经提交，查询收据失败不影响交易结果，只记录日志并返回交易ID，客户端可以稍后用交易ID查询收据
+func attachReceipt(user string, data map[string]interface{}, txID string) {
+	data["txId"] = txID
+	receipt, err := queryReceipt(user, txID)
+	if err != nil {
+		log.Printf("查询交易%s的收据失败%s", txID, err.Error())
+		data["receiptMissing"] = true
+		return
+	}
+	data["receipt"] = receipt
+}
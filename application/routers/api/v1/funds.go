@@ -0,0 +1,161 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+type FundsRequestBody struct {
+	AccountId       string     `json:"accountId"`       //操作人ID(银行)
+	TargetAccountId string     `json:"targetAccountId"` //存取款的账户ID
+	Amount          lib.Amount `json:"amount"`          //金额(元)，最多两位小数，可以是字符串或数字
+}
+
+type TransferRequestBody struct {
+	From   string     `json:"from"`   //转出账户ID
+	To     string     `json:"to"`     //转入账户ID
+	Amount lib.Amount `json:"amount"` //金额(元)，最多两位小数，可以是字符串或数字
+}
+
+type AccountStatementQueryRequestBody struct {
+	AccountId string `json:"accountId"` //需要查询流水的账户ID
+}
+
+func Deposit(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(FundsRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.TargetAccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Amount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Amount金额必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.TargetAccountId))
+	bodyBytes = append(bodyBytes, []byte("")) //金额通过transient传入，不写入区块
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "deposit", bodyBytes, map[string][]byte{"amount": []byte(body.Amount.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func Withdraw(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(FundsRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.TargetAccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Amount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Amount金额必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.TargetAccountId))
+	bodyBytes = append(bodyBytes, []byte("")) //金额通过transient传入，不写入区块
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "withdraw", bodyBytes, map[string][]byte{"amount": []byte(body.Amount.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func Transfer(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(TransferRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.From == "" || body.To == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Amount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Amount金额必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.From))
+	bodyBytes = append(bodyBytes, []byte(body.To))
+	bodyBytes = append(bodyBytes, []byte("")) //金额通过transient传入，不写入区块
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "transfer", bodyBytes, map[string][]byte{"amount": []byte(body.Amount.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func QueryAccountStatement(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(AccountStatementQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "必须指定AccountId查询")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryAccountStatement", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
@@ -0,0 +1,238 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+type LeaseRequestBody struct {
+	ObjectOfLease string     `json:"objectOfLease"` //出租对象(房地产RealEstateID)
+	Landlord      string     `json:"landlord"`      //出租人(业主AccountId)
+	Tenant        string     `json:"tenant"`        //承租人AccountId
+	Rent          lib.Amount `json:"rent"`          //每期租金(元)
+	RentPeriod    int        `json:"rentPeriod"`    //每期天数
+	Deposit       lib.Amount `json:"deposit"`       //押金(元)
+	EndTime       string     `json:"endTime"`       //租期结束时间，RFC3339格式
+}
+
+type LeaseActionRequestBody struct {
+	ObjectOfLease string `json:"objectOfLease"` //出租对象(房地产RealEstateID)
+	Landlord      string `json:"landlord"`      //出租人(业主AccountId)
+	LeaseID       string `json:"leaseId"`       //租约ID
+}
+
+type UpdateLeaseRequestBody struct {
+	LeaseActionRequestBody
+	Status  string `json:"status"`  //需要更改的状态，取消"cancelled"、终止"terminated"
+	Deposit string `json:"deposit"` //押金处理方式，退还"refunded"、没收"forfeited"，只有终止时需要
+}
+
+type LeaseListQueryRequestBody struct {
+	PageRequestBody
+	Landlord      string `json:"landlord"`      //出租人(业主AccountId)
+	ObjectOfLease string `json:"objectOfLease"` //出租对象(RealEstateID)，需要同时指定出租人
+}
+
+type LeaseListQueryByTenantRequestBody struct {
+	PageRequestBody
+	Tenant string `json:"tenant"` //承租人AccountId
+}
+
+type LeasePaymentsQueryRequestBody struct {
+	PageRequestBody
+	Landlord      string `json:"landlord"`      //出租人(业主AccountId)
+	ObjectOfLease string `json:"objectOfLease"` //出租对象(RealEstateID)
+	LeaseID       string `json:"leaseId"`       //租约ID
+}
+
+// CreateLease 业主发起租约
+func CreateLease(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(LeaseRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfLease == "" || body.Landlord == "" || body.Tenant == "" || body.EndTime == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Rent <= 0 || body.Deposit <= 0 || body.RentPeriod <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Rent租金、Deposit押金和RentPeriod每期天数必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfLease))
+	bodyBytes = append(bodyBytes, []byte(body.Landlord))
+	bodyBytes = append(bodyBytes, []byte(body.Tenant))
+	bodyBytes = append(bodyBytes, []byte("")) //租金和押金通过transient传入，不写入区块
+	bodyBytes = append(bodyBytes, []byte(strconv.Itoa(body.RentPeriod)))
+	bodyBytes = append(bodyBytes, []byte(""))
+	bodyBytes = append(bodyBytes, []byte(body.EndTime))
+	executeLease(c, appG, "createLease", bodyBytes, map[string][]byte{
+		"rent":    []byte(body.Rent.String()),
+		"deposit": []byte(body.Deposit.String()),
+	})
+}
+
+// SignLease 承租人签署租约，支付押金和第一期租金
+func SignLease(c *gin.Context) {
+	leaseAction(c, "signLease")
+}
+
+// PayRent 承租人支付一期租金
+func PayRent(c *gin.Context) {
+	leaseAction(c, "payRent")
+}
+
+// UpdateLease 出租人或承租人取消或终止租约
+func UpdateLease(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(UpdateLeaseRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfLease == "" || body.Landlord == "" || body.LeaseID == "" || body.Status == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfLease))
+	bodyBytes = append(bodyBytes, []byte(body.Landlord))
+	bodyBytes = append(bodyBytes, []byte(body.LeaseID))
+	bodyBytes = append(bodyBytes, []byte(body.Status))
+	if body.Status == "terminated" {
+		if body.Deposit != "refunded" && body.Deposit != "forfeited" {
+			appG.Response(http.StatusBadRequest, "失败", "终止租约时Deposit只能为refunded或forfeited")
+			return
+		}
+		bodyBytes = append(bodyBytes, []byte(body.Deposit))
+	}
+	executeLease(c, appG, "updateLease", bodyBytes, nil)
+}
+
+// QueryLeaseList 查询租约(可查询所有，也可根据出租人或出租人和出租对象查询)
+func QueryLeaseList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(LeaseListQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Landlord == "" && body.ObjectOfLease != "" {
+		appG.Response(http.StatusBadRequest, "失败", "按出租对象查询时必须指定出租人")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	if body.Landlord != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Landlord))
+	}
+	if body.ObjectOfLease != "" {
+		bodyBytes = append(bodyBytes, []byte(body.ObjectOfLease))
+	}
+	queryLease(appG, "queryLeaseList", bodyBytes)
+}
+
+// QueryLeaseListByTenant 根据承租人查询租约
+func QueryLeaseListByTenant(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(LeaseListQueryByTenantRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Tenant == "" {
+		appG.Response(http.StatusBadRequest, "失败", "必须指定承租人AccountId查询")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	bodyBytes = append(bodyBytes, []byte(body.Tenant))
+	queryLease(appG, "queryLeaseListByTenant", bodyBytes)
+}
+
+// QueryLeasePayments 查询一份租约的租金支付记录
+func QueryLeasePayments(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(LeasePaymentsQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Landlord == "" || body.ObjectOfLease == "" || body.LeaseID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "必须指定Landlord出租人、ObjectOfLease出租对象和LeaseID查询")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	bodyBytes = append(bodyBytes, []byte(body.Landlord))
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfLease))
+	bodyBytes = append(bodyBytes, []byte(body.LeaseID))
+	queryLease(appG, "queryLeasePayments", bodyBytes)
+}
+
+// leaseAction 签署租约或支付租金，参数相同
+func leaseAction(c *gin.Context, fcn string) {
+	appG := app.Gin{C: c}
+	body := new(LeaseActionRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfLease == "" || body.Landlord == "" || body.LeaseID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfLease))
+	bodyBytes = append(bodyBytes, []byte(body.Landlord))
+	bodyBytes = append(bodyBytes, []byte(body.LeaseID))
+	executeLease(c, appG, fcn, bodyBytes, nil)
+}
+
+// executeLease 以当前用户的身份调用租约的智能合约，transient中的金额不会写入交易
+func executeLease(c *gin.Context, appG app.Gin, fcn string, bodyBytes [][]byte, transient map[string][]byte) {
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), fcn, bodyBytes, transient)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// queryLease 查询租约相关的分页结果
+func queryLease(appG app.Gin, fcn string, bodyBytes [][]byte) {
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(appG.C), fcn, bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
@@ -0,0 +1,182 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+type OfferRequestBody struct {
+	ObjectOfSale string     `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
+	Seller       string     `json:"seller"`       //卖家(卖家AccountId)
+	Buyer        string     `json:"buyer"`        //买家(买家AccountId)
+	Price        lib.Amount `json:"price"`        //报价(元)
+	ExpireTime   string     `json:"expireTime"`   //报价有效期，RFC3339格式
+}
+
+type UpdateOfferRequestBody struct {
+	ObjectOfSale string     `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
+	Seller       string     `json:"seller"`       //卖家(卖家AccountId)
+	OfferID      string     `json:"offerId"`      //报价ID
+	Status       string     `json:"status"`       //需要更改的状态，还价"countered"、接受"accepted"、拒绝"rejected"、撤回"withdrawn"
+	Price        lib.Amount `json:"price"`        //还价(元)，只有还价时需要
+}
+
+type OfferListQueryRequestBody struct {
+	PageRequestBody
+	Seller       string `json:"seller"`       //卖家(卖家AccountId)
+	ObjectOfSale string `json:"objectOfSale"` //销售对象(RealEstateID)，需要同时指定卖家
+}
+
+type OfferListQueryByBuyerRequestBody struct {
+	PageRequestBody
+	Buyer string `json:"buyer"` //买家(买家AccountId)
+}
+
+// CreateOffer 买家对销售中的房产报价
+func CreateOffer(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(OfferRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.Buyer == "" || body.ExpireTime == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Price <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Price报价必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.Buyer))
+	bodyBytes = append(bodyBytes, []byte("")) //报价通过transient传入，不写入区块
+	bodyBytes = append(bodyBytes, []byte(body.ExpireTime))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "createOffer", bodyBytes, map[string][]byte{"price": []byte(body.Price.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// UpdateOffer 答复报价，卖家还价、接受或拒绝，卖家还价后买家接受或拒绝，买家撤回
+func UpdateOffer(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(UpdateOfferRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfSale == "" || body.Seller == "" || body.OfferID == "" || body.Status == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	bodyBytes = append(bodyBytes, []byte(body.Seller))
+	bodyBytes = append(bodyBytes, []byte(body.OfferID))
+	bodyBytes = append(bodyBytes, []byte(body.Status))
+	if body.Status == "countered" {
+		if body.Price <= 0 {
+			appG.Response(http.StatusBadRequest, "失败", "还价时Price必须大于0")
+			return
+		}
+		bodyBytes = append(bodyBytes, []byte("")) //还价通过transient传入，不写入区块
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "updateOffer", bodyBytes, map[string][]byte{"price": []byte(body.Price.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryOfferList 查询报价(可查询所有，也可根据卖家或卖家和销售对象查询)
+func QueryOfferList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(OfferListQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Seller == "" && body.ObjectOfSale != "" {
+		appG.Response(http.StatusBadRequest, "失败", "按销售对象查询时必须指定卖家")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	if body.Seller != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Seller))
+	}
+	if body.ObjectOfSale != "" {
+		bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryOfferList", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryOfferListByBuyer 根据买家查询发出的报价
+func QueryOfferListByBuyer(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(OfferListQueryByBuyerRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Buyer == "" {
+		appG.Response(http.StatusBadRequest, "失败", "必须指定买家AccountId查询")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	bodyBytes = append(bodyBytes, []byte(body.Buyer))
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryOfferListByBuyer", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
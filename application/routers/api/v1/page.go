@@ -0,0 +1,21 @@
+package v1
+
+import "strconv"
+
+// 列表查询默认每页条数，链码中限制最多100条
+const defaultPageSize = 20
+
+// 列表查询的分页参数，嵌入各列表查询的请求体
+type PageRequestBody struct {
+	PageSize int    `json:"pageSize"` //每页条数，默认20
+	Bookmark string `json:"bookmark"` //上一页返回的书签，查询第一页时为空
+}
+
+// pageArgs 分页参数作为链码列表查询的前两个参数
+func (body *PageRequestBody) pageArgs() [][]byte {
+	pageSize := body.PageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+	return [][]byte{[]byte(strconv.Itoa(pageSize)), []byte(body.Bookmark)}
+}
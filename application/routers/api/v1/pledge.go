@@ -0,0 +1,219 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+type PledgeRequestBody struct {
+	ObjectOfPledge string     `json:"objectOfPledge"` //质押对象(房地产RealEstateID)
+	Pledgor        string     `json:"pledgor"`        //出质人(业主AccountId)
+	Lender         string     `json:"lender"`         //出借人AccountId
+	LoanAmount     lib.Amount `json:"loanAmount"`     //贷款金额(元)
+	DueDate        string     `json:"dueDate"`        //到期时间，RFC3339格式
+}
+
+type UpdatePledgeRequestBody struct {
+	ObjectOfPledge string `json:"objectOfPledge"` //质押对象(房地产RealEstateID)
+	Pledgor        string `json:"pledgor"`        //出质人(业主AccountId)
+	PledgeID       string `json:"pledgeId"`       //质押ID
+	Status         string `json:"status"`         //需要更改的状态，放款"active"、取消"cancelled"、违约处置"defaulted"
+}
+
+type RepayPledgeRequestBody struct {
+	ObjectOfPledge string     `json:"objectOfPledge"` //质押对象(房地产RealEstateID)
+	Pledgor        string     `json:"pledgor"`        //出质人(业主AccountId)
+	PledgeID       string     `json:"pledgeId"`       //质押ID
+	Amount         lib.Amount `json:"amount"`         //还款金额(元)
+}
+
+type PledgeListQueryRequestBody struct {
+	PageRequestBody
+	Group   string `json:"group"`   //分组，进行中"active"或已结束"closed"
+	Pledgor string `json:"pledgor"` //出质人(业主AccountId)
+}
+
+type PledgeRepaymentsQueryRequestBody struct {
+	PageRequestBody
+	Pledgor        string `json:"pledgor"`        //出质人(业主AccountId)
+	ObjectOfPledge string `json:"objectOfPledge"` //质押对象(房地产RealEstateID)
+	PledgeID       string `json:"pledgeId"`       //质押ID
+}
+
+// CreatePledge 业主以房地产向出借人发起质押
+func CreatePledge(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(PledgeRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfPledge == "" || body.Pledgor == "" || body.Lender == "" || body.DueDate == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.LoanAmount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "LoanAmount贷款金额必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfPledge))
+	bodyBytes = append(bodyBytes, []byte(body.Pledgor))
+	bodyBytes = append(bodyBytes, []byte(body.Lender))
+	bodyBytes = append(bodyBytes, []byte("")) //贷款金额通过transient传入，不写入区块
+	bodyBytes = append(bodyBytes, []byte(body.DueDate))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "createPledge", bodyBytes, map[string][]byte{"loanAmount": []byte(body.LoanAmount.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// UpdatePledge 更新质押状态，出借人放款或违约处置，待放款时出质人或出借人取消
+func UpdatePledge(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(UpdatePledgeRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfPledge == "" || body.Pledgor == "" || body.PledgeID == "" || body.Status == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfPledge))
+	bodyBytes = append(bodyBytes, []byte(body.Pledgor))
+	bodyBytes = append(bodyBytes, []byte(body.PledgeID))
+	bodyBytes = append(bodyBytes, []byte(body.Status))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "updatePledge", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// RepayPledge 出质人偿还质押贷款，还清后解除质押
+func RepayPledge(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RepayPledgeRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.ObjectOfPledge == "" || body.Pledgor == "" || body.PledgeID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if body.Amount <= 0 {
+		appG.Response(http.StatusBadRequest, "失败", "Amount还款金额必须大于0")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfPledge))
+	bodyBytes = append(bodyBytes, []byte(body.Pledgor))
+	bodyBytes = append(bodyBytes, []byte(body.PledgeID))
+	bodyBytes = append(bodyBytes, []byte("")) //还款金额通过transient传入，不写入区块
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "repayPledge", bodyBytes, map[string][]byte{"amount": []byte(body.Amount.String())})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryPledgeList 查询进行中或已结束的质押(可以再根据出质人查询)
+func QueryPledgeList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(PledgeListQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Group != "active" && body.Group != "closed" {
+		appG.Response(http.StatusBadRequest, "失败", "Group只能为active或closed")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	bodyBytes = append(bodyBytes, []byte(body.Group))
+	if body.Pledgor != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Pledgor))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryPledgeList", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryPledgeRepayments 查询一笔质押的还款记录
+func QueryPledgeRepayments(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(PledgeRepaymentsQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.Pledgor == "" || body.ObjectOfPledge == "" || body.PledgeID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "必须指定Pledgor出质人、ObjectOfPledge质押对象和PledgeID查询")
+		return
+	}
+	bodyBytes := body.pageArgs()
+	bodyBytes = append(bodyBytes, []byte(body.Pledgor))
+	bodyBytes = append(bodyBytes, []byte(body.ObjectOfPledge))
+	bodyBytes = append(bodyBytes, []byte(body.PledgeID))
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryPledgeRepayments", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
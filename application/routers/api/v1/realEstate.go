@@ -10,20 +10,67 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"transaction/application/blockchain"
+	"transaction/application/lib"
 	"transaction/application/pkg/app"
 )
 
 type RealEstateRequestBody struct {
-	AccountId   string  `json:"accountId"`   //操作人ID
-	Proprietor  string  `json:"proprietor"`  //所有者(业主)(业主AccountId)
-	TotalArea   float64 `json:"totalArea"`   //总面积
-	LivingSpace float64 `json:"livingSpace"` //生活空间
+	lib.RealEstateAttributes
+	AccountId        string      `json:"accountId"`        //操作人ID
+	Proprietor       string      `json:"proprietor"`       //所有者(业主)(业主AccountId)，共有时为代表业主
+	TotalArea        float64     `json:"totalArea"`        //总面积
+	LivingSpace      float64     `json:"livingSpace"`      //生活空间
+	Owners           []lib.Owner `json:"owners"`           //共有人及份额(万分之一，合计10000)，不指定时由proprietor单独所有
+	ConsentThreshold int         `json:"consentThreshold"` //处分需要同意的份额合计，不指定时需要全体共有人同意
+}
+
+type RealEstateUpdateRequestBody struct {
+	lib.RealEstateAttributes
+	AccountId    string `json:"accountId"`    //操作人ID(登记员)
+	RealEstateID string `json:"realEstateId"` //房地产ID
+	Reason       string `json:"reason"`       //变更原因
+}
+
+type RealEstateUpdateQueryRequestBody struct {
+	PageRequestBody
+	RealEstateID string `json:"realEstateId"` //房地产ID
+}
+
+type RealEstateConsentRequestBody struct {
+	RealEstateID string `json:"realEstateId"` //房地产ID
+	AccountId    string `json:"accountId"`    //同意的共有人AccountId
+	Action       string `json:"action"`       //处分方式，如出售"selling"、捐赠"donating"、拍卖"auction"、质押"pledge"、出租"lease"
+}
+
+type RealEstateConsentQueryRequestBody struct {
+	RealEstateID string `json:"realEstateId"` //房地产ID
+	Action       string `json:"action"`       //处分方式，不指定时查询所有
+}
+
+type RealEstateSplitRequestBody struct {
+	AccountId    string               `json:"accountId"`    //操作人ID(登记员)
+	RealEstateID string               `json:"realEstateId"` //拆分的房地产ID
+	Parts        []lib.RealEstatePart `json:"parts"`        //拆分后各部分的面积，合计必须等于原房地产
+}
+
+type RealEstateMergeRequestBody struct {
+	AccountId     string   `json:"accountId"`     //操作人ID(登记员)
+	RealEstateIds []string `json:"realEstateIds"` //合并的房地产ID列表，所有权必须相同
 }
 
 type RealEstateQueryRequestBody struct {
+	PageRequestBody
 	Proprietor string `json:"proprietor"` //所有者(业主)(业主AccountId)
 }
 
+type RealEstateIdQueryRequestBody struct {
+	RealEstateIds []string `json:"realEstateIds"` //房地产ID列表
+}
+
+type RealEstateHistoryQueryRequestBody struct {
+	RealEstateID string `json:"realEstateId"` //房地产ID
+}
+
 func CreateRealEstate(c *gin.Context) {
 	appG := app.Gin{C: c}
 	body := new(RealEstateRequestBody)
@@ -41,8 +88,28 @@ func CreateRealEstate(c *gin.Context) {
 	bodyBytes = append(bodyBytes, []byte(body.Proprietor))
 	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.TotalArea, 'E', -1, 64)))
 	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.LivingSpace, 'E', -1, 64)))
+	attributes, err := json.Marshal(body.RealEstateAttributes)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("登记信息出错%s", err.Error()))
+		return
+	}
+	bodyBytes = append(bodyBytes, attributes)
+	if len(body.Owners) != 0 {
+		owners, err := json.Marshal(body.Owners)
+		if err != nil {
+			appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("Owners共有人出错%s", err.Error()))
+			return
+		}
+		bodyBytes = append(bodyBytes, owners)
+		if body.ConsentThreshold != 0 {
+			bodyBytes = append(bodyBytes, []byte(strconv.Itoa(body.ConsentThreshold)))
+		}
+	} else if body.ConsentThreshold != 0 {
+		appG.Response(http.StatusBadRequest, "失败", "指定Owners共有人时才能指定ConsentThreshold")
+		return
+	}
 	//调用智能合约
-	resp, err := blockchain.ChannelExecute("createRealEstate", bodyBytes)
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "createRealEstate", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -63,12 +130,202 @@ func QueryRealEstateList(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
+	bodyBytes := body.pageArgs()
 	if body.Proprietor != "" {
 		bodyBytes = append(bodyBytes, []byte(body.Proprietor))
 	}
 	//调用智能合约
-	resp, err := blockchain.ChannelQuery("queryRealEstateList", bodyBytes)
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryRealEstateList", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func QueryRealEstate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateIdQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if len(body.RealEstateIds) == 0 {
+		appG.Response(http.StatusBadRequest, "失败", "RealEstateIds房地产ID不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	for _, val := range body.RealEstateIds {
+		bodyBytes = append(bodyBytes, []byte(val))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryRealEstate", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func QueryRealEstateHistory(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateHistoryQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.RealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "RealEstateID房地产ID不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.RealEstateID))
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryRealEstateHistory", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// UpdateRealEstate 登记员变更房地产的登记信息，必须说明原因
+func UpdateRealEstate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateUpdateRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.RealEstateID == "" || body.Reason == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	attributes, err := json.Marshal(body.RealEstateAttributes)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("登记信息出错%s", err.Error()))
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.RealEstateID))
+	bodyBytes = append(bodyBytes, attributes)
+	bodyBytes = append(bodyBytes, []byte(body.Reason))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "updateRealEstate", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryRealEstateUpdates 分页查询房地产登记信息的变更记录
+func QueryRealEstateUpdates(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateUpdateQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.RealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "RealEstateID房地产ID不能为空")
+		return
+	}
+	bodyBytes := append(body.pageArgs(), []byte(body.RealEstateID))
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryRealEstateUpdates", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// ConsentRealEstate 共有人同意代表业主对房地产的一项处分
+func ConsentRealEstate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateConsentRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.RealEstateID == "" || body.AccountId == "" || body.Action == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.RealEstateID))
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.Action))
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "consentRealEstate", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// QueryRealEstateConsents 查询共有人对房地产尚未使用的同意
+func QueryRealEstateConsents(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateConsentQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.RealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "RealEstateID房地产ID不能为空")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.RealEstateID))
+	if body.Action != "" {
+		bodyBytes = append(bodyBytes, []byte(body.Action))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "queryRealEstateConsents", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -81,3 +338,79 @@ func QueryRealEstateList(c *gin.Context) {
 	}
 	appG.Response(http.StatusOK, "成功", data)
 }
+
+// SplitRealEstate 登记员将一个房地产拆分为多个
+func SplitRealEstate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateSplitRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.RealEstateID == "" {
+		appG.Response(http.StatusBadRequest, "失败", "参数不能为空")
+		return
+	}
+	if len(body.Parts) < 2 {
+		appG.Response(http.StatusBadRequest, "失败", "Parts至少拆分为2个部分")
+		return
+	}
+	parts, err := json.Marshal(body.Parts)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("Parts拆分部分出错%s", err.Error()))
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	bodyBytes = append(bodyBytes, []byte(body.RealEstateID))
+	bodyBytes = append(bodyBytes, parts)
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "splitRealEstate", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// MergeRealEstate 登记员将所有权相同的多个房地产合并为一个
+func MergeRealEstate(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateMergeRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "AccountId操作人ID不能为空")
+		return
+	}
+	if len(body.RealEstateIds) < 2 {
+		appG.Response(http.StatusBadRequest, "失败", "RealEstateIds至少合并2个房地产")
+		return
+	}
+	var bodyBytes [][]byte
+	bodyBytes = append(bodyBytes, []byte(body.AccountId))
+	for _, val := range body.RealEstateIds {
+		bodyBytes = append(bodyBytes, []byte(val))
+	}
+	//调用智能合约
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "mergeRealEstate", bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
@@ -0,0 +1,97 @@
+package v1
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/pkg/app"
+)
+
+// 销售富查询条件，未指定的条件不参与过滤，原样序列化后传给链码searchSellings
+type SellingFilter struct {
+	Seller        string      `json:"seller,omitempty"`        //卖家AccountId
+	SellingStatus string      `json:"sellingStatus,omitempty"` //销售状态，如saleStart、delivery、done
+	MinPrice      *lib.Amount `json:"minPrice,omitempty"`      //最低价格(元，含)
+	MaxPrice      *lib.Amount `json:"maxPrice,omitempty"`      //最高价格(元，含)
+	CreatedFrom   string      `json:"createdFrom,omitempty"`   //创建时间起(含)，RFC3339格式
+	CreatedTo     string      `json:"createdTo,omitempty"`     //创建时间止(含)，RFC3339格式
+	SortBy        string      `json:"sortBy,omitempty"`        //排序字段，createTime(默认)或price
+	SortOrder     string      `json:"sortOrder,omitempty"`     //排序方向，asc(默认)或desc
+}
+
+type SellingSearchRequestBody struct {
+	PageRequestBody
+	SellingFilter
+}
+
+// 房地产富查询条件，未指定的条件不参与过滤，原样序列化后传给链码searchRealEstates
+type RealEstateFilter struct {
+	Proprietor     string   `json:"proprietor,omitempty"`     //所有者AccountId
+	Encumbrance    *bool    `json:"encumbrance,omitempty"`    //是否作为担保
+	District       string   `json:"district,omitempty"`       //所在行政区
+	PropertyType   string   `json:"propertyType,omitempty"`   //房屋用途
+	MinTotalArea   *float64 `json:"minTotalArea,omitempty"`   //最小总面积(含)
+	MaxTotalArea   *float64 `json:"maxTotalArea,omitempty"`   //最大总面积(含)
+	MinLivingSpace *float64 `json:"minLivingSpace,omitempty"` //最小生活空间(含)
+	MaxLivingSpace *float64 `json:"maxLivingSpace,omitempty"` //最大生活空间(含)
+	SortBy         string   `json:"sortBy,omitempty"`         //排序字段，totalArea(默认)或livingSpace
+	SortOrder      string   `json:"sortOrder,omitempty"`      //排序方向，asc(默认)或desc
+}
+
+type RealEstateSearchRequestBody struct {
+	PageRequestBody
+	RealEstateFilter
+}
+
+// SearchSellings 按状态、价格范围、创建时间等条件查询销售
+func SearchSellings(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(SellingSearchRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	search(appG, "searchSellings", body.pageArgs(), &body.SellingFilter)
+}
+
+// SearchRealEstates 按所有者、总面积、生活空间范围等条件查询房地产
+func SearchRealEstates(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateSearchRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	search(appG, "searchRealEstates", body.pageArgs(), &body.RealEstateFilter)
+}
+
+// search 查询条件序列化为JSON作为分页参数之后的第三个参数
+func search(appG app.Gin, fcn string, bodyBytes [][]byte, filter interface{}) {
+	filterBytes, err := json.Marshal(filter)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("查询条件出错%s", err.Error()))
+		return
+	}
+	bodyBytes = append(bodyBytes, filterBytes)
+	//调用智能合约
+	resp, err := blockchain.ChannelQueryAs(fabricUser(appG.C), fcn, bodyBytes)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
@@ -10,14 +10,15 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"transaction/application/blockchain"
+	"transaction/application/lib"
 	"transaction/application/pkg/app"
 )
 
 type SellingRequestBody struct {
-	ObjectOfSale string  `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
-	Seller       string  `json:"seller"`       //发起销售人、卖家(卖家AccountId)
-	Price        float64 `json:"price"`        //价格
-	SalePeriod   int     `json:"salePeriod"`   //智能合约的有效期(单位为天)
+	ObjectOfSale string     `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
+	Seller       string     `json:"seller"`       //发起销售人、卖家(卖家AccountId)
+	Price        lib.Amount `json:"price"`        //价格(元)，最多两位小数，可以是字符串或数字
+	SalePeriod   int        `json:"salePeriod"`   //智能合约的有效期(单位为天)
 }
 
 type SellingByBuyRequestBody struct {
@@ -27,9 +28,11 @@ type SellingByBuyRequestBody struct {
 }
 
 type SellingListQueryRequestBody struct {
+	PageRequestBody
 	Seller string `json:"seller"` //发起销售人、卖家(卖家AccountId)
 }
 type SellingListQueryByBuyRequestBody struct {
+	PageRequestBody
 	Buyer string `json:"buyer"` //买家(买家AccountId)
 }
 
@@ -59,10 +62,10 @@ func CreateSelling(c *gin.Context) {
 	var bodyBytes [][]byte
 	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
 	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.Price, 'E', -1, 64)))
+	bodyBytes = append(bodyBytes, []byte(body.Price.String()))
 	bodyBytes = append(bodyBytes, []byte(strconv.Itoa(body.SalePeriod)))
 	//调用智能合约
-	resp, err := blockchain.ChannelExecute("createSelling", bodyBytes)
+	resp, err := blockchain.ChannelExecuteAs(fabricUser(c), "createSelling", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -90,9 +93,9 @@ func CreateSellingByBuy(c *gin.Context) {
 	var bodyBytes [][]byte
 	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
 	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(body.Buyer))
+	bodyBytes = append(bodyBytes, []byte("")) //买家通过transient传入，不写入区块
 	//调用智能合约
-	resp, err := blockchain.ChannelExecute("createSellingByBuy", bodyBytes)
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "createSellingByBuy", bodyBytes, map[string][]byte{"buyer": []byte(body.Buyer)})
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -113,18 +116,18 @@ func QuerySellingList(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
 		return
 	}
-	var bodyBytes [][]byte
+	bodyBytes := body.pageArgs()
 	if body.Seller != "" {
 		bodyBytes = append(bodyBytes, []byte(body.Seller))
 	}
 	//调用智能合约
-	resp, err := blockchain.ChannelQuery("querySellingList", bodyBytes)
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "querySellingList", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
 	// 反序列化json
-	var data []map[string]interface{}
+	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -144,16 +147,16 @@ func QuerySellingListByBuyer(c *gin.Context) {
 		appG.Response(http.StatusBadRequest, "失败", "必须指定买家AccountId查询")
 		return
 	}
-	var bodyBytes [][]byte
+	bodyBytes := body.pageArgs()
 	bodyBytes = append(bodyBytes, []byte(body.Buyer))
 	//调用智能合约
-	resp, err := blockchain.ChannelQuery("querySellingListByBuyer", bodyBytes)
+	resp, err := blockchain.ChannelQueryAs(fabricUser(c), "querySellingListByBuyer", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
 	// 反序列化json
-	var data []map[string]interface{}
+	var data map[string]interface{}
 	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -176,10 +179,10 @@ func UpdateSelling(c *gin.Context) {
 	var bodyBytes [][]byte
 	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
 	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(body.Buyer))
+	bodyBytes = append(bodyBytes, []byte("")) //买家通过transient传入，不写入区块
 	bodyBytes = append(bodyBytes, []byte(body.Status))
 	//调用智能合约
-	resp, err := blockchain.ChannelExecute("updateSelling", bodyBytes)
+	resp, err := blockchain.ChannelExecuteWithTransient(fabricUser(c), "updateSelling", bodyBytes, map[string][]byte{"buyer": []byte(body.Buyer)})
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -189,5 +192,9 @@ func UpdateSelling(c *gin.Context) {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
+	//确认收款后附上逐项列出税费的收据
+	if body.Status == "done" {
+		attachReceipt(fabricUser(c), data, string(resp.TransactionID))
+	}
 	appG.Response(http.StatusOK, "成功", data)
 }
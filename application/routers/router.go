@@ -18,19 +18,77 @@ func InitRouter() *gin.Engine {
 	r.Use(Cors())
 	apiV1 := r.Group("/api/v1")
 	{
-
+		apiV1.POST("/login", v1.Login)
+		//之后的接口都需要登录，以会话绑定的Fabric用户签名
+		apiV1.Use(v1.Auth())
+		apiV1.POST("/logout", v1.Logout)
 		apiV1.POST("/queryAccountList", v1.QueryAccountList)
+		apiV1.POST("/bindAccount", v1.BindAccount)
+		apiV1.POST("/updateAccountRoles", v1.UpdateAccountRoles)
+		apiV1.POST("/createAccount", v1.CreateAccount)
+		apiV1.POST("/updateAccount", v1.UpdateAccount)
+		apiV1.POST("/freezeAccount", v1.FreezeAccount)
+		apiV1.POST("/closeAccount", v1.CloseAccount)
+		apiV1.POST("/deposit", v1.Deposit)
+		apiV1.POST("/withdraw", v1.Withdraw)
+		apiV1.POST("/transfer", v1.Transfer)
+		apiV1.POST("/queryAccountStatement", v1.QueryAccountStatement)
 		apiV1.POST("/createRealEstate", v1.CreateRealEstate)
 		apiV1.POST("/queryRealEstateList", v1.QueryRealEstateList)
+		apiV1.POST("/queryRealEstate", v1.QueryRealEstate)
+		apiV1.POST("/queryRealEstateHistory", v1.QueryRealEstateHistory)
+		apiV1.POST("/searchRealEstates", v1.SearchRealEstates)
+		apiV1.POST("/updateRealEstate", v1.UpdateRealEstate)
+		apiV1.POST("/queryRealEstateUpdates", v1.QueryRealEstateUpdates)
+		apiV1.POST("/splitRealEstate", v1.SplitRealEstate)
+		apiV1.POST("/mergeRealEstate", v1.MergeRealEstate)
+		apiV1.POST("/consentRealEstate", v1.ConsentRealEstate)
+		apiV1.POST("/queryRealEstateConsents", v1.QueryRealEstateConsents)
 		apiV1.POST("/createSelling", v1.CreateSelling)
 		apiV1.POST("/createSellingByBuy", v1.CreateSellingByBuy)
 		apiV1.POST("/querySellingList", v1.QuerySellingList)
 		apiV1.POST("/querySellingListByBuyer", v1.QuerySellingListByBuyer)
+		apiV1.POST("/searchSellings", v1.SearchSellings)
 		apiV1.POST("/updateSelling", v1.UpdateSelling)
+		apiV1.POST("/createOffer", v1.CreateOffer)
+		apiV1.POST("/updateOffer", v1.UpdateOffer)
+		apiV1.POST("/queryOfferList", v1.QueryOfferList)
+		apiV1.POST("/queryOfferListByBuyer", v1.QueryOfferListByBuyer)
+		apiV1.POST("/queryEscrowList", v1.QueryEscrowList)
+		apiV1.POST("/queryFundsSummary", v1.QueryFundsSummary)
+		apiV1.POST("/setFeeSchedule", v1.SetFeeSchedule)
+		apiV1.POST("/queryFeeSchedule", v1.QueryFeeSchedule)
+		apiV1.POST("/queryReceipt", v1.QueryReceipt)
+		apiV1.POST("/createAuction", v1.CreateAuction)
+		apiV1.POST("/placeBid", v1.PlaceBid)
+		apiV1.POST("/settleAuction", v1.SettleAuction)
+		apiV1.POST("/cancelAuction", v1.CancelAuction)
+		apiV1.POST("/queryAuctionList", v1.QueryAuctionList)
+		apiV1.POST("/queryAuctionBids", v1.QueryAuctionBids)
+		apiV1.POST("/createSealedAuction", v1.CreateSealedAuction)
+		apiV1.POST("/commitBid", v1.CommitBid)
+		apiV1.POST("/revealBid", v1.RevealBid)
+		apiV1.POST("/querySealedBids", v1.QuerySealedBids)
+		apiV1.POST("/createPledge", v1.CreatePledge)
+		apiV1.POST("/updatePledge", v1.UpdatePledge)
+		apiV1.POST("/repayPledge", v1.RepayPledge)
+		apiV1.POST("/queryPledgeList", v1.QueryPledgeList)
+		apiV1.POST("/queryPledgeRepayments", v1.QueryPledgeRepayments)
+		apiV1.POST("/createLease", v1.CreateLease)
+		apiV1.POST("/signLease", v1.SignLease)
+		apiV1.POST("/payRent", v1.PayRent)
+		apiV1.POST("/updateLease", v1.UpdateLease)
+		apiV1.POST("/queryLeaseList", v1.QueryLeaseList)
+		apiV1.POST("/queryLeaseListByTenant", v1.QueryLeaseListByTenant)
+		apiV1.POST("/queryLeasePayments", v1.QueryLeasePayments)
 		apiV1.POST("/createDonating", v1.CreateDonating)
 		apiV1.POST("/queryDonatingList", v1.QueryDonatingList)
 		apiV1.POST("/queryDonatingListByGrantee", v1.QueryDonatingListByGrantee)
 		apiV1.POST("/updateDonating", v1.UpdateDonating)
+		apiV1.POST("/uploadDocument", v1.UploadDocument)
+		apiV1.POST("/queryDocuments", v1.QueryDocuments)
+		apiV1.GET("/downloadDocument", v1.DownloadDocument)
+		apiV1.POST("/verifyDocument", v1.VerifyDocument)
 	}
 
 	// 静态文件路由
@@ -1,18 +1,14 @@
 package service
 
 import (
-	"bytes"
 	"encoding/json"
-	"fmt"
 	"github.com/robfig/cron"
 	"log"
-	"time"
 	"transaction/application/blockchain"
 	"transaction/application/lib"
 )
 
-//const spec = "0 0 0 * * ?" // 每天0点执行
-const spec = "*/10 * * * * ?" //10秒执行一次，用于测试
+const spec = "0 0 0 * * ?" // 每天0点执行
 
 func Init() {
 	//c := cron.New(cron.WithSeconds()) //支持到秒级别
@@ -24,48 +20,94 @@ func Init() {
 	select {}
 }
 
+// GoRun 触发链码关闭所有超过有效期的销售和超过接收期限的捐赠，标记欠租的租约并关闭租期结束的租约，是否过期或欠租由链码按交易时间判断
+// 每项任务先以查询方式模拟执行，有需要处理的记录时才提交交易，避免每次都提交空的交易
 func GoRun() {
 	log.Printf("定时任务已启动")
-	//先把所有销售查询出来
-	resp, err := blockchain.ChannelQuery("querySellingList", [][]byte{}) //调用智能合约
+	expireSellings()
+	expireDonatings()
+	flagOverdueLeases()
+}
+
+func expireSellings() {
+	//调用智能合约
+	payload, err := executeIfNeeded("expireSellings")
 	if err != nil {
-		log.Printf("定时任务-querySellingList失败%s", err.Error())
+		log.Printf("定时任务-expireSellings失败%s", err.Error())
+		return
+	}
+	if payload == nil {
 		return
 	}
 	// 反序列化json
 	var data []lib.Selling
-	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+	if err = json.Unmarshal(payload, &data); err != nil {
+		log.Printf("定时任务-反序列化json失败%s", err.Error())
+		return
+	}
+	for _, v := range data {
+		log.Printf("定时任务-销售已过期: 房地产%s 卖家%s", v.ObjectOfSale, v.Seller)
+	}
+}
+
+func expireDonatings() {
+	//调用智能合约
+	payload, err := executeIfNeeded("expireDonatings")
+	if err != nil {
+		log.Printf("定时任务-expireDonatings失败%s", err.Error())
+		return
+	}
+	if payload == nil {
+		return
+	}
+	// 反序列化json
+	var data []lib.Donating
+	if err = json.Unmarshal(payload, &data); err != nil {
+		log.Printf("定时任务-反序列化json失败%s", err.Error())
+		return
+	}
+	for _, v := range data {
+		log.Printf("定时任务-捐赠已过期: 房地产%s 创建时间%s", v.ObjectOfDonating, v.CreateTime)
+	}
+}
+
+func flagOverdueLeases() {
+	//调用智能合约
+	payload, err := executeIfNeeded("flagOverdueLeases")
+	if err != nil {
+		log.Printf("定时任务-flagOverdueLeases失败%s", err.Error())
+		return
+	}
+	if payload == nil {
+		return
+	}
+	// 反序列化json
+	var data []lib.Lease
+	if err = json.Unmarshal(payload, &data); err != nil {
 		log.Printf("定时任务-反序列化json失败%s", err.Error())
 		return
 	}
 	for _, v := range data {
-		//把状态为销售中和交付中的筛选出来
-		if v.SellingStatus == lib.SellingStatusConstant()["saleStart"] ||
-			v.SellingStatus == lib.SellingStatusConstant()["delivery"] {
-			//有效期天数
-			day, _ := time.ParseDuration(fmt.Sprintf("%dh", v.SalePeriod*24))
-			local, _ := time.LoadLocation("Local")
-			t, _ := time.ParseInLocation("2006-01-02 15:04:05", v.CreateTime, local)
-			vTime := t.Add(day)
-			//如果 time.Now()大于 vTime 说明过期
-			if time.Now().Local().After(vTime) {
-				//将状态更改为已过期
-				var bodyBytes [][]byte
-				bodyBytes = append(bodyBytes, []byte(v.ObjectOfSale))
-				bodyBytes = append(bodyBytes, []byte(v.Seller))
-				bodyBytes = append(bodyBytes, []byte(v.Buyer))
-				bodyBytes = append(bodyBytes, []byte("expired"))
-				//调用智能合约
-				resp, err := blockchain.ChannelExecute("updateSelling", bodyBytes)
-				if err != nil {
-					return
-				}
-				var data map[string]interface{}
-				if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
-					return
-				}
-				fmt.Println(data)
-			}
-		}
+		log.Printf("定时任务-租约%s: 房地产%s 出租人%s 承租人%s 租金付清到%s 欠租%t", v.LeaseStatus, v.ObjectOfLease, v.Landlord, v.Tenant, v.PaidUntil, v.RentOverdue)
+	}
+}
+
+// executeIfNeeded 先查询(不提交)fcn的执行结果，返回的列表不为空时才提交交易并返回提交结果，否则返回nil
+func executeIfNeeded(fcn string) ([]byte, error) {
+	resp, err := blockchain.ChannelQuery(fcn, [][]byte{})
+	if err != nil {
+		return nil, err
+	}
+	var records []json.RawMessage
+	if err = json.Unmarshal(resp.Payload, &records); err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	resp, err = blockchain.ChannelExecute(fcn, [][]byte{})
+	if err != nil {
+		return nil, err
 	}
+	return resp.Payload, nil
 }
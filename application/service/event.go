@@ -0,0 +1,179 @@
+package service
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+
+	"transaction/application/blockchain"
+	"transaction/application/lib"
+	"transaction/application/setting"
+)
+
+// 订阅断开后重新订阅的间隔
+const resubscribeInterval = 10 * time.Second
+
+// 注册链码事件的处理函数并开始订阅，订阅断开后自动从最后处理完的事件重新订阅
+// 目前的处理函数只记录日志，需要同步到其他系统时在这里注册新的处理函数
+func InitEvents() {
+	for _, name := range []string{"realEstateCreated", "realEstateUpdated"} {
+		blockchain.RegisterEventHandler(name, onRealEstateEvent)
+	}
+	for _, name := range []string{"realEstatesSplit", "realEstatesMerged"} {
+		blockchain.RegisterEventHandler(name, onRealEstateListEvent)
+	}
+	for _, name := range []string{"sellingCreated", "sellingPurchased", "sellingDone", "sellingCancelled", "sellingExpired"} {
+		blockchain.RegisterEventHandler(name, onSellingEvent)
+	}
+	blockchain.RegisterEventHandler("sellingsExpired", onSellingListEvent)
+	for _, name := range []string{"donatingCreated", "donatingDone", "donatingCancelled", "donatingExpired"} {
+		blockchain.RegisterEventHandler(name, onDonatingEvent)
+	}
+	blockchain.RegisterEventHandler("donatingsExpired", onDonatingListEvent)
+	for _, name := range []string{"offerCreated", "offerCountered", "offerAccepted", "offerRejected", "offerWithdrawn"} {
+		blockchain.RegisterEventHandler(name, onOfferEvent)
+	}
+	for _, name := range []string{"auctionCreated", "auctionBid", "auctionCommitted", "auctionRevealed", "auctionDone", "auctionFailed", "auctionCancelled"} {
+		blockchain.RegisterEventHandler(name, onAuctionEvent)
+	}
+	for _, name := range []string{"pledgeCreated", "pledgeActivated", "pledgeCancelled", "pledgeRepaid", "pledgeReleased", "pledgeDefaulted"} {
+		blockchain.RegisterEventHandler(name, onPledgeEvent)
+	}
+	for _, name := range []string{"leaseCreated", "leaseSigned", "leaseRentPaid", "leaseCancelled", "leaseTerminated"} {
+		blockchain.RegisterEventHandler(name, onLeaseEvent)
+	}
+	blockchain.RegisterEventHandler("leasesOverdue", onLeaseListEvent)
+	blockchain.RegisterEventHandler("leasesExpired", onLeaseListEvent)
+	blockchain.RegisterEventHandler("documentAnchored", onDocumentEvent)
+	for {
+		if err := blockchain.ListenEvents(setting.EventSetting.CheckpointPath); err != nil {
+			log.Printf("链码事件订阅失败%s，%s后重试", err.Error(), resubscribeInterval)
+		}
+		time.Sleep(resubscribeInterval)
+	}
+}
+
+func onRealEstateEvent(e *fab.CCEvent) {
+	var event lib.RealEstateEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 所有者%s 区块%d", e.EventName, event.RealEstate.RealEstateID, event.RealEstate.Proprietor, e.BlockNumber)
+}
+
+func onRealEstateListEvent(e *fab.CCEvent) {
+	var event lib.RealEstateListEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	for _, v := range event.RealEstates {
+		log.Printf("链码事件%s: 房地产%s 所有者%s 已失效%t 区块%d", e.EventName, v.RealEstateID, v.Proprietor, v.Retired, e.BlockNumber)
+	}
+}
+
+func onSellingEvent(e *fab.CCEvent) {
+	var event lib.SellingEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 卖家%s 买家%s 价格%s 状态%s 区块%d", e.EventName, event.Selling.ObjectOfSale,
+		event.Selling.Seller, event.Selling.Buyer, event.Selling.Price, event.Selling.SellingStatus, e.BlockNumber)
+}
+
+func onSellingListEvent(e *fab.CCEvent) {
+	var event lib.SellingListEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	for _, v := range event.Sellings {
+		log.Printf("链码事件%s: 房地产%s 卖家%s 买家%s 状态%s 区块%d", e.EventName, v.ObjectOfSale, v.Seller, v.Buyer, v.SellingStatus, e.BlockNumber)
+	}
+}
+
+func onDonatingEvent(e *fab.CCEvent) {
+	var event lib.DonatingEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 状态%s 私有数据哈希%s 区块%d", e.EventName, event.Donating.ObjectOfDonating,
+		event.Donating.DonatingStatus, event.Donating.PrivateHash, e.BlockNumber)
+}
+
+func onDonatingListEvent(e *fab.CCEvent) {
+	var event lib.DonatingListEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	for _, v := range event.Donatings {
+		log.Printf("链码事件%s: 房地产%s 状态%s 私有数据哈希%s 区块%d", e.EventName, v.ObjectOfDonating, v.DonatingStatus, v.PrivateHash, e.BlockNumber)
+	}
+}
+
+func onOfferEvent(e *fab.CCEvent) {
+	var event lib.OfferEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 卖家%s 买家%s 价格%s 状态%s 区块%d", e.EventName, event.Offer.ObjectOfSale,
+		event.Offer.Seller, event.Offer.Buyer, event.Offer.Price, event.Offer.OfferStatus, e.BlockNumber)
+}
+
+func onAuctionEvent(e *fab.CCEvent) {
+	var event lib.AuctionEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 卖家%s 状态%s 私有数据哈希%s 区块%d", e.EventName, event.Auction.ObjectOfSale,
+		event.Auction.Seller, event.Auction.AuctionStatus, event.Auction.PrivateHash, e.BlockNumber)
+}
+
+func onPledgeEvent(e *fab.CCEvent) {
+	var event lib.PledgeEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 出质人%s 出借人%s 状态%s 私有数据哈希%s 区块%d", e.EventName, event.Pledge.ObjectOfPledge,
+		event.Pledge.Pledgor, event.Pledge.Lender, event.Pledge.PledgeStatus, event.Pledge.PrivateHash, e.BlockNumber)
+}
+
+func onLeaseEvent(e *fab.CCEvent) {
+	var event lib.LeaseEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: 房地产%s 出租人%s 承租人%s 租金付清到%s 状态%s 区块%d", e.EventName, event.Lease.ObjectOfLease,
+		event.Lease.Landlord, event.Lease.Tenant, event.Lease.PaidUntil, event.Lease.LeaseStatus, e.BlockNumber)
+}
+
+func onLeaseListEvent(e *fab.CCEvent) {
+	var event lib.LeaseListEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	for _, v := range event.Leases {
+		log.Printf("链码事件%s: 房地产%s 出租人%s 承租人%s 租金付清到%s 状态%s 区块%d", e.EventName, v.ObjectOfLease, v.Landlord, v.Tenant, v.PaidUntil, v.LeaseStatus, e.BlockNumber)
+	}
+}
+
+func onDocumentEvent(e *fab.CCEvent) {
+	var event lib.DocumentEvent
+	if err := json.Unmarshal(e.Payload, &event); err != nil {
+		log.Printf("链码事件%s-反序列化json失败%s", e.EventName, err.Error())
+		return
+	}
+	log.Printf("链码事件%s: %s%v 文档%s 上传人%s 区块%d", e.EventName, event.Document.ObjectType, event.Document.ObjectKey,
+		event.Document.Hash, event.Document.Uploader, e.BlockNumber)
+}
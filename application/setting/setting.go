@@ -2,7 +2,9 @@ package setting
 
 import (
 	"github.com/go-ini/ini"
+	"golang.org/x/crypto/bcrypt"
 	"log"
+	"strings"
 	"time"
 )
 
@@ -15,6 +17,34 @@ type Server struct {
 
 var ServerSetting = &Server{}
 
+type Document struct {
+	StoragePath string //按内容寻址保存文档的目录
+	MaxSize     int64  //单个文档的最大字节数(MB)
+}
+
+var DocumentSetting = &Document{}
+
+type Auth struct {
+	SessionTimeout time.Duration //登录会话的有效期(分钟)，有访问时顺延
+}
+
+var AuthSetting = &Auth{}
+
+type Event struct {
+	CheckpointPath string //记录最后处理完的链码事件位置的文件，重新订阅时从该位置继续
+}
+
+var EventSetting = &Event{}
+
+// User 可以登录应用的账户，请求以FabricUser的身份签名
+type User struct {
+	FabricUser   string //签名交易和查询的Fabric用户，证书需要在blockchain/config.yaml中配置
+	PasswordHash string //密码的bcrypt哈希，如$2y$10$...
+}
+
+// Users 按账户ID索引，读取自conf/app.ini的[users]
+var Users = make(map[string]User)
+
 var cfg *ini.File
 
 func Setup() {
@@ -26,6 +56,24 @@ func Setup() {
 	mapTo("server", ServerSetting)
 	ServerSetting.ReadTimeout = ServerSetting.ReadTimeout * time.Second
 	ServerSetting.WriteTimeout = ServerSetting.WriteTimeout * time.Second
+	mapTo("document", DocumentSetting)
+	DocumentSetting.MaxSize = DocumentSetting.MaxSize * 1024 * 1024
+	mapTo("auth", AuthSetting)
+	AuthSetting.SessionTimeout = AuthSetting.SessionTimeout * time.Minute
+	mapTo("event", EventSetting)
+	for _, key := range cfg.Section("users").Keys() {
+		parts := strings.SplitN(key.Value(), ":", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			log.Fatalf("setting.Setup, [users] %s的格式应为 Fabric用户:密码的bcrypt哈希", key.Name())
+		}
+		if _, err := bcrypt.Cost([]byte(parts[1])); err != nil {
+			log.Fatalf("setting.Setup, [users] %s的密码不是有效的bcrypt哈希: %v", key.Name(), err)
+		}
+		Users[key.Name()] = User{FabricUser: parts[0], PasswordHash: parts[1]}
+	}
+	if len(Users) == 0 {
+		log.Printf("conf/app.ini的[users]没有配置可以登录的账户")
+	}
 }
 
 func mapTo(section string, v interface{}) {
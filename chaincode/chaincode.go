@@ -4,10 +4,8 @@ import (
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
-	"time"
 	"transaction/chaincode/lib"
 	"transaction/chaincode/routers"
-	"transaction/chaincode/utils"
 )
 
 type BlockChainRealEstate struct {
@@ -15,11 +13,6 @@ type BlockChainRealEstate struct {
 
 func (t *BlockChainRealEstate) Init(stub shim.ChaincodeStubInterface) peer.Response {
 	fmt.Println("链码初始化")
-	timeLocal, err := time.LoadLocation("Asia/Shanghai")
-	if err != nil {
-		return shim.Error(fmt.Sprintf("时区设置失败%s", err))
-	}
-	time.Local = timeLocal
 	//初始化默认数据
 	var accountIds = [6]string{
 		"5feceb66ffc8",
@@ -30,30 +23,88 @@ func (t *BlockChainRealEstate) Init(stub shim.ChaincodeStubInterface) peer.Respo
 		"ef2d127de37b",
 	}
 	var userNames = [6]string{"管理员", "①号业主", "②号业主", "③号业主", "④号业主", "⑤号业主"}
-	var balances = [6]float64{0, 5000000, 5000000, 5000000, 5000000, 5000000}
+	var balances = [6]lib.Amount{0, 5000000 * lib.Yuan, 5000000 * lib.Yuan, 5000000 * lib.Yuan, 5000000 * lib.Yuan, 5000000 * lib.Yuan}
 
 	for i, val := range accountIds {
+		roles := []string{"owner"}
+		if i == 0 {
+			roles = []string{"registrar", "bank"}
+		}
 		account := &lib.Account{
 			AccountId: val,
 			UserName:  userNames[i],
 			Balance:   balances[i],
+			Roles:     roles,
+			Status:    lib.AccountStatusConstant()["active"],
 		}
-		if err := utils.WriteLedger(account, stub, lib.AccountKey, []string{val}); err != nil {
+		//完整的账户写入私有数据集合，账本中的账户不含余额
+		if err := routers.InitAccount(stub, *account); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
 	}
+	//管理员账户绑定实例化链码的身份，其余业主账户由管理员通过bindAccount绑定
+	if response := routers.InitAdminAccount(stub, accountIds[0]); response.Status != shim.OK {
+		return response
+	}
 	return shim.Success(nil)
 }
 
 func (t *BlockChainRealEstate) Invoke(stub shim.ChaincodeStubInterface) peer.Response {
 	funcName, args := stub.GetFunctionAndParameters()
+	if err := routers.Authorize(stub, funcName); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	switch funcName {
 	case "queryAccountList":
 		return routers.QueryAccountList(stub, args)
+	case "bindAccount":
+		return routers.BindAccount(stub, args)
+	case "updateAccountRoles":
+		return routers.UpdateAccountRoles(stub, args)
+	case "createAccount":
+		return routers.CreateAccount(stub, args)
+	case "updateAccount":
+		return routers.UpdateAccount(stub, args)
+	case "freezeAccount":
+		return routers.FreezeAccount(stub, args)
+	case "closeAccount":
+		return routers.CloseAccount(stub, args)
+	case "deposit":
+		return routers.Deposit(stub, args)
+	case "withdraw":
+		return routers.Withdraw(stub, args)
+	case "transfer":
+		return routers.Transfer(stub, args)
+	case "queryAccountStatement":
+		return routers.QueryAccountStatement(stub, args)
+	case "migrateAmounts":
+		return routers.MigrateAmounts(stub, args)
+	case "migratePrivateData":
+		return routers.MigratePrivateData(stub, args)
+	case "migrateRealEstateKeys":
+		return routers.MigrateRealEstateKeys(stub, args)
 	case "createRealEstate":
 		return routers.CreateRealEstate(stub, args)
+	case "splitRealEstate":
+		return routers.SplitRealEstate(stub, args)
+	case "mergeRealEstate":
+		return routers.MergeRealEstate(stub, args)
+	case "updateRealEstate":
+		return routers.UpdateRealEstate(stub, args)
+	case "queryRealEstateUpdates":
+		return routers.QueryRealEstateUpdates(stub, args)
+	case "consentRealEstate":
+		return routers.ConsentRealEstate(stub, args)
+	case "queryRealEstateConsents":
+		return routers.QueryRealEstateConsents(stub, args)
 	case "queryRealEstateList":
 		return routers.QueryRealEstateList(stub, args)
+	case "queryRealEstate":
+		return routers.QueryRealEstate(stub, args)
+	case "queryRealEstateHistory":
+		return routers.QueryRealEstateHistory(stub, args)
+	case "searchRealEstates":
+		return routers.SearchRealEstates(stub, args)
 	case "createSelling":
 		return routers.CreateSelling(stub, args)
 	case "createSellingByBuy":
@@ -62,8 +113,78 @@ func (t *BlockChainRealEstate) Invoke(stub shim.ChaincodeStubInterface) peer.Res
 		return routers.QuerySellingList(stub, args)
 	case "querySellingListByBuyer":
 		return routers.QuerySellingListByBuyer(stub, args)
+	case "searchSellings":
+		return routers.SearchSellings(stub, args)
 	case "updateSelling":
 		return routers.UpdateSelling(stub, args)
+	case "expireSellings":
+		return routers.ExpireSellings(stub, args)
+	case "createOffer":
+		return routers.CreateOffer(stub, args)
+	case "updateOffer":
+		return routers.UpdateOffer(stub, args)
+	case "queryOfferList":
+		return routers.QueryOfferList(stub, args)
+	case "queryOfferListByBuyer":
+		return routers.QueryOfferListByBuyer(stub, args)
+	case "queryEscrowList":
+		return routers.QueryEscrowList(stub, args)
+	case "queryFundsSummary":
+		return routers.QueryFundsSummary(stub, args)
+	case "migrateEscrows":
+		return routers.MigrateEscrows(stub, args)
+	case "setFeeSchedule":
+		return routers.SetFeeSchedule(stub, args)
+	case "queryFeeSchedule":
+		return routers.QueryFeeSchedule(stub, args)
+	case "queryReceipt":
+		return routers.QueryReceipt(stub, args)
+	case "createAuction":
+		return routers.CreateAuction(stub, args)
+	case "placeBid":
+		return routers.PlaceBid(stub, args)
+	case "settleAuction":
+		return routers.SettleAuction(stub, args)
+	case "cancelAuction":
+		return routers.CancelAuction(stub, args)
+	case "queryAuctionList":
+		return routers.QueryAuctionList(stub, args)
+	case "queryAuctionBids":
+		return routers.QueryAuctionBids(stub, args)
+	case "createSealedAuction":
+		return routers.CreateSealedAuction(stub, args)
+	case "commitBid":
+		return routers.CommitBid(stub, args)
+	case "revealBid":
+		return routers.RevealBid(stub, args)
+	case "querySealedBids":
+		return routers.QuerySealedBids(stub, args)
+	case "createPledge":
+		return routers.CreatePledge(stub, args)
+	case "updatePledge":
+		return routers.UpdatePledge(stub, args)
+	case "repayPledge":
+		return routers.RepayPledge(stub, args)
+	case "queryPledgeList":
+		return routers.QueryPledgeList(stub, args)
+	case "queryPledgeRepayments":
+		return routers.QueryPledgeRepayments(stub, args)
+	case "createLease":
+		return routers.CreateLease(stub, args)
+	case "signLease":
+		return routers.SignLease(stub, args)
+	case "payRent":
+		return routers.PayRent(stub, args)
+	case "updateLease":
+		return routers.UpdateLease(stub, args)
+	case "flagOverdueLeases":
+		return routers.FlagOverdueLeases(stub, args)
+	case "queryLeaseList":
+		return routers.QueryLeaseList(stub, args)
+	case "queryLeaseListByTenant":
+		return routers.QueryLeaseListByTenant(stub, args)
+	case "queryLeasePayments":
+		return routers.QueryLeasePayments(stub, args)
 	case "createDonating":
 		return routers.CreateDonating(stub, args)
 	case "queryDonatingList":
@@ -72,6 +193,14 @@ func (t *BlockChainRealEstate) Invoke(stub shim.ChaincodeStubInterface) peer.Res
 		return routers.QueryDonatingListByGrantee(stub, args)
 	case "updateDonating":
 		return routers.UpdateDonating(stub, args)
+	case "expireDonatings":
+		return routers.ExpireDonatings(stub, args)
+	case "anchorDocument":
+		return routers.AnchorDocument(stub, args)
+	case "queryDocuments":
+		return routers.QueryDocuments(stub, args)
+	case "queryDocument":
+		return routers.QueryDocument(stub, args)
 	default:
 		return shim.Error(fmt.Sprintf("没有该功能: %s", funcName))
 	}
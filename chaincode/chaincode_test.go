@@ -2,31 +2,466 @@ package main
 
 import (
 	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/msp"
 	"github.com/hyperledger/fabric/protos/peer"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 	"transaction/chaincode/lib"
 )
 
-func initTest(t *testing.T) *shim.MockStub {
+const (
+	adminId = "5feceb66ffc8"
+	mspId   = "Org1MSP"
+)
+
+// 初始化的业主账户
+var ownerIds = []string{"6b86b273ff34", "d4735e3a265e", "4e07408562be", "4b227777d4dd", "ef2d127de37b"}
+
+// MockStub不支持模拟交易提交者、历史查询、分页查询、富查询、事件和transient，这里包装一层，以指定的账户身份调用链码，并记录成功交易的写入历史和事件
+type identityStub struct {
+	*shim.MockStub
+	cc        shim.Chaincode
+	args      [][]byte
+	creator   []byte
+	pending   []*queryresult.KV
+	history   map[string][]*queryresult.KeyModification
+	event     *peer.ChaincodeEvent   //当前交易设置的事件
+	events    []*peer.ChaincodeEvent //成功交易的事件
+	clock     time.Duration          //交易时间相对当前时间的偏移，用于模拟时间流逝
+	transient map[string][]byte      //交易的transient，不会写入交易
+	denied    map[string]bool        //模拟背书节点所在组织不是成员的私有数据集合，读取时返回错误
+}
+
+func (stub *identityStub) PutState(key string, value []byte) error {
+	stub.pending = append(stub.pending, &queryresult.KV{Key: key, Value: value})
+	return stub.MockStub.PutState(key, value)
+}
+
+func (stub *identityStub) DelState(key string) error {
+	stub.pending = append(stub.pending, &queryresult.KV{Key: key})
+	return stub.MockStub.DelState(key)
+}
+
+// 与Fabric一致，每笔交易只保留最后设置的事件
+func (stub *identityStub) SetEvent(name string, payload []byte) error {
+	stub.event = &peer.ChaincodeEvent{TxId: stub.TxID, EventName: name, Payload: payload}
+	return nil
+}
+
+func (stub *identityStub) GetTransient() (map[string][]byte, error) {
+	return stub.transient, nil
+}
+
+func (stub *identityStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	return &historyIterator{modifications: stub.history[key]}, nil
+}
+
+// 按复合键顺序分页，书签为下一页第一条记录的键
+func (stub *identityStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	iter, err := stub.MockStub.GetStateByPartialCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Close()
+	page := &kvIterator{}
+	metadata := &peer.QueryResponseMetadata{}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, nil, err
+		}
+		if kv.Key < bookmark {
+			continue
+		}
+		if int32(len(page.kvs)) == pageSize {
+			metadata.Bookmark = kv.Key
+			break
+		}
+		page.kvs = append(page.kvs, kv)
+	}
+	metadata.FetchedRecordsCount = int32(len(page.kvs))
+	return page, metadata, nil
+}
+
+// MockStub不支持私有数据的部分复合键查询，这里按键排序后按前缀匹配
+func (stub *identityStub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	prefix, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, err
+	}
+	iter := &kvIterator{}
+	for key, value := range stub.PvtState[collection] {
+		if strings.HasPrefix(key, prefix) {
+			iter.kvs = append(iter.kvs, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	sort.Slice(iter.kvs, func(i, j int) bool { return iter.kvs[i].Key < iter.kvs[j].Key })
+	return iter, nil
+}
+
+func (stub *identityStub) GetPrivateData(collection string, key string) ([]byte, error) {
+	if stub.denied[collection] {
+		return nil, errors.New(fmt.Sprintf("collection %s: not a member", collection))
+	}
+	return stub.MockStub.GetPrivateData(collection, key)
+}
+
+// MockStub不支持删除私有数据
+func (stub *identityStub) DelPrivateData(collection string, key string) error {
+	delete(stub.PvtState[collection], key)
+	return nil
+}
+
+// 模拟CouchDB富查询，只支持链码中用到的等值、$gt/$gte/$lte/$ne/$or/$elemMatch条件和排序，书签为下一页的偏移量
+func (stub *identityStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *peer.QueryResponseMetadata, error) {
+	var q struct {
+		Selector map[string]interface{} `json:"selector"`
+		Sort     []map[string]string    `json:"sort"`
+	}
+	if err := json.Unmarshal([]byte(query), &q); err != nil {
+		return nil, nil, err
+	}
+	var matched []*queryresult.KV
+	var docs []map[string]interface{}
+	for key, value := range stub.State {
+		var doc map[string]interface{}
+		if json.Unmarshal(value, &doc) != nil || !matchSelector(doc, q.Selector) {
+			continue
+		}
+		matched = append(matched, &queryresult.KV{Key: key, Value: value})
+		docs = append(docs, doc)
+	}
+	sort.Sort(&sortedDocs{kvs: matched, docs: docs, sort: q.Sort})
+	offset := 0
+	if bookmark != "" {
+		offset, _ = strconv.Atoi(bookmark)
+	}
+	page := &kvIterator{}
+	metadata := &peer.QueryResponseMetadata{}
+	for i := offset; i < len(matched) && int32(len(page.kvs)) < pageSize; i++ {
+		page.kvs = append(page.kvs, matched[i])
+	}
+	if offset+len(page.kvs) < len(matched) {
+		metadata.Bookmark = strconv.Itoa(offset + len(page.kvs))
+	}
+	metadata.FetchedRecordsCount = int32(len(page.kvs))
+	return page, metadata, nil
+}
+
+func matchSelector(doc map[string]interface{}, selector map[string]interface{}) bool {
+	for field, condition := range selector {
+		if field == "$or" {
+			matched := false
+			for _, v := range condition.([]interface{}) {
+				matched = matched || matchSelector(doc, v.(map[string]interface{}))
+			}
+			if !matched {
+				return false
+			}
+			continue
+		}
+		value, ok := doc[field]
+		operators, isOperators := condition.(map[string]interface{})
+		if !isOperators {
+			if !ok || compareValue(value, condition) != 0 {
+				return false
+			}
+			continue
+		}
+		for operator, operand := range operators {
+			if !ok && operator != "$ne" {
+				return false
+			}
+			switch operator {
+			case "$gt":
+				if operand != nil && compareValue(value, operand) <= 0 {
+					return false
+				}
+			case "$gte":
+				if compareValue(value, operand) < 0 {
+					return false
+				}
+			case "$lte":
+				if compareValue(value, operand) > 0 {
+					return false
+				}
+			case "$ne":
+				if ok && compareValue(value, operand) == 0 {
+					return false
+				}
+			case "$elemMatch":
+				elements, _ := value.([]interface{})
+				matched := false
+				for _, v := range elements {
+					element, isDoc := v.(map[string]interface{})
+					matched = matched || isDoc && matchSelector(element, operand.(map[string]interface{}))
+				}
+				if !matched {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func compareValue(a, b interface{}) int {
+	switch a := a.(type) {
+	case float64:
+		b, _ := b.(float64)
+		if a < b {
+			return -1
+		} else if a > b {
+			return 1
+		}
+	case string:
+		return strings.Compare(a, b.(string))
+	case bool:
+		if a != b.(bool) {
+			return 1
+		}
+	}
+	return 0
+}
+
+type sortedDocs struct {
+	kvs  []*queryresult.KV
+	docs []map[string]interface{}
+	sort []map[string]string
+}
+
+func (s *sortedDocs) Len() int {
+	return len(s.kvs)
+}
+
+func (s *sortedDocs) Less(i, j int) bool {
+	for _, v := range s.sort {
+		for field, order := range v {
+			c := compareValue(s.docs[i][field], s.docs[j][field])
+			if order == "desc" {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+	}
+	return s.kvs[i].Key < s.kvs[j].Key
+}
+
+func (s *sortedDocs) Swap(i, j int) {
+	s.kvs[i], s.kvs[j] = s.kvs[j], s.kvs[i]
+	s.docs[i], s.docs[j] = s.docs[j], s.docs[i]
+}
+
+type kvIterator struct {
+	kvs []*queryresult.KV
+}
+
+func (iter *kvIterator) HasNext() bool {
+	return len(iter.kvs) > 0
+}
+
+func (iter *kvIterator) Next() (*queryresult.KV, error) {
+	kv := iter.kvs[0]
+	iter.kvs = iter.kvs[1:]
+	return kv, nil
+}
+
+func (iter *kvIterator) Close() error {
+	return nil
+}
+
+type historyIterator struct {
+	modifications []*queryresult.KeyModification
+}
+
+func (iter *historyIterator) HasNext() bool {
+	return len(iter.modifications) > 0
+}
+
+func (iter *historyIterator) Next() (*queryresult.KeyModification, error) {
+	modification := iter.modifications[0]
+	iter.modifications = iter.modifications[1:]
+	return modification, nil
+}
+
+func (iter *historyIterator) Close() error {
+	return nil
+}
+
+func (stub *identityStub) GetArgs() [][]byte {
+	return stub.args
+}
+
+func (stub *identityStub) GetStringArgs() []string {
+	var args []string
+	for _, arg := range stub.args {
+		args = append(args, string(arg))
+	}
+	return args
+}
+
+func (stub *identityStub) GetFunctionAndParameters() (string, []string) {
+	args := stub.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (stub *identityStub) GetCreator() ([]byte, error) {
+	return stub.creator, nil
+}
+
+func (stub *identityStub) invoke(txID string, caller string, args [][]byte, init bool) peer.Response {
+	stub.args = args
+	stub.creator = identityOf(caller)
+	stub.pending = nil
+	stub.event = nil
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	stub.TxTimestamp, _ = ptypes.TimestampProto(time.Now().Add(stub.clock))
+	var res peer.Response
+	if init {
+		res = stub.cc.Init(stub)
+	} else {
+		res = stub.cc.Invoke(stub)
+	}
+	//只有成功的交易才会提交到账本，记入历史
+	if res.Status == shim.OK {
+		if stub.history == nil {
+			stub.history = make(map[string][]*queryresult.KeyModification)
+		}
+		for _, kv := range stub.pending {
+			stub.history[kv.Key] = append(stub.history[kv.Key], &queryresult.KeyModification{
+				TxId:      txID,
+				Value:     kv.Value,
+				Timestamp: stub.TxTimestamp,
+				IsDelete:  kv.Value == nil,
+			})
+		}
+		if stub.event != nil {
+			stub.events = append(stub.events, stub.event)
+		}
+	}
+	return res
+}
+
+// 每个账户使用一张自签名证书作为身份，证书主题的CN为账户ID
+var identities = make(map[string][]byte)
+
+func identityOf(accountId string) []byte {
+	if creator, ok := identities[accountId]; ok {
+		return creator
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(int64(len(identities) + 1)),
+		Subject:      pkix.Name{CommonName: accountId, Organization: []string{"org1.blockchainrealestate.com"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		panic(err)
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   mspId,
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+	if err != nil {
+		panic(err)
+	}
+	identities[accountId] = creator
+	return creator
+}
+
+func subjectOf(accountId string) string {
+	return pkix.Name{CommonName: accountId, Organization: []string{"org1.blockchainrealestate.com"}}.String()
+}
+
+func hasRole(account lib.Account, role string) bool {
+	for _, r := range account.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func initTest(t *testing.T) *identityStub {
 	scc := new(BlockChainRealEstate)
-	stub := shim.NewMockStub("ex01", scc)
+	stub := &identityStub{MockStub: shim.NewMockStub("ex01", scc), cc: scc}
 	checkInit(t, stub, [][]byte{[]byte("init")})
+	//管理员为业主账户绑定身份
+	for _, ownerId := range ownerIds {
+		checkInvoke(t, stub, adminId, [][]byte{
+			[]byte("bindAccount"),
+			[]byte(adminId),
+			[]byte(ownerId),
+			[]byte(mspId),
+			[]byte(subjectOf(ownerId)),
+		})
+	}
 	return stub
 }
 
-func checkInit(t *testing.T, stub *shim.MockStub, args [][]byte) {
-	res := stub.MockInit("1", args)
+func checkInit(t *testing.T, stub *identityStub, args [][]byte) {
+	res := stub.invoke(nextTxID(), adminId, args, true)
 	if res.Status != shim.OK {
 		fmt.Println("Init failed", string(res.Message))
 		t.FailNow()
 	}
 }
 
-func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) peer.Response {
-	res := stub.MockInvoke("1", args)
+// 每次调用使用不同的交易ID，链码中的ID由交易ID生成
+var txCount int
+
+func nextTxID() string {
+	txCount++
+	return fmt.Sprintf("tx%d", txCount)
+}
+
+// putLegacyState 按升级前的格式直接写入账本，返回复合键
+func putLegacyState(stub *identityStub, objectType string, keys []string, value string) string {
+	key, _ := stub.CreateCompositeKey(objectType, keys)
+	stub.MockTransactionStart(nextTxID())
+	stub.PutState(key, []byte(value))
+	stub.MockTransactionEnd(stub.TxID)
+	return key
+}
+
+// legacyTime 升级前记录使用的本地时间格式(Asia/Shanghai)
+func legacyTime(offset time.Duration) string {
+	return time.Now().Add(offset).In(time.FixedZone("CST", 8*60*60)).Format("2006-01-02 15:04:05")
+}
+
+func checkInvoke(t *testing.T, stub *identityStub, caller string, args [][]byte) peer.Response {
+	res := stub.invoke(nextTxID(), caller, args, false)
 	if res.Status != shim.OK {
 		fmt.Println("Invoke", args, "failed", string(res.Message))
 		t.FailNow()
@@ -34,6 +469,24 @@ func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) peer.Response
 	return res
 }
 
+// 解析分页查询结果，记录写入records
+func unmarshalRecords(payload []byte, records interface{}) lib.Page {
+	page := lib.Page{Records: records}
+	json.Unmarshal(payload, &page)
+	return page
+}
+
+// 预期调用失败
+func checkInvokeError(t *testing.T, stub *identityStub, caller string, args [][]byte) peer.Response {
+	res := stub.invoke(nextTxID(), caller, args, false)
+	if res.Status == shim.OK {
+		fmt.Println("Invoke", args, "should fail")
+		t.FailNow()
+	}
+	fmt.Println(string(res.Message))
+	return res
+}
+
 // 测试链码初始化
 func TestBlockChainRealEstate_Init(t *testing.T) {
 	initTest(t)
@@ -43,23 +496,31 @@ func TestBlockChainRealEstate_Init(t *testing.T) {
 func Test_QueryAccountList(t *testing.T) {
 	stub := initTest(t)
 	fmt.Println(fmt.Sprintf("1、测试获取所有数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryAccountList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 		}).Payload)))
 	fmt.Println(fmt.Sprintf("2、测试获取多个数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryAccountList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 			[]byte("5feceb66ffc8"),
 			[]byte("6b86b273ff34"),
 		}).Payload)))
 	fmt.Println(fmt.Sprintf("3、测试获取单个数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryAccountList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 			[]byte("4e07408562be"),
 		}).Payload)))
 	fmt.Println(fmt.Sprintf("4、测试获取无效数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryAccountList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 			[]byte("0"),
 		}).Payload)))
 }
@@ -68,7 +529,7 @@ func Test_QueryAccountList(t *testing.T) {
 func Test_CreateRealEstate(t *testing.T) {
 	stub := initTest(t)
 	//成功
-	checkInvoke(t, stub, [][]byte{
+	checkInvoke(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("6b86b273ff34"), //所有者
@@ -77,7 +538,7 @@ func Test_CreateRealEstate(t *testing.T) {
 	})
 
 	//操作人权限不足
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, "6b86b273ff34", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("6b86b273ff34"), //操作人
 		[]byte("4e07408562be"), //所有者
@@ -86,7 +547,7 @@ func Test_CreateRealEstate(t *testing.T) {
 	})
 
 	//操作人应为管理员且与所有人不能相同
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("5feceb66ffc8"), //所有者
@@ -94,7 +555,7 @@ func Test_CreateRealEstate(t *testing.T) {
 		[]byte("30"),           //生活空间
 	})
 	//业主proprietor信息验证失败
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"),    //操作人
 		[]byte("6b86b273ff34555"), //所有者
@@ -102,14 +563,14 @@ func Test_CreateRealEstate(t *testing.T) {
 		[]byte("30"),              //生活空间
 	})
 	//参数个数不满足
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("6b86b273ff34"), //所有者
 		[]byte("50"),           //总面积
 	})
 	//参数格式转换出错
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("6b86b273ff34"), //所有者
@@ -118,33 +579,56 @@ func Test_CreateRealEstate(t *testing.T) {
 	})
 }
 
+// 测试不同背书节点对同一交易生成的ID一致
+func Test_DeterministicID(t *testing.T) {
+	args := [][]byte{
+		[]byte("createRealEstate"),
+		[]byte("5feceb66ffc8"), //操作人
+		[]byte("6b86b273ff34"), //所有者
+		[]byte("50"),           //总面积
+		[]byte("30"),           //生活空间
+	}
+	var realEstates [2]lib.RealEstate
+	for i := range realEstates {
+		stub := initTest(t)
+		res := stub.invoke("same-tx", adminId, args, false)
+		if res.Status != shim.OK {
+			t.Fatalf("createRealEstate failed: %s", res.Message)
+		}
+		json.Unmarshal(res.Payload, &realEstates[i])
+	}
+	if realEstates[0].RealEstateID == "" || realEstates[0].RealEstateID != realEstates[1].RealEstateID {
+		t.Fatalf("RealEstateID不一致: %s %s", realEstates[0].RealEstateID, realEstates[1].RealEstateID)
+	}
+}
+
 //手动创建一些房地产
-func checkCreateRealEstate(stub *shim.MockStub, t *testing.T) []lib.RealEstate {
+func checkCreateRealEstate(stub *identityStub, t *testing.T) []lib.RealEstate {
 	var realEstateList []lib.RealEstate
 	var realEstate lib.RealEstate
 	//成功
-	resp1 := checkInvoke(t, stub, [][]byte{
+	resp1 := checkInvoke(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("6b86b273ff34"), //所有者
 		[]byte("50"),           //总面积
 		[]byte("30"),           //生活空间
 	})
-	resp2 := checkInvoke(t, stub, [][]byte{
+	resp2 := checkInvoke(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("6b86b273ff34"), //所有者
 		[]byte("80"),           //总面积
 		[]byte("60.8"),         //生活空间
 	})
-	resp3 := checkInvoke(t, stub, [][]byte{
+	resp3 := checkInvoke(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("4e07408562be"), //所有者
 		[]byte("60"),           //总面积
 		[]byte("40"),           //生活空间
 	})
-	resp4 := checkInvoke(t, stub, [][]byte{
+	resp4 := checkInvoke(t, stub, "5feceb66ffc8", [][]byte{
 		[]byte("createRealEstate"),
 		[]byte("5feceb66ffc8"), //操作人
 		[]byte("ef2d127de37b"), //所有者
@@ -168,18 +652,24 @@ func Test_QueryRealEstateList(t *testing.T) {
 	realEstateList := checkCreateRealEstate(stub, t)
 
 	fmt.Println(fmt.Sprintf("1、测试获取所有数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 		}).Payload)))
 	fmt.Println(fmt.Sprintf("2、测试获取指定数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 			[]byte(realEstateList[0].Proprietor),
 			[]byte(realEstateList[0].RealEstateID),
 		}).Payload)))
 	fmt.Println(fmt.Sprintf("3、测试获取无效数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 			[]byte("0"),
 		}).Payload)))
 }
@@ -189,22 +679,27 @@ func Test_CreateSelling(t *testing.T) {
 	stub := initTest(t)
 	realEstateList := checkCreateRealEstate(stub, t)
 	//成功
-	checkInvoke(t, stub, [][]byte{
+	resp := checkInvoke(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
 		[]byte("50"),                           //价格
 		[]byte("30"),                           //智能合约的有效期(单位为天)
 	})
+	var selling lib.Selling
+	json.Unmarshal(resp.Payload, &selling)
+	if createTime, err := time.Parse(time.RFC3339, selling.CreateTime); err != nil || createTime.Location() != time.UTC {
+		t.Fatalf("CreateTime不是UTC的RFC3339格式: %s", selling.CreateTime)
+	}
 	//验证销售对象objectOfSale属于卖家seller失败
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, realEstateList[2].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[2].Proprietor),   //卖家(卖家AccountId)
 		[]byte("50"),                           //价格
 		[]byte("30"),                           //智能合约的有效期(单位为天)
 	})
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte("123"),                        //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor), //卖家(卖家AccountId)
@@ -212,13 +707,13 @@ func Test_CreateSelling(t *testing.T) {
 		[]byte("30"),                         //智能合约的有效期(单位为天)
 	})
 	//参数错误
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
 		[]byte("50"),                           //价格
 	})
-	checkInvoke(t, stub, [][]byte{
+	checkInvokeError(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte(""),                           //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor), //卖家(卖家AccountId)
@@ -232,14 +727,14 @@ func Test_QuerySellingList(t *testing.T) {
 	stub := initTest(t)
 	realEstateList := checkCreateRealEstate(stub, t)
 	//先发起
-	fmt.Println(fmt.Sprintf("发起\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("发起\n%s", string(checkInvoke(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
 		[]byte("500000"),                       //价格
 		[]byte("30"),                           //智能合约的有效期(单位为天)
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("发起\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("发起\n%s", string(checkInvoke(t, stub, realEstateList[2].Proprietor, [][]byte{
 		[]byte("createSelling"),
 		[]byte(realEstateList[2].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[2].Proprietor),   //卖家(卖家AccountId)
@@ -247,88 +742,120 @@ func Test_QuerySellingList(t *testing.T) {
 		[]byte("40"),                           //智能合约的有效期(单位为天)
 	}).Payload)))
 	//查询成功
-	fmt.Println(fmt.Sprintf("1、查询所有\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("1、查询所有\n%s", string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("querySellingList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("2、查询指定%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("2、查询指定%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("querySellingList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[0].Proprietor),
 	}).Payload)))
 	//购买
-	fmt.Println(fmt.Sprintf("3、购买前先查询%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("3、购买前先查询%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("4、开始购买\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("4、开始购买\n%s", string(checkInvoke(t, stub, realEstateList[2].Proprietor, [][]byte{
 		[]byte("createSellingByBuy"),
 		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
 		[]byte(realEstateList[2].Proprietor),   //买家(买家AccountId)
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》购买后再次查询%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》购买后再次查询%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》卖家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》卖家查询购买成功信息\n%s", string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("querySellingList"),
+		[]byte("100"),                        //pageSize
+		[]byte(""),                           //bookmark
 		[]byte(realEstateList[0].Proprietor), //买家(买家AccountId)
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》买家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》买家查询购买成功信息\n%s", string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("querySellingListByBuyer"),
+		[]byte("100"),                        //pageSize
+		[]byte(""),                           //bookmark
 		[]byte(realEstateList[2].Proprietor), //买家(买家AccountId)
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前卖家%s的账户余额\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款前卖家%s的账户余额\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[0].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前买家%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款前买家%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前卖家%s的房产信息\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款前卖家%s的房产信息\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryRealEstateList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[0].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前买家%s的房产信息\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款前买家%s的房产信息\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryRealEstateList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》卖家确认收款\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》卖家确认收款\n%s", string(checkInvoke(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("updateSelling"),
 		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
 		[]byte(realEstateList[2].Proprietor),   //买家(买家AccountId)
 		[]byte("done"),                         //确认收款
 	}).Payload)))
-	//fmt.Println(fmt.Sprintf("》卖家取消收款\n%s", string(checkInvoke(t, stub, [][]byte{
+	//fmt.Println(fmt.Sprintf("》卖家取消收款\n%s", string(checkInvoke(t, stub, realEstateList[0].Proprietor, [][]byte{
 	//	[]byte("updateSelling"),
 	//	[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
 	//	[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
 	//	[]byte(realEstateList[2].Proprietor),   //买家(买家AccountId)
 	//	[]byte("cancelled"),                    //取消收款
 	//}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后卖家%s的账户余额\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款后卖家%s的账户余额\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[0].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后买家%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款后买家%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后卖家%s的房产信息\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款后卖家%s的房产信息\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryRealEstateList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[0].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后买家%s的房产信息\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》确认收款后买家%s的房产信息\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryRealEstateList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》卖家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》卖家查询购买成功信息\n%s", string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("querySellingList"),
+		[]byte("100"),                        //pageSize
+		[]byte(""),                           //bookmark
 		[]byte(realEstateList[0].Proprietor), //买家(买家AccountId)
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("》买家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("》买家查询购买成功信息\n%s", string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("querySellingListByBuyer"),
+		[]byte("100"),                        //pageSize
+		[]byte(""),                           //bookmark
 		[]byte(realEstateList[2].Proprietor), //买家(买家AccountId)
 	}).Payload)))
 }
@@ -339,42 +866,53 @@ func Test_Donating(t *testing.T) {
 	realEstateList := checkCreateRealEstate(stub, t)
 
 	fmt.Println(fmt.Sprintf("获取房地产信息\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 		}).Payload)))
 	//先发起
-	fmt.Println(fmt.Sprintf("发起捐赠\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("发起捐赠\n%s", string(checkInvoke(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("createDonating"),
 		[]byte(realEstateList[0].RealEstateID),
 		[]byte(realEstateList[0].Proprietor),
 		[]byte(realEstateList[2].Proprietor),
+		[]byte("30"),
 	}).Payload)))
 
 	fmt.Println(fmt.Sprintf("获取房地产信息\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 		}).Payload)))
 
-	fmt.Println(fmt.Sprintf("1、查询所有\n%s", string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("1、查询所有\n%s", string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryDonatingList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("2、查询指定%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("2、查询指定%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryDonatingList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
-	fmt.Println(fmt.Sprintf("3、查询指定受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("3、查询指定受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, adminId, [][]byte{
 		[]byte("queryDonatingListByGrantee"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
 		[]byte(realEstateList[2].Proprietor),
 	}).Payload)))
 
-	//fmt.Println(fmt.Sprintf("4、接受受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	//fmt.Println(fmt.Sprintf("4、接受受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, realEstateList[2].Proprietor, [][]byte{
 	//	[]byte("updateDonating"),
 	//	[]byte(realEstateList[0].RealEstateID),
 	//	[]byte(realEstateList[0].Proprietor),
 	//	[]byte(realEstateList[2].Proprietor),
 	//	[]byte("done"),
 	//}).Payload)))
-	fmt.Println(fmt.Sprintf("4、取消受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
+	fmt.Println(fmt.Sprintf("4、取消受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, realEstateList[0].Proprietor, [][]byte{
 		[]byte("updateDonating"),
 		[]byte(realEstateList[0].RealEstateID),
 		[]byte(realEstateList[0].Proprietor),
@@ -383,7 +921,2550 @@ func Test_Donating(t *testing.T) {
 	}).Payload)))
 
 	fmt.Println(fmt.Sprintf("获取房地产信息\n%s",
-		string(checkInvoke(t, stub, [][]byte{
+		string(checkInvoke(t, stub, adminId, [][]byte{
 			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
 		}).Payload)))
 }
+
+// 测试交易提交者必须是所操作账户的持有人
+func Test_AccountIdentity(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+
+	//非管理员不能绑定身份
+	checkInvokeError(t, stub, seller, [][]byte{
+		[]byte("bindAccount"),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte(mspId),
+		[]byte(subjectOf("someone")),
+	})
+	//一个身份只能绑定一个账户
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("bindAccount"),
+		[]byte(adminId),
+		[]byte(buyer),
+		[]byte(mspId),
+		[]byte(subjectOf(seller)),
+	})
+	//冒充卖家发起销售
+	checkInvokeError(t, stub, buyer, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("500000"),
+		[]byte("30"),
+	})
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("500000"),
+		[]byte("30"),
+	})
+	//冒充买家购买
+	checkInvokeError(t, stub, seller, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	})
+	checkInvoke(t, stub, buyer, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	})
+	//只有卖家可以确认收款
+	checkInvokeError(t, stub, buyer, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+
+	//只有受赠人可以确认接收
+	donor := realEstateList[3].Proprietor
+	checkInvoke(t, stub, donor, [][]byte{
+		[]byte("createDonating"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(donor),
+		[]byte(buyer),
+		[]byte("30"),
+	})
+	checkInvokeError(t, stub, donor, [][]byte{
+		[]byte("updateDonating"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(donor),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+	checkInvoke(t, stub, buyer, [][]byte{
+		[]byte("updateDonating"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(donor),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+}
+
+// 测试基于角色的权限校验
+func Test_Authorization(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	auditor := ownerIds[1]
+
+	//业主不能登记房地产
+	res := checkInvokeError(t, stub, ownerIds[0], [][]byte{
+		[]byte("createRealEstate"),
+		[]byte(ownerIds[0]),
+		[]byte(ownerIds[2]),
+		[]byte("50"),
+		[]byte("30"),
+	})
+	if !strings.Contains(res.Message, "permission denied") {
+		t.Fatalf("权限不足的错误信息不明确: %s", res.Message)
+	}
+	//未绑定账户的身份不能调用任何功能
+	checkInvokeError(t, stub, "stranger", [][]byte{
+		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+	})
+	//登记员才能修改角色
+	checkInvokeError(t, stub, auditor, [][]byte{
+		[]byte("updateAccountRoles"),
+		[]byte(auditor),
+		[]byte(auditor),
+		[]byte("registrar"),
+	})
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("updateAccountRoles"),
+		[]byte(adminId),
+		[]byte(auditor),
+		[]byte("superuser"),
+	})
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("updateAccountRoles"),
+		[]byte(adminId),
+		[]byte(auditor),
+		[]byte("auditor"),
+	})
+	//审计员只能查询
+	checkInvoke(t, stub, auditor, [][]byte{
+		[]byte("querySellingList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+	})
+	checkInvokeError(t, stub, auditor, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[0].Proprietor),
+		[]byte(auditor),
+	})
+	//非业主不能接收捐赠
+	checkInvokeError(t, stub, realEstateList[0].Proprietor, [][]byte{
+		[]byte("createDonating"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[0].Proprietor),
+		[]byte(auditor),
+		[]byte("30"),
+	})
+
+	//买家以账本中的销售为准，其他业主不能冒充买家取消销售
+	seller, other, buyer := realEstateList[1].Proprietor, realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	updateSelling := func(buyer string, status string) [][]byte {
+		return [][]byte{[]byte("updateSelling"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(buyer), []byte(status)}
+	}
+	checkInvoke(t, stub, seller, [][]byte{[]byte("createSelling"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte("50"), []byte("30")})
+	checkInvokeError(t, stub, other, updateSelling(other, "cancelled"))
+	checkInvoke(t, stub, buyer, [][]byte{[]byte("createSellingByBuy"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(buyer)})
+	checkInvokeError(t, stub, other, updateSelling(other, "cancelled"))
+	checkInvokeError(t, stub, seller, updateSelling(other, "cancelled"))
+	checkInvoke(t, stub, buyer, updateSelling(buyer, "cancelled"))
+}
+
+// 测试账户的创建、修改、冻结和注销
+func Test_AccountLifecycle(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+
+	//创建账户
+	var account lib.Account
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("createAccount"),
+		[]byte(adminId),
+		[]byte("⑥号业主"),
+		[]byte(mspId),
+		[]byte(subjectOf("newUser")),
+	}).Payload, &account)
+	if account.AccountId == "" || account.Status != lib.AccountStatusConstant()["active"] || !hasRole(account, "owner") {
+		t.Fatalf("创建的账户信息有误: %+v", account)
+	}
+	identities[account.AccountId] = identityOf("newUser")
+	//身份不能重复绑定
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("createAccount"),
+		[]byte(adminId),
+		[]byte("⑦号业主"),
+		[]byte(mspId),
+		[]byte(subjectOf("newUser")),
+	})
+	//只能修改自己的账户名
+	checkInvoke(t, stub, account.AccountId, [][]byte{
+		[]byte("updateAccount"),
+		[]byte(account.AccountId),
+		[]byte(account.AccountId),
+		[]byte("⑥号业主(新)"),
+	})
+	checkInvokeError(t, stub, account.AccountId, [][]byte{
+		[]byte("updateAccount"),
+		[]byte(account.AccountId),
+		[]byte(seller),
+		[]byte("改名"),
+	})
+
+	//冻结后不能出售和受赠
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("freezeAccount"),
+		[]byte(adminId),
+		[]byte(seller),
+		[]byte("frozen"),
+	})
+	checkInvokeError(t, stub, seller, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("500000"),
+		[]byte("30"),
+	})
+	checkInvokeError(t, stub, realEstateList[2].Proprietor, [][]byte{
+		[]byte("createDonating"),
+		[]byte(realEstateList[2].RealEstateID),
+		[]byte(realEstateList[2].Proprietor),
+		[]byte(seller),
+		[]byte("30"),
+	})
+	var accountList []lib.Account
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(seller),
+	}).Payload, &accountList)
+	if len(accountList) != 1 || accountList[0].Status != lib.AccountStatusConstant()["frozen"] {
+		t.Fatalf("账户状态应为已冻结: %+v", accountList)
+	}
+	//解冻后恢复
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("freezeAccount"),
+		[]byte(adminId),
+		[]byte(seller),
+		[]byte("active"),
+	})
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("500000"),
+		[]byte("30"),
+	})
+
+	//有余额或房产的账户不能注销
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("closeAccount"),
+		[]byte(adminId),
+		[]byte(seller),
+	})
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("closeAccount"),
+		[]byte(adminId),
+		[]byte(account.AccountId),
+	})
+	//注销后身份解除绑定
+	checkInvokeError(t, stub, account.AccountId, [][]byte{
+		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+	})
+}
+
+// 测试存款、取款、转账和账户流水
+func Test_Funds(t *testing.T) {
+	stub := initTest(t)
+	from := ownerIds[0]
+	to := ownerIds[1]
+
+	//只有银行可以存款
+	checkInvokeError(t, stub, from, [][]byte{
+		[]byte("deposit"),
+		[]byte(from),
+		[]byte(from),
+		[]byte("100"),
+	})
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("deposit"),
+		[]byte(adminId),
+		[]byte(from),
+		[]byte("1000"),
+	})
+	//余额不足不能取款
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("withdraw"),
+		[]byte(adminId),
+		[]byte(from),
+		[]byte("9000000"),
+	})
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("withdraw"),
+		[]byte(adminId),
+		[]byte(from),
+		[]byte("500"),
+	})
+	//冒充转出账户
+	checkInvokeError(t, stub, to, [][]byte{
+		[]byte("transfer"),
+		[]byte(from),
+		[]byte(to),
+		[]byte("100"),
+	})
+	checkInvokeError(t, stub, from, [][]byte{
+		[]byte("transfer"),
+		[]byte(from),
+		[]byte(to),
+		[]byte("-100"),
+	})
+	checkInvoke(t, stub, from, [][]byte{
+		[]byte("transfer"),
+		[]byte(from),
+		[]byte(to),
+		[]byte("300"),
+	})
+
+	var accountList []lib.Account
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(from),
+		[]byte(to),
+	}).Payload, &accountList)
+	if accountList[0].Balance != 5000200*lib.Yuan || accountList[1].Balance != 5000300*lib.Yuan {
+		t.Fatalf("转账后余额有误: %+v", accountList)
+	}
+
+	//其他业主不能查询流水
+	checkInvokeError(t, stub, to, [][]byte{
+		[]byte("queryAccountStatement"),
+		[]byte(from),
+	})
+	var journalList []lib.Journal
+	json.Unmarshal(checkInvoke(t, stub, from, [][]byte{
+		[]byte("queryAccountStatement"),
+		[]byte(from),
+	}).Payload, &journalList)
+	if len(journalList) != 3 {
+		t.Fatalf("流水条数有误: %+v", journalList)
+	}
+	last := journalList[2]
+	if last.JournalType != lib.JournalTypeConstant()["transferOut"] || last.Amount != -300*lib.Yuan || last.Balance != 5000200*lib.Yuan || last.TxID == "" || last.Counterparty != to {
+		t.Fatalf("转账流水有误: %+v", last)
+	}
+}
+
+// 测试金额精确到分，以及旧账本浮点数金额的迁移
+func Test_Amount(t *testing.T) {
+	stub := initTest(t)
+	from := ownerIds[0]
+	to := ownerIds[1]
+
+	//超过两位小数或格式错误的金额
+	for _, amount := range []string{"0.001", "1e3", "0", "-0.01", "1."} {
+		checkInvokeError(t, stub, from, [][]byte{
+			[]byte("transfer"),
+			[]byte(from),
+			[]byte(to),
+			[]byte(amount),
+		})
+	}
+	//多次小额转账不累计舍入误差
+	for i := 0; i < 10; i++ {
+		checkInvoke(t, stub, from, [][]byte{
+			[]byte("transfer"),
+			[]byte(from),
+			[]byte(to),
+			[]byte("0.1"),
+		})
+	}
+	var accountList []lib.Account
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryAccountList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(from),
+		[]byte(to),
+	}).Payload, &accountList)
+	if accountList[0].Balance != 4999999*lib.Yuan || accountList[1].Balance != 5000001*lib.Yuan {
+		t.Fatalf("转账后余额有误: %+v", accountList)
+	}
+
+	//旧账本中的金额以浮点数(元)存储
+	key, _ := stub.CreateCompositeKey(lib.AccountKey, []string{from})
+	stub.MockTransactionStart(nextTxID())
+	stub.PutState(key, []byte(`{"accountId":"`+from+`","userName":"①号业主","balance":1234.5,"mspId":"`+mspId+`","subject":"`+subjectOf(from)+`","roles":["owner"],"status":"正常"}`))
+	stub.MockTransactionEnd(stub.TxID)
+	checkInvokeError(t, stub, from, [][]byte{
+		[]byte("migrateAmounts"),
+		[]byte(from),
+	})
+	var migrated map[string]int
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("migrateAmounts"),
+		[]byte(adminId),
+	}).Payload, &migrated)
+	if migrated[lib.AccountKey] != 1 {
+		t.Fatalf("迁移条数有误: %+v", migrated)
+	}
+	if !strings.Contains(string(stub.State[key]), `"balance":"1234.50"`) {
+		t.Fatalf("迁移后金额格式有误: %s", stub.State[key])
+	}
+	//重复执行不会再次写入
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("migrateAmounts"),
+		[]byte(adminId),
+	}).Payload, &migrated)
+	if migrated[lib.AccountKey] != 0 {
+		t.Fatalf("重复迁移条数有误: %+v", migrated)
+	}
+}
+
+// 测试升级前以[Proprietor, RealEstateID]为复合键的房地产迁移
+func Test_MigrateRealEstateKeys(t *testing.T) {
+	stub := initTest(t)
+	proprietor := ownerIds[0]
+	realEstateId := "legacy000001"
+	oldKey, _ := stub.CreateCompositeKey(lib.RealEstateKey, []string{proprietor, realEstateId})
+	stub.MockTransactionStart(nextTxID())
+	stub.PutState(oldKey, []byte(`{"realEstateId":"`+realEstateId+`","proprietor":"`+proprietor+`","encumbrance":false,"totalArea":120,"livingSpace":100}`))
+	stub.MockTransactionEnd(stub.TxID)
+
+	//迁移复合键之前不能迁移金额，非登记员不能迁移
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("migrateAmounts"),
+		[]byte(adminId),
+	})
+	checkInvokeError(t, stub, proprietor, [][]byte{
+		[]byte("migrateRealEstateKeys"),
+		[]byte(proprietor),
+	})
+	var migrated []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("migrateRealEstateKeys"),
+		[]byte(adminId),
+	}).Payload, &migrated)
+	if len(migrated) != 1 || migrated[0].RealEstateID != realEstateId {
+		t.Fatalf("迁移的房地产有误: %+v", migrated)
+	}
+	if _, ok := stub.State[oldKey]; ok {
+		t.Fatalf("旧的复合键没有删除")
+	}
+	//迁移后可以按RealEstateID和所有者索引查询
+	var realEstateList []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstate"),
+		[]byte(realEstateId),
+	}).Payload, &realEstateList)
+	if len(realEstateList) != 1 || realEstateList[0].Proprietor != proprietor || realEstateList[0].CauseType != lib.RealEstateCauseConstant()["migrate"] {
+		t.Fatalf("迁移后的房地产有误: %+v", realEstateList)
+	}
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstateList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(proprietor),
+	}).Payload, &realEstateList)
+	if len(realEstateList) != 1 || realEstateList[0].RealEstateID != realEstateId {
+		t.Fatalf("所有者索引有误: %+v", realEstateList)
+	}
+	//迁移后的房地产可以正常出售
+	checkInvoke(t, stub, proprietor, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateId),
+		[]byte(proprietor),
+		[]byte("500000"),
+		[]byte("30"),
+	})
+	//重复执行不会再次迁移，之后可以迁移金额
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("migrateRealEstateKeys"),
+		[]byte(adminId),
+	}).Payload, &migrated)
+	if len(migrated) != 0 {
+		t.Fatalf("重复迁移的房地产有误: %+v", migrated)
+	}
+	checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("migrateAmounts"),
+		[]byte(adminId),
+	})
+}
+
+// 测试升级前以本地时间格式存储创建时间的销售按有效期过期
+func Test_LegacyTime(t *testing.T) {
+	stub := initTest(t)
+	seller := ownerIds[0]
+	for i, createTime := range []string{legacyTime(-40 * 24 * time.Hour), legacyTime(-10 * 24 * time.Hour)} {
+		realEstateId := fmt.Sprintf("legacy00000%d", i)
+		putLegacyState(stub, lib.RealEstateKey, []string{seller, realEstateId},
+			`{"realEstateId":"`+realEstateId+`","proprietor":"`+seller+`","encumbrance":true,"totalArea":120,"livingSpace":100}`)
+		putLegacyState(stub, lib.SellingKey, []string{seller, realEstateId},
+			`{"objectOfSale":"`+realEstateId+`","seller":"`+seller+`","buyer":"","price":500000,"createTime":"`+createTime+`","salePeriod":30,"sellingStatus":"销售中"}`)
+	}
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("migrateRealEstateKeys"), []byte(adminId)})
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("migratePrivateData"), []byte(adminId)})
+
+	//40天前创建的销售已过期，10天前创建的仍在有效期内
+	var expiredList []lib.Selling
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireSellings")}).Payload, &expiredList)
+	if len(expiredList) != 1 || expiredList[0].ObjectOfSale != "legacy000000" {
+		t.Fatalf("升级前的销售过期有误: %+v", expiredList)
+	}
+	var realEstateList []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstate"),
+		[]byte("legacy000000"),
+		[]byte("legacy000001"),
+	}).Payload, &realEstateList)
+	if len(realEstateList) != 2 || realEstateList[0].Encumbrance || !realEstateList[1].Encumbrance {
+		t.Fatalf("过期后担保状态有误: %+v", realEstateList)
+	}
+	//卖家可以取消仍在有效期内的旧销售
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("updateSelling"),
+		[]byte("legacy000001"),
+		[]byte(seller),
+		[]byte(""),
+		[]byte("cancelled"),
+	})
+}
+
+// 测试房地产历史版本和所有权链
+func Test_RealEstateHistory(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	grantee := ownerIds[1]
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("500000"),
+		[]byte("30"),
+	})
+	checkInvoke(t, stub, buyer, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+	})
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("updateSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte(buyer),
+		[]byte("done"),
+	})
+	realEstateId := realEstateList[0].RealEstateID
+	checkInvoke(t, stub, buyer, [][]byte{
+		[]byte("createDonating"),
+		[]byte(realEstateId),
+		[]byte(buyer),
+		[]byte(grantee),
+		[]byte("30"),
+	})
+	checkInvoke(t, stub, grantee, [][]byte{
+		[]byte("updateDonating"),
+		[]byte(realEstateId),
+		[]byte(buyer),
+		[]byte(grantee),
+		[]byte("done"),
+	})
+	//过户后RealEstateID不变，可以只根据ID查询，也可以按所有者查询
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstate"),
+		[]byte(realEstateId),
+	}).Payload, &realEstates)
+	if len(realEstates) != 1 || realEstates[0].Proprietor != grantee || realEstates[0].Encumbrance {
+		t.Fatalf("受赠后房产信息有误: %+v", realEstates)
+	}
+	for proprietor, count := range map[string]int{seller: 1, buyer: 1, grantee: 1} {
+		var proprietorList []lib.RealEstate
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+			[]byte("queryRealEstateList"),
+			[]byte("100"), //pageSize
+			[]byte(""),    //bookmark
+			[]byte(proprietor),
+		}).Payload, &proprietorList)
+		if len(proprietorList) != count {
+			t.Fatalf("%s名下房产有误: %+v", proprietor, proprietorList)
+		}
+	}
+
+	checkInvokeError(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstateHistory"),
+		[]byte("123"),
+	})
+	var historyList []lib.RealEstateHistory
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstateHistory"),
+		[]byte(realEstateId),
+	}).Payload, &historyList)
+	fmt.Println(fmt.Sprintf("房产历史\n%+v", historyList))
+	//登记、发起出售、完成出售、发起捐赠、完成捐赠
+	proprietors := []string{seller, seller, buyer, buyer, grantee}
+	causes := []string{"create", "selling", "selling", "donating", "donating"}
+	if len(historyList) != len(proprietors) {
+		t.Fatalf("历史版本数有误: %+v", historyList)
+	}
+	for i, history := range historyList {
+		if history.RealEstate.Proprietor != proprietors[i] || history.RealEstate.CauseType != lib.RealEstateCauseConstant()[causes[i]] || history.TxID == "" || history.Timestamp == "" {
+			t.Fatalf("第%d个历史版本有误: %+v", i, history)
+		}
+	}
+	if historyList[0].Selling != nil || historyList[0].Donating != nil {
+		t.Fatalf("登记版本不应关联业务: %+v", historyList[0])
+	}
+	//历史版本关联的是账本中的公开记录，不含买家
+	if historyList[2].Selling == nil || historyList[2].Selling.SellingStatus != lib.SellingStatusConstant()["done"] || historyList[2].Selling.Buyer != "" {
+		t.Fatalf("完成出售的版本关联的销售有误: %+v", historyList[2].Selling)
+	}
+	if historyList[3].Donating == nil || historyList[3].Donating.DonatingStatus != lib.DonatingStatusConstant()["donatingStart"] {
+		t.Fatalf("发起捐赠的版本关联的捐赠有误: %+v", historyList[3].Donating)
+	}
+}
+
+// 测试列表分页查询
+func Test_Pagination(t *testing.T) {
+	stub := initTest(t)
+	checkCreateRealEstate(stub, t)
+
+	//pageSize超出范围或缺少分页参数
+	for _, args := range [][][]byte{
+		{[]byte("queryRealEstateList"), []byte("0"), []byte("")},
+		{[]byte("queryRealEstateList"), []byte("101"), []byte("")},
+		{[]byte("queryRealEstateList")},
+	} {
+		checkInvokeError(t, stub, adminId, args)
+	}
+	//每页3条，共4条
+	var realEstateIds []string
+	bookmark := ""
+	for pages := 0; ; pages++ {
+		var realEstateList []lib.RealEstate
+		page := unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+			[]byte("queryRealEstateList"),
+			[]byte("3"),
+			[]byte(bookmark),
+		}).Payload, &realEstateList)
+		for _, v := range realEstateList {
+			realEstateIds = append(realEstateIds, v.RealEstateID)
+		}
+		if !page.HasMore {
+			if pages != 1 || len(realEstateList) != 1 || page.Bookmark != "" {
+				t.Fatalf("最后一页有误: %+v", page)
+			}
+			break
+		}
+		if len(realEstateList) != 3 || page.Bookmark == "" {
+			t.Fatalf("第一页有误: %+v", page)
+		}
+		bookmark = page.Bookmark
+	}
+	if len(realEstateIds) != 4 {
+		t.Fatalf("分页查询结果有误: %v", realEstateIds)
+	}
+	//刚好取完时没有下一页
+	var accountList []lib.Account
+	page := unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryAccountList"),
+		[]byte("6"),
+		[]byte(""),
+	}).Payload, &accountList)
+	if len(accountList) != 6 || page.HasMore {
+		t.Fatalf("账户分页有误: %+v", page)
+	}
+	//按所有者分页
+	var proprietorList []lib.RealEstate
+	page = unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstateList"),
+		[]byte("1"),
+		[]byte(""),
+		[]byte(ownerIds[0]),
+	}).Payload, &proprietorList)
+	if len(proprietorList) != 1 || !page.HasMore || proprietorList[0].Proprietor != ownerIds[0] {
+		t.Fatalf("按所有者分页有误: %+v", page)
+	}
+}
+
+// 测试按结构化条件富查询销售和房地产
+func Test_Search(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	var createdList []lib.Selling
+	for _, v := range []struct {
+		index int
+		price string
+	}{{0, "50"}, {2, "120.5"}, {3, "80"}} {
+		var selling lib.Selling
+		json.Unmarshal(checkInvoke(t, stub, realEstateList[v.index].Proprietor, [][]byte{
+			[]byte("createSelling"),
+			[]byte(realEstateList[v.index].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(realEstateList[v.index].Proprietor),   //卖家(卖家AccountId)
+			[]byte(v.price),                              //价格
+			[]byte("30"),                                 //智能合约的有效期(单位为天)
+		}).Payload, &selling)
+		createdList = append(createdList, selling)
+	}
+	searchSellings := func(filter string, pageSize string, bookmark string) ([]lib.Selling, lib.Page) {
+		var sellingList []lib.Selling
+		page := unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+			[]byte("searchSellings"),
+			[]byte(pageSize), //pageSize
+			[]byte(bookmark), //bookmark
+			[]byte(filter),   //filter
+		}).Payload, &sellingList)
+		return sellingList, page
+	}
+	//不指定条件，默认按创建时间升序
+	sellingList, _ := searchSellings("", "100", "")
+	if len(sellingList) != 3 || sellingList[0].ObjectOfSale != createdList[0].ObjectOfSale || sellingList[2].ObjectOfSale != createdList[2].ObjectOfSale {
+		t.Fatalf("查询全部销售有误: %+v", sellingList)
+	}
+	//价格范围，按价格降序
+	sellingList, _ = searchSellings(`{"minPrice":"60","maxPrice":"200.00","sortBy":"price","sortOrder":"desc"}`, "100", "")
+	if len(sellingList) != 2 || sellingList[0].Price != 12050 || sellingList[1].Price != 80*lib.Yuan {
+		t.Fatalf("按价格范围查询有误: %+v", sellingList)
+	}
+	//销售状态和创建时间
+	sellingList, _ = searchSellings(`{"sellingStatus":"saleStart","createdFrom":"`+createdList[1].CreateTime+`"}`, "100", "")
+	if len(sellingList) != 2 || sellingList[0].ObjectOfSale != createdList[1].ObjectOfSale {
+		t.Fatalf("按状态和创建时间查询有误: %+v", sellingList)
+	}
+	if sellingList, _ = searchSellings(`{"sellingStatus":"done"}`, "100", ""); len(sellingList) != 0 {
+		t.Fatalf("按状态查询有误: %+v", sellingList)
+	}
+	//分页
+	sellingList, page := searchSellings("", "2", "")
+	if len(sellingList) != 2 || !page.HasMore {
+		t.Fatalf("富查询分页有误: %+v", page)
+	}
+	if sellingList, page = searchSellings("", "2", page.Bookmark); len(sellingList) != 1 || page.HasMore {
+		t.Fatalf("富查询下一页有误: %+v", page)
+	}
+	//条件错误
+	for _, filter := range []string{
+		`{"sellingStatus":"unknown"}`,
+		`{"sortBy":"seller"}`,
+		`{"sortOrder":"up"}`,
+		`{"minPrice":"100","maxPrice":"50"}`,
+		`{"minPrice":"-1"}`,
+		`{"createdFrom":"2020-01-01"}`,
+		`{"price":"50"}`,
+	} {
+		checkInvokeError(t, stub, adminId, [][]byte{[]byte("searchSellings"), []byte("100"), []byte(""), []byte(filter)})
+	}
+	checkInvokeError(t, stub, adminId, [][]byte{[]byte("searchSellings"), []byte("100"), []byte("")})
+	//房地产按面积范围查询
+	var searchedList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("searchRealEstates"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(`{"minTotalArea":60,"sortBy":"totalArea","sortOrder":"desc"}`),
+	}).Payload, &searchedList)
+	if len(searchedList) != 3 || searchedList[0].TotalArea != 80 || searchedList[2].TotalArea != 60 {
+		t.Fatalf("按总面积查询有误: %+v", searchedList)
+	}
+	searchedList = nil
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("searchRealEstates"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(`{"minLivingSpace":35,"maxLivingSpace":60,"encumbrance":true,"sortBy":"livingSpace"}`),
+	}).Payload, &searchedList)
+	if len(searchedList) != 2 || searchedList[0].LivingSpace != 40 || searchedList[1].LivingSpace != 60 {
+		t.Fatalf("按生活空间和担保状态查询有误: %+v", searchedList)
+	}
+}
+
+// 测试状态变更后设置的链码事件
+func Test_Events(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	updateSelling := func(caller string, realEstate lib.RealEstate, buyer string, status string) {
+		checkInvoke(t, stub, caller, [][]byte{
+			[]byte("updateSelling"),
+			[]byte(realEstate.RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(realEstate.Proprietor),   //卖家(卖家AccountId)
+			[]byte(buyer),                   //买家(买家AccountId)
+			[]byte(status),                  //状态
+		})
+	}
+	for _, realEstate := range realEstateList[:2] {
+		checkInvoke(t, stub, seller, [][]byte{
+			[]byte("createSelling"),
+			[]byte(realEstate.RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(seller),                  //卖家(卖家AccountId)
+			[]byte("50"),                    //价格
+			[]byte("30"),                    //智能合约的有效期(单位为天)
+		})
+		checkInvoke(t, stub, buyer, [][]byte{
+			[]byte("createSellingByBuy"),
+			[]byte(realEstate.RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(seller),                  //卖家(卖家AccountId)
+			[]byte(buyer),                   //买家(买家AccountId)
+		})
+	}
+	updateSelling(seller, realEstateList[0], buyer, "done")
+	updateSelling(buyer, realEstateList[1], buyer, "cancelled")
+	//失败的交易没有事件
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("updateSelling"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(buyer), []byte("done")})
+	checkInvoke(t, stub, seller, [][]byte{[]byte("createDonating"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(buyer), []byte("30")})
+	checkInvoke(t, stub, buyer, [][]byte{[]byte("updateDonating"), []byte(realEstateList[1].RealEstateID), []byte(seller), []byte(buyer), []byte("done")})
+
+	expected := []string{
+		"realEstateCreated", "realEstateCreated", "realEstateCreated", "realEstateCreated",
+		"sellingCreated", "sellingPurchased", "sellingCreated", "sellingPurchased",
+		"sellingDone", "sellingCancelled", "donatingCreated", "donatingDone",
+	}
+	if len(stub.events) != len(expected) {
+		t.Fatalf("事件个数有误: %d", len(stub.events))
+	}
+	for i, event := range stub.events {
+		if event.EventName != expected[i] {
+			t.Fatalf("第%d个事件应为%s，实际为%s", i, expected[i], event.EventName)
+		}
+		if _, ok := lib.EventConstant()[event.EventName]; !ok {
+			t.Fatalf("事件%s未定义", event.EventName)
+		}
+	}
+	var realEstateEvent lib.RealEstateEvent
+	json.Unmarshal(stub.events[0].Payload, &realEstateEvent)
+	if realEstateEvent.TxID != stub.events[0].TxId || realEstateEvent.RealEstate.RealEstateID != realEstateList[0].RealEstateID {
+		t.Fatalf("登记事件内容有误: %+v", realEstateEvent)
+	}
+	var sellingEvent lib.SellingEvent
+	json.Unmarshal(stub.events[8].Payload, &sellingEvent)
+	//事件写入区块，只包含公开记录
+	if sellingEvent.Selling.SellingStatus != lib.SellingStatusConstant()["done"] || sellingEvent.Selling.Buyer != "" || sellingEvent.Selling.Price != 50*lib.Yuan {
+		t.Fatalf("确认收款事件内容有误: %+v", sellingEvent)
+	}
+	json.Unmarshal(stub.events[9].Payload, &sellingEvent)
+	if sellingEvent.Selling.SellingStatus != lib.SellingStatusConstant()["cancelled"] || sellingEvent.Selling.ObjectOfSale != realEstateList[1].RealEstateID {
+		t.Fatalf("取消销售事件内容有误: %+v", sellingEvent)
+	}
+	var donatingEvent lib.DonatingEvent
+	json.Unmarshal(stub.events[11].Payload, &donatingEvent)
+	if donatingEvent.Donating.DonatingStatus != lib.DonatingStatusConstant()["done"] || donatingEvent.Donating.Grantee != "" {
+		t.Fatalf("确认受赠事件内容有误: %+v", donatingEvent)
+	}
+}
+
+// 测试购房资金托管和对账
+func Test_Escrow(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	summary := func() lib.FundsSummary {
+		var s lib.FundsSummary
+		json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &s)
+		return s
+	}
+	total := summary().Total
+	if total != 5*5000000*lib.Yuan {
+		t.Fatalf("初始资金合计有误: %s", total)
+	}
+	for i, price := range []string{"100.50", "200"} {
+		checkInvoke(t, stub, seller, [][]byte{
+			[]byte("createSelling"),
+			[]byte(realEstateList[i].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(seller),                         //卖家(卖家AccountId)
+			[]byte(price),                          //价格
+			[]byte("30"),                           //智能合约的有效期(单位为天)
+		})
+		checkInvoke(t, stub, buyer, [][]byte{
+			[]byte("createSellingByBuy"),
+			[]byte(realEstateList[i].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(seller),                         //卖家(卖家AccountId)
+			[]byte(buyer),                          //买家(买家AccountId)
+		})
+	}
+	if s := summary(); s.Escrow != 30050 || s.Total != total {
+		t.Fatalf("购买后托管有误: %+v", s)
+	}
+	var escrowList []lib.Escrow
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryEscrowList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(seller),
+		[]byte(realEstateList[0].RealEstateID),
+	}).Payload, &escrowList)
+	if len(escrowList) != 1 || escrowList[0].Amount != 10050 || escrowList[0].Buyer != buyer || escrowList[0].EscrowStatus != lib.EscrowStatusConstant()["held"] {
+		t.Fatalf("托管记录有误: %+v", escrowList)
+	}
+	//确认收款支付给卖家，取消退还买家
+	for i, status := range []string{"done", "cancelled"} {
+		checkInvoke(t, stub, seller, [][]byte{
+			[]byte("updateSelling"),
+			[]byte(realEstateList[i].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(seller),                         //卖家(卖家AccountId)
+			[]byte(buyer),                          //买家(买家AccountId)
+			[]byte(status),                         //状态
+		})
+	}
+	if s := summary(); s.Escrow != 0 || s.Total != total {
+		t.Fatalf("结束托管后资金有误: %+v", s)
+	}
+	escrowList = nil
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryEscrowList"),
+		[]byte("100"), //pageSize
+		[]byte(""),    //bookmark
+		[]byte(seller),
+	}).Payload, &escrowList)
+	statuses := map[string]string{}
+	for _, v := range escrowList {
+		statuses[v.ObjectOfSale] = v.EscrowStatus
+		if v.SettleTime == "" {
+			t.Fatalf("托管结束时间为空: %+v", v)
+		}
+	}
+	if statuses[realEstateList[0].RealEstateID] != lib.EscrowStatusConstant()["released"] || statuses[realEstateList[1].RealEstateID] != lib.EscrowStatusConstant()["refunded"] {
+		t.Fatalf("托管状态有误: %+v", escrowList)
+	}
+	var sellerAccount, buyerAccount []lib.Account
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(seller)}).Payload, &sellerAccount)
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &buyerAccount)
+	if sellerAccount[0].Balance != 5000000*lib.Yuan+10050 || buyerAccount[0].Balance != 5000000*lib.Yuan-10050 {
+		t.Fatalf("托管结束后余额有误: %s %s", sellerAccount[0].Balance, buyerAccount[0].Balance)
+	}
+	//业主不能对账
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("queryFundsSummary")})
+
+	//升级前交付中的销售没有托管记录，迁移后补建
+	checkInvoke(t, stub, buyer, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[2].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+		[]byte(buyer),                          //卖家(卖家AccountId)
+		[]byte("300"),                          //价格
+		[]byte("30"),                           //智能合约的有效期(单位为天)
+	})
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("createSellingByBuy"),
+		[]byte(realEstateList[2].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+		[]byte(buyer),                          //卖家(卖家AccountId)
+		[]byte(seller),                         //买家(买家AccountId)
+	})
+	escrowList = nil
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryEscrowList"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &escrowList)
+	key, _ := stub.CreateCompositeKey(lib.EscrowKey, []string{buyer, realEstateList[2].RealEstateID, escrowList[0].EscrowID})
+	stub.MockStub.DelState(key)
+	if s := summary(); s.Total != total-300*lib.Yuan {
+		t.Fatalf("删除托管记录后资金有误: %+v", s)
+	}
+	var migrated []lib.Escrow
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("migrateEscrows"), []byte(adminId)}).Payload, &migrated)
+	if len(migrated) != 1 || migrated[0].Buyer != "" {
+		t.Fatalf("迁移托管有误: %+v", migrated)
+	}
+	escrowList = nil
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryEscrowList"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &escrowList)
+	if len(escrowList) != 1 || escrowList[0].Amount != 300*lib.Yuan || escrowList[0].Buyer != seller {
+		t.Fatalf("迁移托管有误: %+v", escrowList)
+	}
+	migrated = nil
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("migrateEscrows"), []byte(adminId)}).Payload, &migrated)
+	if len(migrated) != 0 || summary().Total != total {
+		t.Fatalf("重复迁移托管有误: %+v", migrated)
+	}
+}
+
+// 测试按交易时间判断销售过期以及批量过期
+func Test_Expiry(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	buyer := realEstateList[2].Proprietor
+	createSelling := func(realEstate lib.RealEstate, price string, salePeriod string) {
+		checkInvoke(t, stub, realEstate.Proprietor, [][]byte{
+			[]byte("createSelling"),
+			[]byte(realEstate.RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(realEstate.Proprietor),   //卖家(卖家AccountId)
+			[]byte(price),                   //价格
+			[]byte(salePeriod),              //智能合约的有效期(单位为天)
+		})
+	}
+	buy := func(realEstate lib.RealEstate) [][]byte {
+		return [][]byte{
+			[]byte("createSellingByBuy"),
+			[]byte(realEstate.RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(realEstate.Proprietor),   //卖家(卖家AccountId)
+			[]byte(buyer),                   //买家(买家AccountId)
+		}
+	}
+	updateSelling := func(realEstate lib.RealEstate, status string) [][]byte {
+		return [][]byte{
+			[]byte("updateSelling"),
+			[]byte(realEstate.RealEstateID), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(realEstate.Proprietor),   //卖家(卖家AccountId)
+			[]byte(buyer),                   //买家(买家AccountId)
+			[]byte(status),                  //状态
+		}
+	}
+	//有效期必须大于0
+	checkInvokeError(t, stub, realEstateList[3].Proprietor, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[3].RealEstateID),
+		[]byte(realEstateList[3].Proprietor),
+		[]byte("50"),
+		[]byte("0"),
+	})
+	createSelling(realEstateList[3], "50", "1") //销售中过期
+	createSelling(realEstateList[0], "100", "1")
+	createSelling(realEstateList[1], "200", "1")
+	createSelling(realEstateList[2], "300", "30") //未过期
+	checkInvoke(t, stub, buyer, buy(realEstateList[0]))
+	checkInvoke(t, stub, buyer, buy(realEstateList[1]))
+	//未到期不能设置为过期
+	checkInvokeError(t, stub, adminId, updateSelling(realEstateList[0], "expired"))
+
+	stub.clock = 25 * time.Hour
+	checkInvokeError(t, stub, buyer, buy(realEstateList[3]))
+	checkInvokeError(t, stub, realEstateList[0].Proprietor, updateSelling(realEstateList[0], "done"))
+	checkInvokeError(t, stub, buyer, [][]byte{[]byte("expireSellings")})
+	events := len(stub.events)
+	var expiredList []lib.Selling
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireSellings")}).Payload, &expiredList)
+	if len(expiredList) != 3 {
+		t.Fatalf("批量过期的销售有误: %+v", expiredList)
+	}
+	for _, v := range expiredList {
+		if v.SellingStatus != lib.SellingStatusConstant()["expired"] {
+			t.Fatalf("销售状态应为已过期: %+v", v)
+		}
+	}
+	if len(stub.events) != events+1 || stub.events[events].EventName != "sellingsExpired" {
+		t.Fatalf("批量过期事件有误: %+v", stub.events[events:])
+	}
+	var event lib.SellingListEvent
+	json.Unmarshal(stub.events[events].Payload, &event)
+	if len(event.Sellings) != 3 {
+		t.Fatalf("批量过期事件内容有误: %+v", event)
+	}
+	//同一买家的两笔托管都要退还
+	var accountList []lib.Account
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &accountList)
+	if accountList[0].Balance != 5000000*lib.Yuan {
+		t.Fatalf("过期后买家余额应全部退还: %s", accountList[0].Balance)
+	}
+	var summary lib.FundsSummary
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &summary)
+	if summary.Escrow != 0 {
+		t.Fatalf("过期后托管资金有误: %+v", summary)
+	}
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstate"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[2].RealEstateID),
+		[]byte(realEstateList[3].RealEstateID),
+	}).Payload, &realEstates)
+	for _, v := range realEstates {
+		if v.Encumbrance != (v.RealEstateID == realEstateList[2].RealEstateID) {
+			t.Fatalf("过期后担保状态有误: %+v", v)
+		}
+	}
+	//没有需要过期的销售
+	expiredList = nil
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireSellings")}).Payload, &expiredList)
+	if len(expiredList) != 0 || len(stub.events) != events+1 {
+		t.Fatalf("重复过期有误: %+v", expiredList)
+	}
+}
+
+// 测试捐赠超过接收期限后过期并解除担保
+func Test_DonatingExpiry(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	donor, grantee := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	createDonating := func(realEstate lib.RealEstate, donatingPeriod string) [][]byte {
+		return [][]byte{
+			[]byte("createDonating"),
+			[]byte(realEstate.RealEstateID), //捐赠对象(正在捐赠的房地产RealEstateID)
+			[]byte(realEstate.Proprietor),   //捐赠人(捐赠人AccountId)
+			[]byte(grantee),                 //受赠人(受赠人AccountId)
+			[]byte(donatingPeriod),          //接收期限(单位为天)
+		}
+	}
+	updateDonating := func(realEstate lib.RealEstate, status string) [][]byte {
+		return [][]byte{
+			[]byte("updateDonating"),
+			[]byte(realEstate.RealEstateID), //捐赠对象(正在捐赠的房地产RealEstateID)
+			[]byte(realEstate.Proprietor),   //捐赠人(捐赠人AccountId)
+			[]byte(grantee),                 //受赠人(受赠人AccountId)
+			[]byte(status),                  //状态
+		}
+	}
+	//接收期限必须大于0
+	checkInvokeError(t, stub, donor, createDonating(realEstateList[0], "0"))
+	checkInvokeError(t, stub, donor, createDonating(realEstateList[0], "abc"))
+	var donating lib.Donating
+	json.Unmarshal(checkInvoke(t, stub, donor, createDonating(realEstateList[0], "1")).Payload, &donating)
+	if donating.DonatingPeriod != 1 || donating.DonatingStatus != lib.DonatingStatusConstant()["donatingStart"] {
+		t.Fatalf("发起捐赠有误: %+v", donating)
+	}
+	checkInvoke(t, stub, donor, createDonating(realEstateList[1], "1"))
+	checkInvoke(t, stub, realEstateList[3].Proprietor, createDonating(realEstateList[3], "30")) //未过期
+	//未到期不能设置为过期
+	checkInvokeError(t, stub, adminId, updateDonating(realEstateList[0], "expired"))
+
+	stub.clock = 25 * time.Hour
+	//到期后不能再确认接收，其他业主不能设置为过期，捐赠人可以设置为过期
+	checkInvokeError(t, stub, grantee, updateDonating(realEstateList[0], "done"))
+	checkInvokeError(t, stub, realEstateList[3].Proprietor, updateDonating(realEstateList[0], "expired"))
+	events := len(stub.events)
+	json.Unmarshal(checkInvoke(t, stub, donor, updateDonating(realEstateList[0], "expired")).Payload, &donating)
+	if donating.DonatingStatus != lib.DonatingStatusConstant()["expired"] || stub.events[events].EventName != "donatingExpired" {
+		t.Fatalf("设置捐赠过期有误: %+v", donating)
+	}
+	//定时任务批量过期其余到期的捐赠
+	checkInvokeError(t, stub, grantee, [][]byte{[]byte("expireDonatings")})
+	var expiredList []lib.Donating
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireDonatings")}).Payload, &expiredList)
+	if len(expiredList) != 1 || expiredList[0].ObjectOfDonating != realEstateList[1].RealEstateID ||
+		expiredList[0].DonatingStatus != lib.DonatingStatusConstant()["expired"] || expiredList[0].Grantee != "" {
+		t.Fatalf("批量过期的捐赠有误: %+v", expiredList)
+	}
+	var event lib.DonatingListEvent
+	json.Unmarshal(stub.events[len(stub.events)-1].Payload, &event)
+	if stub.events[len(stub.events)-1].EventName != "donatingsExpired" || len(event.Donatings) != 1 {
+		t.Fatalf("批量过期事件有误: %+v", event)
+	}
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstate"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[1].RealEstateID),
+		[]byte(realEstateList[3].RealEstateID),
+	}).Payload, &realEstates)
+	for _, v := range realEstates {
+		if v.Encumbrance != (v.RealEstateID == realEstateList[3].RealEstateID) || v.Proprietor == grantee {
+			t.Fatalf("过期后房地产有误: %+v", v)
+		}
+	}
+	//受赠人查询到的记录同步为已过期
+	var donatingGranteeList []lib.DonatingGrantee
+	unmarshalRecords(checkInvoke(t, stub, grantee, [][]byte{[]byte("queryDonatingListByGrantee"), []byte("100"), []byte(""), []byte(grantee)}).Payload, &donatingGranteeList)
+	expired := 0
+	for _, v := range donatingGranteeList {
+		if v.Donating.DonatingStatus == lib.DonatingStatusConstant()["expired"] {
+			expired++
+		}
+	}
+	if len(donatingGranteeList) != 3 || expired != 2 {
+		t.Fatalf("受赠人的捐赠记录有误: %+v", donatingGranteeList)
+	}
+	//过期后可以重新发起捐赠
+	checkInvoke(t, stub, donor, createDonating(realEstateList[0], "30"))
+	expiredList = nil
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireDonatings")}).Payload, &expiredList)
+	if len(expiredList) != 0 {
+		t.Fatalf("重复过期有误: %+v", expiredList)
+	}
+	checkInvoke(t, stub, grantee, updateDonating(realEstateList[0], "done"))
+
+	//升级前发起的捐赠按升级前的格式写入：复合键包含受赠人，创建时间为本地时间，没有接收期限，迁移后按创建后30天过期
+	legacyId := "legacy000001"
+	legacyCreateTime := legacyTime(stub.clock - 29*24*time.Hour)
+	legacyCreated, _ := time.ParseInLocation("2006-01-02 15:04:05", legacyCreateTime, time.FixedZone("CST", 8*60*60))
+	legacyDonating := `{"objectOfDonating":"` + legacyId + `","donor":"` + donor + `","grantee":"` + grantee + `","createTime":"` + legacyCreateTime + `","donatingStatus":"捐赠中"}`
+	putLegacyState(stub, lib.RealEstateKey, []string{donor, legacyId},
+		`{"realEstateId":"`+legacyId+`","proprietor":"`+donor+`","encumbrance":true,"totalArea":120,"livingSpace":100}`)
+	putLegacyState(stub, lib.DonatingKey, []string{donor, legacyId, grantee}, legacyDonating)
+	putLegacyState(stub, lib.DonatingGranteeKey, []string{grantee, fmt.Sprintf("%d", legacyCreated.UnixNano())},
+		`{"grantee":"`+grantee+`","createTime":"`+legacyCreateTime+`","donating":`+legacyDonating+`}`)
+	legacyHash := strings.Repeat("ab", 32)
+	putLegacyState(stub, lib.DocumentKey, []string{"donating", donor, legacyId, grantee, legacyHash},
+		`{"objectType":"donating","objectKey":["`+donor+`","`+legacyId+`","`+grantee+`"],"hash":"`+legacyHash+`","uploader":"`+donor+`"}`)
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("migrateRealEstateKeys"), []byte(adminId)})
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("migratePrivateData"), []byte(adminId)})
+	//迁移后捐赠和关联的文档以捐赠对象和创建时间作为复合键，捐赠人通过私有数据集合中的索引查询
+	legacyKey := []string{legacyId, fmt.Sprintf("%d", legacyCreated.UnixNano())}
+	var documents []lib.Document
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryDocuments"), []byte("donating"), []byte(legacyKey[0]), []byte(legacyKey[1])}).Payload, &documents)
+	if len(documents) != 1 || documents[0].Hash != legacyHash || strings.Join(documents[0].ObjectKey, ",") != strings.Join(legacyKey, ",") {
+		t.Fatalf("升级前捐赠关联的文档迁移有误: %+v", documents)
+	}
+	var donatingList []lib.Donating
+	unmarshalRecords(checkInvoke(t, stub, donor, [][]byte{[]byte("queryDonatingList"), []byte("100"), []byte(""), []byte(donor), []byte(legacyId)}).Payload, &donatingList)
+	if len(donatingList) != 1 || donatingList[0].Grantee != grantee {
+		t.Fatalf("升级前的捐赠迁移有误: %+v", donatingList)
+	}
+	legacyPrefix, _ := stub.CreateCompositeKey(lib.DonatingKey, []string{donor})
+	for key := range stub.State {
+		if strings.HasPrefix(key, legacyPrefix) {
+			t.Fatalf("升级前捐赠的复合键应删除: %s", key)
+		}
+	}
+	expiredList = nil
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireDonatings")}).Payload, &expiredList)
+	if len(expiredList) != 0 {
+		t.Fatalf("升级前的捐赠未到期就过期: %+v", expiredList)
+	}
+	stub.clock += 2 * 24 * time.Hour
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("expireDonatings")}).Payload, &expiredList)
+	if len(expiredList) != 1 || expiredList[0].ObjectOfDonating != legacyId || expiredList[0].DonatingPeriod != 0 {
+		t.Fatalf("升级前的捐赠没有按默认期限过期: %+v", expiredList)
+	}
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(legacyId)}).Payload, &realEstates)
+	if len(realEstates) != 1 || realEstates[0].Encumbrance {
+		t.Fatalf("升级前的捐赠过期后担保状态有误: %+v", realEstates)
+	}
+	//受赠人的记录同步为已过期
+	unmarshalRecords(checkInvoke(t, stub, grantee, [][]byte{[]byte("queryDonatingListByGrantee"), []byte("100"), []byte(""), []byte(grantee)}).Payload, &donatingGranteeList)
+	found := false
+	for _, v := range donatingGranteeList {
+		if v.Donating.ObjectOfDonating == legacyId {
+			found = v.Donating.DonatingStatus == lib.DonatingStatusConstant()["expired"]
+		}
+	}
+	if !found {
+		t.Fatalf("升级前的捐赠受赠人记录有误: %+v", donatingGranteeList)
+	}
+}
+
+// 测试英式拍卖的出价、退还、结算、流拍和取消
+func Test_Auction(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	bidderA, bidderB := realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	endTime := time.Now().Add(24 * time.Hour).Format(time.RFC3339)
+	createAuction := func(realEstate lib.RealEstate) [][]byte {
+		return [][]byte{
+			[]byte("createAuction"),
+			[]byte(realEstate.RealEstateID), //拍卖对象(房地产RealEstateID)
+			[]byte(seller),                  //卖家(卖家AccountId)
+			[]byte("100"),                   //保留价
+			[]byte("10"),                    //最小加价幅度
+			[]byte(endTime),                 //结束时间
+		}
+	}
+	placeBid := func(realEstate lib.RealEstate, bidder string, amount string) [][]byte {
+		return placeBidArgs(realEstate, seller, bidder, amount)
+	}
+	auctionArgs := func(fcn string, realEstate lib.RealEstate) [][]byte {
+		return [][]byte{[]byte(fcn), []byte(realEstate.RealEstateID), []byte(seller)}
+	}
+	balance := func(accountId string) lib.Amount {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0].Balance
+	}
+	var summary lib.FundsSummary
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &summary)
+	total := summary.Total
+
+	//结束时间必须晚于当前时间，非所有者不能发起
+	past := createAuction(realEstateList[0])
+	past[5] = []byte(time.Now().Add(-time.Hour).Format(time.RFC3339))
+	checkInvokeError(t, stub, seller, past)
+	checkInvokeError(t, stub, bidderA, createAuction(realEstateList[2]))
+	var auction lib.Auction
+	json.Unmarshal(checkInvoke(t, stub, seller, createAuction(realEstateList[0])).Payload, &auction)
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["bidding"] || auction.ReservePrice != 100*lib.Yuan {
+		t.Fatalf("发起拍卖有误: %+v", auction)
+	}
+	checkInvoke(t, stub, seller, createAuction(realEstateList[1]))
+	//拍卖中的房地产不能再出售
+	checkInvokeError(t, stub, seller, [][]byte{
+		[]byte("createSelling"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(seller),
+		[]byte("50"),
+		[]byte("30"),
+	})
+
+	//首次出价不能低于保留价，之后不能低于最高出价加最小加价幅度
+	checkInvokeError(t, stub, bidderA, placeBid(realEstateList[0], bidderA, "99.99"))
+	checkInvokeError(t, stub, seller, placeBid(realEstateList[0], seller, "100"))
+	checkInvokeError(t, stub, bidderA, placeBid(realEstateList[0], bidderB, "100"))
+	checkInvoke(t, stub, bidderA, placeBid(realEstateList[0], bidderA, "100"))
+	checkInvokeError(t, stub, bidderB, placeBid(realEstateList[0], bidderB, "109.99"))
+	checkInvoke(t, stub, bidderB, placeBid(realEstateList[0], bidderB, "110"))
+	if balance(bidderA) != 5000000*lib.Yuan || balance(bidderB) != 5000000*lib.Yuan-110*lib.Yuan {
+		t.Fatalf("被超过的出价应退还: %s %s", balance(bidderA), balance(bidderB))
+	}
+	//最高出价人再次加价，之前的出价退还后重新托管
+	checkInvoke(t, stub, bidderB, placeBid(realEstateList[0], bidderB, "120"))
+	if balance(bidderB) != 5000000*lib.Yuan-120*lib.Yuan {
+		t.Fatalf("最高出价人加价后余额有误: %s", balance(bidderB))
+	}
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &summary)
+	if summary.Escrow != 120*lib.Yuan || summary.Total != total {
+		t.Fatalf("竞拍中托管资金有误: %+v", summary)
+	}
+	queryBids := func(caller string) []lib.AuctionBid {
+		var bidList []lib.AuctionBid
+		unmarshalRecords(checkInvoke(t, stub, caller, [][]byte{[]byte("queryAuctionBids"), []byte("100"), []byte(""), []byte(seller), []byte(realEstateList[0].RealEstateID)}).Payload, &bidList)
+		return bidList
+	}
+	if bidList := queryBids(adminId); len(bidList) != 3 || bidList[0].Bidder != bidderA || bidList[2].Amount != 120*lib.Yuan {
+		t.Fatalf("出价记录有误: %+v", bidList)
+	}
+	//出价只写入私有数据集合，事件中的拍卖不含最高出价，竞拍人只能看到自己的出价和当前最高出价
+	var auctionEvent lib.AuctionEvent
+	json.Unmarshal(stub.events[len(stub.events)-1].Payload, &auctionEvent)
+	if auctionEvent.Auction.HighestBid != 0 || auctionEvent.Auction.HighestBidder != "" || auctionEvent.Auction.PrivateHash == "" {
+		t.Fatalf("出价事件不应包含最高出价: %+v", auctionEvent)
+	}
+	for key := range stub.State {
+		if strings.Contains(key, lib.AuctionBidKey) {
+			t.Fatalf("出价不应写入账本: %s", key)
+		}
+	}
+	if bidList := queryBids(bidderA); len(bidList) != 3 || bidList[0].Amount != 100*lib.Yuan || bidList[1].Bidder != "" || bidList[1].Amount != 0 {
+		t.Fatalf("竞拍人查询出价有误: %+v", bidList)
+	}
+	var biddingList []lib.Auction
+	unmarshalRecords(checkInvoke(t, stub, bidderA, [][]byte{[]byte("queryAuctionList"), []byte("100"), []byte(""), []byte(seller)}).Payload, &biddingList)
+	if len(biddingList) != 2 || (biddingList[0].HighestBid != 120*lib.Yuan && biddingList[1].HighestBid != 120*lib.Yuan) || biddingList[0].HighestBidder != "" || biddingList[1].HighestBidder != "" {
+		t.Fatalf("竞拍人查询拍卖有误: %+v", biddingList)
+	}
+	//已有出价不能取消，未结束不能结算
+	checkInvokeError(t, stub, seller, auctionArgs("cancelAuction", realEstateList[0]))
+	checkInvokeError(t, stub, seller, auctionArgs("settleAuction", realEstateList[0]))
+
+	stub.clock = 25 * time.Hour
+	checkInvokeError(t, stub, bidderA, placeBid(realEstateList[0], bidderA, "200"))
+	events := len(stub.events)
+	json.Unmarshal(checkInvoke(t, stub, bidderB, auctionArgs("settleAuction", realEstateList[0])).Payload, &auction)
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["done"] || stub.events[events].EventName != "auctionDone" {
+		t.Fatalf("拍卖成交有误: %+v", auction)
+	}
+	checkInvokeError(t, stub, seller, auctionArgs("settleAuction", realEstateList[0]))
+	//无人出价则流拍
+	json.Unmarshal(checkInvoke(t, stub, seller, auctionArgs("settleAuction", realEstateList[1])).Payload, &auction)
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["failed"] {
+		t.Fatalf("拍卖流拍有误: %+v", auction)
+	}
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{
+		[]byte("queryRealEstate"),
+		[]byte(realEstateList[0].RealEstateID),
+		[]byte(realEstateList[1].RealEstateID),
+	}).Payload, &realEstates)
+	if realEstates[0].Proprietor != bidderB || realEstates[0].Encumbrance || realEstates[1].Proprietor != seller || realEstates[1].Encumbrance {
+		t.Fatalf("拍卖结算后房地产有误: %+v", realEstates)
+	}
+	if balance(seller) != 5000000*lib.Yuan+120*lib.Yuan {
+		t.Fatalf("拍卖成交后卖家余额有误: %s", balance(seller))
+	}
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &summary)
+	if summary.Escrow != 0 || summary.Total != total {
+		t.Fatalf("拍卖结算后资金有误: %+v", summary)
+	}
+	var historyList []lib.RealEstateHistory
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateHistory"), []byte(realEstateList[0].RealEstateID)}).Payload, &historyList)
+	last := historyList[len(historyList)-1]
+	if last.Auction == nil || last.Auction.AuctionStatus != lib.AuctionStatusConstant()["done"] {
+		t.Fatalf("房地产历史中的拍卖有误: %+v", last)
+	}
+
+	//无人出价时卖家可以取消，流拍后可以再次发起
+	endTime = time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+	checkInvoke(t, stub, seller, createAuction(realEstateList[1]))
+	checkInvokeError(t, stub, bidderA, auctionArgs("cancelAuction", realEstateList[1]))
+	json.Unmarshal(checkInvoke(t, stub, seller, auctionArgs("cancelAuction", realEstateList[1])).Payload, &auction)
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["cancelled"] {
+		t.Fatalf("取消拍卖有误: %+v", auction)
+	}
+	var auctionList []lib.Auction
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAuctionList"), []byte("100"), []byte(""), []byte(seller)}).Payload, &auctionList)
+	if len(auctionList) != 2 {
+		t.Fatalf("拍卖列表有误: %+v", auctionList)
+	}
+
+	//最高出价人的账户被冻结后结算按流拍处理，最高出价退还，房地产解除担保
+	checkInvoke(t, stub, seller, createAuction(realEstateList[1]))
+	checkInvoke(t, stub, bidderA, placeBid(realEstateList[1], bidderA, "100"))
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("freezeAccount"), []byte(adminId), []byte(bidderA), []byte("frozen")})
+	stub.clock = 49 * time.Hour
+	events = len(stub.events)
+	json.Unmarshal(checkInvoke(t, stub, seller, auctionArgs("settleAuction", realEstateList[1])).Payload, &auction)
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["failed"] || stub.events[events].EventName != "auctionFailed" {
+		t.Fatalf("竞拍人账户冻结后结算有误: %+v", auction)
+	}
+	if balance(bidderA) != 5000000*lib.Yuan {
+		t.Fatalf("竞拍人账户冻结后最高出价应退还: %s", balance(bidderA))
+	}
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(realEstateList[1].RealEstateID)}).Payload, &realEstates)
+	if realEstates[0].Proprietor != seller || realEstates[0].Encumbrance {
+		t.Fatalf("竞拍人账户冻结后房地产有误: %+v", realEstates)
+	}
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &summary)
+	if summary.Escrow != 0 || summary.Total != total {
+		t.Fatalf("竞拍人账户冻结后资金有误: %+v", summary)
+	}
+}
+
+func placeBidArgs(realEstate lib.RealEstate, seller string, bidder string, amount string) [][]byte {
+	return [][]byte{
+		[]byte("placeBid"),
+		[]byte(realEstate.RealEstateID), //拍卖对象(房地产RealEstateID)
+		[]byte(seller),                  //卖家(卖家AccountId)
+		[]byte(bidder),                  //竞拍人(竞拍人AccountId)
+		[]byte(amount),                  //出价
+	}
+}
+
+// 测试密封拍卖的提交、揭示和结算
+func Test_SealedAuction(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	bidderA, bidderB, bidderC := realEstateList[2].Proprietor, realEstateList[3].Proprietor, ownerIds[1]
+	var auction lib.Auction
+	json.Unmarshal(checkInvoke(t, stub, seller, [][]byte{
+		[]byte("createSealedAuction"),
+		[]byte(realEstateList[0].RealEstateID), //拍卖对象(房地产RealEstateID)
+		[]byte(seller),                         //卖家(卖家AccountId)
+		[]byte("100"),                          //保留价
+		[]byte(time.Now().Add(24 * time.Hour).Format(time.RFC3339)), //结束时间
+		[]byte(time.Now().Add(48 * time.Hour).Format(time.RFC3339)), //揭示截止时间
+	}).Payload, &auction)
+	if auction.AuctionType != lib.AuctionTypeConstant()["sealed"] {
+		t.Fatalf("发起密封拍卖有误: %+v", auction)
+	}
+	auctionArgs := func(fcn string, bidder string) [][]byte {
+		return [][]byte{[]byte(fcn), []byte(realEstateList[0].RealEstateID), []byte(seller), []byte(bidder)}
+	}
+	commit := func(bidder string, amount string, salt string) peer.Response {
+		stub.transient = map[string][]byte{"bid": []byte(fmt.Sprintf(`{"amount":"%s","salt":"%s"}`, amount, salt))}
+		defer func() { stub.transient = nil }()
+		return stub.invoke(nextTxID(), bidder, auctionArgs("commitBid", bidder), false)
+	}
+	//出价只能通过transient传入，不能公开出价
+	checkInvokeError(t, stub, bidderA, auctionArgs("commitBid", bidderA))
+	checkInvokeError(t, stub, bidderA, placeBidArgs(realEstateList[0], seller, bidderA, "100"))
+	for _, c := range []struct{ bidder, amount, salt string }{
+		{bidderA, "99", "a"}, //低于保留价
+		{bidderA, "150", ""}, //没有盐值
+		{seller, "150", "s"}, //卖家不能出价
+		{bidderB, "-1", "b"}, //负数
+	} {
+		if res := commit(c.bidder, c.amount, c.salt); res.Status == shim.OK {
+			t.Fatalf("提交密封出价应失败: %+v", c)
+		}
+	}
+	for _, c := range []struct{ bidder, amount, salt string }{
+		{bidderA, "120", "a1"},
+		{bidderA, "150", "a2"}, //结束前重新提交覆盖之前的出价
+		{bidderB, "200", "b1"},
+		{bidderC, "180", "c1"},
+	} {
+		if res := commit(c.bidder, c.amount, c.salt); res.Status != shim.OK {
+			t.Fatalf("提交密封出价失败: %s", res.Message)
+		}
+	}
+	//账本中只有哈希，没有出价
+	var sealedBids []lib.SealedBid
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("querySealedBids"), []byte("100"), []byte(""), []byte(auction.AuctionID)}).Payload, &sealedBids)
+	if len(sealedBids) != 3 {
+		t.Fatalf("出价承诺有误: %+v", sealedBids)
+	}
+	for _, v := range sealedBids {
+		if v.BidHash == "" || v.Amount != 0 || v.Revealed {
+			t.Fatalf("揭示前出价承诺有误: %+v", v)
+		}
+	}
+	for key, value := range stub.State {
+		if strings.Contains(string(value), "salt") {
+			t.Fatalf("出价不能写入公开账本: %s", key)
+		}
+	}
+	//结束前不能揭示、不能结算，已有出价不能取消
+	checkInvokeError(t, stub, bidderB, auctionArgs("revealBid", bidderB))
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("settleAuction"), []byte(realEstateList[0].RealEstateID), []byte(seller)})
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("cancelAuction"), []byte(realEstateList[0].RealEstateID), []byte(seller)})
+
+	stub.clock = 25 * time.Hour
+	if res := commit(bidderB, "300", "b2"); res.Status == shim.OK {
+		t.Fatalf("结束后不能提交出价")
+	}
+	checkInvoke(t, stub, bidderA, auctionArgs("revealBid", bidderA))
+	checkInvoke(t, stub, bidderB, auctionArgs("revealBid", bidderB))
+	checkInvoke(t, stub, bidderC, auctionArgs("revealBid", bidderC)) //低于最高出价，不转入托管
+	checkInvokeError(t, stub, bidderC, auctionArgs("revealBid", bidderC))
+	balance := func(accountId string) lib.Amount {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0].Balance
+	}
+	if balance(bidderA) != 5000000*lib.Yuan || balance(bidderB) != 5000000*lib.Yuan-200*lib.Yuan || balance(bidderC) != 5000000*lib.Yuan {
+		t.Fatalf("揭示后余额有误: %s %s %s", balance(bidderA), balance(bidderB), balance(bidderC))
+	}
+	sealedBids = nil
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("querySealedBids"), []byte("100"), []byte(""), []byte(auction.AuctionID)}).Payload, &sealedBids)
+	for _, v := range sealedBids {
+		if !v.Revealed || (v.Bidder == bidderA && v.Amount != 150*lib.Yuan) {
+			t.Fatalf("揭示后出价有误: %+v", v)
+		}
+	}
+	//揭示截止前不能结算
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("settleAuction"), []byte(realEstateList[0].RealEstateID), []byte(seller)})
+
+	stub.clock = 49 * time.Hour
+	json.Unmarshal(checkInvoke(t, stub, seller, [][]byte{[]byte("settleAuction"), []byte(realEstateList[0].RealEstateID), []byte(seller)}).Payload, &auction)
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["done"] || auction.HighestBidder != "" || auction.HighestBid != 0 {
+		t.Fatalf("密封拍卖成交有误: %+v", auction)
+	}
+	var auctionList []lib.Auction
+	unmarshalRecords(checkInvoke(t, stub, seller, [][]byte{[]byte("queryAuctionList"), []byte("100"), []byte(""), []byte(seller)}).Payload, &auctionList)
+	if len(auctionList) != 1 || auctionList[0].HighestBidder != bidderB || auctionList[0].HighestBid != 200*lib.Yuan {
+		t.Fatalf("密封拍卖的最高出价有误: %+v", auctionList)
+	}
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(realEstateList[0].RealEstateID)}).Payload, &realEstates)
+	if realEstates[0].Proprietor != bidderB || realEstates[0].Encumbrance || balance(seller) != 5000000*lib.Yuan+200*lib.Yuan {
+		t.Fatalf("密封拍卖结算后有误: %+v %s", realEstates, balance(seller))
+	}
+}
+
+// 测试买家报价、卖家还价和接受报价
+func Test_Offer(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller := realEstateList[0].Proprietor
+	buyerA, buyerB := realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	objectOfSale := realEstateList[0].RealEstateID
+	checkInvoke(t, stub, seller, [][]byte{
+		[]byte("createSelling"),
+		[]byte(objectOfSale), //销售对象(正在出售的房地产RealEstateID)
+		[]byte(seller),       //卖家(卖家AccountId)
+		[]byte("300"),        //价格
+		[]byte("30"),         //智能合约的有效期(单位为天)
+	})
+	expireTime := time.Now().Add(48 * time.Hour).Format(time.RFC3339)
+	createOffer := func(buyer string, price string) lib.Offer {
+		var offer lib.Offer
+		json.Unmarshal(checkInvoke(t, stub, buyer, [][]byte{
+			[]byte("createOffer"),
+			[]byte(objectOfSale), //销售对象(正在出售的房地产RealEstateID)
+			[]byte(seller),       //卖家(卖家AccountId)
+			[]byte(buyer),        //买家(买家AccountId)
+			[]byte(price),        //报价
+			[]byte(expireTime),   //报价有效期
+		}).Payload, &offer)
+		return offer
+	}
+	updateOffer := func(offer lib.Offer, status string, price ...string) [][]byte {
+		args := [][]byte{[]byte("updateOffer"), []byte(objectOfSale), []byte(seller), []byte(offer.OfferID), []byte(status)}
+		for _, v := range price {
+			args = append(args, []byte(v))
+		}
+		return args
+	}
+	//卖家不能对自己报价，其他人不能代买家报价
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("createOffer"), []byte(objectOfSale), []byte(seller), []byte(seller), []byte("200"), []byte(expireTime)})
+	checkInvokeError(t, stub, buyerB, [][]byte{[]byte("createOffer"), []byte(objectOfSale), []byte(seller), []byte(buyerA), []byte("200"), []byte(expireTime)})
+	offerA := createOffer(buyerA, "200")
+	offerB := createOffer(buyerB, "250")
+	//返回的是公开记录，报价只有当事人可以查询
+	queryOffer := func(buyer string) lib.Offer {
+		var offerList []lib.Offer
+		unmarshalRecords(checkInvoke(t, stub, buyer, [][]byte{[]byte("queryOfferListByBuyer"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &offerList)
+		return offerList[0]
+	}
+	if offerA.OfferStatus != lib.OfferStatusConstant()["pending"] || offerA.Price != 0 || offerA.Buyer != "" {
+		t.Fatalf("报价有误: %+v", offerA)
+	}
+	if o := queryOffer(buyerA); o.OfferStatus != lib.OfferStatusConstant()["pending"] || o.Price != 200*lib.Yuan || o.Buyer != buyerA {
+		t.Fatalf("报价有误: %+v", o)
+	}
+	//待答复时只有卖家可以还价、接受或拒绝
+	checkInvokeError(t, stub, buyerA, updateOffer(offerA, "accepted"))
+	checkInvokeError(t, stub, seller, updateOffer(offerA, "countered"))
+	json.Unmarshal(checkInvoke(t, stub, seller, updateOffer(offerA, "countered", "260")).Payload, &offerA)
+	if o := queryOffer(buyerA); o.OfferStatus != lib.OfferStatusConstant()["countered"] || o.Price != 260*lib.Yuan || o.OfferPrice != 200*lib.Yuan {
+		t.Fatalf("还价有误: %+v", o)
+	}
+	//已还价时由买家答复
+	checkInvokeError(t, stub, seller, updateOffer(offerA, "accepted"))
+	checkInvokeError(t, stub, buyerA, updateOffer(offerA, "countered", "250"))
+
+	//过期后不能接受
+	stub.clock = 49 * time.Hour
+	checkInvokeError(t, stub, buyerA, updateOffer(offerA, "accepted"))
+	stub.clock = 0
+	events := len(stub.events)
+	json.Unmarshal(checkInvoke(t, stub, buyerA, updateOffer(offerA, "accepted")).Payload, &offerA)
+	if offerA.OfferStatus != lib.OfferStatusConstant()["accepted"] {
+		t.Fatalf("接受报价有误: %+v", offerA)
+	}
+	var event lib.OfferEvent
+	json.Unmarshal(stub.events[events].Payload, &event)
+	if stub.events[events].EventName != "offerAccepted" || event.Selling == nil || event.Selling.SellingStatus != lib.SellingStatusConstant()["delivery"] {
+		t.Fatalf("接受报价事件有误: %+v", event)
+	}
+	//销售以商定价格进入交付中，资金转入托管
+	var sellingList []lib.Selling
+	unmarshalRecords(checkInvoke(t, stub, seller, [][]byte{[]byte("querySellingList"), []byte("100"), []byte(""), []byte(seller)}).Payload, &sellingList)
+	if sellingList[0].Buyer != buyerA || sellingList[0].Price != 260*lib.Yuan || sellingList[0].SellingStatus != lib.SellingStatusConstant()["delivery"] {
+		t.Fatalf("接受报价后销售有误: %+v", sellingList[0])
+	}
+	var escrowList []lib.Escrow
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryEscrowList"), []byte("100"), []byte(""), []byte(seller), []byte(objectOfSale)}).Payload, &escrowList)
+	if len(escrowList) != 1 || escrowList[0].Amount != 260*lib.Yuan || escrowList[0].Buyer != buyerA {
+		t.Fatalf("接受报价后托管有误: %+v", escrowList)
+	}
+	//同一销售的其他报价自动拒绝
+	var offerList []lib.Offer
+	unmarshalRecords(checkInvoke(t, stub, buyerB, [][]byte{[]byte("queryOfferListByBuyer"), []byte("100"), []byte(""), []byte(buyerB)}).Payload, &offerList)
+	if len(offerList) != 1 || offerList[0].OfferID != offerB.OfferID || offerList[0].OfferStatus != lib.OfferStatusConstant()["rejected"] {
+		t.Fatalf("其他报价应自动拒绝: %+v", offerList)
+	}
+	checkInvokeError(t, stub, seller, updateOffer(offerB, "accepted"))
+	offerList = nil
+	unmarshalRecords(checkInvoke(t, stub, seller, [][]byte{[]byte("queryOfferList"), []byte("100"), []byte(""), []byte(seller), []byte(objectOfSale)}).Payload, &offerList)
+	if len(offerList) != 2 {
+		t.Fatalf("按销售查询报价有误: %+v", offerList)
+	}
+	//交付中的销售不能再报价
+	checkInvokeError(t, stub, buyerB, [][]byte{[]byte("createOffer"), []byte(objectOfSale), []byte(seller), []byte(buyerB), []byte("280"), []byte(expireTime)})
+
+	//买家可以撤回报价，卖家可以拒绝报价
+	checkInvoke(t, stub, buyerA, [][]byte{[]byte("createSelling"), []byte(realEstateList[2].RealEstateID), []byte(buyerA), []byte("100"), []byte("30")})
+	offer := func(buyer string) lib.Offer {
+		var o lib.Offer
+		json.Unmarshal(checkInvoke(t, stub, buyer, [][]byte{[]byte("createOffer"), []byte(realEstateList[2].RealEstateID), []byte(buyerA), []byte(buyer), []byte("90"), []byte(expireTime)}).Payload, &o)
+		return o
+	}
+	withdrawn, rejected := offer(buyerB), offer(seller)
+	respond := func(caller string, o lib.Offer, status string) lib.Offer {
+		json.Unmarshal(checkInvoke(t, stub, caller, [][]byte{[]byte("updateOffer"), []byte(realEstateList[2].RealEstateID), []byte(buyerA), []byte(o.OfferID), []byte(status)}).Payload, &o)
+		return o
+	}
+	checkInvokeError(t, stub, buyerA, [][]byte{[]byte("updateOffer"), []byte(realEstateList[2].RealEstateID), []byte(buyerA), []byte(withdrawn.OfferID), []byte("withdrawn")})
+	if o := respond(buyerB, withdrawn, "withdrawn"); o.OfferStatus != lib.OfferStatusConstant()["withdrawn"] {
+		t.Fatalf("撤回报价有误: %+v", o)
+	}
+	if o := respond(buyerA, rejected, "rejected"); o.OfferStatus != lib.OfferStatusConstant()["rejected"] {
+		t.Fatalf("拒绝报价有误: %+v", o)
+	}
+	checkInvokeError(t, stub, buyerB, [][]byte{[]byte("updateOffer"), []byte(realEstateList[2].RealEstateID), []byte(buyerA), []byte(withdrawn.OfferID), []byte("rejected")})
+}
+
+// 测试质押放款、还款解除和违约处置
+func Test_Pledge(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	pledgor, lender := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	dueDate := time.Now().Add(30 * 24 * time.Hour).Format(time.RFC3339)
+	createPledge := func(realEstate lib.RealEstate) lib.Pledge {
+		var pledge lib.Pledge
+		json.Unmarshal(checkInvoke(t, stub, pledgor, [][]byte{
+			[]byte("createPledge"),
+			[]byte(realEstate.RealEstateID), //质押对象(房地产RealEstateID)
+			[]byte(pledgor),                 //出质人(业主AccountId)
+			[]byte(lender),                  //出借人AccountId
+			[]byte("1000"),                  //贷款金额
+			[]byte(dueDate),                 //到期时间
+		}).Payload, &pledge)
+		return pledge
+	}
+	updatePledge := func(pledge lib.Pledge, status string) [][]byte {
+		return [][]byte{[]byte("updatePledge"), []byte(pledge.ObjectOfPledge), []byte(pledgor), []byte(pledge.PledgeID), []byte(status)}
+	}
+	repay := func(pledge lib.Pledge, amount string) [][]byte {
+		return [][]byte{[]byte("repayPledge"), []byte(pledge.ObjectOfPledge), []byte(pledgor), []byte(pledge.PledgeID), []byte(amount)}
+	}
+	balance := func(accountId string) lib.Amount {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0].Balance
+	}
+	pledgeList := func(keys ...string) []lib.Pledge {
+		args := [][]byte{[]byte("queryPledgeList"), []byte("100"), []byte("")}
+		for _, v := range keys {
+			args = append(args, []byte(v))
+		}
+		var list []lib.Pledge
+		unmarshalRecords(checkInvoke(t, stub, adminId, args).Payload, &list)
+		return list
+	}
+	//非所有者不能质押，质押中的房地产不能出售
+	checkInvokeError(t, stub, lender, [][]byte{[]byte("createPledge"), []byte(realEstateList[0].RealEstateID), []byte(pledgor), []byte(lender), []byte("1000"), []byte(dueDate)})
+	pledge := createPledge(realEstateList[0])
+	if pledge.PledgeStatus != lib.PledgeStatusConstant()["pending"] {
+		t.Fatalf("发起质押有误: %+v", pledge)
+	}
+	checkInvokeError(t, stub, pledgor, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(pledgor), []byte("50"), []byte("30")})
+	//只有出借人可以放款
+	checkInvokeError(t, stub, pledgor, updatePledge(pledge, "active"))
+	checkInvokeError(t, stub, pledgor, repay(pledge, "100"))
+	json.Unmarshal(checkInvoke(t, stub, lender, updatePledge(pledge, "active")).Payload, &pledge)
+	if pledge.PledgeStatus != lib.PledgeStatusConstant()["active"] || balance(pledgor) != 5000000*lib.Yuan+1000*lib.Yuan || balance(lender) != 5000000*lib.Yuan-1000*lib.Yuan {
+		t.Fatalf("放款有误: %+v", pledge)
+	}
+	checkInvokeError(t, stub, pledgor, updatePledge(pledge, "cancelled"))
+	//未到期不能违约处置，还款不能超过未还金额
+	checkInvokeError(t, stub, lender, updatePledge(pledge, "defaulted"))
+	checkInvokeError(t, stub, pledgor, repay(pledge, "1000.01"))
+	json.Unmarshal(checkInvoke(t, stub, pledgor, repay(pledge, "400")).Payload, &pledge)
+	if pledge.LoanAmount != 0 || pledge.Repaid != 0 || pledge.PrivateHash == "" || pledge.PledgeStatus != lib.PledgeStatusConstant()["active"] {
+		t.Fatalf("部分还款有误: %+v", pledge)
+	}
+	//贷款金额和还款只写入私有数据集合，交易的返回值和事件中不含金额
+	var pledgeEvent lib.PledgeEvent
+	json.Unmarshal(stub.events[len(stub.events)-1].Payload, &pledgeEvent)
+	if pledgeEvent.Pledge.LoanAmount != 0 || pledgeEvent.Pledge.Repaid != 0 {
+		t.Fatalf("还款事件不应包含金额: %+v", pledgeEvent)
+	}
+	if list := pledgeList("active"); len(list) != 1 || list[0].LoanAmount != 1000*lib.Yuan || list[0].Repaid != 400*lib.Yuan {
+		t.Fatalf("银行查询质押有误: %+v", list)
+	}
+	json.Unmarshal(checkInvoke(t, stub, pledgor, repay(pledge, "600")).Payload, &pledge)
+	if pledge.PledgeStatus != lib.PledgeStatusConstant()["repaid"] || pledge.CloseTime == "" {
+		t.Fatalf("还清有误: %+v", pledge)
+	}
+	if balance(pledgor) != 5000000*lib.Yuan || balance(lender) != 5000000*lib.Yuan {
+		t.Fatalf("还清后余额有误: %s %s", balance(pledgor), balance(lender))
+	}
+	var repayments []lib.PledgeRepayment
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryPledgeRepayments"), []byte("100"), []byte(""), []byte(pledgor), []byte(pledge.ObjectOfPledge), []byte(pledge.PledgeID)}).Payload, &repayments)
+	if len(repayments) != 2 || repayments[1].Repaid != 1000*lib.Yuan {
+		t.Fatalf("还款记录有误: %+v", repayments)
+	}
+	checkInvokeError(t, stub, realEstateList[3].Proprietor, [][]byte{[]byte("queryPledgeRepayments"), []byte("100"), []byte(""), []byte(pledgor), []byte(pledge.ObjectOfPledge), []byte(pledge.PledgeID)})
+
+	//待放款时可以取消
+	cancelled := createPledge(realEstateList[1])
+	checkInvokeError(t, stub, realEstateList[3].Proprietor, updatePledge(cancelled, "cancelled"))
+	checkInvoke(t, stub, lender, updatePledge(cancelled, "cancelled"))
+
+	//到期未还清，出借人违约处置
+	defaulted := createPledge(realEstateList[0])
+	checkInvoke(t, stub, lender, updatePledge(defaulted, "active"))
+	if list := pledgeList("active"); len(list) != 1 || list[0].PledgeID != defaulted.PledgeID {
+		t.Fatalf("进行中的质押有误: %+v", list)
+	}
+	stub.clock = 31 * 24 * time.Hour
+	checkInvokeError(t, stub, pledgor, updatePledge(defaulted, "defaulted"))
+	json.Unmarshal(checkInvoke(t, stub, lender, updatePledge(defaulted, "defaulted")).Payload, &defaulted)
+	if defaulted.PledgeStatus != lib.PledgeStatusConstant()["defaulted"] {
+		t.Fatalf("违约处置有误: %+v", defaulted)
+	}
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[1].RealEstateID)}).Payload, &realEstates)
+	if realEstates[0].Proprietor != lender || realEstates[0].Encumbrance || realEstates[1].Proprietor != pledgor || realEstates[1].Encumbrance {
+		t.Fatalf("质押结束后房地产有误: %+v", realEstates)
+	}
+	if list := pledgeList("active"); len(list) != 0 {
+		t.Fatalf("进行中的质押应为空: %+v", list)
+	}
+	if list := pledgeList("closed", pledgor); len(list) != 3 {
+		t.Fatalf("已结束的质押有误: %+v", list)
+	}
+	checkInvokeError(t, stub, adminId, [][]byte{[]byte("queryPledgeList"), []byte("100"), []byte(""), []byte("pending")})
+	var historyList []lib.RealEstateHistory
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateHistory"), []byte(realEstateList[0].RealEstateID)}).Payload, &historyList)
+	if last := historyList[len(historyList)-1]; last.Pledge == nil || last.Pledge.PledgeStatus != lib.PledgeStatusConstant()["defaulted"] {
+		t.Fatalf("房地产历史中的质押有误: %+v", last)
+	}
+}
+
+// 测试租约签署、支付租金、标记欠租以及终止时退还或没收押金
+func Test_Lease(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	landlord, tenant := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	endTime := time.Now().Add(90 * 24 * time.Hour).Format(time.RFC3339)
+	createLease := func(realEstate lib.RealEstate) lib.Lease {
+		var lease lib.Lease
+		json.Unmarshal(checkInvoke(t, stub, landlord, [][]byte{
+			[]byte("createLease"),
+			[]byte(realEstate.RealEstateID), //出租对象(房地产RealEstateID)
+			[]byte(landlord),                //出租人(业主AccountId)
+			[]byte(tenant),                  //承租人AccountId
+			[]byte("100"),                   //每期租金
+			[]byte("30"),                    //每期天数
+			[]byte("500"),                   //押金
+			[]byte(endTime),                 //租期结束时间
+		}).Payload, &lease)
+		return lease
+	}
+	leaseArgs := func(fcn string, lease lib.Lease, extra ...string) [][]byte {
+		args := [][]byte{[]byte(fcn), []byte(lease.ObjectOfLease), []byte(landlord), []byte(lease.LeaseID)}
+		for _, v := range extra {
+			args = append(args, []byte(v))
+		}
+		return args
+	}
+	balance := func(accountId string) lib.Amount {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0].Balance
+	}
+	flagOverdue := func() []lib.Lease {
+		var list []lib.Lease
+		json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("flagOverdueLeases")}).Payload, &list)
+		return list
+	}
+	//非所有者不能出租，出租中的房地产不能出售或捐赠
+	checkInvokeError(t, stub, tenant, [][]byte{[]byte("createLease"), []byte(realEstateList[0].RealEstateID), []byte(landlord), []byte(tenant), []byte("100"), []byte("30"), []byte("500"), []byte(endTime)})
+	lease := createLease(realEstateList[0])
+	if lease.LeaseStatus != lib.LeaseStatusConstant()["pending"] {
+		t.Fatalf("发起租约有误: %+v", lease)
+	}
+	checkInvokeError(t, stub, landlord, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(landlord), []byte("50"), []byte("30")})
+	checkInvokeError(t, stub, landlord, [][]byte{[]byte("createDonating"), []byte(realEstateList[0].RealEstateID), []byte(landlord), []byte(tenant), []byte("30")})
+	//只有承租人可以签署，签署时支付押金和第一期租金
+	checkInvokeError(t, stub, landlord, leaseArgs("signLease", lease))
+	checkInvokeError(t, stub, tenant, leaseArgs("payRent", lease))
+	json.Unmarshal(checkInvoke(t, stub, tenant, leaseArgs("signLease", lease)).Payload, &lease)
+	if lease.LeaseStatus != lib.LeaseStatusConstant()["active"] || lease.DepositStatus != lib.EscrowStatusConstant()["held"] ||
+		balance(tenant) != 5000000*lib.Yuan-600*lib.Yuan || balance(landlord) != 5000000*lib.Yuan+100*lib.Yuan {
+		t.Fatalf("签署租约有误: %+v", lease)
+	}
+	checkInvokeError(t, stub, tenant, leaseArgs("updateLease", lease, "cancelled"))
+	//租金最多付清到租期结束
+	checkInvoke(t, stub, tenant, leaseArgs("payRent", lease))
+	json.Unmarshal(checkInvoke(t, stub, tenant, leaseArgs("payRent", lease)).Payload, &lease)
+	checkInvokeError(t, stub, tenant, leaseArgs("payRent", lease))
+	if lease.PaidUntil < lease.EndTime || balance(landlord) != 5000000*lib.Yuan+300*lib.Yuan {
+		t.Fatalf("支付租金有误: %+v", lease)
+	}
+	var payments []lib.LeasePayment
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryLeasePayments"), []byte("100"), []byte(""), []byte(landlord), []byte(lease.ObjectOfLease), []byte(lease.LeaseID)}).Payload, &payments)
+	if len(payments) != 3 || payments[2].PaidUntil != lease.PaidUntil {
+		t.Fatalf("租金支付记录有误: %+v", payments)
+	}
+
+	//欠租的租约被标记，出租人可以没收押金
+	overdue := createLease(realEstateList[1])
+	checkInvoke(t, stub, tenant, leaseArgs("signLease", overdue))
+	if list := flagOverdue(); len(list) != 0 {
+		t.Fatalf("不应标记欠租: %+v", list)
+	}
+	checkInvokeError(t, stub, landlord, leaseArgs("updateLease", overdue, "terminated", "forfeited"))
+	stub.clock = 31 * 24 * time.Hour
+	checkInvokeError(t, stub, tenant, [][]byte{[]byte("flagOverdueLeases")})
+	if list := flagOverdue(); len(list) != 1 || list[0].LeaseID != overdue.LeaseID || !list[0].RentOverdue {
+		t.Fatalf("标记欠租有误: %+v", list)
+	}
+	if list := flagOverdue(); len(list) != 0 {
+		t.Fatalf("不应重复标记欠租: %+v", list)
+	}
+	checkInvokeError(t, stub, tenant, leaseArgs("updateLease", overdue, "terminated", "refunded"))
+	checkInvokeError(t, stub, landlord, leaseArgs("updateLease", lease, "terminated", "forfeited"))
+	json.Unmarshal(checkInvoke(t, stub, landlord, leaseArgs("updateLease", overdue, "terminated", "forfeited")).Payload, &overdue)
+	if overdue.LeaseStatus != lib.LeaseStatusConstant()["terminated"] || overdue.DepositStatus != lib.EscrowStatusConstant()["forfeited"] ||
+		balance(landlord) != 5000000*lib.Yuan+900*lib.Yuan {
+		t.Fatalf("没收押金有误: %+v", overdue)
+	}
+
+	//租期结束后承租人终止并取回押金
+	checkInvokeError(t, stub, tenant, leaseArgs("updateLease", lease, "terminated", "refunded"))
+	stub.clock = 91 * 24 * time.Hour
+	json.Unmarshal(checkInvoke(t, stub, tenant, leaseArgs("updateLease", lease, "terminated", "refunded")).Payload, &lease)
+	if lease.DepositStatus != lib.EscrowStatusConstant()["refunded"] || balance(tenant) != 5000000*lib.Yuan-900*lib.Yuan {
+		t.Fatalf("退还押金有误: %+v", lease)
+	}
+
+	//待签署时可以取消
+	endTime = time.Now().Add(120 * 24 * time.Hour).Format(time.RFC3339)
+	cancelled := createLease(realEstateList[0])
+	checkInvokeError(t, stub, realEstateList[3].Proprietor, leaseArgs("updateLease", cancelled, "cancelled"))
+	checkInvoke(t, stub, tenant, leaseArgs("updateLease", cancelled, "cancelled"))
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[1].RealEstateID)}).Payload, &realEstates)
+	if realEstates[0].Encumbrance || realEstates[1].Encumbrance || realEstates[0].Proprietor != landlord {
+		t.Fatalf("租约结束后房地产有误: %+v", realEstates)
+	}
+	var leaseList []lib.Lease
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryLeaseListByTenant"), []byte("100"), []byte(""), []byte(tenant)}).Payload, &leaseList)
+	if len(leaseList) != 3 {
+		t.Fatalf("承租人的租约有误: %+v", leaseList)
+	}
+	var historyList []lib.RealEstateHistory
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateHistory"), []byte(realEstateList[0].RealEstateID)}).Payload, &historyList)
+	if last := historyList[len(historyList)-1]; last.Lease == nil || last.Lease.LeaseStatus != lib.LeaseStatusConstant()["cancelled"] {
+		t.Fatalf("房地产历史中的租约有误: %+v", last)
+	}
+
+	//租期不是整期时最后一期租金按剩余天数折算，只付清到租期结束
+	endTime = time.Now().Add((91 + 45) * 24 * time.Hour).Format(time.RFC3339)
+	partial := createLease(realEstateList[1])
+	checkInvoke(t, stub, tenant, leaseArgs("signLease", partial))
+	tenantBalance := balance(tenant)
+	json.Unmarshal(checkInvoke(t, stub, tenant, leaseArgs("payRent", partial)).Payload, &partial)
+	if partial.PaidUntil != partial.EndTime || balance(tenant) != tenantBalance-50*lib.Yuan {
+		t.Fatalf("最后一期租金折算有误: %+v %s", partial, tenantBalance-balance(tenant))
+	}
+	checkInvokeError(t, stub, tenant, leaseArgs("payRent", partial))
+	//租期结束后定时任务关闭租约：付清租金的退还押金，未签署的直接关闭，房地产解除担保
+	endTime = time.Now().Add((91 + 10) * 24 * time.Hour).Format(time.RFC3339)
+	unsigned := createLease(realEstateList[0])
+	stub.clock = (91 + 46) * 24 * time.Hour
+	tenantBalance = balance(tenant)
+	events := len(stub.events)
+	expiredList := flagOverdue()
+	if len(expiredList) != 2 || stub.events[events].EventName != "leasesExpired" {
+		t.Fatalf("到期关闭租约有误: %+v", expiredList)
+	}
+	for _, v := range expiredList {
+		if v.LeaseStatus != lib.LeaseStatusConstant()["expired"] || (v.LeaseID == partial.LeaseID) != (v.DepositStatus == lib.EscrowStatusConstant()["refunded"]) {
+			t.Fatalf("到期关闭的租约有误: %+v", v)
+		}
+	}
+	if balance(tenant) != tenantBalance+500*lib.Yuan {
+		t.Fatalf("到期后押金应退还承租人: %s", balance(tenant))
+	}
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(realEstateList[0].RealEstateID), []byte(realEstateList[1].RealEstateID)}).Payload, &realEstates)
+	if realEstates[0].Encumbrance || realEstates[1].Encumbrance {
+		t.Fatalf("租约到期后房地产有误: %+v", realEstates)
+	}
+	checkInvokeError(t, stub, tenant, leaseArgs("signLease", unsigned))
+	if list := flagOverdue(); len(list) != 0 {
+		t.Fatalf("不应重复关闭租约: %+v", list)
+	}
+}
+
+// 测试共有房地产的处分同意、按份额分配售房款和按共有人查询
+func Test_CoOwnership(t *testing.T) {
+	stub := initTest(t)
+	proprietor, coOwner, minorOwner, buyer := ownerIds[0], ownerIds[1], ownerIds[3], ownerIds[2]
+	owners := `[{"accountId":"` + proprietor + `","share":5000},{"accountId":"` + coOwner + `","share":3000},{"accountId":"` + minorOwner + `","share":2000}]`
+	createArgs := func(owners string, threshold string) [][]byte {
+		return [][]byte{
+			[]byte("createRealEstate"),
+			[]byte(adminId),    //操作人
+			[]byte(proprietor), //所有者(代表业主)
+			[]byte("90"),       //总面积
+			[]byte("70"),       //生活空间
+			[]byte(testAttributes),
+			[]byte(owners),    //共有人及份额
+			[]byte(threshold), //处分需要同意的份额合计
+		}
+	}
+	//份额合计不为100%、代表业主不在共有人中、门槛超出范围
+	checkInvokeError(t, stub, adminId, createArgs(`[{"accountId":"`+proprietor+`","share":5000},{"accountId":"`+coOwner+`","share":3000}]`, "6000"))
+	checkInvokeError(t, stub, adminId, createArgs(`[{"accountId":"`+coOwner+`","share":10000}]`, "6000"))
+	checkInvokeError(t, stub, adminId, createArgs(owners, "10001"))
+	var realEstate lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, createArgs(owners, "6000")).Payload, &realEstate)
+	if len(realEstate.Owners) != 3 || realEstate.ConsentThreshold != 6000 {
+		t.Fatalf("登记共有房地产有误: %+v", realEstate)
+	}
+	//持有任意份额的共有人都能查询到
+	for _, accountId := range []string{coOwner, minorOwner} {
+		var realEstateList []lib.RealEstate
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &realEstateList)
+		if len(realEstateList) != 1 || realEstateList[0].RealEstateID != realEstate.RealEstateID {
+			t.Fatalf("按共有人查询房地产有误: %+v", realEstateList)
+		}
+		realEstateList = nil
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("searchRealEstates"), []byte("100"), []byte(""), []byte(`{"proprietor":"` + accountId + `"}`)}).Payload, &realEstateList)
+		if len(realEstateList) != 1 || realEstateList[0].RealEstateID != realEstate.RealEstateID {
+			t.Fatalf("按共有人富查询房地产有误: %+v", realEstateList)
+		}
+	}
+
+	consent := func(accountId string, action string) [][]byte {
+		return [][]byte{[]byte("consentRealEstate"), []byte(realEstate.RealEstateID), []byte(accountId), []byte(action)}
+	}
+	createSelling := [][]byte{[]byte("createSelling"), []byte(realEstate.RealEstateID), []byte(proprietor), []byte("1000.01"), []byte("30")}
+	//代表业主的份额不足，需要其他共有人同意
+	checkInvokeError(t, stub, proprietor, createSelling)
+	checkInvokeError(t, stub, proprietor, consent(proprietor, "selling"))
+	checkInvokeError(t, stub, buyer, consent(buyer, "selling"))
+	checkInvokeError(t, stub, coOwner, consent(coOwner, "create"))
+	checkInvokeError(t, stub, minorOwner, consent(coOwner, "selling"))
+	checkInvoke(t, stub, minorOwner, consent(minorOwner, "donating"))
+	checkInvokeError(t, stub, proprietor, createSelling)
+	checkInvoke(t, stub, coOwner, consent(coOwner, "selling"))
+	var consents []lib.RealEstateConsent
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateConsents"), []byte(realEstate.RealEstateID)}).Payload, &consents)
+	if len(consents) != 2 {
+		t.Fatalf("共有人的同意有误: %+v", consents)
+	}
+	checkInvoke(t, stub, proprietor, createSelling)
+	consents = nil
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateConsents"), []byte(realEstate.RealEstateID)}).Payload, &consents)
+	if len(consents) != 1 || consents[0].Action != "donating" {
+		t.Fatalf("发起销售后应删除已使用的同意: %+v", consents)
+	}
+
+	//售房款按份额分配，除不尽的部分计入代表业主
+	checkInvoke(t, stub, buyer, [][]byte{[]byte("createSellingByBuy"), []byte(realEstate.RealEstateID), []byte(proprietor), []byte(buyer)})
+	checkInvoke(t, stub, proprietor, [][]byte{[]byte("updateSelling"), []byte(realEstate.RealEstateID), []byte(proprietor), []byte(buyer), []byte("done")})
+	balance := func(accountId string) lib.Amount {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0].Balance
+	}
+	for accountId, expected := range map[string]lib.Amount{
+		proprietor: 5000000*lib.Yuan + 50001,
+		coOwner:    5000000*lib.Yuan + 300*lib.Yuan,
+		minorOwner: 5000000*lib.Yuan + 200*lib.Yuan,
+		buyer:      5000000*lib.Yuan - 100001,
+	} {
+		if actual := balance(accountId); actual != expected {
+			t.Fatalf("%s售房款分配有误: %s", accountId, actual)
+		}
+	}
+	//过户后买家单独所有，原共有人查询不到
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(realEstate.RealEstateID)}).Payload, &realEstates)
+	if realEstates[0].Proprietor != buyer || len(realEstates[0].Owners) != 1 || realEstates[0].Owners[0].Share != lib.ShareTotal {
+		t.Fatalf("过户后共有人有误: %+v", realEstates[0])
+	}
+	var realEstateList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateList"), []byte("100"), []byte(""), []byte(coOwner)}).Payload, &realEstateList)
+	if len(realEstateList) != 0 {
+		t.Fatalf("过户后原共有人不应查询到房地产: %+v", realEstateList)
+	}
+
+	//共有人购买共有的房地产，分得的售房款和缴纳的契税都要计入同一账户
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("setFeeSchedule"), []byte(adminId), []byte("300"), []byte("0"), []byte("0"), []byte(adminId)})
+	var funds lib.FundsSummary
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &funds)
+	realEstate = lib.RealEstate{}
+	json.Unmarshal(checkInvoke(t, stub, adminId, createArgs(`[{"accountId":"`+proprietor+`","share":5000},{"accountId":"`+coOwner+`","share":5000}]`, "5000")).Payload, &realEstate)
+	proprietorBalance, coOwnerBalance := balance(proprietor), balance(coOwner)
+	checkInvoke(t, stub, proprietor, [][]byte{[]byte("createSelling"), []byte(realEstate.RealEstateID), []byte(proprietor), []byte("1000"), []byte("30")})
+	checkInvoke(t, stub, coOwner, [][]byte{[]byte("createSellingByBuy"), []byte(realEstate.RealEstateID), []byte(proprietor), []byte(coOwner)})
+	checkInvoke(t, stub, proprietor, [][]byte{[]byte("updateSelling"), []byte(realEstate.RealEstateID), []byte(proprietor), []byte(coOwner), []byte("done")})
+	if balance(proprietor) != proprietorBalance+500*lib.Yuan || balance(coOwner) != coOwnerBalance-530*lib.Yuan || balance(adminId) != 30*lib.Yuan {
+		t.Fatalf("共有人购买后余额有误: %s %s %s", balance(proprietor), balance(coOwner), balance(adminId))
+	}
+	var fundsAfter lib.FundsSummary
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &fundsAfter)
+	if fundsAfter.Total != funds.Total {
+		t.Fatalf("共有人购买后资金总额不平: %s %s", funds.Total, fundsAfter.Total)
+	}
+}
+
+// 测试登记员拆分和合并房地产
+func Test_SplitMerge(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	proprietor := realEstateList[0].Proprietor
+	split := func(realEstate lib.RealEstate, parts string) [][]byte {
+		return [][]byte{[]byte("splitRealEstate"), []byte(adminId), []byte(realEstate.RealEstateID), []byte(parts)}
+	}
+	//只有登记员可以拆分，面积合计必须一致
+	checkInvokeError(t, stub, proprietor, split(realEstateList[0], `[{"totalArea":20,"livingSpace":10},{"totalArea":30,"livingSpace":20}]`))
+	checkInvokeError(t, stub, adminId, split(realEstateList[0], `[{"totalArea":20,"livingSpace":10},{"totalArea":20,"livingSpace":10}]`))
+	checkInvokeError(t, stub, adminId, split(realEstateList[0], `[{"totalArea":50,"livingSpace":30}]`))
+	var splitList []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, split(realEstateList[0], `[{"totalArea":20.2,"livingSpace":10.1},{"totalArea":29.8,"livingSpace":19.9}]`)).Payload, &splitList)
+	if len(splitList) != 3 || !splitList[0].Retired || len(splitList[0].Children) != 2 {
+		t.Fatalf("拆分有误: %+v", splitList)
+	}
+	children := splitList[1:]
+	for _, v := range children {
+		if v.Proprietor != proprietor || v.Retired || len(v.Parents) != 1 || v.Parents[0] != realEstateList[0].RealEstateID {
+			t.Fatalf("拆分后的房地产有误: %+v", v)
+		}
+	}
+	//拆分后原房地产不再有效，业主名下只有拆分后的房地产
+	checkInvokeError(t, stub, adminId, split(realEstateList[0], `[{"totalArea":20,"livingSpace":10},{"totalArea":30,"livingSpace":20}]`))
+	checkInvokeError(t, stub, proprietor, [][]byte{[]byte("createSelling"), []byte(realEstateList[0].RealEstateID), []byte(proprietor), []byte("50"), []byte("30")})
+	var ownedList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateList"), []byte("100"), []byte(""), []byte(proprietor)}).Payload, &ownedList)
+	if len(ownedList) != 3 {
+		t.Fatalf("拆分后业主名下的房地产有误: %+v", ownedList)
+	}
+	for _, v := range ownedList {
+		if v.RealEstateID == realEstateList[0].RealEstateID {
+			t.Fatalf("拆分前的房地产不应在业主名下: %+v", ownedList)
+		}
+	}
+
+	merge := func(realEstateIds ...string) [][]byte {
+		args := [][]byte{[]byte("mergeRealEstate"), []byte(adminId)}
+		for _, v := range realEstateIds {
+			args = append(args, []byte(v))
+		}
+		return args
+	}
+	//担保状态、所有权不同或重复的房地产不能合并
+	checkInvoke(t, stub, proprietor, [][]byte{[]byte("createSelling"), []byte(realEstateList[1].RealEstateID), []byte(proprietor), []byte("50"), []byte("30")})
+	checkInvokeError(t, stub, adminId, merge(children[0].RealEstateID, realEstateList[1].RealEstateID))
+	checkInvokeError(t, stub, adminId, split(realEstateList[1], `[{"totalArea":40,"livingSpace":30},{"totalArea":40,"livingSpace":30.8}]`))
+	checkInvokeError(t, stub, adminId, merge(children[0].RealEstateID, realEstateList[2].RealEstateID))
+	checkInvokeError(t, stub, adminId, merge(children[0].RealEstateID, children[0].RealEstateID))
+	checkInvokeError(t, stub, proprietor, merge(children[0].RealEstateID, children[1].RealEstateID))
+	var mergedList []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, merge(children[0].RealEstateID, children[1].RealEstateID)).Payload, &mergedList)
+	merged := mergedList[len(mergedList)-1]
+	if len(mergedList) != 3 || !mergedList[0].Retired || !mergedList[1].Retired || merged.Retired ||
+		!isAreaClose(merged.TotalArea, 50) || !isAreaClose(merged.LivingSpace, 30) || len(merged.Parents) != 2 || merged.Proprietor != proprietor {
+		t.Fatalf("合并有误: %+v", mergedList)
+	}
+	//富查询不包括已拆分或合并的房地产
+	var searchedList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("searchRealEstates"), []byte("100"), []byte(""), []byte(`{"proprietor":"` + proprietor + `"}`)}).Payload, &searchedList)
+	if len(searchedList) != 2 {
+		t.Fatalf("富查询应只返回有效的房地产: %+v", searchedList)
+	}
+	var historyList []lib.RealEstateHistory
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateHistory"), []byte(children[0].RealEstateID)}).Payload, &historyList)
+	if len(historyList) != 2 || historyList[0].RealEstate.CauseType != lib.RealEstateCauseConstant()["split"] || historyList[1].RealEstate.CauseType != lib.RealEstateCauseConstant()["merge"] {
+		t.Fatalf("拆分合并的历史有误: %+v", historyList)
+	}
+}
+
+func isAreaClose(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// 测试用的房地产登记信息
+const testAttributes = `{"address":"人民路1号1单元101","district":"西湖区","propertyType":"residential","floor":1,"buildYear":2010,"certificateNo":"浙(2010)杭州市不动产权第0000001号","usageTerm":70}`
+
+// 测试登记信息的校验、登记员变更登记信息及审计记录
+func Test_UpdateRealEstate(t *testing.T) {
+	stub := initTest(t)
+	createArgs := func(attributes string) [][]byte {
+		return [][]byte{
+			[]byte("createRealEstate"),
+			[]byte(adminId),     //操作人
+			[]byte(ownerIds[0]), //所有者
+			[]byte("50"),        //总面积
+			[]byte("30"),        //生活空间
+			[]byte(attributes),  //登记信息
+		}
+	}
+	//登记信息缺失、用途不支持、使用权年限超过用途的最高年限、建成年份晚于交易时间、楼层为0
+	checkInvokeError(t, stub, adminId, createArgs(`{"address":"人民路1号","propertyType":"residential","floor":1,"buildYear":2010,"certificateNo":"001","usageTerm":70}`))
+	checkInvokeError(t, stub, adminId, createArgs(strings.Replace(testAttributes, "residential", "farm", 1)))
+	checkInvokeError(t, stub, adminId, createArgs(strings.Replace(testAttributes, "residential", "commercial", 1)))
+	checkInvokeError(t, stub, adminId, createArgs(strings.Replace(testAttributes, "2010,", "2999,", 1)))
+	checkInvokeError(t, stub, adminId, createArgs(strings.Replace(testAttributes, `"floor":1`, `"floor":0`, 1)))
+	var realEstate lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, createArgs(testAttributes)).Payload, &realEstate)
+	if realEstate.District != "西湖区" || realEstate.UsageTerm != 70 || realEstate.BuildYear != 2010 {
+		t.Fatalf("登记信息有误: %+v", realEstate)
+	}
+
+	updated := strings.Replace(testAttributes, "人民路1号1单元101", "人民路1号1单元102", 1)
+	updateArgs := func(accountId string, attributes string, reason string) [][]byte {
+		return [][]byte{[]byte("updateRealEstate"), []byte(accountId), []byte(realEstate.RealEstateID), []byte(attributes), []byte(reason)}
+	}
+	//只有登记员可以变更，必须说明原因，信息必须有效且有变化
+	checkInvokeError(t, stub, ownerIds[0], updateArgs(ownerIds[0], updated, "门牌号登记错误"))
+	checkInvokeError(t, stub, adminId, updateArgs(adminId, updated, ""))
+	checkInvokeError(t, stub, adminId, updateArgs(adminId, strings.Replace(updated, `"floor":1`, `"floor":0`, 1), "门牌号登记错误"))
+	checkInvokeError(t, stub, adminId, updateArgs(adminId, testAttributes, "门牌号登记错误"))
+	json.Unmarshal(checkInvoke(t, stub, adminId, updateArgs(adminId, updated, "门牌号登记错误")).Payload, &realEstate)
+	if realEstate.Address != "人民路1号1单元102" || realEstate.CauseType != lib.RealEstateCauseConstant()["update"] || realEstate.TotalArea != 50 {
+		t.Fatalf("变更登记信息有误: %+v", realEstate)
+	}
+
+	var updateList []lib.RealEstateUpdate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateUpdates"), []byte("100"), []byte(""), []byte(realEstate.RealEstateID)}).Payload, &updateList)
+	if len(updateList) != 1 || updateList[0].Reason != "门牌号登记错误" || updateList[0].Operator != adminId ||
+		updateList[0].Before.Address != "人民路1号1单元101" || updateList[0].After.Address != "人民路1号1单元102" {
+		t.Fatalf("变更记录有误: %+v", updateList)
+	}
+	var historyList []lib.RealEstateHistory
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateHistory"), []byte(realEstate.RealEstateID)}).Payload, &historyList)
+	if len(historyList) != 2 || historyList[1].Update == nil || historyList[1].Update.Reason != "门牌号登记错误" {
+		t.Fatalf("变更登记信息的历史有误: %+v", historyList)
+	}
+	//按行政区和用途富查询
+	var searchedList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("searchRealEstates"), []byte("100"), []byte(""), []byte(`{"district":"西湖区","propertyType":"residential"}`)}).Payload, &searchedList)
+	if len(searchedList) != 1 || searchedList[0].RealEstateID != realEstate.RealEstateID {
+		t.Fatalf("按登记信息富查询有误: %+v", searchedList)
+	}
+	var otherDistrictList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("searchRealEstates"), []byte("100"), []byte(""), []byte(`{"district":"上城区"}`)}).Payload, &otherDistrictList)
+	if len(otherDistrictList) != 0 {
+		t.Fatalf("按行政区富查询有误: %+v", otherDistrictList)
+	}
+	//查询业主名下房地产时返回登记信息
+	var realEstateList []lib.RealEstate
+	unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstateList"), []byte("100"), []byte(""), []byte(ownerIds[0])}).Payload, &realEstateList)
+	if len(realEstateList) != 1 || realEstateList[0].CertificateNo == "" || realEstateList[0].Address != "人民路1号1单元102" {
+		t.Fatalf("查询房地产列表未返回登记信息: %+v", realEstateList)
+	}
+}
+
+// 测试为房地产和销售登记文档哈希及查询
+func Test_Document(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	proprietor := realEstateList[0].Proprietor
+	deed := sha256.Sum256([]byte("产权证扫描件"))
+	deedHash := hex.EncodeToString(deed[:])
+	anchor := func(accountId string, objectType string, hash string, objectKey ...string) [][]byte {
+		args := [][]byte{[]byte("anchorDocument"), []byte(accountId), []byte(objectType), []byte(hash), []byte("application/pdf"), []byte("产权证.pdf")}
+		for _, v := range objectKey {
+			args = append(args, []byte(v))
+		}
+		return args
+	}
+	//哈希格式错误、对象类型不支持、对象不存在、不是当事人
+	checkInvokeError(t, stub, proprietor, anchor(proprietor, "realEstate", strings.ToUpper(deedHash), realEstateList[0].RealEstateID))
+	checkInvokeError(t, stub, proprietor, anchor(proprietor, "realEstate", deedHash[:60], realEstateList[0].RealEstateID))
+	checkInvokeError(t, stub, proprietor, anchor(proprietor, "lease", deedHash, realEstateList[0].RealEstateID))
+	checkInvokeError(t, stub, proprietor, anchor(proprietor, "realEstate", deedHash, "123"))
+	checkInvokeError(t, stub, proprietor, anchor(proprietor, "realEstate", deedHash, realEstateList[0].RealEstateID, proprietor))
+	checkInvokeError(t, stub, realEstateList[2].Proprietor, anchor(realEstateList[2].Proprietor, "realEstate", deedHash, realEstateList[0].RealEstateID))
+	var document lib.Document
+	json.Unmarshal(checkInvoke(t, stub, proprietor, anchor(proprietor, "realEstate", deedHash, realEstateList[0].RealEstateID)).Payload, &document)
+	if document.Uploader != proprietor || document.Hash != deedHash || document.MediaType != "application/pdf" {
+		t.Fatalf("登记文档有误: %+v", document)
+	}
+	//同一文档不能重复登记，登记员可以为任何对象登记
+	checkInvokeError(t, stub, proprietor, anchor(proprietor, "realEstate", deedHash, realEstateList[0].RealEstateID))
+	checkInvoke(t, stub, adminId, anchor(adminId, "realEstate", deedHash, realEstateList[2].RealEstateID))
+
+	//销售的卖家可以登记买卖合同，其他业主不能
+	checkInvoke(t, stub, proprietor, [][]byte{[]byte("createSelling"), []byte(realEstateList[1].RealEstateID), []byte(proprietor), []byte("50"), []byte("30")})
+	contract := sha256.Sum256([]byte("买卖合同"))
+	contractHash := hex.EncodeToString(contract[:])
+	checkInvokeError(t, stub, ownerIds[1], anchor(ownerIds[1], "selling", contractHash, proprietor, realEstateList[1].RealEstateID))
+	checkInvoke(t, stub, proprietor, anchor(proprietor, "selling", contractHash, proprietor, realEstateList[1].RealEstateID))
+
+	var documentList []lib.Document
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryDocuments"), []byte("realEstate"), []byte(realEstateList[0].RealEstateID)}).Payload, &documentList)
+	if len(documentList) != 1 || documentList[0].Hash != deedHash {
+		t.Fatalf("查询房地产的文档有误: %+v", documentList)
+	}
+	var matchedList []lib.Document
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryDocuments"), []byte("selling"), []byte(proprietor), []byte(realEstateList[1].RealEstateID), []byte(deedHash)}).Payload, &matchedList)
+	if len(matchedList) != 0 {
+		t.Fatalf("按哈希查询不应匹配其他文档: %+v", matchedList)
+	}
+	checkInvokeError(t, stub, adminId, [][]byte{[]byte("queryDocuments"), []byte("selling"), []byte(proprietor)})
+
+	//下载文档与登记一致只允许当事人，登记员和审计员可以下载任何文档
+	download := func(accountId string, hash string) [][]byte {
+		return [][]byte{[]byte("queryDocument"), []byte(accountId), []byte("realEstate"), []byte(hash), []byte(realEstateList[0].RealEstateID)}
+	}
+	outsider := realEstateList[2].Proprietor
+	json.Unmarshal(checkInvoke(t, stub, proprietor, download(proprietor, deedHash)).Payload, &document)
+	if document.Hash != deedHash || document.Name != "产权证.pdf" {
+		t.Fatalf("下载文档的记录有误: %+v", document)
+	}
+	checkInvoke(t, stub, adminId, download(adminId, deedHash))
+	checkInvokeError(t, stub, outsider, download(outsider, deedHash))
+	checkInvokeError(t, stub, proprietor, download(outsider, deedHash))
+	checkInvokeError(t, stub, proprietor, download(proprietor, contractHash))
+	checkInvoke(t, stub, adminId, [][]byte{[]byte("updateAccountRoles"), []byte(adminId), []byte(outsider), []byte("auditor")})
+	checkInvoke(t, stub, outsider, download(outsider, deedHash))
+}
+
+// 测试余额、成交价和受赠人写入私有数据集合，账本中只保留公开记录
+func Test_PrivateData(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer, other := realEstateList[0].Proprietor, realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	objectOfSale := realEstateList[0].RealEstateID
+	withTransient := func(name string, value string, caller string, args [][]byte) peer.Response {
+		stub.transient = map[string][]byte{name: []byte(value)}
+		defer func() { stub.transient = nil }()
+		return checkInvoke(t, stub, caller, args)
+	}
+	publicState := func(objectType string, keys ...string) []byte {
+		key, _ := stub.CreateCompositeKey(objectType, keys)
+		return stub.State[key]
+	}
+	queryAccount := func(caller string, accountId string) lib.Account {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, caller, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0]
+	}
+
+	//金额通过transient传入，账本中的账户不含余额，只有持有人和银行可以看到
+	withTransient("amount", "1000", adminId, [][]byte{[]byte("deposit"), []byte(adminId), []byte(seller), []byte("")})
+	var account lib.Account
+	json.Unmarshal(publicState(lib.AccountKey, seller), &account)
+	if account.AccountId != seller || account.Balance != 0 {
+		t.Fatalf("账本中的账户不应包含余额: %+v", account)
+	}
+	if a := queryAccount(seller, seller); a.Balance != 5000000*lib.Yuan+1000*lib.Yuan {
+		t.Fatalf("持有人查询余额有误: %+v", a)
+	}
+	if a := queryAccount(adminId, seller); a.Balance != 5000000*lib.Yuan+1000*lib.Yuan {
+		t.Fatalf("银行查询余额有误: %+v", a)
+	}
+	if a := queryAccount(other, seller); a.Balance != 0 {
+		t.Fatalf("其他业主不应看到余额: %+v", a)
+	}
+	for key := range stub.State {
+		if strings.Contains(key, lib.JournalKey) {
+			t.Fatalf("流水不应写入账本: %s", key)
+		}
+	}
+
+	//买家通过transient传入，账本中的销售不含买家，价格为售价
+	checkInvoke(t, stub, seller, [][]byte{[]byte("createSelling"), []byte(objectOfSale), []byte(seller), []byte("300"), []byte("30")})
+	withTransient("buyer", buyer, buyer, [][]byte{[]byte("createSellingByBuy"), []byte(objectOfSale), []byte(seller), []byte("")})
+	withTransient("buyer", buyer, seller, [][]byte{[]byte("updateSelling"), []byte(objectOfSale), []byte(seller), []byte(""), []byte("done")})
+	var selling lib.Selling
+	json.Unmarshal(publicState(lib.SellingKey, seller, objectOfSale), &selling)
+	if selling.Buyer != "" || selling.Price != 300*lib.Yuan || selling.SellingStatus != lib.SellingStatusConstant()["done"] {
+		t.Fatalf("账本中的销售不应包含买家: %+v", selling)
+	}
+	querySelling := func(caller string) lib.Selling {
+		var sellingList []lib.Selling
+		unmarshalRecords(checkInvoke(t, stub, caller, [][]byte{[]byte("querySellingList"), []byte("100"), []byte(""), []byte(seller), []byte(objectOfSale)}).Payload, &sellingList)
+		return sellingList[0]
+	}
+	if s := querySelling(seller); s.Buyer != buyer {
+		t.Fatalf("卖家应看到买家: %+v", s)
+	}
+	if s := querySelling(other); s.Buyer != "" {
+		t.Fatalf("其他业主不应看到买家: %+v", s)
+	}
+	for key := range stub.State {
+		if strings.Contains(key, lib.SellingBuyKey) {
+			t.Fatalf("买家购买不应写入账本: %s", key)
+		}
+	}
+	var sellingBuyList []lib.SellingBuy
+	unmarshalRecords(checkInvoke(t, stub, buyer, [][]byte{[]byte("querySellingListByBuyer"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &sellingBuyList)
+	if len(sellingBuyList) != 1 || sellingBuyList[0].Buyer != buyer {
+		t.Fatalf("买家查询购买有误: %+v", sellingBuyList)
+	}
+	checkInvokeError(t, stub, other, [][]byte{[]byte("querySellingListByBuyer"), []byte("100"), []byte(""), []byte(buyer)})
+
+	//受赠人通过transient传入，账本中的捐赠不含捐赠人和受赠人，复合键也不含捐赠人
+	donatingObject := realEstateList[2].RealEstateID
+	withTransient("grantee", other, buyer, [][]byte{[]byte("createDonating"), []byte(donatingObject), []byte(buyer), []byte(""), []byte("30")})
+	var donatingList []lib.Donating
+	unmarshalRecords(checkInvoke(t, stub, seller, [][]byte{[]byte("queryDonatingList"), []byte("100"), []byte("")}).Payload, &donatingList)
+	if len(donatingList) != 1 || donatingList[0].Donor != "" || donatingList[0].Grantee != "" {
+		t.Fatalf("其他业主不应看到捐赠人和受赠人: %+v", donatingList)
+	}
+	for key := range stub.State {
+		if strings.Contains(key, lib.DonatingKey) && strings.Contains(key, buyer) {
+			t.Fatalf("捐赠的复合键不应包含捐赠人: %s", key)
+		}
+	}
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("queryDonatingList"), []byte("100"), []byte(""), []byte(buyer)})
+	donatingList = nil
+	unmarshalRecords(checkInvoke(t, stub, buyer, [][]byte{[]byte("queryDonatingList"), []byte("100"), []byte(""), []byte(buyer)}).Payload, &donatingList)
+	if len(donatingList) != 1 || donatingList[0].Donor != buyer || donatingList[0].Grantee != other {
+		t.Fatalf("捐赠人查询捐赠有误: %+v", donatingList)
+	}
+	//公开记录中保存私有数据集合中完整记录的哈希
+	donatingPrefix, _ := stub.CreateCompositeKey(lib.DonatingKey, []string{donatingObject})
+	hashed := 0
+	for key, value := range stub.State {
+		if !strings.HasPrefix(key, donatingPrefix) {
+			continue
+		}
+		var donating lib.Donating
+		json.Unmarshal(value, &donating)
+		hash := sha256.Sum256(stub.PvtState[lib.DealCollection][key])
+		if donating.PrivateHash != hex.EncodeToString(hash[:]) {
+			t.Fatalf("公开记录中的哈希有误: %+v", donating)
+		}
+		hashed++
+	}
+	if hashed != 1 {
+		t.Fatalf("账本中的捐赠有误: %d", hashed)
+	}
+	var donatingGranteeList []lib.DonatingGrantee
+	unmarshalRecords(checkInvoke(t, stub, other, [][]byte{[]byte("queryDonatingListByGrantee"), []byte("100"), []byte(""), []byte(other)}).Payload, &donatingGranteeList)
+	if len(donatingGranteeList) != 1 || donatingGranteeList[0].Donating.Grantee != other {
+		t.Fatalf("受赠人查询捐赠有误: %+v", donatingGranteeList)
+	}
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("queryDonatingListByGrantee"), []byte("100"), []byte(""), []byte(other)})
+	withTransient("grantee", other, other, [][]byte{[]byte("updateDonating"), []byte(donatingObject), []byte(buyer), []byte(""), []byte("done")})
+	var realEstates []lib.RealEstate
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryRealEstate"), []byte(donatingObject)}).Payload, &realEstates)
+	if realEstates[0].Proprietor != other {
+		t.Fatalf("受赠后房产信息有误: %+v", realEstates)
+	}
+
+	//升级前写入账本的完整记录迁移到私有数据集合
+	legacy := lib.Account{AccountId: "legacy", UserName: "升级前账户", Balance: 100 * lib.Yuan}
+	legacyByte, _ := json.Marshal(legacy)
+	legacyKey, _ := stub.CreateCompositeKey(lib.AccountKey, []string{legacy.AccountId})
+	legacyPledge := lib.Pledge{PledgeID: "legacy", ObjectOfPledge: donatingObject, Pledgor: other, Lender: seller, LoanAmount: 500 * lib.Yuan, PledgeStatus: lib.PledgeStatusConstant()["cancelled"]}
+	legacyPledgeByte, _ := json.Marshal(legacyPledge)
+	legacyPledgeKey, _ := stub.CreateCompositeKey(lib.PledgeKey, []string{other, donatingObject, legacyPledge.PledgeID})
+	stub.MockTransactionStart("legacy")
+	stub.MockStub.PutState(legacyKey, legacyByte)
+	stub.MockStub.PutState(legacyPledgeKey, legacyPledgeByte)
+	stub.MockTransactionEnd("legacy")
+	//之前迁移过的捐赠复合键为[Donor, ObjectOfDonating, CreateTime]，完整记录在私有数据集合中
+	migratedTime := time.Now().Add(-time.Hour).UTC()
+	migratedDonating := lib.Donating{ObjectOfDonating: objectOfSale, Donor: seller, Grantee: other, CreateTime: migratedTime.Format(time.RFC3339Nano), DonatingStatus: lib.DonatingStatusConstant()["cancelled"]}
+	migratedByte, _ := json.Marshal(migratedDonating)
+	migratedDonating.Grantee = ""
+	migratedPublicByte, _ := json.Marshal(migratedDonating)
+	migratedKey := putLegacyState(stub, lib.DonatingKey, []string{seller, objectOfSale, fmt.Sprintf("%d", migratedTime.UnixNano())}, string(migratedPublicByte))
+	stub.PvtState[lib.DealCollection][migratedKey] = migratedByte
+	checkInvokeError(t, stub, seller, [][]byte{[]byte("migratePrivateData"), []byte(seller)})
+	var migrated map[string]int
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("migratePrivateData"), []byte(adminId)}).Payload, &migrated)
+	if migrated[lib.AccountKey] != 1 || migrated[lib.PledgeKey] != 1 || migrated[lib.DonatingKey] != 1 {
+		t.Fatalf("迁移私有数据有误: %+v", migrated)
+	}
+	if stub.State[migratedKey] != nil || stub.PvtState[lib.DealCollection][migratedKey] != nil {
+		t.Fatalf("之前迁移过的捐赠应删除原复合键: %s", migratedKey)
+	}
+	donatingList = nil
+	unmarshalRecords(checkInvoke(t, stub, seller, [][]byte{[]byte("queryDonatingList"), []byte("100"), []byte(""), []byte(seller)}).Payload, &donatingList)
+	if len(donatingList) != 1 || donatingList[0].ObjectOfDonating != objectOfSale || donatingList[0].Grantee != other {
+		t.Fatalf("之前迁移过的捐赠重新写入有误: %+v", donatingList)
+	}
+	var pledge lib.Pledge
+	json.Unmarshal(publicState(lib.PledgeKey, other, donatingObject, legacyPledge.PledgeID), &pledge)
+	if pledge.LoanAmount != 0 || pledge.PrivateHash == "" {
+		t.Fatalf("迁移后账本中的质押不应包含金额: %+v", pledge)
+	}
+	json.Unmarshal(publicState(lib.AccountKey, legacy.AccountId), &account)
+	if account.Balance != 0 || queryAccount(adminId, legacy.AccountId).Balance != 100*lib.Yuan {
+		t.Fatalf("迁移后账户有误: %+v", account)
+	}
+	migrated = nil
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("migratePrivateData"), []byte(adminId)}).Payload, &migrated)
+	if migrated[lib.AccountKey] != 0 || migrated[lib.PledgeKey] != 0 || migrated[lib.DonatingKey] != 0 {
+		t.Fatalf("重复迁移私有数据有误: %+v", migrated)
+	}
+
+	//权限校验只读取账本中的公开账户，没有保存账户私有数据的节点也可以背书
+	stub.denied = map[string]bool{lib.AccountCollection: true}
+	checkInvoke(t, stub, other, [][]byte{[]byte("queryRealEstate"), []byte(donatingObject)})
+	stub.denied = nil
+}
+
+// 测试销售和捐赠完成时按税费标准收取税费并生成收据
+func Test_FeeSchedule(t *testing.T) {
+	stub := initTest(t)
+	realEstateList := checkCreateRealEstate(stub, t)
+	seller, buyer, grantee := realEstateList[0].Proprietor, realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	balance := func(accountId string) lib.Amount {
+		var accountList []lib.Account
+		unmarshalRecords(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountList"), []byte("100"), []byte(""), []byte(accountId)}).Payload, &accountList)
+		return accountList[0].Balance
+	}
+	setFeeSchedule := func(caller string, deedTaxRate string, transactionFeeRate string, giftTaxPerArea string, treasury string) [][]byte {
+		return [][]byte{[]byte("setFeeSchedule"), []byte(caller), []byte(deedTaxRate), []byte(transactionFeeRate), []byte(giftTaxPerArea), []byte(treasury)}
+	}
+	//未设置时不收取税费
+	var schedule lib.FeeSchedule
+	json.Unmarshal(checkInvoke(t, stub, seller, [][]byte{[]byte("queryFeeSchedule")}).Payload, &schedule)
+	if schedule.DeedTaxRate != 0 || schedule.TransactionFeeRate != 0 || schedule.GiftTaxPerArea != 0 || schedule.Treasury != "" {
+		t.Fatalf("默认税费标准有误: %+v", schedule)
+	}
+	//只有登记员可以设置，税率不能超过100%，收取税费时必须指定不是业主的国库账户
+	checkInvokeError(t, stub, seller, setFeeSchedule(seller, "300", "100", "10", adminId))
+	checkInvokeError(t, stub, adminId, setFeeSchedule(adminId, "10001", "100", "10", adminId))
+	checkInvokeError(t, stub, adminId, setFeeSchedule(adminId, "300", "-1", "10", adminId))
+	checkInvokeError(t, stub, adminId, setFeeSchedule(adminId, "300", "100", "10", ""))
+	checkInvokeError(t, stub, adminId, setFeeSchedule(adminId, "300", "100", "10", seller))
+	json.Unmarshal(checkInvoke(t, stub, adminId, setFeeSchedule(adminId, "300", "100", "10", adminId)).Payload, &schedule)
+	if schedule.DeedTaxRate != 300 || schedule.TransactionFeeRate != 100 || schedule.GiftTaxPerArea != 10*lib.Yuan || schedule.Treasury != adminId || schedule.Operator != adminId {
+		t.Fatalf("设置税费标准有误: %+v", schedule)
+	}
+
+	//买家购买时3%契税随购房资金一起托管，确认收款时卖家从售房资金中缴纳1%手续费
+	objectOfSale := realEstateList[0].RealEstateID
+	checkInvoke(t, stub, seller, [][]byte{[]byte("createSelling"), []byte(objectOfSale), []byte(seller), []byte("1000"), []byte("30")})
+	checkInvoke(t, stub, buyer, [][]byte{[]byte("createSellingByBuy"), []byte(objectOfSale), []byte(seller), []byte(buyer)})
+	var funds lib.FundsSummary
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryFundsSummary")}).Payload, &funds)
+	if balance(buyer) != 5000000*lib.Yuan-1030*lib.Yuan || funds.Escrow != 1030*lib.Yuan {
+		t.Fatalf("购买时应托管契税: %s %+v", balance(buyer), funds)
+	}
+	checkInvoke(t, stub, seller, [][]byte{[]byte("updateSelling"), []byte(objectOfSale), []byte(seller), []byte(buyer), []byte("done")})
+	sellingTxID := fmt.Sprintf("tx%d", txCount)
+	if balance(seller) != 5000000*lib.Yuan+990*lib.Yuan || balance(buyer) != 5000000*lib.Yuan-1030*lib.Yuan || balance(adminId) != 40*lib.Yuan {
+		t.Fatalf("收取税费后余额有误: %s %s %s", balance(seller), balance(buyer), balance(adminId))
+	}
+	var receipt lib.Receipt
+	json.Unmarshal(checkInvoke(t, stub, buyer, [][]byte{[]byte("queryReceipt"), []byte(sellingTxID)}).Payload, &receipt)
+	if receipt.TxID != sellingTxID || receipt.Price != 1000*lib.Yuan || receipt.Proceeds != 990*lib.Yuan || receipt.Total != 40*lib.Yuan || len(receipt.Items) != 2 {
+		t.Fatalf("销售的税费收据有误: %+v", receipt)
+	}
+	if receipt.Items[0].Item != lib.FeeItemConstant()["deedTax"] || receipt.Items[0].Payer != buyer || receipt.Items[0].Payee != adminId || receipt.Items[0].Amount != 30*lib.Yuan ||
+		receipt.Items[1].Item != lib.FeeItemConstant()["transactionFee"] || receipt.Items[1].Payer != seller || receipt.Items[1].Amount != 10*lib.Yuan {
+		t.Fatalf("销售的税费明细有误: %+v", receipt.Items)
+	}
+	//收据包含成交价，其他业主不能查询
+	checkInvokeError(t, stub, grantee, [][]byte{[]byte("queryReceipt"), []byte(sellingTxID)})
+	checkInvokeError(t, stub, buyer, [][]byte{[]byte("queryReceipt"), []byte("tx0")})
+
+	//确认接收捐赠时受赠人按总面积缴纳赠与税，余额不足时不能接收
+	objectOfDonating := realEstateList[1].RealEstateID
+	checkInvoke(t, stub, seller, [][]byte{[]byte("createDonating"), []byte(objectOfDonating), []byte(seller), []byte(grantee), []byte("30")})
+	checkInvoke(t, stub, adminId, setFeeSchedule(adminId, "300", "100", "100000", adminId))
+	checkInvokeError(t, stub, grantee, [][]byte{[]byte("updateDonating"), []byte(objectOfDonating), []byte(seller), []byte(grantee), []byte("done")})
+	checkInvoke(t, stub, adminId, setFeeSchedule(adminId, "300", "100", "10", adminId))
+	checkInvoke(t, stub, grantee, [][]byte{[]byte("updateDonating"), []byte(objectOfDonating), []byte(seller), []byte(grantee), []byte("done")})
+	donatingTxID := fmt.Sprintf("tx%d", txCount)
+	if balance(grantee) != 5000000*lib.Yuan-800*lib.Yuan || balance(adminId) != 840*lib.Yuan {
+		t.Fatalf("收取赠与税后余额有误: %s %s", balance(grantee), balance(adminId))
+	}
+	receipt = lib.Receipt{}
+	json.Unmarshal(checkInvoke(t, stub, seller, [][]byte{[]byte("queryReceipt"), []byte(donatingTxID)}).Payload, &receipt)
+	if receipt.ObjectType != "donating" || receipt.Total != 800*lib.Yuan || len(receipt.Items) != 1 || receipt.Items[0].Payer != grantee {
+		t.Fatalf("捐赠的税费收据有误: %+v", receipt)
+	}
+	var statement []lib.Journal
+	json.Unmarshal(checkInvoke(t, stub, adminId, [][]byte{[]byte("queryAccountStatement"), []byte(adminId)}).Payload, &statement)
+	if len(statement) != 3 {
+		t.Fatalf("国库账户流水有误: %+v", statement)
+	}
+
+	//拍卖成交时同样按成交价收取契税和交易手续费
+	objectOfAuction := realEstateList[2].RealEstateID
+	sellerBalance, buyerBalance := balance(seller), balance(buyer)
+	checkInvoke(t, stub, buyer, [][]byte{[]byte("createAuction"), []byte(objectOfAuction), []byte(buyer), []byte("900"), []byte("10"), []byte(time.Now().Add(24 * time.Hour).Format(time.RFC3339))})
+	granteeBalance := balance(grantee)
+	checkInvoke(t, stub, grantee, placeBidArgs(realEstateList[2], buyer, grantee, "900"))
+	if balance(grantee) != granteeBalance-927*lib.Yuan {
+		t.Fatalf("出价时应托管契税: %s", balance(grantee))
+	}
+	//出价被超过时契税一起退还
+	checkInvoke(t, stub, seller, placeBidArgs(realEstateList[2], buyer, seller, "1000"))
+	if balance(grantee) != granteeBalance {
+		t.Fatalf("出价被超过时应退还契税: %s", balance(grantee))
+	}
+	stub.clock = 25 * time.Hour
+	checkInvoke(t, stub, seller, [][]byte{[]byte("settleAuction"), []byte(objectOfAuction), []byte(buyer)})
+	auctionTxID := fmt.Sprintf("tx%d", txCount)
+	if balance(seller) != sellerBalance-1030*lib.Yuan || balance(buyer) != buyerBalance+990*lib.Yuan || balance(adminId) != 880*lib.Yuan {
+		t.Fatalf("拍卖收取税费后余额有误: %s %s %s", balance(seller), balance(buyer), balance(adminId))
+	}
+	receipt = lib.Receipt{}
+	json.Unmarshal(checkInvoke(t, stub, buyer, [][]byte{[]byte("queryReceipt"), []byte(auctionTxID)}).Payload, &receipt)
+	if receipt.ObjectType != "auction" || receipt.Price != 1000*lib.Yuan || receipt.Total != 40*lib.Yuan || receipt.Items[0].Payer != seller {
+		t.Fatalf("拍卖的税费收据有误: %+v", receipt)
+	}
+}
@@ -0,0 +1,83 @@
+package lib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+//金额，以分为单位的整数存储，避免浮点数累计舍入误差
+//序列化为保留两位小数的字符串，如"5000000.00"
+type Amount int64
+
+const Yuan Amount = 100 //1元=100分
+
+//解析以元为单位的金额字符串，最多两位小数，不能为负数
+func ParseAmount(value string) (Amount, error) {
+	if strings.HasPrefix(value, "-") {
+		return 0, errors.New(fmt.Sprintf("金额%s不能为负数", value))
+	}
+	return parseAmount(value)
+}
+
+func parseAmount(value string) (Amount, error) {
+	negative := strings.HasPrefix(value, "-")
+	digits := strings.TrimPrefix(value, "-")
+	integer, fraction := digits, ""
+	if i := strings.Index(digits, "."); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+	}
+	if integer == "" || len(fraction) > 2 || strings.HasSuffix(digits, ".") {
+		return 0, errors.New(fmt.Sprintf("金额%s格式错误，最多保留两位小数", value))
+	}
+	for _, c := range integer + fraction {
+		if c < '0' || c > '9' {
+			return 0, errors.New(fmt.Sprintf("金额%s格式错误", value))
+		}
+	}
+	fraction += strings.Repeat("0", 2-len(fraction))
+	cents, err := strconv.ParseInt(integer+fraction, 10, 64)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("金额%s超出范围: %s", value, err))
+	}
+	if negative {
+		cents = -cents
+	}
+	return Amount(cents), nil
+}
+
+func (a Amount) String() string {
+	sign := ""
+	cents := int64(a)
+	if cents < 0 {
+		sign = "-"
+		cents = -cents
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, cents/100, cents%100)
+}
+
+func (a Amount) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.String())
+}
+
+//兼容账本中旧的以浮点数(元)存储的金额，见migrateAmounts
+func (a *Amount) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err == nil {
+		amount, err := parseAmount(value)
+		if err != nil {
+			return err
+		}
+		*a = amount
+		return nil
+	}
+	var legacy float64
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return errors.New(fmt.Sprintf("金额%s格式错误", string(data)))
+	}
+	*a = Amount(math.Round(legacy * 100))
+	return nil
+}
@@ -1,35 +1,361 @@
 package lib
 
+import "encoding/json"
+
+//写入私有数据集合的记录在账本中的公开记录嵌入Hashed
+//PrivateHash为私有数据集合中完整记录(不含PrivateHash)JSON的SHA-256哈希，当事人可以用查询到的完整记录校验公开记录
+type Hashed struct {
+	PrivateHash string `json:"privateHash,omitempty"` //完整记录的哈希，只出现在公开记录中，升级前写入的记录为空
+}
+
+//SetPrivateHash 设置公开记录中完整记录的哈希，写入私有数据集合的完整记录设置为空
+func (h *Hashed) SetPrivateHash(hash string) {
+	h.PrivateHash = hash
+}
+
 //账户，虚拟管理员和若干业主账号
+//账户与交易提交者的身份(MSP ID + X.509证书主题)绑定，只有持有该身份的交易提交者才能以该账户操作
+//完整的账户写入AccountCollection，账本中公开的账户不含余额
 type Account struct {
-	AccountId string  `json:"accountId"` //账号ID
-	UserName  string  `json:"userName"`  //账号名
-	Balance   float64 `json:"balance"`   //余额
+	AccountId string   `json:"accountId"` //账号ID
+	UserName  string   `json:"userName"`  //账号名
+	Balance   Amount   `json:"balance"`   //余额
+	MspId     string   `json:"mspId"`     //所属组织的MSP ID
+	Subject   string   `json:"subject"`   //X.509证书主题
+	Roles     []string `json:"roles"`     //账户角色，决定可以调用的链码功能
+	Status    string   `json:"status"`    //账户状态
+	Hashed
+}
+
+//账户状态
+var AccountStatusConstant = func() map[string]string {
+	return map[string]string{
+		"active": "正常",  //可以正常交易
+		"frozen": "已冻结", //不能出售、购买、捐赠和受赠，可以查询和取消进行中的交易
+		"closed": "已注销", //账户注销后解除身份绑定，不能再进行任何操作
+	}
+}
+
+//账户角色
+var RoleConstant = func() map[string]string {
+	return map[string]string{
+		"registrar": "登记员", //登记房地产、管理账户
+		"owner":     "业主",  //持有房地产，可以出售、购买、捐赠和受赠
+		"bank":      "银行",  //管理账户资金
+		"auditor":   "审计员", //只能查询
+	}
+}
+
+//链码功能允许调用的角色，Invoke中的所有功能都要经过校验，未列出的功能不允许调用
+var PermissionConstant = func() map[string][]string {
+	all := []string{"registrar", "owner", "bank", "auditor"}
+	return map[string][]string{
+		"queryAccountList":           all,
+		"bindAccount":                {"registrar"},
+		"updateAccountRoles":         {"registrar"},
+		"createAccount":              {"registrar"},
+		"updateAccount":              all,
+		"freezeAccount":              {"registrar"},
+		"closeAccount":               {"registrar"},
+		"deposit":                    {"bank"},
+		"withdraw":                   {"bank"},
+		"transfer":                   {"owner"},
+		"queryAccountStatement":      all,
+		"migrateAmounts":             {"registrar"},
+		"migratePrivateData":         {"registrar"},
+		"migrateRealEstateKeys":      {"registrar"},
+		"createRealEstate":           {"registrar"},
+		"splitRealEstate":            {"registrar"},
+		"mergeRealEstate":            {"registrar"},
+		"updateRealEstate":           {"registrar"},
+		"queryRealEstateUpdates":     all,
+		"consentRealEstate":          {"owner"},
+		"queryRealEstateConsents":    all,
+		"queryRealEstateList":        all,
+		"queryRealEstate":            all,
+		"queryRealEstateHistory":     all,
+		"searchRealEstates":          all,
+		"createSelling":              {"owner"},
+		"createSellingByBuy":         {"owner"},
+		"querySellingList":           all,
+		"querySellingListByBuyer":    all,
+		"searchSellings":             all,
+		"updateSelling":              {"owner", "registrar"},
+		"expireSellings":             {"registrar"},
+		"createAuction":              {"owner"},
+		"placeBid":                   {"owner"},
+		"settleAuction":              {"owner", "registrar"},
+		"cancelAuction":              {"owner"},
+		"queryAuctionList":           all,
+		"queryAuctionBids":           all,
+		"createSealedAuction":        {"owner"},
+		"commitBid":                  {"owner"},
+		"revealBid":                  {"owner"},
+		"querySealedBids":            all,
+		"createOffer":                {"owner"},
+		"updateOffer":                {"owner"},
+		"queryOfferList":             all,
+		"queryOfferListByBuyer":      all,
+		"queryEscrowList":            all,
+		"queryFundsSummary":          {"registrar", "bank", "auditor"},
+		"migrateEscrows":             {"registrar"},
+		"setFeeSchedule":             {"registrar"},
+		"queryFeeSchedule":           all,
+		"queryReceipt":               all,
+		"createPledge":               {"owner"},
+		"updatePledge":               {"owner"},
+		"repayPledge":                {"owner"},
+		"queryPledgeList":            all,
+		"queryPledgeRepayments":      all,
+		"createLease":                {"owner"},
+		"signLease":                  {"owner"},
+		"payRent":                    {"owner"},
+		"updateLease":                {"owner"},
+		"flagOverdueLeases":          {"registrar"},
+		"queryLeaseList":             all,
+		"queryLeaseListByTenant":     all,
+		"queryLeasePayments":         all,
+		"createDonating":             {"owner"},
+		"queryDonatingList":          all,
+		"queryDonatingListByGrantee": all,
+		"updateDonating":             {"owner", "registrar"},
+		"expireDonatings":            {"registrar"},
+		"anchorDocument":             {"registrar", "owner"},
+		"queryDocuments":             all,
+		"queryDocument":              {"registrar", "owner", "auditor"},
+	}
+}
+
+//账户绑定的身份
+//MspId和Subject一起作为复合键,保证可以通过交易提交者的身份查询到其绑定的账户
+type AccountIdentity struct {
+	MspId     string `json:"mspId"`     //所属组织的MSP ID
+	Subject   string `json:"subject"`   //X.509证书主题
+	AccountId string `json:"accountId"` //绑定的账号ID
+}
+
+//账户流水，每次余额变动都记录一条，只写入AccountCollection
+//AccountId、CreateTime、TxID、JournalType和Reference一起作为复合键,保证可以通过AccountId按时间查询到账户的所有余额变动
+type Journal struct {
+	AccountId    string `json:"accountId"`    //账号ID
+	TxID         string `json:"txId"`         //引起余额变动的交易ID
+	JournalType  string `json:"journalType"`  //流水类型
+	Amount       Amount `json:"amount"`       //变动金额，收入为正，支出为负
+	Balance      Amount `json:"balance"`      //变动后的余额
+	Counterparty string `json:"counterparty"` //交易对方(对方AccountId)
+	Reference    string `json:"reference"`    //关联业务(如销售的房地产RealEstateID)
+	CreateTime   string `json:"createTime"`   //创建时间
+}
+
+//流水类型
+var JournalTypeConstant = func() map[string]string {
+	return map[string]string{
+		"deposit":          "存入",    //银行为账户存入资金
+		"withdraw":         "取出",    //银行为账户办理取款
+		"transferIn":       "转入",    //其他账户转入
+		"transferOut":      "转出",    //转出到其他账户
+		"purchase":         "购房付款",  //买家购买房地产付款，资金转入托管
+		"sale":             "售房收款",  //卖家确认收款，托管资金支付给卖家
+		"refund":           "退款",    //销售取消或过期，托管资金连同契税退还买家；拍卖出价被超过时退还竞拍人
+		"bid":              "竞拍出价",  //竞拍人出价，资金转入托管
+		"loanOut":          "发放贷款",  //出借人发放质押贷款
+		"loanIn":           "贷款到账",  //出质人收到质押贷款
+		"repayOut":         "偿还贷款",  //出质人偿还质押贷款
+		"repayIn":          "收回贷款",  //出借人收到还款
+		"leaseDeposit":     "支付押金",  //承租人签署租约，押金转入托管
+		"rentOut":          "支付租金",  //承租人支付租金
+		"rentIn":           "收取租金",  //出租人收到租金
+		"forfeit":          "没收押金",  //租约终止时押金支付给出租人
+		"deedTax":          "缴纳契税",  //买家购买或出价时契税随购房资金转入托管，成交时转入国库账户
+		"giftTax":          "缴纳赠与税", //捐赠完成时受赠人缴纳赠与税
+		"deedTaxIn":        "契税收入",  //国库账户收到契税
+		"transactionFeeIn": "手续费收入", //国库账户收到卖家的交易手续费
+		"giftTaxIn":        "赠与税收入", //国库账户收到赠与税
+	}
 }
 
-//房地产作为担保出售、捐赠或质押时Encumbrance为true，默认状态false。
-//仅当Encumbrance为false时，才可发起出售、捐赠或质押
-//Proprietor和RealEstateID一起作为复合键,保证可以通过Proprietor查询到名下所有的房产信息
+//房地产作为担保出售、捐赠、质押或出租时Encumbrance为true，默认状态false。
+//仅当Encumbrance为false时，才可发起出售、捐赠、质押或出租
+//RealEstateID作为主键，过户时保持不变；每个共有人的所有权都记录在RealEstateProprietor索引中
+//Proprietor为代表业主，由其发起出售、捐赠等处分，需要同意的共有人份额合计达到ConsentThreshold
+//拆分或合并后原房地产Retired为true，不再有效，Parents和Children记录拆分合并的来源和去向
+//RealEstateAttributes为地址、用途等登记信息，由登记员登记和变更，变更记录在RealEstateUpdate中
+//每次写入都记录引起变更的业务，供queryRealEstateHistory追溯
 type RealEstate struct {
-	RealEstateID string  `json:"realEstateId"` //房地产ID
-	Proprietor   string  `json:"proprietor"`   //所有者(业主)(业主AccountId)
-	Encumbrance  bool    `json:"encumbrance"`  //是否作为担保
-	TotalArea    float64 `json:"totalArea"`    //总面积
-	LivingSpace  float64 `json:"livingSpace"`  //生活空间
+	RealEstateAttributes
+	RealEstateID     string   `json:"realEstateId"`       //房地产ID
+	Proprietor       string   `json:"proprietor"`         //所有者(业主)(业主AccountId)，共有时为代表业主
+	Owners           []Owner  `json:"owners"`             //共有人及份额，单独所有时只有Proprietor一人
+	ConsentThreshold int      `json:"consentThreshold"`   //处分需要同意的份额合计，ShareTotal为需要全体共有人同意
+	Encumbrance      bool     `json:"encumbrance"`        //是否作为担保
+	TotalArea        float64  `json:"totalArea"`          //总面积
+	LivingSpace      float64  `json:"livingSpace"`        //生活空间
+	Parents          []string `json:"parents,omitempty"`  //拆分或合并前的房地产RealEstateID
+	Children         []string `json:"children,omitempty"` //拆分或合并后的房地产RealEstateID
+	Retired          bool     `json:"retired"`            //是否已拆分或合并，不再有效
+	CauseType        string   `json:"causeType"`          //引起本次变更的业务类型
+	CauseKey         []string `json:"causeKey"`           //引起本次变更的业务的复合键
+}
+
+//写入账本时附加docType，供CouchDB富查询区分记录类型
+func (r RealEstate) MarshalJSON() ([]byte, error) {
+	type realEstate RealEstate
+	return json.Marshal(&struct {
+		realEstate
+		DocType string `json:"docType"` //记录类型，固定为RealEstateKey
+	}{realEstate(r), RealEstateKey})
+}
+
+//房地产的登记信息，登记时可选，指定时所有字段都需要有效
+type RealEstateAttributes struct {
+	Address       string `json:"address"`       //地址
+	District      string `json:"district"`      //所在行政区
+	PropertyType  string `json:"propertyType"`  //房屋用途，PropertyTypeConstant的键
+	Floor         int    `json:"floor"`         //所在楼层，地下为负数
+	BuildYear     int    `json:"buildYear"`     //建成年份
+	CertificateNo string `json:"certificateNo"` //不动产权证书号
+	UsageTerm     int    `json:"usageTerm"`     //土地使用权年限(年)
+}
+
+//房屋用途
+var PropertyTypeConstant = func() map[string]string {
+	return map[string]string{
+		"residential": "住宅",
+		"commercial":  "商业",
+		"office":      "办公",
+		"industrial":  "工业",
+	}
+}
+
+//各房屋用途土地使用权的最高年限
+var UsageTermLimitConstant = func() map[string]int {
+	return map[string]int{
+		"residential": 70,
+		"commercial":  40,
+		"office":      50,
+		"industrial":  50,
+	}
+}
+
+//登记员变更房地产登记信息的记录，变更前后的信息和原因供审计
+//RealEstateID、UpdateTime和TxID一起作为复合键,保证可以按时间查询到一个房地产的所有变更
+type RealEstateUpdate struct {
+	RealEstateID string               `json:"realEstateId"` //房地产ID
+	TxID         string               `json:"txId"`         //变更的交易ID
+	Operator     string               `json:"operator"`     //操作人(登记员AccountId)
+	Reason       string               `json:"reason"`       //变更原因
+	Before       RealEstateAttributes `json:"before"`       //变更前的登记信息
+	After        RealEstateAttributes `json:"after"`        //变更后的登记信息
+	UpdateTime   string               `json:"updateTime"`   //变更时间
+}
+
+//拆分后的一部分房地产的面积
+type RealEstatePart struct {
+	TotalArea   float64 `json:"totalArea"`   //总面积
+	LivingSpace float64 `json:"livingSpace"` //生活空间
+}
+
+//房地产的一个共有人及其份额
+type Owner struct {
+	AccountId string `json:"accountId"` //共有人(业主AccountId)
+	Share     int    `json:"share"`     //份额，单位为万分之一
+}
+
+//所有共有人的份额合计，即100%
+const ShareTotal = 10000
+
+//共有人对处分房地产的同意，代表业主发起处分时使用并删除
+//RealEstateID、Action和AccountId一起作为复合键,保证可以查询到一项处分的所有同意
+type RealEstateConsent struct {
+	RealEstateID string `json:"realEstateId"` //房地产ID
+	Action       string `json:"action"`       //处分方式，RealEstateCauseConstant中除登记员操作的create、split、merge、update、migrate以外的键，如selling
+	AccountId    string `json:"accountId"`    //同意的共有人AccountId
+	CreateTime   string `json:"createTime"`   //同意时间
+}
+
+//房地产、销售或捐赠关联的文档，如产权证扫描件、签署的买卖合同、捐赠协议
+//文件由应用按内容寻址保存，账本只记录文件的SHA-256哈希、媒体类型和上传人，供校验文件未被篡改
+//ObjectType、ObjectKey和Hash一起作为复合键,保证可以查询到一个对象关联的所有文档
+type Document struct {
+	ObjectType string   `json:"objectType"` //关联对象的类型，DocumentObjectConstant的键
+	ObjectKey  []string `json:"objectKey"`  //关联对象的复合键，如房地产为[RealEstateID]，销售为[Seller, ObjectOfSale]
+	Hash       string   `json:"hash"`       //文件内容的SHA-256哈希，小写十六进制
+	MediaType  string   `json:"mediaType"`  //文件的媒体类型，如application/pdf
+	Name       string   `json:"name"`       //文件名
+	Uploader   string   `json:"uploader"`   //上传人AccountId
+	CreateTime string   `json:"createTime"` //登记时间
+}
+
+//文档可以关联的对象，值为对象复合键包含的字段个数
+var DocumentObjectConstant = func() map[string]int {
+	return map[string]int{
+		"realEstate": 1, //[RealEstateID]
+		"selling":    2, //[Seller, ObjectOfSale]
+		"donating":   2, //[ObjectOfDonating, CreateTime]，CreateTime为TimeKey格式
+	}
+}
+
+//房地产所有者索引
+//Proprietor和RealEstateID一起作为复合键,保证可以通过Proprietor查询到名下所有的房产信息，共有时每个共有人一条
+type RealEstateProprietor struct {
+	Proprietor   string `json:"proprietor"`   //所有者(业主)(业主AccountId)
+	RealEstateID string `json:"realEstateId"` //房地产ID
+}
+
+//引起房地产变更的业务类型
+var RealEstateCauseConstant = func() map[string]string {
+	return map[string]string{
+		"create":   "登记", //登记员登记房地产
+		"split":    "拆分", //登记员拆分房地产，CauseKey为拆分前后的RealEstateID
+		"merge":    "合并", //登记员合并房地产，CauseKey为合并前后的RealEstateID
+		"update":   "变更", //登记员变更登记信息，CauseKey为RealEstateUpdateKey的复合键
+		"migrate":  "迁移", //登记员将升级前以[Proprietor, RealEstateID]为复合键的房地产迁移到[RealEstateID]，CauseKey为原复合键
+		"selling":  "出售", //发起、取消、过期或完成销售，CauseKey为SellingKey的复合键
+		"donating": "捐赠", //发起、取消或完成捐赠，CauseKey为DonatingKey的复合键
+		"auction":  "拍卖", //发起、取消、流拍或成交拍卖，CauseKey为AuctionKey的复合键
+		"pledge":   "质押", //发起、取消、还清或违约处置质押，CauseKey为PledgeKey的复合键
+		"lease":    "出租", //发起、取消或终止租约，CauseKey为LeaseKey的复合键
+	}
+}
+
+//房地产的一个历史版本
+type RealEstateHistory struct {
+	TxID       string            `json:"txId"`               //引起变更的交易ID
+	Timestamp  string            `json:"timestamp"`          //交易时间
+	RealEstate RealEstate        `json:"realEstate"`         //变更后的房地产信息
+	Selling    *Selling          `json:"selling,omitempty"`  //引起本次变更的销售(该交易写入的版本)
+	Donating   *Donating         `json:"donating,omitempty"` //引起本次变更的捐赠(该交易写入的版本)
+	Auction    *Auction          `json:"auction,omitempty"`  //引起本次变更的拍卖(该交易写入的版本)
+	Pledge     *Pledge           `json:"pledge,omitempty"`   //引起本次变更的质押(该交易写入的版本)
+	Lease      *Lease            `json:"lease,omitempty"`    //引起本次变更的租约(该交易写入的版本)
+	Update     *RealEstateUpdate `json:"update,omitempty"`   //引起本次变更的登记信息变更记录
 }
 
 //销售要约
 //需要确定ObjectOfSale是否属于Seller
 //买家初始为空
 //Seller和ObjectOfSale一起作为复合键,保证可以通过seller查询到名下所有发起的销售
+//完整的销售写入DealCollection，账本中公开的销售不含买家，价格始终为卖家的售价而不是成交价
 type Selling struct {
-	ObjectOfSale  string  `json:"objectOfSale"`  //销售对象(正在出售的房地产RealEstateID)
-	Seller        string  `json:"seller"`        //发起销售人、卖家(卖家AccountId)
-	Buyer         string  `json:"buyer"`         //参与销售人、买家(买家AccountId)
-	Price         float64 `json:"price"`         //价格
-	CreateTime    string  `json:"createTime"`    //创建时间
-	SalePeriod    int     `json:"salePeriod"`    //智能合约的有效期(单位为天)
-	SellingStatus string  `json:"sellingStatus"` //销售状态
+	ObjectOfSale  string `json:"objectOfSale"`  //销售对象(正在出售的房地产RealEstateID)
+	Seller        string `json:"seller"`        //发起销售人、卖家(卖家AccountId)
+	Buyer         string `json:"buyer"`         //参与销售人、买家(买家AccountId)
+	Price         Amount `json:"price"`         //价格
+	CreateTime    string `json:"createTime"`    //创建时间
+	SalePeriod    int    `json:"salePeriod"`    //智能合约的有效期(单位为天)
+	SellingStatus string `json:"sellingStatus"` //销售状态
+	Hashed
+}
+
+//写入账本时附加docType和数值类型的价格，供CouchDB富查询区分记录类型以及按价格范围查询和排序
+//Price序列化为字符串，CouchDB只能按字典序比较，不能用于范围查询
+func (s Selling) MarshalJSON() ([]byte, error) {
+	type selling Selling
+	return json.Marshal(&struct {
+		selling
+		DocType    string `json:"docType"`    //记录类型，固定为SellingKey
+		PriceValue int64  `json:"priceValue"` //价格(分)
+	}{selling(s), SellingKey, int64(s.Price)})
 }
 
 //销售状态
@@ -45,22 +371,331 @@ var SellingStatusConstant = func() map[string]string {
 
 //买家参与销售
 //销售对象不能是买家发起的
-//Buyer和CreateTime作为复合键,保证可以通过buyer查询到名下所有参与的销售
+//Buyer和CreateTime作为复合键,保证可以通过buyer查询到名下所有参与的销售，只写入DealCollection
 type SellingBuy struct {
 	Buyer      string  `json:"buyer"`      //参与销售人、买家(买家AccountId)
 	CreateTime string  `json:"createTime"` //创建时间
 	Selling    Selling `json:"selling"`    //销售对象
 }
 
+//买家对销售中的房产的报价，卖家可以接受、拒绝或还价，卖家还价后由买家接受或拒绝
+//接受后销售以Price成交进入交付中，购房资金转入托管，同一销售的其他报价自动拒绝
+//Seller、ObjectOfSale和OfferID一起作为复合键,保证可以通过销售或卖家查询到所有报价
+//完整的报价写入DealCollection，账本中公开的报价不含买家和价格
+type Offer struct {
+	OfferID      string `json:"offerId"`      //报价ID
+	ObjectOfSale string `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
+	Seller       string `json:"seller"`       //卖家AccountId
+	Buyer        string `json:"buyer"`        //买家AccountId
+	Price        Amount `json:"price"`        //当前价格，买家的报价，卖家还价后为还价
+	OfferPrice   Amount `json:"offerPrice"`   //买家最初的报价
+	ExpireTime   string `json:"expireTime"`   //报价有效期，之后不能再接受或还价
+	CreateTime   string `json:"createTime"`   //创建时间
+	UpdateTime   string `json:"updateTime"`   //最后一次更新时间
+	OfferStatus  string `json:"offerStatus"`  //报价状态
+	Hashed
+}
+
+//报价状态
+var OfferStatusConstant = func() map[string]string {
+	return map[string]string{
+		"pending":   "待答复", //买家报价，等待卖家接受、拒绝或还价
+		"countered": "已还价", //卖家还价，等待买家接受或拒绝
+		"accepted":  "已接受", //一方接受，销售进入交付中
+		"rejected":  "已拒绝", //一方拒绝，或同一销售接受了其他报价
+		"withdrawn": "已撤回", //买家在接受之前撤回
+	}
+}
+
+//供买家查询的报价索引
+//Buyer、Seller、ObjectOfSale和OfferID一起作为复合键,保证可以通过buyer查询到所有发出的报价，只写入DealCollection
+type OfferBuyer struct {
+	Buyer        string `json:"buyer"`        //买家AccountId
+	Seller       string `json:"seller"`       //卖家AccountId
+	ObjectOfSale string `json:"objectOfSale"` //销售对象
+	OfferID      string `json:"offerId"`      //报价ID
+}
+
+//购房资金托管
+//买家购买时从买家余额扣除并转入托管，卖家确认收款时支付给卖家，取消或过期时退还买家
+//买家应缴纳的契税在购买或出价时按当时的税费标准一起托管，成交时转入国库账户，退款时一起退还
+//租赁押金同样托管，Seller为出租人，Buyer为承租人，租约终止时退还或没收
+//Seller、ObjectOfSale和EscrowID一起作为复合键,保证可以通过销售查询到托管记录，同一销售同时最多只有一条托管中的记录
+//完整的托管写入DealCollection，账本中公开的托管不含买家和金额
+type Escrow struct {
+	EscrowID     string `json:"escrowId"`     //托管ID
+	ObjectOfSale string `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
+	Seller       string `json:"seller"`       //卖家AccountId
+	Buyer        string `json:"buyer"`        //买家AccountId
+	Amount       Amount `json:"amount"`       //托管金额(成交价或押金)
+	DeedTax      Amount `json:"deedTax"`      //一起托管的契税，升级前的托管和押金为0
+	EscrowStatus string `json:"escrowStatus"` //托管状态
+	CreateTime   string `json:"createTime"`   //转入托管时间
+	SettleTime   string `json:"settleTime"`   //支付给卖家或退还买家的时间
+	Hashed
+}
+
+//托管状态
+var EscrowStatusConstant = func() map[string]string {
+	return map[string]string{
+		"held":      "托管中",   //买家已付款，等待卖家确认收款
+		"released":  "已支付卖家", //卖家确认收款
+		"refunded":  "已退还买家", //销售取消或过期
+		"forfeited": "已没收",   //租约终止时押金支付给出租人
+	}
+}
+
+//资金汇总，购房资金在托管中时不计入任何账户余额，余额合计加托管中金额合计才是全部资金
+type FundsSummary struct {
+	Balance Amount `json:"balance"` //账户余额合计
+	Escrow  Amount `json:"escrow"`  //托管中金额合计
+	Total   Amount `json:"total"`   //全部资金
+}
+
+//税费标准，登记员设置，只保存一份，每次设置覆盖之前的标准
+//买家购买或出价时按当时的契税税率将契税随购房资金转入托管，确认收款或拍卖成交时转入国库账户，取消或过期时退还买家
+//卖家按成交价缴纳交易手续费，确认收款或拍卖成交时从售房资金中扣除
+//捐赠确认接收时受赠人按房地产总面积缴纳赠与税，收取的税费全部转入Treasury国库账户
+type FeeSchedule struct {
+	DeedTaxRate        int    `json:"deedTaxRate"`        //契税税率，单位为万分之一
+	TransactionFeeRate int    `json:"transactionFeeRate"` //交易手续费率，单位为万分之一
+	GiftTaxPerArea     Amount `json:"giftTaxPerArea"`     //赠与税每平方米金额
+	Treasury           string `json:"treasury"`           //国库账户(AccountId)，不能是业主
+	Operator           string `json:"operator"`           //设置人(登记员AccountId)
+	UpdateTime         string `json:"updateTime"`         //设置时间
+}
+
+//税率的分母，即100%
+const RateTotal = 10000
+
+//税费项目
+var FeeItemConstant = func() map[string]string {
+	return map[string]string{
+		"deedTax":        "契税",    //买家缴纳
+		"transactionFee": "交易手续费", //卖家缴纳
+		"giftTax":        "赠与税",   //受赠人缴纳
+	}
+}
+
+//税费收据，销售确认收款、拍卖成交和捐赠确认接收时生成，逐项列出缴纳的税费，税费为0的项目也列出
+//TxID作为复合键，收据包含成交价，只写入DealCollection，当事人通过queryReceipt查询
+type Receipt struct {
+	TxID         string        `json:"txId"`         //完成交易的交易ID
+	ObjectType   string        `json:"objectType"`   //业务类型，销售"selling"、拍卖"auction"、捐赠"donating"
+	ObjectKey    []string      `json:"objectKey"`    //业务的复合键
+	RealEstateID string        `json:"realEstateId"` //房地产ID
+	Parties      []string      `json:"parties"`      //当事人，销售为卖家和买家，拍卖为卖家和最高出价人，捐赠为捐赠人和受赠人
+	Price        Amount        `json:"price"`        //成交价，捐赠为0
+	Items        []ReceiptItem `json:"items"`        //税费明细
+	Total        Amount        `json:"total"`        //税费合计
+	Proceeds     Amount        `json:"proceeds"`     //卖家实收，成交价减去交易手续费，捐赠为0
+	CreateTime   string        `json:"createTime"`   //创建时间
+}
+
+//税费收据中的一项税费
+type ReceiptItem struct {
+	Item   string `json:"item"`   //税费项目
+	Payer  string `json:"payer"`  //缴纳人(AccountId)
+	Payee  string `json:"payee"`  //收款的国库账户(AccountId)，未设置税费标准时为空
+	Basis  string `json:"basis"`  //计税依据
+	Amount Amount `json:"amount"` //金额
+}
+
+//拍卖，英式拍卖竞拍人公开出价，每次出价不低于当前最高出价加最小加价幅度，结束后由最高出价人买下
+//密封拍卖竞拍人在结束前提交出价的哈希，结束后到揭示截止时间前揭示，揭示的最高出价人买下
+//需要确定ObjectOfSale是否属于Seller，拍卖期间房地产处于担保状态
+//Seller和ObjectOfSale一起作为复合键,保证可以通过seller查询到名下所有发起的拍卖
+//完整的拍卖写入DealCollection，账本和事件中公开的拍卖不含最高出价和最高出价人，竞拍人通过queryAuctionList查询当前最高出价
+type Auction struct {
+	AuctionID     string `json:"auctionId"`               //拍卖ID，用于查询出价记录
+	AuctionType   string `json:"auctionType"`             //拍卖方式
+	ObjectOfSale  string `json:"objectOfSale"`            //拍卖对象(正在拍卖的房地产RealEstateID)
+	Seller        string `json:"seller"`                  //卖家AccountId
+	ReservePrice  Amount `json:"reservePrice"`            //保留价，第一次出价不能低于保留价
+	MinIncrement  Amount `json:"minIncrement"`            //最小加价幅度
+	HighestBid    Amount `json:"highestBid"`              //当前最高出价
+	HighestBidder string `json:"highestBidder"`           //当前最高出价人AccountId，无人出价时为空
+	EndTime       string `json:"endTime"`                 //结束时间，之后不能再出价，英式拍卖可以结算
+	RevealEndTime string `json:"revealEndTime,omitempty"` //密封拍卖的揭示截止时间，之后不能再揭示，可以结算
+	CreateTime    string `json:"createTime"`              //创建时间
+	AuctionStatus string `json:"auctionStatus"`           //拍卖状态
+	Hashed
+}
+
+//拍卖方式
+var AuctionTypeConstant = func() map[string]string {
+	return map[string]string{
+		"english": "英式拍卖", //公开出价，出价时转入托管
+		"sealed":  "密封拍卖", //提交出价哈希，揭示时转入托管
+	}
+}
+
+//拍卖状态
+var AuctionStatusConstant = func() map[string]string {
+	return map[string]string{
+		"bidding":   "竞拍中", //等待竞拍人出价
+		"done":      "成交",  //结束后结算，最高出价支付给卖家，房地产过户给最高出价人
+		"failed":    "流拍",  //结束时无人出价，或卖家、最高出价人的账户已冻结或注销，最高出价退还
+		"cancelled": "已取消", //卖家在无人出价前取消
+	}
+}
+
+//拍卖出价记录
+//最高出价的资金转入托管，被更高的出价超过时退还
+//AuctionID、CreateTime和TxID一起作为复合键,保证可以按时间查询到一次拍卖的所有出价，只写入DealCollection
+type AuctionBid struct {
+	AuctionID  string `json:"auctionId"`  //拍卖ID
+	TxID       string `json:"txId"`       //出价的交易ID
+	Bidder     string `json:"bidder"`     //竞拍人AccountId
+	Amount     Amount `json:"amount"`     //出价
+	CreateTime string `json:"createTime"` //出价时间
+}
+
+//密封拍卖的出价承诺，公开记录出价的哈希，出价本身保存在私有数据集合中，揭示后才公开
+//AuctionID和Bidder一起作为复合键，结束前重复提交会覆盖之前的承诺
+type SealedBid struct {
+	AuctionID  string `json:"auctionId"`            //拍卖ID
+	Bidder     string `json:"bidder"`               //竞拍人AccountId
+	BidHash    string `json:"bidHash"`              //sha256(AuctionID|Bidder|出价|盐值)的十六进制
+	Amount     Amount `json:"amount"`               //揭示后的出价，揭示前为0
+	Revealed   bool   `json:"revealed"`             //是否已经揭示
+	CreateTime string `json:"createTime"`           //提交时间
+	RevealTime string `json:"revealTime,omitempty"` //揭示时间
+}
+
+//保存在私有数据集合中的密封出价，通过transient的bid传入，不会写入交易
+type SealedBidPrivate struct {
+	AuctionID string `json:"auctionId"` //拍卖ID
+	Bidder    string `json:"bidder"`    //竞拍人AccountId
+	Amount    Amount `json:"amount"`    //出价
+	Salt      string `json:"salt"`      //盐值，防止通过穷举出价反推哈希
+}
+
+//质押合同，业主以房地产作为担保向出借人借款
+//需要确定ObjectOfPledge是否属于Pledgor，质押期间房地产处于担保状态
+//出借人同意后贷款从出借人转给出质人，还清后解除质押，到期未还清时出借人可以处置，房地产过户给出借人
+//Pledgor、ObjectOfPledge和PledgeID一起作为复合键,保证可以通过出质人查询到所有质押
+//完整的质押写入DealCollection，账本中公开的质押不含贷款金额和已还金额
+type Pledge struct {
+	PledgeID       string `json:"pledgeId"`            //质押ID
+	ObjectOfPledge string `json:"objectOfPledge"`      //质押对象(房地产RealEstateID)
+	Pledgor        string `json:"pledgor"`             //出质人(业主AccountId)
+	Lender         string `json:"lender"`              //出借人AccountId
+	LoanAmount     Amount `json:"loanAmount"`          //贷款金额
+	Repaid         Amount `json:"repaid"`              //已还金额
+	DueDate        string `json:"dueDate"`             //到期时间，之后未还清可以违约处置
+	CreateTime     string `json:"createTime"`          //创建时间
+	CloseTime      string `json:"closeTime,omitempty"` //取消、还清或违约处置的时间
+	PledgeStatus   string `json:"pledgeStatus"`        //质押状态
+	Hashed
+}
+
+//质押状态
+var PledgeStatusConstant = func() map[string]string {
+	return map[string]string{
+		"pending":   "待放款", //出质人发起质押，等待出借人放款
+		"active":    "质押中", //出借人已放款，等待出质人还款
+		"cancelled": "已取消", //放款前出质人取消或出借人拒绝
+		"repaid":    "已还清", //出质人还清贷款，解除质押
+		"defaulted": "已违约", //到期未还清，房地产过户给出借人
+	}
+}
+
+//质押的分组，待放款和质押中属于active，其余属于closed
+var PledgeGroupConstant = func() map[string][]string {
+	return map[string][]string{
+		"active": {"pending", "active"},
+		"closed": {"cancelled", "repaid", "defaulted"},
+	}
+}
+
+//质押的分组索引，结束时从active移到closed
+//Group、Pledgor和PledgeID一起作为复合键,保证可以分页查询进行中或已结束的质押
+type PledgeIndex struct {
+	Group          string `json:"group"`          //active或closed
+	Pledgor        string `json:"pledgor"`        //出质人AccountId
+	ObjectOfPledge string `json:"objectOfPledge"` //质押对象
+	PledgeID       string `json:"pledgeId"`       //质押ID
+}
+
+//质押还款记录
+//PledgeID、CreateTime和TxID一起作为复合键,保证可以按时间查询到一笔质押的所有还款，只写入DealCollection
+type PledgeRepayment struct {
+	PledgeID   string `json:"pledgeId"`   //质押ID
+	TxID       string `json:"txId"`       //还款的交易ID
+	Amount     Amount `json:"amount"`     //还款金额
+	Repaid     Amount `json:"repaid"`     //本次还款后的已还金额
+	CreateTime string `json:"createTime"` //还款时间
+}
+
+//租约，业主将房地产出租给承租人，租金按RentPeriod天一期预付
+//需要确定ObjectOfLease是否属于Landlord，租约有效期间房地产处于担保状态，不能出售、捐赠或质押
+//承租人签署时押金转入托管并支付第一期租金，PaidUntil之前的租金已付清，最后不足一期的租金按剩余时间折算
+//租期结束后租约由定时任务关闭并解除担保
+//Landlord、ObjectOfLease和LeaseID一起作为复合键,保证可以通过出租人查询到所有租约
+//完整的租约写入DealCollection，账本中公开的租约不含租金和押金
+type Lease struct {
+	LeaseID       string `json:"leaseId"`                 //租约ID
+	ObjectOfLease string `json:"objectOfLease"`           //出租对象(房地产RealEstateID)
+	Landlord      string `json:"landlord"`                //出租人(业主AccountId)
+	Tenant        string `json:"tenant"`                  //承租人AccountId
+	Rent          Amount `json:"rent"`                    //每期租金
+	RentPeriod    int    `json:"rentPeriod"`              //每期天数
+	Deposit       Amount `json:"deposit"`                 //押金
+	EndTime       string `json:"endTime"`                 //租期结束时间
+	PaidUntil     string `json:"paidUntil,omitempty"`     //租金已付清到的时间
+	RentOverdue   bool   `json:"rentOverdue"`             //是否被标记为欠租
+	CreateTime    string `json:"createTime"`              //创建时间
+	SignTime      string `json:"signTime,omitempty"`      //承租人签署时间
+	CloseTime     string `json:"closeTime,omitempty"`     //取消、终止或到期的时间
+	DepositStatus string `json:"depositStatus,omitempty"` //押金的托管状态
+	LeaseStatus   string `json:"leaseStatus"`             //租约状态
+	Hashed
+}
+
+//租约状态
+var LeaseStatusConstant = func() map[string]string {
+	return map[string]string{
+		"pending":    "待签署", //出租人发起租约，等待承租人签署
+		"active":     "租赁中", //承租人已签署并支付押金
+		"cancelled":  "已取消", //签署前出租人或承租人取消
+		"terminated": "已终止", //租约终止，押金已退还或没收
+		"expired":    "已到期", //租期结束后由flagOverdueLeases关闭，未签署的直接关闭，付清租金的退还押金，欠租的没收押金
+	}
+}
+
+//供承租人查询的租约索引
+//Tenant、Landlord、ObjectOfLease和LeaseID一起作为复合键,保证可以通过tenant查询到所有租约
+type LeaseTenant struct {
+	Tenant        string `json:"tenant"`        //承租人AccountId
+	Landlord      string `json:"landlord"`      //出租人AccountId
+	ObjectOfLease string `json:"objectOfLease"` //出租对象
+	LeaseID       string `json:"leaseId"`       //租约ID
+}
+
+//租金支付记录
+//LeaseID、CreateTime和TxID一起作为复合键,保证可以按时间查询到一份租约的所有租金支付，只写入DealCollection
+type LeasePayment struct {
+	LeaseID    string `json:"leaseId"`    //租约ID
+	TxID       string `json:"txId"`       //支付的交易ID
+	Amount     Amount `json:"amount"`     //支付金额
+	PaidUntil  string `json:"paidUntil"`  //本次支付后租金付清到的时间
+	CreateTime string `json:"createTime"` //支付时间
+}
+
 //捐赠要约
 //需要确定ObjectOfDonating是否属于Donor
-//需要指定受赠人Grantee，并等待受赠人同意接收
+//需要指定受赠人Grantee，并等待受赠人在DonatingPeriod天内同意接收，超过期限后过期并解除担保
+//ObjectOfDonating和CreateTime一起作为复合键，捐赠人和受赠人都不出现在复合键中
+//完整的捐赠写入DealCollection，账本中公开的捐赠不含捐赠人和受赠人
 type Donating struct {
 	ObjectOfDonating string `json:"objectOfDonating"` //捐赠对象(正在捐赠的房地产RealEstateID)
 	Donor            string `json:"donor"`            //捐赠人(捐赠人AccountId)
 	Grantee          string `json:"grantee"`          //受赠人(受赠人AccountId)
 	CreateTime       string `json:"createTime"`       //创建时间
+	DonatingPeriod   int    `json:"donatingPeriod"`   //受赠人确认接收的期限(单位为天)，升级前发起的捐赠为0，按30天计算
 	DonatingStatus   string `json:"donatingStatus"`   //捐赠状态
+	Hashed
 }
 
 //捐赠状态
@@ -69,21 +704,239 @@ var DonatingStatusConstant = func() map[string]string {
 		"donatingStart": "捐赠中", //捐赠人发起捐赠合约，等待受赠人确认受赠
 		"cancelled":     "已取消", //捐赠人在受赠人确认受赠之前取消捐赠或受赠人取消接收受赠
 		"done":          "完成",  //受赠人确认接收，交易完成
+		"expired":       "已过期", //超过接收期限受赠人仍未确认接收，解除担保
 	}
 }
 
-//供受赠人查询的
+//供捐赠人查询的捐赠索引
+//Donor、ObjectOfDonating和CreateTime一起作为复合键,保证可以通过donor查询到所有发起的捐赠，只写入DealCollection
+type DonatingDonor struct {
+	Donor            string `json:"donor"`            //捐赠人AccountId
+	ObjectOfDonating string `json:"objectOfDonating"` //捐赠对象
+	CreateTime       string `json:"createTime"`       //创建时间
+}
+
+//供受赠人查询的，Grantee和CreateTime作为复合键，只写入DealCollection
 type DonatingGrantee struct {
 	Grantee    string   `json:"grantee"`    //受赠人(受赠人AccountId)
 	CreateTime string   `json:"createTime"` //创建时间
 	Donating   Donating `json:"donating"`   //捐赠对象
 }
 
+//链码事件，状态变更成功后设置，应用通过事件服务订阅
+//Fabric每笔交易只保留最后设置的一个事件，键为事件名称
+var EventConstant = func() map[string]string {
+	return map[string]string{
+		"realEstateCreated": "登记房地产", //内容为RealEstateEvent
+		"realEstatesSplit":  "拆分房地产", //内容为RealEstateListEvent，包含拆分前后的房地产
+		"realEstatesMerged": "合并房地产", //内容为RealEstateListEvent，包含合并前后的房地产
+		"realEstateUpdated": "变更房地产", //内容为RealEstateEvent
+		"sellingCreated":    "发起销售",  //内容为SellingEvent，下同
+		"sellingPurchased":  "买家购买",  //买家付款，销售进入交付中
+		"sellingDone":       "确认收款",  //卖家确认收款，完成过户
+		"sellingCancelled":  "取消销售",
+		"sellingExpired":    "销售过期",
+		"sellingsExpired":   "批量过期", //expireSellings关闭的所有销售，内容为SellingListEvent
+		"offerCreated":      "买家报价", //内容为OfferEvent，下同
+		"offerCountered":    "卖家还价",
+		"offerAccepted":     "接受报价", //销售以报价成交进入交付中，事件中包含变更后的销售
+		"offerRejected":     "拒绝报价",
+		"offerWithdrawn":    "撤回报价",
+		"auctionCreated":    "发起拍卖", //内容为AuctionEvent，下同
+		"auctionBid":        "竞拍出价", //出价成为最高出价
+		"auctionDone":       "拍卖成交",
+		"auctionFailed":     "拍卖流拍",
+		"auctionCancelled":  "取消拍卖",
+		"auctionCommitted":  "提交密封出价",
+		"auctionRevealed":   "揭示密封出价", //揭示的出价成为最高出价时拍卖的最高出价随之更新
+		"pledgeCreated":     "发起质押",   //内容为PledgeEvent，下同
+		"pledgeActivated":   "质押放款",
+		"pledgeCancelled":   "取消质押",
+		"pledgeRepaid":      "质押还款", //部分还款
+		"pledgeReleased":    "解除质押", //还清贷款
+		"pledgeDefaulted":   "质押违约", //房地产过户给出借人
+		"leaseCreated":      "发起租约", //内容为LeaseEvent，下同
+		"leaseSigned":       "签署租约",
+		"leaseRentPaid":     "支付租金",
+		"leaseCancelled":    "取消租约",
+		"leaseTerminated":   "终止租约",
+		"leasesOverdue":     "标记欠租", //flagOverdueLeases新标记欠租的所有租约，内容为LeaseListEvent
+		"leasesExpired":     "租约到期", //flagOverdueLeases关闭了租期结束的租约，内容同时包含新标记欠租的租约，按LeaseStatus区分
+		"donatingCreated":   "发起捐赠", //内容为DonatingEvent，下同
+		"donatingDone":      "确认受赠", //受赠人确认接收，完成过户
+		"donatingCancelled": "取消捐赠",
+		"donatingExpired":   "捐赠过期",
+		"donatingsExpired":  "捐赠批量过期", //expireDonatings关闭的所有捐赠，内容为DonatingListEvent
+		"documentAnchored":  "登记文档",   //内容为DocumentEvent
+	}
+}
+
+//房地产事件的内容
+type RealEstateEvent struct {
+	TxID       string     `json:"txId"`       //交易ID
+	RealEstate RealEstate `json:"realEstate"` //变更后的房地产
+}
+
+//批量变更房地产的事件内容
+type RealEstateListEvent struct {
+	TxID        string       `json:"txId"`        //交易ID
+	RealEstates []RealEstate `json:"realEstates"` //变更后的房地产
+}
+
+//销售事件的内容
+type SellingEvent struct {
+	TxID    string  `json:"txId"`    //交易ID
+	Selling Selling `json:"selling"` //变更后的销售
+}
+
+//批量变更销售的事件内容
+type SellingListEvent struct {
+	TxID     string    `json:"txId"`     //交易ID
+	Sellings []Selling `json:"sellings"` //变更后的销售
+}
+
+//报价事件的内容
+type OfferEvent struct {
+	TxID    string   `json:"txId"`              //交易ID
+	Offer   Offer    `json:"offer"`             //变更后的报价
+	Selling *Selling `json:"selling,omitempty"` //接受报价时变更后的销售
+}
+
+//拍卖事件的内容
+type AuctionEvent struct {
+	TxID    string  `json:"txId"`    //交易ID
+	Auction Auction `json:"auction"` //变更后的拍卖
+}
+
+//质押事件的内容
+type PledgeEvent struct {
+	TxID   string `json:"txId"`   //交易ID
+	Pledge Pledge `json:"pledge"` //变更后的质押
+}
+
+//租约事件的内容
+type LeaseEvent struct {
+	TxID  string `json:"txId"`  //交易ID
+	Lease Lease  `json:"lease"` //变更后的租约
+}
+
+//批量变更租约的事件内容
+type LeaseListEvent struct {
+	TxID   string  `json:"txId"`   //交易ID
+	Leases []Lease `json:"leases"` //变更后的租约
+}
+
+//捐赠事件的内容
+type DonatingEvent struct {
+	TxID     string   `json:"txId"`     //交易ID
+	Donating Donating `json:"donating"` //变更后的捐赠
+}
+
+//批量过期的捐赠事件的内容
+type DonatingListEvent struct {
+	TxID      string     `json:"txId"`      //交易ID
+	Donatings []Donating `json:"donatings"` //变更后的捐赠
+}
+
+//文档事件的内容
+type DocumentEvent struct {
+	TxID     string   `json:"txId"`     //交易ID
+	Document Document `json:"document"` //登记的文档
+}
+
+//销售富查询条件，未指定的条件不参与过滤
+type SellingFilter struct {
+	Seller        string  `json:"seller"`        //卖家AccountId
+	SellingStatus string  `json:"sellingStatus"` //销售状态，SellingStatusConstant的键，如saleStart
+	MinPrice      *Amount `json:"minPrice"`      //最低价格(含)
+	MaxPrice      *Amount `json:"maxPrice"`      //最高价格(含)
+	CreatedFrom   string  `json:"createdFrom"`   //创建时间起(含)，RFC3339格式
+	CreatedTo     string  `json:"createdTo"`     //创建时间止(含)，RFC3339格式
+	SortBy        string  `json:"sortBy"`        //排序字段，SellingSortConstant的键，默认createTime
+	SortOrder     string  `json:"sortOrder"`     //排序方向，asc或desc，默认asc
+}
+
+//销售富查询可以排序的字段，值为CouchDB中的字段名
+var SellingSortConstant = func() map[string]string {
+	return map[string]string{
+		"createTime": "createTime", //按创建时间
+		"price":      "priceValue", //按价格
+	}
+}
+
+//房地产富查询条件，未指定的条件不参与过滤
+type RealEstateFilter struct {
+	Proprietor     string   `json:"proprietor"`     //所有者AccountId，持有任意份额的共有人都能查询到
+	Encumbrance    *bool    `json:"encumbrance"`    //是否作为担保
+	District       string   `json:"district"`       //所在行政区
+	PropertyType   string   `json:"propertyType"`   //房屋用途
+	MinTotalArea   *float64 `json:"minTotalArea"`   //最小总面积(含)
+	MaxTotalArea   *float64 `json:"maxTotalArea"`   //最大总面积(含)
+	MinLivingSpace *float64 `json:"minLivingSpace"` //最小生活空间(含)
+	MaxLivingSpace *float64 `json:"maxLivingSpace"` //最大生活空间(含)
+	SortBy         string   `json:"sortBy"`         //排序字段，RealEstateSortConstant的键，默认totalArea
+	SortOrder      string   `json:"sortOrder"`      //排序方向，asc或desc，默认asc
+}
+
+//房地产富查询可以排序的字段，值为CouchDB中的字段名
+var RealEstateSortConstant = func() map[string]string {
+	return map[string]string{
+		"totalArea":   "totalArea",   //按总面积
+		"livingSpace": "livingSpace", //按生活空间
+	}
+}
+
+//列表查询的一页结果
+type Page struct {
+	Records  interface{} `json:"records"`  //本页记录
+	PageSize int32       `json:"pageSize"` //每页条数
+	Bookmark string      `json:"bookmark"` //下一页的书签，查询下一页时传入
+	HasMore  bool        `json:"hasMore"`  //是否还有下一页
+}
+
+//私有数据集合，与deploy/collections_config.json中的name一致
+//账本中只保留私有数据的哈希，链码查询时只向当事人、登记员、银行和审计员返回私有数据中的完整记录
+const (
+	SealedBidCollection = "collectionSealedBids" //密封出价
+	AccountCollection   = "collectionAccounts"   //账户余额和流水
+	DealCollection      = "collectionDeals"      //销售和报价的买家与价格、托管、拍卖出价、质押和租约的金额、捐赠双方、税费收据
+)
+
+//除当事人之外可以查看私有数据集合中完整记录的角色
+var PrivateReaderConstant = func() map[string][]string {
+	return map[string][]string{
+		AccountCollection: {"bank", "auditor"},              //与查询流水的权限一致
+		DealCollection:    {"registrar", "bank", "auditor"}, //登记员办理过户，银行管理托管资金
+	}
+}
+
 const (
-	AccountKey         = "account-key"
-	RealEstateKey      = "real-estate-key"
-	SellingKey         = "selling-key"
-	SellingBuyKey      = "selling-buy-key"
-	DonatingKey        = "donating-key"
-	DonatingGranteeKey = "donating-grantee-key"
+	AccountKey              = "account-key"
+	AccountIdentityKey      = "account-identity-key"
+	JournalKey              = "journal-key"
+	RealEstateKey           = "real-estate-key"
+	RealEstateProprietorKey = "real-estate-proprietor-key"
+	RealEstateConsentKey    = "real-estate-consent-key"
+	RealEstateUpdateKey     = "real-estate-update-key"
+	SellingKey              = "selling-key"
+	SellingBuyKey           = "selling-buy-key"
+	OfferKey                = "offer-key"
+	OfferBuyerKey           = "offer-buyer-key"
+	EscrowKey               = "escrow-key"
+	AuctionKey              = "auction-key"
+	AuctionBidKey           = "auction-bid-key"
+	SealedBidKey            = "sealed-bid-key"
+	SealedBidPrivateKey     = "sealed-bid-private-key"
+	PledgeKey               = "pledge-key"
+	PledgeIndexKey          = "pledge-index-key"
+	PledgeRepaymentKey      = "pledge-repayment-key"
+	LeaseKey                = "lease-key"
+	LeaseTenantKey          = "lease-tenant-key"
+	LeasePaymentKey         = "lease-payment-key"
+	DonatingKey             = "donating-key"
+	DonatingDonorKey        = "donating-donor-key"
+	DonatingGranteeKey      = "donating-grantee-key"
+	DocumentKey             = "document-key"
+	FeeScheduleKey          = "fee-schedule-key"
+	ReceiptKey              = "receipt-key"
 )
@@ -2,6 +2,7 @@ package routers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
@@ -9,9 +10,26 @@ import (
 	"transaction/chaincode/utils"
 )
 
+// QueryAccountList 查询账户列表，指定AccountId时直接返回这些账户，不分页
+// 只有账户持有人、银行和审计员可以看到余额
 func QueryAccountList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, accountIds, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	var accountList []lib.Account
-	results, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, args)
+	var results [][]byte
+	var nextBookmark string
+	var hasMore bool
+	if len(accountIds) != 0 {
+		results, err = utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, accountIds)
+	} else {
+		results, nextBookmark, hasMore, err = utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.AccountKey, accountIds, pageSize, bookmark)
+	}
 	if err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
@@ -22,12 +40,392 @@ func QueryAccountList(stub shim.ChaincodeStubInterface, args []string) peer.Resp
 			if err != nil {
 				return shim.Error(fmt.Sprintf("QueryAccountList-反序列化出错: %s", err))
 			}
+			if canReadPrivate(caller, lib.AccountCollection, account.AccountId) {
+				if err := readPrivate(stub, lib.AccountCollection, lib.AccountKey, []string{account.AccountId}, &account); err != nil {
+					return shim.Error(fmt.Sprintf("%s", err))
+				}
+			}
 			accountList = append(accountList, account)
 		}
 	}
-	accountListByte, err := json.Marshal(accountList)
+	return pageResponse(accountList, pageSize, nextBookmark, hasMore)
+}
+
+func BindAccount(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	bindAccountId := args[1]
+	mspId := args[2]
+	subject := args[3]
+	if accountId == "" || bindAccountId == "" || mspId == "" || subject == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := getAccount(stub, bindAccountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if account.Status == lib.AccountStatusConstant()["closed"] {
+		return shim.Error(fmt.Sprintf("账户%s已注销", bindAccountId))
+	}
+	//一个身份只能绑定一个账户
+	resultsIdentity, err := utils.GetStateByPartialCompositeKeys2(stub, lib.AccountIdentityKey, []string{mspId, subject})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(resultsIdentity) != 0 {
+		return shim.Error(fmt.Sprintf("身份%s已经绑定了账户", subject))
+	}
+	//重新绑定时删除原有身份
+	if account.MspId != "" {
+		if err := utils.DelLedger(stub, lib.AccountIdentityKey, []string{account.MspId, account.Subject}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	account.MspId = mspId
+	account.Subject = subject
+	if err := writeAccountIdentity(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功绑定的信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+func UpdateAccountRoles(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 2 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	updateAccountId := args[1]
+	roles := args[2:]
+	if accountId == "" || updateAccountId == "" {
+		return shim.Error("参数存在空值")
+	}
+	for _, role := range roles {
+		if _, ok := lib.RoleConstant()[role]; !ok {
+			return shim.Error(fmt.Sprintf("%s角色不支持", role))
+		}
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := getAccount(stub, updateAccountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account.Roles = roles
+	if err := writeAccount(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功更新的信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// CreateAccount 登记员为指定身份创建账户，账号ID由交易ID生成，未指定角色时默认为业主
+func CreateAccount(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 4 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	userName := args[1]
+	mspId := args[2]
+	subject := args[3]
+	roles := args[4:]
+	if accountId == "" || userName == "" || mspId == "" || subject == "" {
+		return shim.Error("参数存在空值")
+	}
+	if len(roles) == 0 {
+		roles = []string{"owner"}
+	}
+	for _, role := range roles {
+		if _, ok := lib.RoleConstant()[role]; !ok {
+			return shim.Error(fmt.Sprintf("%s角色不支持", role))
+		}
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	//一个身份只能绑定一个账户
+	resultsIdentity, err := utils.GetStateByPartialCompositeKeys2(stub, lib.AccountIdentityKey, []string{mspId, subject})
 	if err != nil {
-		return shim.Error(fmt.Sprintf("QueryAccountList-序列化出错: %s", err))
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(resultsIdentity) != 0 {
+		return shim.Error(fmt.Sprintf("身份%s已经绑定了账户", subject))
+	}
+	account := lib.Account{
+		AccountId: utils.GenerateID(stub, 0),
+		UserName:  userName,
+		Balance:   0,
+		MspId:     mspId,
+		Subject:   subject,
+		Roles:     roles,
+		Status:    lib.AccountStatusConstant()["active"],
+	}
+	if _, err := getAccount(stub, account.AccountId); err == nil {
+		return shim.Error(fmt.Sprintf("账户%s已存在", account.AccountId))
+	}
+	if err := writeAccountIdentity(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// UpdateAccount 修改账户显示名称，账户持有人或登记员可以修改
+func UpdateAccount(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	updateAccountId := args[1]
+	userName := args[2]
+	if accountId == "" || updateAccountId == "" || userName == "" {
+		return shim.Error("参数存在空值")
+	}
+	operator, err := checkAccountOwner(stub, accountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if accountId != updateAccountId && !hasRole(operator, "registrar") {
+		return shim.Error("只有账户持有人或登记员可以修改账户信息")
+	}
+	account, err := getAccount(stub, updateAccountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if account.Status == lib.AccountStatusConstant()["closed"] {
+		return shim.Error(fmt.Sprintf("账户%s已注销", updateAccountId))
+	}
+	account.UserName = userName
+	if err := writeAccount(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功更新的信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// FreezeAccount 冻结或解冻账户，status为frozen或active
+func FreezeAccount(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	freezeAccountId := args[1]
+	status := args[2]
+	if accountId == "" || freezeAccountId == "" || status == "" {
+		return shim.Error("参数存在空值")
+	}
+	if status != "frozen" && status != "active" {
+		return shim.Error(fmt.Sprintf("%s状态不支持", status))
+	}
+	if accountId == freezeAccountId {
+		return shim.Error("不能冻结自己的账户")
 	}
-	return shim.Success(accountListByte)
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := getAccount(stub, freezeAccountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if account.Status == lib.AccountStatusConstant()["closed"] {
+		return shim.Error(fmt.Sprintf("账户%s已注销", freezeAccountId))
+	}
+	account.Status = lib.AccountStatusConstant()[status]
+	if err := writeAccount(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功更新的信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// CloseAccount 注销账户，余额必须为0且名下没有房地产，注销后解除身份绑定
+func CloseAccount(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 2 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	closeAccountId := args[1]
+	if accountId == "" || closeAccountId == "" {
+		return shim.Error("参数存在空值")
+	}
+	if accountId == closeAccountId {
+		return shim.Error("不能注销自己的账户")
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := getAccount(stub, closeAccountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if account.Status == lib.AccountStatusConstant()["closed"] {
+		return shim.Error(fmt.Sprintf("账户%s已注销", closeAccountId))
+	}
+	if account.Balance != 0 {
+		return shim.Error(fmt.Sprintf("账户%s余额不为0，不能注销", closeAccountId))
+	}
+	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateProprietorKey, []string{closeAccountId})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(resultsRealEstate) != 0 {
+		return shim.Error(fmt.Sprintf("账户%s名下还有房地产，不能注销", closeAccountId))
+	}
+	if account.MspId != "" {
+		if err := utils.DelLedger(stub, lib.AccountIdentityKey, []string{account.MspId, account.Subject}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	account.Status = lib.AccountStatusConstant()["closed"]
+	if err := writeAccount(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功更新的信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// InitAdminAccount 将管理员账户绑定到实例化链码的交易提交者
+func InitAdminAccount(stub shim.ChaincodeStubInterface, accountId string) peer.Response {
+	identity, err := utils.GetCreatorIdentity(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account.MspId = identity.MspId
+	account.Subject = identity.Subject
+	if err := writeAccountIdentity(stub, account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return shim.Success(nil)
+}
+
+// getAccount 根据AccountId获取账户信息，余额从私有数据集合中读取
+func getAccount(stub shim.ChaincodeStubInterface, accountId string) (lib.Account, error) {
+	account, err := getPublicAccount(stub, accountId)
+	if err != nil {
+		return account, err
+	}
+	if err := readPrivate(stub, lib.AccountCollection, lib.AccountKey, []string{accountId}, &account); err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+// getPublicAccount 根据AccountId获取账本中公开的账户信息，不含余额，不读取私有数据集合
+func getPublicAccount(stub shim.ChaincodeStubInterface, accountId string) (lib.Account, error) {
+	var account lib.Account
+	results, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{accountId})
+	if err != nil {
+		return account, err
+	}
+	if len(results) != 1 {
+		return account, errors.New(fmt.Sprintf("账户%s不存在", accountId))
+	}
+	if err := json.Unmarshal(results[0], &account); err != nil {
+		return account, errors.New(fmt.Sprintf("账户%s-反序列化出错: %s", accountId, err))
+	}
+	return account, nil
+}
+
+// checkAccountActive 冻结或注销的账户不能出售、购买、捐赠和受赠
+func checkAccountActive(account lib.Account) error {
+	if account.Status != lib.AccountStatusConstant()["active"] {
+		return errors.New(fmt.Sprintf("账户%s%s，不能进行交易", account.AccountId, account.Status))
+	}
+	return nil
+}
+
+// checkAccountOwner 验证交易提交者是否为账户的持有人
+func checkAccountOwner(stub shim.ChaincodeStubInterface, accountId string) (lib.Account, error) {
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return account, err
+	}
+	identity, err := utils.GetCreatorIdentity(stub)
+	if err != nil {
+		return account, err
+	}
+	if account.MspId == "" || account.MspId != identity.MspId || account.Subject != identity.Subject {
+		return account, errors.New(fmt.Sprintf("交易提交者%s不是账户%s的持有人", identity.Subject, accountId))
+	}
+	return account, nil
+}
+
+// getCallerAccount 根据交易提交者的身份获取其绑定的账户，只用于校验角色和身份，不含余额
+// 每次调用都经过Authorize，只读取账本中公开的账户，不依赖节点是否保存了私有数据集合
+func getCallerAccount(stub shim.ChaincodeStubInterface) (lib.Account, error) {
+	var account lib.Account
+	identity, err := utils.GetCreatorIdentity(stub)
+	if err != nil {
+		return account, err
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.AccountIdentityKey, []string{identity.MspId, identity.Subject})
+	if err != nil {
+		return account, err
+	}
+	if len(results) != 1 {
+		return account, errors.New(fmt.Sprintf("交易提交者%s没有绑定账户", identity.Subject))
+	}
+	var accountIdentity lib.AccountIdentity
+	if err := json.Unmarshal(results[0], &accountIdentity); err != nil {
+		return account, errors.New(fmt.Sprintf("账户身份-反序列化出错: %s", err))
+	}
+	return getPublicAccount(stub, accountIdentity.AccountId)
+}
+
+// writeAccountIdentity 写入账户及其身份索引
+func writeAccountIdentity(stub shim.ChaincodeStubInterface, account lib.Account) error {
+	if err := writeAccount(stub, account); err != nil {
+		return err
+	}
+	accountIdentity := &lib.AccountIdentity{
+		MspId:     account.MspId,
+		Subject:   account.Subject,
+		AccountId: account.AccountId,
+	}
+	return utils.WriteLedger(accountIdentity, stub, lib.AccountIdentityKey, []string{account.MspId, account.Subject})
+}
+
+// InitAccount 链码初始化时写入默认账户
+func InitAccount(stub shim.ChaincodeStubInterface, account lib.Account) error {
+	return writeAccount(stub, account)
+}
+
+// writeAccount 完整的账户写入私有数据集合，账本中公开的账户不含余额
+func writeAccount(stub shim.ChaincodeStubInterface, account lib.Account) error {
+	public := publicAccount(account)
+	return writeWithPrivate(stub, lib.AccountCollection, lib.AccountKey, []string{account.AccountId}, &account, &public)
+}
+
+// publicAccount 去掉余额的账户，用于写入账本和交易的返回值
+func publicAccount(account lib.Account) lib.Account {
+	account.Balance = 0
+	return account
 }
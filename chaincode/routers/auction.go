@@ -0,0 +1,455 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// CreateAuction 卖家发起英式拍卖，拍卖期间房地产处于担保状态
+func CreateAuction(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 5 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	reservePrice := args[2]
+	minIncrement := args[3]
+	endTime := args[4]
+	if objectOfSale == "" || seller == "" || reservePrice == "" || minIncrement == "" || endTime == "" {
+		return shim.Error("参数存在空值")
+	}
+	formattedReservePrice, err := parseAmount(reservePrice)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("reservePrice参数%s", err))
+	}
+	formattedMinIncrement, err := parseAmount(minIncrement)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("minIncrement参数%s", err))
+	}
+	formattedEndTime, err := parseFutureTime(stub, endTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("endTime参数%s", err))
+	}
+	return startAuction(stub, &lib.Auction{
+		AuctionType:  lib.AuctionTypeConstant()["english"],
+		ObjectOfSale: objectOfSale,
+		Seller:       seller,
+		ReservePrice: formattedReservePrice,
+		MinIncrement: formattedMinIncrement,
+		EndTime:      formattedEndTime,
+	})
+}
+
+// PlaceBid 竞拍人出价，出价和契税转入托管，之前的最高出价连同契税退还原竞拍人
+// 出价可以置空并通过transient的amount传入，出价记录只写入DealCollection
+func PlaceBid(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	bidder := args[2]
+	amount, err := privateArg(stub, args, 3, "amount")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if objectOfSale == "" || seller == "" || bidder == "" || amount == "" {
+		return shim.Error("参数存在空值")
+	}
+	if seller == bidder {
+		return shim.Error("竞拍人和卖家不能同一人")
+	}
+	formattedAmount, err := parseAmount(amount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("amount参数%s", err))
+	}
+	accountBidder, err := checkAccountOwner(stub, bidder)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountBidder); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction, err := getAuction(stub, seller, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if auction.AuctionType == lib.AuctionTypeConstant()["sealed"] {
+		return shim.Error("密封拍卖需要通过commitBid提交出价")
+	}
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["bidding"] {
+		return shim.Error("此拍卖不处于竞拍中状态，不能出价")
+	}
+	if ended, err := isTimePassed(stub, auction.EndTime); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if ended {
+		return shim.Error("此拍卖已经结束，不能出价")
+	}
+	if auction.HighestBidder == "" && formattedAmount < auction.ReservePrice {
+		return shim.Error(fmt.Sprintf("出价不能低于保留价%s", auction.ReservePrice))
+	}
+	if auction.HighestBidder != "" && formattedAmount < auction.HighestBid+auction.MinIncrement {
+		return shim.Error(fmt.Sprintf("出价不能低于当前最高出价%s加最小加价幅度%s", auction.HighestBid, auction.MinIncrement))
+	}
+
+	//竞拍人可能就是之前的最高出价人，先退还再转入托管，同一账户需要使用同一个对象
+	accounts := map[string]*lib.Account{bidder: &accountBidder}
+	if auction.HighestBidder != "" {
+		escrow, err := getHeldEscrow(stub, seller, objectOfSale, auction.HighestBidder)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		accountOutbid, err := getAccountOnce(stub, accounts, auction.HighestBidder)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := settleEscrow(stub, &escrow, "refunded", accountOutbid); err != nil {
+			return shim.Error(fmt.Sprintf("退还之前的最高出价失败%s", err))
+		}
+	}
+	deedTax, err := escrowDeedTax(stub, formattedAmount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if _, err := holdEscrow(stub, seller, objectOfSale, formattedAmount, deedTax, "bid", &accountBidder); err != nil {
+		return shim.Error(fmt.Sprintf("出价转入托管失败%s", err))
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	bid := &lib.AuctionBid{
+		AuctionID:  auction.AuctionID,
+		TxID:       stub.GetTxID(),
+		Bidder:     bidder,
+		Amount:     formattedAmount,
+		CreateTime: utils.FormatTime(txTime),
+	}
+	createTimeKey, err := utils.TimeKey(bid.CreateTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.WritePrivateData(bid, stub, lib.DealCollection, lib.AuctionBidKey, []string{bid.AuctionID, createTimeKey, bid.TxID}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction.HighestBid = formattedAmount
+	auction.HighestBidder = bidder
+	if err := writeAuction(stub, &auction); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return auctionResponse(stub, "auctionBid", &auction)
+}
+
+// SettleAuction 拍卖结束后结算，有人出价时最高出价支付给卖家并过户，否则流拍，卖家、竞拍人或登记员都可以调用
+// 卖家或最高出价人的账户已冻结或注销时也按流拍处理，最高出价退还竞拍人
+// 成交时与销售确认收款一样按税费标准收取契税和交易手续费，税费收据可以用本交易ID通过queryReceipt查询
+func SettleAuction(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 2 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	if objectOfSale == "" || seller == "" {
+		return shim.Error("参数存在空值")
+	}
+	auction, err := getAuction(stub, seller, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["bidding"] {
+		return shim.Error("此拍卖不处于竞拍中状态，不能结算")
+	}
+	//密封拍卖在揭示截止后才能结算
+	closeTime := auction.EndTime
+	if auction.AuctionType == lib.AuctionTypeConstant()["sealed"] {
+		closeTime = auction.RevealEndTime
+	}
+	if ended, err := isTimePassed(stub, closeTime); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if !ended {
+		return shim.Error("此拍卖尚未结束，不能结算")
+	}
+	realEstate, err := getRealEstate(stub, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate.Encumbrance = false
+	eventName := "auctionFailed"
+	auction.AuctionStatus = lib.AuctionStatusConstant()["failed"]
+	if auction.HighestBidder != "" {
+		accounts := make(map[string]*lib.Account)
+		accountSeller, err := getAccountOnce(stub, accounts, seller)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("seller卖家信息验证失败%s", err))
+		}
+		accountBidder, err := getAccountOnce(stub, accounts, auction.HighestBidder)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("竞拍人信息验证失败%s", err))
+		}
+		escrow, err := getHeldEscrow(stub, seller, objectOfSale, auction.HighestBidder)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		//卖家或最高出价人的账户已冻结或注销时不能成交，按流拍处理并退还最高出价，否则托管和担保无法解除
+		if checkAccountActive(*accountSeller) != nil || checkAccountActive(*accountBidder) != nil {
+			if err := settleEscrow(stub, &escrow, "refunded", accountBidder); err != nil {
+				return shim.Error(fmt.Sprintf("最高出价退还竞拍人失败%s", err))
+			}
+		} else {
+			if err := settleSale(stub, &escrow, realEstate, "auction", []string{seller, objectOfSale}, accounts); err != nil {
+				return shim.Error(fmt.Sprintf("最高出价支付给卖家失败%s", err))
+			}
+			if err := changeProprietor(stub, &realEstate, auction.HighestBidder); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+			eventName = "auctionDone"
+			auction.AuctionStatus = lib.AuctionStatusConstant()["done"]
+		}
+	}
+	if err := writeRealEstate(stub, &realEstate, "auction", []string{seller, objectOfSale}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeAuction(stub, &auction); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return auctionResponse(stub, eventName, &auction)
+}
+
+// CancelAuction 卖家在无人出价前取消拍卖
+func CancelAuction(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 2 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	if objectOfSale == "" || seller == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, err := checkAccountOwner(stub, seller); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction, err := getAuction(stub, seller, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["bidding"] {
+		return shim.Error("此拍卖不处于竞拍中状态，不能取消")
+	}
+	if auction.HighestBidder != "" {
+		return shim.Error("已经有人出价，不能取消拍卖")
+	}
+	if auction.AuctionType == lib.AuctionTypeConstant()["sealed"] {
+		sealedBids, err := getSealedBids(stub, auction.AuctionID)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if len(sealedBids) > 0 {
+			return shim.Error("已经有人提交密封出价，不能取消拍卖")
+		}
+	}
+	realEstate, err := getRealEstate(stub, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate.Encumbrance = false
+	if err := writeRealEstate(stub, &realEstate, "auction", []string{seller, objectOfSale}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction.AuctionStatus = lib.AuctionStatusConstant()["cancelled"]
+	if err := writeAuction(stub, &auction); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return auctionResponse(stub, "auctionCancelled", &auction)
+}
+
+// QueryAuctionList 分页查询拍卖(可查询所有，也可根据卖家查询)
+// 竞拍人需要知道当前最高出价，所有人都可以看到最高出价，最高出价人只有卖家、最高出价人本人、登记员、银行和审计员可以看到
+func QueryAuctionList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.AuctionKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var auctionList []lib.Auction
+	for _, v := range results {
+		var auction lib.Auction
+		if err := json.Unmarshal(v, &auction); err != nil {
+			return shim.Error(fmt.Sprintf("QueryAuctionList-反序列化出错: %s", err))
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.AuctionKey, auctionKeys(&auction), &auction); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if !canReadPrivate(caller, lib.DealCollection, auction.Seller, auction.HighestBidder) {
+			auction.HighestBidder = ""
+		}
+		auctionList = append(auctionList, auction)
+	}
+	return pageResponse(auctionList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryAuctionBids 按时间分页查询一次拍卖的所有出价，keys为[seller, objectOfSale]
+// 出价只保存在私有数据集合中，卖家、登记员、银行和审计员可以看到所有出价，竞拍人只能看到自己的出价金额
+func QueryAuctionBids(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 2 {
+		return shim.Error("必须指定卖家和拍卖对象查询")
+	}
+	auction, err := getAuction(stub, keys[0], keys[1])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.AuctionBidKey, []string{auction.AuctionID}, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var bidList []lib.AuctionBid
+	for _, v := range results {
+		var bid lib.AuctionBid
+		if err := json.Unmarshal(v, &bid); err != nil {
+			return shim.Error(fmt.Sprintf("QueryAuctionBids-反序列化出错: %s", err))
+		}
+		if !canReadPrivate(caller, lib.DealCollection, auction.Seller, bid.Bidder) {
+			bid.Bidder = ""
+			bid.Amount = 0
+		}
+		bidList = append(bidList, bid)
+	}
+	return pageResponse(bidList, pageSize, nextBookmark, hasMore)
+}
+
+// getAuction 根据卖家和拍卖对象获取拍卖
+func getAuction(stub shim.ChaincodeStubInterface, seller string, objectOfSale string) (lib.Auction, error) {
+	var auction lib.Auction
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.AuctionKey, []string{seller, objectOfSale})
+	if err != nil {
+		return auction, err
+	}
+	if len(results) != 1 {
+		return auction, errors.New(fmt.Sprintf("根据%s和%s获取拍卖信息失败", objectOfSale, seller))
+	}
+	if err := json.Unmarshal(results[0], &auction); err != nil {
+		return auction, errors.New(fmt.Sprintf("Auction-反序列化出错: %s", err))
+	}
+	if err := readPrivate(stub, lib.DealCollection, lib.AuctionKey, auctionKeys(&auction), &auction); err != nil {
+		return auction, err
+	}
+	return auction, nil
+}
+
+// startAuction 校验卖家和房地产后发起拍卖，auction中的ID、创建时间和状态在这里填写
+func startAuction(stub shim.ChaincodeStubInterface, auction *lib.Auction) peer.Response {
+	accountSeller, err := checkAccountOwner(stub, auction.Seller)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountSeller); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate, err := getRealEstate(stub, auction.ObjectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if realEstate.Proprietor != auction.Seller {
+		return shim.Error(fmt.Sprintf("验证%s属于%s失败", auction.ObjectOfSale, auction.Seller))
+	}
+	if realEstate.Encumbrance {
+		return shim.Error("此房地产已经作为担保状态，不能发起拍卖")
+	}
+	if err := useOwnerConsents(stub, realEstate, "auction"); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction.AuctionID = utils.GenerateID(stub, 0)
+	auction.CreateTime = utils.FormatTime(txTime)
+	auction.AuctionStatus = lib.AuctionStatusConstant()["bidding"]
+	if err := writeAuction(stub, auction); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate.Encumbrance = true
+	if err := writeRealEstate(stub, &realEstate, "auction", []string{auction.Seller, auction.ObjectOfSale}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return auctionResponse(stub, "auctionCreated", auction)
+}
+
+// parseFutureTime 解析时间参数并格式化，必须晚于交易时间
+func parseFutureTime(stub shim.ChaincodeStubInterface, value string) (string, error) {
+	t, err := utils.ParseTime(value)
+	if err != nil {
+		return "", err
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return "", err
+	}
+	if !t.After(txTime) {
+		return "", errors.New("必须晚于当前时间")
+	}
+	return utils.FormatTime(t), nil
+}
+
+// isTimePassed 按交易时间判断是否已经到达value指定的时间
+func isTimePassed(stub shim.ChaincodeStubInterface, value string) (bool, error) {
+	t, err := utils.ParseTime(value)
+	if err != nil {
+		return false, err
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return false, err
+	}
+	return !txTime.Before(t), nil
+}
+
+func auctionKeys(auction *lib.Auction) []string {
+	return []string{auction.Seller, auction.ObjectOfSale}
+}
+
+// writeAuction 完整的拍卖写入私有数据集合，账本中公开的拍卖不含最高出价和最高出价人
+func writeAuction(stub shim.ChaincodeStubInterface, auction *lib.Auction) error {
+	public := publicAuction(*auction)
+	return writeWithPrivate(stub, lib.DealCollection, lib.AuctionKey, auctionKeys(auction), auction, &public)
+}
+
+// publicAuction 去掉最高出价和最高出价人的拍卖，用于写入账本、事件和交易的返回值
+func publicAuction(auction lib.Auction) lib.Auction {
+	auction.HighestBid = 0
+	auction.HighestBidder = ""
+	return auction
+}
+
+// auctionResponse 设置拍卖事件并返回拍卖信息，事件和返回值随交易写入区块，只包含公开的拍卖
+func auctionResponse(stub shim.ChaincodeStubInterface, eventName string, auction *lib.Auction) peer.Response {
+	public := publicAuction(*auction)
+	if err := utils.SetEvent(stub, eventName, &lib.AuctionEvent{TxID: stub.GetTxID(), Auction: public}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auctionByte, err := json.Marshal(public)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化拍卖信息出错: %s", err))
+	}
+	return shim.Success(auctionByte)
+}
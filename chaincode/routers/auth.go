@@ -0,0 +1,35 @@
+package routers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"transaction/chaincode/lib"
+)
+
+// Authorize 校验交易提交者绑定的账户是否具有调用funcName的角色
+func Authorize(stub shim.ChaincodeStubInterface, funcName string) error {
+	roles, ok := lib.PermissionConstant()[funcName]
+	if !ok {
+		return errors.New(fmt.Sprintf("没有该功能: %s", funcName))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return errors.New(fmt.Sprintf("权限不足(permission denied): %s", err))
+	}
+	for _, role := range roles {
+		if hasRole(caller, role) {
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("权限不足(permission denied): 账户%s的角色%v不能调用%s", caller.AccountId, caller.Roles, funcName))
+}
+
+func hasRole(account lib.Account, role string) bool {
+	for _, r := range account.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
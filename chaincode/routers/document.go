@@ -0,0 +1,219 @@
+package routers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// AnchorDocument 登记房地产、销售或捐赠关联的文档，账本只记录文件的哈希、媒体类型和上传人
+// args为[accountId, objectType, hash, mediaType, name, objectKey...]
+// 登记员可以为任何对象登记文档，业主只能为自己作为共有人、买卖双方或捐赠双方的对象登记
+func AnchorDocument(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 6 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	objectType := args[1]
+	hash := args[2]
+	mediaType := args[3]
+	name := args[4]
+	objectKey := args[5:]
+	if accountId == "" || objectType == "" || hash == "" || mediaType == "" || name == "" {
+		return shim.Error("参数存在空值")
+	}
+	if err := checkDocumentHash(hash); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := checkAccountOwner(stub, accountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
+	}
+	if err := checkAccountActive(account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	parties, err := getDocumentParties(stub, objectType, objectKey)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !hasRole(account, "registrar") && !parties[accountId] {
+		return shim.Error(fmt.Sprintf("%s不是%s%v的当事人，不能登记文档", accountId, objectType, objectKey))
+	}
+	documentKey := append(append([]string{objectType}, objectKey...), hash)
+	existing, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DocumentKey, documentKey)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(existing) != 0 {
+		return shim.Error(fmt.Sprintf("文档%s已经登记", hash))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	document := &lib.Document{
+		ObjectType: objectType,
+		ObjectKey:  objectKey,
+		Hash:       hash,
+		MediaType:  mediaType,
+		Name:       name,
+		Uploader:   accountId,
+		CreateTime: utils.FormatTime(txTime),
+	}
+	if err := utils.WriteLedger(document, stub, lib.DocumentKey, documentKey); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.SetEvent(stub, "documentAnchored", &lib.DocumentEvent{TxID: stub.GetTxID(), Document: *document}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	documentByte, err := json.Marshal(document)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	}
+	return shim.Success(documentByte)
+}
+
+// QueryDocuments 查询一个对象关联的文档，args为[objectType, objectKey...]，可以再指定hash查询单个文档
+func QueryDocuments(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 2 {
+		return shim.Error("必须指定关联对象的类型和复合键")
+	}
+	size, ok := lib.DocumentObjectConstant()[args[0]]
+	if !ok {
+		return shim.Error(fmt.Sprintf("%s不能关联文档", args[0]))
+	}
+	if len(args) != size+1 && len(args) != size+2 {
+		return shim.Error(fmt.Sprintf("%s的复合键必须包含%d个字段，可以再指定hash", args[0], size))
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DocumentKey, args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var documentList []lib.Document
+	for _, v := range results {
+		var document lib.Document
+		if err := json.Unmarshal(v, &document); err != nil {
+			return shim.Error(fmt.Sprintf("QueryDocuments-反序列化出错: %s", err))
+		}
+		documentList = append(documentList, document)
+	}
+	documentListByte, err := json.Marshal(documentList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryDocuments-序列化出错: %s", err))
+	}
+	return shim.Success(documentListByte)
+}
+
+// QueryDocument 查询单个文档用于下载，args为[accountId, objectType, hash, objectKey...]
+// 与登记一致只有关联对象的当事人可以下载，登记员和审计员可以下载任何对象的文档
+func QueryDocument(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 4 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	objectType := args[1]
+	hash := args[2]
+	objectKey := args[3:]
+	if err := checkDocumentHash(hash); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := checkAccountOwner(stub, accountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
+	}
+	parties, err := getDocumentParties(stub, objectType, objectKey)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !hasRole(account, "registrar") && !hasRole(account, "auditor") && !parties[accountId] {
+		return shim.Error(fmt.Sprintf("%s不是%s%v的当事人，不能下载文档", accountId, objectType, objectKey))
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DocumentKey, append(append([]string{objectType}, objectKey...), hash))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(results) != 1 {
+		return shim.Error(fmt.Sprintf("文档%s没有在%s%v登记", hash, objectType, objectKey))
+	}
+	return shim.Success(results[0])
+}
+
+// checkDocumentHash 哈希必须是64位小写十六进制的SHA-256，与应用保存文件时计算的格式一致
+func checkDocumentHash(hash string) error {
+	value, err := hex.DecodeString(hash)
+	if err != nil || len(value) != sha256.Size || hex.EncodeToString(value) != hash {
+		return errors.New("hash必须是64位小写十六进制的SHA-256")
+	}
+	return nil
+}
+
+// getDocumentParties 获取文档关联对象的当事人，对象必须存在
+// 房地产为所有共有人，销售为卖家和买家，捐赠为捐赠人和受赠人
+func getDocumentParties(stub shim.ChaincodeStubInterface, objectType string, objectKey []string) (map[string]bool, error) {
+	size, ok := lib.DocumentObjectConstant()[objectType]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("%s不能关联文档", objectType))
+	}
+	if len(objectKey) != size {
+		return nil, errors.New(fmt.Sprintf("%s的复合键必须包含%d个字段", objectType, size))
+	}
+	for _, v := range objectKey {
+		if v == "" {
+			return nil, errors.New("关联对象的复合键不能为空")
+		}
+	}
+	var ledgerKey string
+	switch objectType {
+	case "realEstate":
+		ledgerKey = lib.RealEstateKey
+	case "selling":
+		ledgerKey = lib.SellingKey
+	case "donating":
+		ledgerKey = lib.DonatingKey
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, ledgerKey, objectKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(results) != 1 {
+		return nil, errors.New(fmt.Sprintf("%s%v不存在", objectType, objectKey))
+	}
+	parties := make(map[string]bool)
+	switch objectType {
+	case "realEstate":
+		var realEstate lib.RealEstate
+		if err := json.Unmarshal(results[0], &realEstate); err != nil {
+			return nil, errors.New(fmt.Sprintf("RealEstate-反序列化出错: %s", err))
+		}
+		for _, v := range realEstateOwners(realEstate) {
+			parties[v.AccountId] = true
+		}
+	case "selling":
+		var selling lib.Selling
+		if err := json.Unmarshal(results[0], &selling); err != nil {
+			return nil, errors.New(fmt.Sprintf("Selling-反序列化出错: %s", err))
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, objectKey, &selling); err != nil {
+			return nil, err
+		}
+		parties[selling.Seller] = true
+		parties[selling.Buyer] = true
+	case "donating":
+		var donating lib.Donating
+		if err := json.Unmarshal(results[0], &donating); err != nil {
+			return nil, errors.New(fmt.Sprintf("Donating-反序列化出错: %s", err))
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.DonatingKey, objectKey, &donating); err != nil {
+			return nil, err
+		}
+		parties[donating.Donor] = true
+		parties[donating.Grantee] = true
+	}
+	return parties, nil
+}
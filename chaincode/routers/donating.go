@@ -2,120 +2,215 @@ package routers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
+	"strconv"
 	"time"
 	"transaction/chaincode/lib"
 	"transaction/chaincode/utils"
 )
 
+// CreateDonating 捐赠人发起捐赠，受赠人可以置空并通过transient的grantee传入
+// 受赠人需要在donatingPeriod天内确认接收，超过期限后捐赠过期，房地产解除担保
 func CreateDonating(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 3 {
+	if len(args) != 4 {
 		return shim.Error("参数个数不满足")
 	}
 	objectOfDonating := args[0]
 	donor := args[1]
-	grantee := args[2]
-	if objectOfDonating == "" || donor == "" || grantee == "" {
+	grantee, err := privateArg(stub, args, 2, "grantee")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	donatingPeriod := args[3]
+	if objectOfDonating == "" || donor == "" || grantee == "" || donatingPeriod == "" {
 		return shim.Error("参数存在空值")
 	}
+	var formattedDonatingPeriod int
+	if val, err := strconv.Atoi(donatingPeriod); err != nil {
+		return shim.Error(fmt.Sprintf("donatingPeriod参数格式转换出错: %s", err))
+	} else {
+		formattedDonatingPeriod = val
+	}
+	if formattedDonatingPeriod <= 0 {
+		return shim.Error("donatingPeriod接收期限必须大于0天")
+	}
 	if donor == grantee {
 		return shim.Error("捐赠人和受赠人不能同一人")
 	}
+	accountDonor, err := checkAccountOwner(stub, donor)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountDonor); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateKey, []string{donor, objectOfDonating})
+	realEstate, err := getRealEstate(stub, objectOfDonating)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("验证%s属于%s失败: %s", objectOfDonating, donor, err))
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
-	var realEstate lib.RealEstate
-	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
-		return shim.Error(fmt.Sprintf("CreateDonating-反序列化出错: %s", err))
+	if realEstate.Proprietor != donor {
+		return shim.Error(fmt.Sprintf("验证%s属于%s失败", objectOfDonating, donor))
 	}
 
-	resultAccount, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{grantee})
-	if err != nil || len(resultAccount) != 1 {
+	accountGrantee, err := getAccount(stub, grantee)
+	if err != nil {
 		return shim.Error(fmt.Sprintf("grantee受赠人信息验证失败%s", err))
 	}
-	var accountGrantee lib.Account
-	if err = json.Unmarshal(resultAccount[0], &accountGrantee); err != nil {
-		return shim.Error(fmt.Sprintf("查询操作人信息-反序列化出错: %s", err))
+	if !hasRole(accountGrantee, "owner") {
+		return shim.Error(fmt.Sprintf("受赠人%s不是业主，不能接收捐赠", grantee))
 	}
-	if accountGrantee.UserName == "管理员" {
-		return shim.Error(fmt.Sprintf("不能捐赠给管理员%s", err))
+	if err := checkAccountActive(accountGrantee); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
 
 	if realEstate.Encumbrance {
 		return shim.Error("此房地产已经作为担保状态，不能再发起捐赠")
 	}
+	if err := useOwnerConsents(stub, realEstate, "donating"); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	donating := &lib.Donating{
 		ObjectOfDonating: objectOfDonating,
 		Donor:            donor,
 		Grantee:          grantee,
-		CreateTime:       time.Now().Local().Format("2006-01-02 15:04:05"),
+		CreateTime:       utils.FormatTime(txTime),
+		DonatingPeriod:   formattedDonatingPeriod,
 		DonatingStatus:   lib.DonatingStatusConstant()["donatingStart"],
 	}
 
-	if err := utils.WriteLedger(donating, stub, lib.DonatingKey, []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee}); err != nil {
+	donatingKey, err := donatingKeys(*donating)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeDonating(stub, donating); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeDonatingDonor(stub, donating); err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
 
 	realEstate.Encumbrance = true
-	if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+	if err := writeRealEstate(stub, &realEstate, "donating", donatingKey); err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
 
 	donatingGrantee := &lib.DonatingGrantee{
 		Grantee:    grantee,
-		CreateTime: time.Now().Local().Format("2006-01-02 15:04:05"),
+		CreateTime: donating.CreateTime,
 		Donating:   *donating,
 	}
-	local, _ := time.LoadLocation("Local")
-	createTimeUnixNano, _ := time.ParseInLocation("2006-01-02 15:04:05", donatingGrantee.CreateTime, local)
-	if err := utils.WriteLedger(donatingGrantee, stub, lib.DonatingGranteeKey, []string{donatingGrantee.Grantee, fmt.Sprintf("%d", createTimeUnixNano.UnixNano())}); err != nil {
+	createTimeKey, err := utils.TimeKey(donatingGrantee.CreateTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.WritePrivateData(donatingGrantee, stub, lib.DealCollection, lib.DonatingGranteeKey, []string{donatingGrantee.Grantee, createTimeKey}); err != nil {
 		return shim.Error(fmt.Sprintf("将本次捐赠交易写入账本失败%s", err))
 	}
-	donatingGranteeByte, err := json.Marshal(donatingGrantee)
+	public := publicDonating(*donating)
+	if err := utils.SetEvent(stub, "donatingCreated", &lib.DonatingEvent{TxID: stub.GetTxID(), Donating: public}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	donatingByte, err := json.Marshal(public)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
 	}
-	return shim.Success(donatingGranteeByte)
+	return shim.Success(donatingByte)
 }
 
+// QueryDonatingList 分页查询捐赠(可查询所有，也可根据捐赠人或捐赠人和捐赠对象查询)
+// 只有捐赠双方、登记员、银行和审计员可以看到捐赠人和受赠人，根据捐赠人查询时只有捐赠人本人、登记员、银行和审计员可以查询
 func QueryDonatingList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	var donatingList []lib.Donating
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingKey, args)
+	pageSize, bookmark, keys, err := parsePage(args)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
-	for _, v := range results {
-		if v != nil {
+	if len(keys) > 2 {
+		return shim.Error("最多指定捐赠人和捐赠对象两个查询条件")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var donatingList []lib.Donating
+	if len(keys) == 0 {
+		results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.DonatingKey, keys, pageSize, bookmark)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		for _, v := range results {
 			var donating lib.Donating
-			err := json.Unmarshal(v, &donating)
-			if err != nil {
+			if err := json.Unmarshal(v, &donating); err != nil {
 				return shim.Error(fmt.Sprintf("QueryDonatingList-反序列化出错: %s", err))
 			}
+			full := donating
+			if donatingKey, err := donatingKeys(full); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			} else if err := readPrivate(stub, lib.DealCollection, lib.DonatingKey, donatingKey, &full); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+			if canReadPrivate(caller, lib.DealCollection, full.Donor, full.Grantee) {
+				donating = full
+			}
 			donatingList = append(donatingList, donating)
 		}
+		return pageResponse(donatingList, pageSize, nextBookmark, hasMore)
 	}
-	donatingListByte, err := json.Marshal(donatingList)
+	//捐赠人不出现在捐赠的复合键中，通过私有数据集合中的捐赠人索引查询
+	if !canReadPrivate(caller, lib.DealCollection, keys[0]) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询捐赠人%s的捐赠", keys[0]))
+	}
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.DonatingDonorKey, keys, pageSize, bookmark)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("QueryDonatingList-序列化出错: %s", err))
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	for _, v := range results {
+		var donatingDonor lib.DonatingDonor
+		if err := json.Unmarshal(v, &donatingDonor); err != nil {
+			return shim.Error(fmt.Sprintf("QueryDonatingList-反序列化出错: %s", err))
+		}
+		donatingKey, err := donatingKeys(lib.Donating{ObjectOfDonating: donatingDonor.ObjectOfDonating, CreateTime: donatingDonor.CreateTime})
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		donating, err := getDonatingByKey(stub, donatingKey)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		donatingList = append(donatingList, donating)
 	}
-	return shim.Success(donatingListByte)
+	return pageResponse(donatingList, pageSize, nextBookmark, hasMore)
 }
 
+// QueryDonatingListByGrantee 分页查询受赠人的捐赠，只有受赠人本人、登记员、银行和审计员可以查询
 func QueryDonatingListByGrantee(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 1 {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 1 {
 		return shim.Error(fmt.Sprintf("必须指定受赠人AccountId查询"))
 	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.DealCollection, keys[0]) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询受赠人%s的捐赠", keys[0]))
+	}
 	var donatingGranteeList []lib.DonatingGrantee
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingGranteeKey, args)
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.DonatingGranteeKey, keys, pageSize, bookmark)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
-
 	for _, v := range results {
 		if v != nil {
 			var donatingGrantee lib.DonatingGrantee
@@ -126,20 +221,21 @@ func QueryDonatingListByGrantee(stub shim.ChaincodeStubInterface, args []string)
 			donatingGranteeList = append(donatingGranteeList, donatingGrantee)
 		}
 	}
-	donatingGranteeListByte, err := json.Marshal(donatingGranteeList)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("QueryDonatingListByGrantee-序列化出错: %s", err))
-	}
-	return shim.Success(donatingGranteeListByte)
+	return pageResponse(donatingGranteeList, pageSize, nextBookmark, hasMore)
 }
 
+// UpdateDonating 确认接收、取消或过期捐赠，受赠人可以置空并通过transient的grantee传入
+// 确认接收时受赠人按税费标准缴纳赠与税，税费收据可以用本交易ID通过queryReceipt查询
 func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	if len(args) != 4 {
 		return shim.Error("参数个数不满足")
 	}
 	objectOfDonating := args[0]
 	donor := args[1]
-	grantee := args[2]
+	grantee, err := privateArg(stub, args, 2, "grantee")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	status := args[3]
 	if objectOfDonating == "" || donor == "" || grantee == "" || status == "" {
 		return shim.Error("参数存在空值")
@@ -147,15 +243,38 @@ func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) peer.Respon
 	if donor == grantee {
 		return shim.Error("捐赠人和受赠人不能同一人")
 	}
-
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateKey, []string{donor, objectOfDonating})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败: %s", objectOfDonating, donor, err))
+	//只有受赠人可以确认接收，捐赠人或受赠人可以取消，过期还可以由登记员操作
+	switch status {
+	case "done":
+		accountGrantee, err := checkAccountOwner(stub, grantee)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("只有受赠人可以确认接收: %s", err))
+		}
+		if err := checkAccountActive(accountGrantee); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	case "cancelled":
+		if _, err := checkAccountOwner(stub, donor); err != nil {
+			if _, err := checkAccountOwner(stub, grantee); err != nil {
+				return shim.Error(fmt.Sprintf("只有捐赠人或受赠人可以取消捐赠: %s", err))
+			}
+		}
+	case "expired":
+		caller, err := getCallerAccount(stub)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if caller.AccountId != donor && caller.AccountId != grantee && !hasRole(caller, "registrar") {
+			return shim.Error("只有捐赠人、受赠人或登记员可以将捐赠设置为过期")
+		}
 	}
 
-	var realEstate lib.RealEstate
-	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
-		return shim.Error(fmt.Sprintf("UpdateDonating-反序列化出错: %s", err))
+	realEstate, err := getRealEstate(stub, objectOfDonating)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("获取捐赠的房产信息失败: %s", err))
+	}
+	if realEstate.Proprietor != donor {
+		return shim.Error(fmt.Sprintf("房产%s不属于%s", objectOfDonating, donor))
 	}
 	resultsGranteeAccount, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{grantee})
 	if err != nil || len(resultsGranteeAccount) != 1 {
@@ -166,88 +285,104 @@ func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) peer.Respon
 		return shim.Error(fmt.Sprintf("查询grantee受赠人信息-反序列化出错: %s", err))
 	}
 	//根据objectOfDonating和donor和grantee获取捐赠信息
-	resultsDonating, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingKey, []string{donor, objectOfDonating, grantee})
-	if err != nil || len(resultsDonating) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s和%s获取销售信息失败: %s", objectOfDonating, donor, grantee, err))
+	donating, err := getDonating(stub, donor, objectOfDonating, grantee)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
-	var donating lib.Donating
-	if err = json.Unmarshal(resultsDonating[0], &donating); err != nil {
-		return shim.Error(fmt.Sprintf("UpdateDonating-反序列化出错: %s", err))
+	donatingKey, err := donatingKeys(donating)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
 
 	if donating.DonatingStatus != lib.DonatingStatusConstant()["donatingStart"] {
 		return shim.Error("此交易并不处于捐赠中，确认/取消捐赠失败")
 	}
 
-	var donatingGrantee lib.DonatingGrantee
-	resultsDonatingGrantee, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingGranteeKey, []string{grantee})
-	if err != nil || len(resultsDonatingGrantee) == 0 {
-		return shim.Error(fmt.Sprintf("根据%s获取受赠人信息失败: %s", grantee, err))
+	donatingGrantee, err := getDonatingGrantee(stub, donating)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
-	for _, v := range resultsDonatingGrantee {
-		if v != nil {
-			var s lib.DonatingGrantee
-			err := json.Unmarshal(v, &s)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("UpdateDonating-反序列化出错: %s", err))
-			}
-			if s.Donating.ObjectOfDonating == objectOfDonating && s.Donating.Donor == donor && s.Grantee == grantee {
-				if s.Donating.DonatingStatus == lib.DonatingStatusConstant()["donatingStart"] {
-					donatingGrantee = s
-					break
-				}
-			}
-		}
+	//超过接收期限后只能设置为过期，不能再确认接收
+	overdue, err := isDonatingOverdue(stub, donating)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if status == "done" && overdue {
+		return shim.Error("此捐赠已超过接收期限，不能确认接收")
+	}
+	if status == "expired" && !overdue {
+		return shim.Error("此捐赠尚未超过接收期限，不能设置为过期")
 	}
 
 	var data []byte
 	switch status {
 	case "done":
-		realEstate.Proprietor = grantee
+		//受赠人按税费标准缴纳赠与税，转入国库账户
+		schedule, err := getFeeSchedule(stub)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		treasury, err := getTreasury(stub, schedule)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		payer, err := getAccount(stub, grantee)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		tax, basis := giftTax(schedule, realEstate)
+		taxItem, err := payFee(stub, "giftTax", grantee, &payer, treasury, tax, basis, objectOfDonating)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := writeReceipt(stub, &lib.Receipt{
+			ObjectType:   "donating",
+			ObjectKey:    donatingKey,
+			RealEstateID: objectOfDonating,
+			Parties:      []string{donor, grantee},
+			Items:        []lib.ReceiptItem{taxItem},
+		}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
 		realEstate.Encumbrance = false
-		realEstate.RealEstateID = fmt.Sprintf("%d", time.Now().Local().UnixNano())
-		if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		if err := changeProprietor(stub, &realEstate, grantee); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
-
-		if err := utils.DelLedger(stub, lib.RealEstateKey, []string{donor, objectOfDonating}); err != nil {
+		if err := writeRealEstate(stub, &realEstate, "donating", donatingKey); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
 
 		donating.DonatingStatus = lib.DonatingStatusConstant()["done"]
-		donating.ObjectOfDonating = realEstate.RealEstateID
-		if err := utils.WriteLedger(donating, stub, lib.DonatingKey, []string{donating.Donor, objectOfDonating, grantee}); err != nil {
+		if err := writeDonating(stub, &donating); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
 		donatingGrantee.Donating = donating
-		local, _ := time.LoadLocation("Local")
-		sellingBuyCreateTimeUnixNano, _ := time.ParseInLocation("2006-01-02 15:04:05", donatingGrantee.CreateTime, local)
-		if err := utils.WriteLedger(donatingGrantee, stub, lib.DonatingGranteeKey, []string{donatingGrantee.Grantee, fmt.Sprintf("%d", sellingBuyCreateTimeUnixNano.UnixNano())}); err != nil {
+		donatingGranteeCreateTimeKey, err := utils.TimeKey(donatingGrantee.CreateTime)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := utils.WritePrivateData(donatingGrantee, stub, lib.DealCollection, lib.DonatingGranteeKey, []string{donatingGrantee.Grantee, donatingGranteeCreateTimeKey}); err != nil {
 			return shim.Error(fmt.Sprintf("将本次捐赠交易写入账本失败%s", err))
 		}
-		data, err = json.Marshal(donatingGrantee)
+		public := publicDonating(donating)
+		if err := utils.SetEvent(stub, "donatingDone", &lib.DonatingEvent{TxID: stub.GetTxID(), Donating: public}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		data, err = json.Marshal(public)
 		if err != nil {
 			return shim.Error(fmt.Sprintf("序列化捐赠交易的信息出错: %s", err))
 		}
 		break
-	case "cancelled":
-		//重置房产信息担保状态
-		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
-		}
-		//更新捐赠状态
-		donating.DonatingStatus = lib.DonatingStatusConstant()["cancelled"]
-		if err := utils.WriteLedger(donating, stub, lib.DonatingKey, []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee}); err != nil {
+	case "cancelled", "expired":
+		if err := closeDonating(stub, status, &donating, realEstate, donatingGrantee); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
-		donatingGrantee.Donating = donating
-		local, _ := time.LoadLocation("Local")
-		sellingBuyCreateTimeUnixNano, _ := time.ParseInLocation("2006-01-02 15:04:05", donatingGrantee.CreateTime, local)
-		if err := utils.WriteLedger(donatingGrantee, stub, lib.DonatingGranteeKey, []string{donatingGrantee.Grantee, fmt.Sprintf("%d", sellingBuyCreateTimeUnixNano.UnixNano())}); err != nil {
+		public := publicDonating(donating)
+		eventName := map[string]string{"cancelled": "donatingCancelled", "expired": "donatingExpired"}[status]
+		if err := utils.SetEvent(stub, eventName, &lib.DonatingEvent{TxID: stub.GetTxID(), Donating: public}); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
-		data, err = json.Marshal(donatingGrantee)
+		data, err = json.Marshal(public)
 		if err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
@@ -257,3 +392,223 @@ func UpdateDonating(stub shim.ChaincodeStubInterface, args []string) peer.Respon
 	}
 	return shim.Success(data)
 }
+
+// ExpireDonatings 按交易时间关闭所有超过接收期限的捐赠中的捐赠，解除房地产的担保
+// 在一笔交易中完成，由应用的定时任务触发，返回本次过期的捐赠
+func ExpireDonatings(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 0 {
+		return shim.Error("参数个数不满足")
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingKey, []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var expiredList []lib.Donating
+	for _, v := range results {
+		var donating lib.Donating
+		if err := json.Unmarshal(v, &donating); err != nil {
+			return shim.Error(fmt.Sprintf("ExpireDonatings-反序列化出错: %s", err))
+		}
+		if donating.DonatingStatus != lib.DonatingStatusConstant()["donatingStart"] {
+			continue
+		}
+		overdue, err := isDonatingOverdue(stub, donating)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if !overdue {
+			continue
+		}
+		donatingKey, err := donatingKeys(donating)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.DonatingKey, donatingKey, &donating); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		donatingGrantee, err := getDonatingGrantee(stub, donating)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		realEstate, err := getRealEstate(stub, donating.ObjectOfDonating)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := closeDonating(stub, "expired", &donating, realEstate, donatingGrantee); err != nil {
+			return shim.Error(fmt.Sprintf("捐赠%s设置为过期失败%s", donating.ObjectOfDonating, err))
+		}
+		expiredList = append(expiredList, publicDonating(donating))
+	}
+	if len(expiredList) != 0 {
+		if err := utils.SetEvent(stub, "donatingsExpired", &lib.DonatingListEvent{TxID: stub.GetTxID(), Donatings: expiredList}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	expiredListByte, err := json.Marshal(expiredList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("ExpireDonatings-序列化出错: %s", err))
+	}
+	return shim.Success(expiredListByte)
+}
+
+// 升级前发起的捐赠没有接收期限(DonatingPeriod为0)，按创建后defaultDonatingPeriod天过期
+const defaultDonatingPeriod = 30
+
+// isDonatingOverdue 按交易时间判断捐赠是否已超过接收期限(创建时间加DonatingPeriod天)
+func isDonatingOverdue(stub shim.ChaincodeStubInterface, donating lib.Donating) (bool, error) {
+	donatingPeriod := donating.DonatingPeriod
+	if donatingPeriod == 0 {
+		donatingPeriod = defaultDonatingPeriod
+	}
+	createTime, err := utils.ParseTime(donating.CreateTime)
+	if err != nil {
+		return false, err
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return false, err
+	}
+	deadline := createTime.Add(time.Duration(donatingPeriod) * 24 * time.Hour)
+	return !txTime.Before(deadline), nil
+}
+
+// closeDonating 取消或过期捐赠，解除房地产的担保并同步受赠人的查询记录
+func closeDonating(stub shim.ChaincodeStubInterface, status string, donating *lib.Donating, realEstate lib.RealEstate, donatingGrantee lib.DonatingGrantee) error {
+	donatingKey, err := donatingKeys(*donating)
+	if err != nil {
+		return err
+	}
+	//重置房产信息担保状态
+	realEstate.Encumbrance = false
+	if err := writeRealEstate(stub, &realEstate, "donating", donatingKey); err != nil {
+		return err
+	}
+	//更新捐赠状态
+	donating.DonatingStatus = lib.DonatingStatusConstant()[status]
+	if err := writeDonating(stub, donating); err != nil {
+		return err
+	}
+	donatingGrantee.Donating = *donating
+	donatingGranteeCreateTimeKey, err := utils.TimeKey(donatingGrantee.CreateTime)
+	if err != nil {
+		return err
+	}
+	return utils.WritePrivateData(donatingGrantee, stub, lib.DealCollection, lib.DonatingGranteeKey, []string{donatingGrantee.Grantee, donatingGranteeCreateTimeKey})
+}
+
+// getDonatingGrantee 获取捐赠对应的受赠人查询记录，与捐赠的创建时间相同
+func getDonatingGrantee(stub shim.ChaincodeStubInterface, donating lib.Donating) (lib.DonatingGrantee, error) {
+	createTimeKey, err := utils.TimeKey(donating.CreateTime)
+	if err != nil {
+		return lib.DonatingGrantee{}, err
+	}
+	bytes, err := utils.GetPrivateData(stub, lib.DealCollection, lib.DonatingGranteeKey, []string{donating.Grantee, createTimeKey})
+	if err != nil {
+		return lib.DonatingGrantee{}, err
+	}
+	if bytes == nil {
+		return lib.DonatingGrantee{}, errors.New(fmt.Sprintf("根据%s获取受赠人信息失败", donating.Grantee))
+	}
+	var donatingGrantee lib.DonatingGrantee
+	if err := json.Unmarshal(bytes, &donatingGrantee); err != nil {
+		return lib.DonatingGrantee{}, errors.New(fmt.Sprintf("DonatingGrantee-反序列化出错: %s", err))
+	}
+	return donatingGrantee, nil
+}
+
+// getDonating 获取捐赠人将房产捐赠给受赠人的捐赠，有多条时优先返回捐赠中的
+// 捐赠人和受赠人只保存在私有数据集合中，升级前发起的捐赠需要先执行MigratePrivateData
+func getDonating(stub shim.ChaincodeStubInterface, donor string, objectOfDonating string, grantee string) (lib.Donating, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingKey, []string{objectOfDonating})
+	if err != nil {
+		return lib.Donating{}, err
+	}
+	var found []lib.Donating
+	for _, v := range results {
+		var donating lib.Donating
+		if err := json.Unmarshal(v, &donating); err != nil {
+			return lib.Donating{}, errors.New(fmt.Sprintf("Donating-反序列化出错: %s", err))
+		}
+		donatingKey, err := donatingKeys(donating)
+		if err != nil {
+			return lib.Donating{}, err
+		}
+		private, err := utils.GetPrivateData(stub, lib.DealCollection, lib.DonatingKey, donatingKey)
+		if err != nil {
+			return lib.Donating{}, err
+		}
+		if private == nil {
+			continue
+		}
+		if err := json.Unmarshal(private, &donating); err != nil {
+			return lib.Donating{}, errors.New(fmt.Sprintf("Donating-私有数据反序列化出错: %s", err))
+		}
+		if donating.Donor != donor || donating.Grantee != grantee {
+			continue
+		}
+		if donating.DonatingStatus == lib.DonatingStatusConstant()["donatingStart"] {
+			return donating, nil
+		}
+		found = append(found, donating)
+	}
+	if len(found) == 0 {
+		return lib.Donating{}, errors.New(fmt.Sprintf("根据%s和%s和%s获取捐赠信息失败", objectOfDonating, donor, grantee))
+	}
+	return found[len(found)-1], nil
+}
+
+// getDonatingByKey 根据复合键获取完整的捐赠
+func getDonatingByKey(stub shim.ChaincodeStubInterface, donatingKey []string) (lib.Donating, error) {
+	var donating lib.Donating
+	bytes, err := utils.GetPrivateData(stub, lib.DealCollection, lib.DonatingKey, donatingKey)
+	if err != nil {
+		return donating, err
+	}
+	if bytes == nil {
+		return donating, errors.New(fmt.Sprintf("根据%v获取捐赠信息失败", donatingKey))
+	}
+	if err := json.Unmarshal(bytes, &donating); err != nil {
+		return donating, errors.New(fmt.Sprintf("Donating-私有数据反序列化出错: %s", err))
+	}
+	return donating, nil
+}
+
+// donatingKeys 捐赠的复合键，由捐赠对象和创建时间组成
+func donatingKeys(donating lib.Donating) ([]string, error) {
+	createTimeKey, err := utils.TimeKey(donating.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	return []string{donating.ObjectOfDonating, createTimeKey}, nil
+}
+
+// writeDonatingDonor 写入供捐赠人查询的捐赠索引
+func writeDonatingDonor(stub shim.ChaincodeStubInterface, donating *lib.Donating) error {
+	createTimeKey, err := utils.TimeKey(donating.CreateTime)
+	if err != nil {
+		return err
+	}
+	donatingDonor := &lib.DonatingDonor{
+		Donor:            donating.Donor,
+		ObjectOfDonating: donating.ObjectOfDonating,
+		CreateTime:       donating.CreateTime,
+	}
+	return utils.WritePrivateData(donatingDonor, stub, lib.DealCollection, lib.DonatingDonorKey, []string{donatingDonor.Donor, donatingDonor.ObjectOfDonating, createTimeKey})
+}
+
+// writeDonating 完整的捐赠写入私有数据集合，账本中公开的捐赠不含捐赠人和受赠人
+func writeDonating(stub shim.ChaincodeStubInterface, donating *lib.Donating) error {
+	donatingKey, err := donatingKeys(*donating)
+	if err != nil {
+		return err
+	}
+	public := publicDonating(*donating)
+	return writeWithPrivate(stub, lib.DealCollection, lib.DonatingKey, donatingKey, donating, &public)
+}
+
+// publicDonating 去掉捐赠人和受赠人的捐赠，用于写入账本、事件和交易的返回值
+func publicDonating(donating lib.Donating) lib.Donating {
+	donating.Donor = ""
+	donating.Grantee = ""
+	return donating
+}
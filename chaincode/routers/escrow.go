@@ -0,0 +1,275 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// QueryEscrowList 分页查询托管记录，可以指定卖家，或卖家和销售对象
+// 只有买卖双方、登记员、银行和审计员可以看到买家和托管金额
+func QueryEscrowList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) > 2 {
+		return shim.Error("最多指定卖家和销售对象两个查询条件")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.EscrowKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var escrowList []lib.Escrow
+	for _, v := range results {
+		var escrow lib.Escrow
+		if err := json.Unmarshal(v, &escrow); err != nil {
+			return shim.Error(fmt.Sprintf("QueryEscrowList-反序列化出错: %s", err))
+		}
+		full := escrow
+		if err := readPrivateEscrow(stub, &full); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if canReadPrivate(caller, lib.DealCollection, full.Seller, full.Buyer) {
+			escrow = full
+		}
+		escrowList = append(escrowList, escrow)
+	}
+	return pageResponse(escrowList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryFundsSummary 汇总账户余额和托管中的资金，用于对账
+// 只有存取款会改变全部资金，购买、确认收款、取消和过期只是在账户和托管之间转移
+func QueryFundsSummary(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	var summary lib.FundsSummary
+	accounts, err := utils.GetStateByPartialCompositeKeys2(stub, lib.AccountKey, []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	for _, v := range accounts {
+		var account lib.Account
+		if err := json.Unmarshal(v, &account); err != nil {
+			return shim.Error(fmt.Sprintf("QueryFundsSummary-反序列化出错: %s", err))
+		}
+		if err := readPrivate(stub, lib.AccountCollection, lib.AccountKey, []string{account.AccountId}, &account); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		summary.Balance += account.Balance
+	}
+	escrows, err := utils.GetStateByPartialCompositeKeys2(stub, lib.EscrowKey, []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	for _, v := range escrows {
+		var escrow lib.Escrow
+		if err := json.Unmarshal(v, &escrow); err != nil {
+			return shim.Error(fmt.Sprintf("QueryFundsSummary-反序列化出错: %s", err))
+		}
+		if err := readPrivateEscrow(stub, &escrow); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if escrow.EscrowStatus == lib.EscrowStatusConstant()["held"] {
+			summary.Escrow += escrow.Amount + escrow.DeedTax
+		}
+	}
+	summary.Total = summary.Balance + summary.Escrow
+	summaryByte, err := json.Marshal(summary)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryFundsSummary-序列化出错: %s", err))
+	}
+	return shim.Success(summaryByte)
+}
+
+// MigrateEscrows 为升级前已经处于交付中的销售补建托管记录，只有登记员可以调用
+// 升级前买家付款已经从余额中扣除，这里只补记托管，不再变动余额，可以重复执行
+func MigrateEscrows(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("参数个数不满足")
+	}
+	if _, err := checkAccountOwner(stub, args[0]); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var escrowList []lib.Escrow
+	for _, v := range results {
+		var selling lib.Selling
+		if err := json.Unmarshal(v, &selling); err != nil {
+			return shim.Error(fmt.Sprintf("MigrateEscrows-反序列化出错: %s", err))
+		}
+		if selling.SellingStatus != lib.SellingStatusConstant()["delivery"] {
+			continue
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, []string{selling.Seller, selling.ObjectOfSale}, &selling); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if _, err := getHeldEscrow(stub, selling.Seller, selling.ObjectOfSale, selling.Buyer); err == nil {
+			continue
+		}
+		escrow := lib.Escrow{
+			EscrowID:     utils.GenerateID(stub, len(escrowList)),
+			ObjectOfSale: selling.ObjectOfSale,
+			Seller:       selling.Seller,
+			Buyer:        selling.Buyer,
+			Amount:       selling.Price,
+			EscrowStatus: lib.EscrowStatusConstant()["held"],
+			CreateTime:   utils.FormatTime(txTime),
+		}
+		if err := writeEscrow(stub, &escrow); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		escrowList = append(escrowList, publicEscrow(escrow))
+	}
+	escrowListByte, err := json.Marshal(escrowList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("MigrateEscrows-序列化出错: %s", err))
+	}
+	return shim.Success(escrowListByte)
+}
+
+// holdEscrow 从买家余额中扣除amount和契税deedTax并转入托管，journalType为买家的流水类型，押金的deedTax为0
+func holdEscrow(stub shim.ChaincodeStubInterface, seller string, objectOfSale string, amount lib.Amount, deedTax lib.Amount, journalType string, buyer *lib.Account) (lib.Escrow, error) {
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return lib.Escrow{}, err
+	}
+	if err := changeBalance(stub, buyer, -amount, journalType, seller, objectOfSale); err != nil {
+		return lib.Escrow{}, err
+	}
+	if deedTax != 0 {
+		if err := changeBalance(stub, buyer, -deedTax, "deedTax", seller, objectOfSale); err != nil {
+			return lib.Escrow{}, err
+		}
+	}
+	escrow := lib.Escrow{
+		EscrowID:     utils.GenerateID(stub, 0),
+		ObjectOfSale: objectOfSale,
+		Seller:       seller,
+		Buyer:        buyer.AccountId,
+		Amount:       amount,
+		DeedTax:      deedTax,
+		EscrowStatus: lib.EscrowStatusConstant()["held"],
+		CreateTime:   utils.FormatTime(txTime),
+	}
+	return escrow, writeEscrow(stub, &escrow)
+}
+
+// settleEscrow 结束托管，released支付给卖家，refunded退还买家，forfeited没收押金支付给出租人，account为收款方账户
+// 退还买家时托管的契税一起退还，支付给卖家时契税由settleSale转入国库账户
+func settleEscrow(stub shim.ChaincodeStubInterface, escrow *lib.Escrow, status string, account *lib.Account) error {
+	if escrow.EscrowStatus != lib.EscrowStatusConstant()["held"] {
+		return errors.New(fmt.Sprintf("托管%s不处于托管中状态", escrow.EscrowID))
+	}
+	journalType, counterparty, amount := "sale", escrow.Buyer, escrow.Amount
+	switch status {
+	case "refunded":
+		journalType, counterparty, amount = "refund", escrow.Seller, escrow.Amount+escrow.DeedTax
+	case "forfeited":
+		journalType = "forfeit"
+	}
+	if err := changeBalance(stub, account, amount, journalType, counterparty, escrow.ObjectOfSale); err != nil {
+		return err
+	}
+	return closeEscrow(stub, escrow, status)
+}
+
+// releaseEscrowToOwners 将托管的售房资金扣除fee后按份额支付给房地产的所有共有人，除不尽的部分计入代表业主seller
+// fee为卖方承担的交易手续费，由调用方转入国库账户
+// 买家也可能是共有人，accounts缓存本交易中已经读取的账户，调用方之后变动的同一账户需要从accounts中取出，见getAccountOnce
+func releaseEscrowToOwners(stub shim.ChaincodeStubInterface, escrow *lib.Escrow, realEstate lib.RealEstate, seller *lib.Account, fee lib.Amount, accounts map[string]*lib.Account) error {
+	owners := realEstateOwners(realEstate)
+	if len(owners) == 1 && fee == 0 {
+		return settleEscrow(stub, escrow, "released", seller)
+	}
+	if escrow.EscrowStatus != lib.EscrowStatusConstant()["held"] {
+		return errors.New(fmt.Sprintf("托管%s不处于托管中状态", escrow.EscrowID))
+	}
+	proceeds := escrow.Amount - fee
+	remaining := proceeds
+	for _, v := range owners {
+		if v.AccountId == seller.AccountId {
+			continue
+		}
+		amount := proceeds * lib.Amount(v.Share) / lib.ShareTotal
+		if amount == 0 {
+			continue
+		}
+		account, err := getAccountOnce(stub, accounts, v.AccountId)
+		if err != nil {
+			return err
+		}
+		if err := changeBalance(stub, account, amount, "sale", escrow.Buyer, escrow.ObjectOfSale); err != nil {
+			return err
+		}
+		remaining -= amount
+	}
+	if err := changeBalance(stub, seller, remaining, "sale", escrow.Buyer, escrow.ObjectOfSale); err != nil {
+		return err
+	}
+	return closeEscrow(stub, escrow, "released")
+}
+
+// closeEscrow 记录托管结束的状态和时间
+func closeEscrow(stub shim.ChaincodeStubInterface, escrow *lib.Escrow, status string) error {
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	escrow.EscrowStatus = lib.EscrowStatusConstant()[status]
+	escrow.SettleTime = utils.FormatTime(txTime)
+	return writeEscrow(stub, escrow)
+}
+
+// getHeldEscrow 获取买家为卖家的销售对象托管中的记录
+func getHeldEscrow(stub shim.ChaincodeStubInterface, seller string, objectOfSale string, buyer string) (lib.Escrow, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.EscrowKey, []string{seller, objectOfSale})
+	if err != nil {
+		return lib.Escrow{}, err
+	}
+	for _, v := range results {
+		var escrow lib.Escrow
+		if err := json.Unmarshal(v, &escrow); err != nil {
+			return lib.Escrow{}, errors.New(fmt.Sprintf("Escrow-反序列化出错: %s", err))
+		}
+		if err := readPrivateEscrow(stub, &escrow); err != nil {
+			return lib.Escrow{}, err
+		}
+		if escrow.EscrowStatus == lib.EscrowStatusConstant()["held"] && escrow.Buyer == buyer {
+			return escrow, nil
+		}
+	}
+	return lib.Escrow{}, errors.New(fmt.Sprintf("%s没有%s托管中的购房资金", objectOfSale, buyer))
+}
+
+// writeEscrow 完整的托管写入私有数据集合，账本中公开的托管不含买家和金额
+func writeEscrow(stub shim.ChaincodeStubInterface, escrow *lib.Escrow) error {
+	public := publicEscrow(*escrow)
+	return writeWithPrivate(stub, lib.DealCollection, lib.EscrowKey, []string{escrow.Seller, escrow.ObjectOfSale, escrow.EscrowID}, escrow, &public)
+}
+
+// publicEscrow 去掉买家、金额和契税的托管，用于写入账本和交易的返回值
+func publicEscrow(escrow lib.Escrow) lib.Escrow {
+	escrow.Buyer = ""
+	escrow.Amount = 0
+	escrow.DeedTax = 0
+	return escrow
+}
+
+// readPrivateEscrow 从私有数据集合读取完整的托管
+func readPrivateEscrow(stub shim.ChaincodeStubInterface, escrow *lib.Escrow) error {
+	return readPrivate(stub, lib.DealCollection, lib.EscrowKey, []string{escrow.Seller, escrow.ObjectOfSale, escrow.EscrowID}, escrow)
+}
@@ -0,0 +1,273 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"math"
+	"strconv"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// SetFeeSchedule 设置契税税率、交易手续费率、赠与税每平方米金额和收取税费的国库账户，只有登记员可以调用
+// 税率单位为万分之一，全部为0时不收取税费，可以不指定国库账户
+func SetFeeSchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 5 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	treasury := args[4]
+	if accountId == "" || args[1] == "" || args[2] == "" || args[3] == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
+	}
+	var rates [2]int
+	for i := range rates {
+		val, err := strconv.Atoi(args[i+1])
+		if err != nil {
+			return shim.Error(fmt.Sprintf("税率参数格式转换出错: %s", err))
+		}
+		if val < 0 || val > lib.RateTotal {
+			return shim.Error(fmt.Sprintf("税率必须在0到%d之间", lib.RateTotal))
+		}
+		rates[i] = val
+	}
+	giftTaxPerArea, err := lib.ParseAmount(args[3])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("赠与税金额格式转换出错: %s", err))
+	}
+	if giftTaxPerArea < 0 {
+		return shim.Error("赠与税金额不能小于0")
+	}
+	if rates[0] != 0 || rates[1] != 0 || giftTaxPerArea != 0 {
+		if treasury == "" {
+			return shim.Error("收取税费时必须指定国库账户")
+		}
+	}
+	//国库账户不能是业主，不会成为出售或捐赠的当事人，同一交易中不会重复变动余额
+	if treasury != "" {
+		account, err := getAccount(stub, treasury)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := checkAccountActive(account); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if hasRole(account, "owner") {
+			return shim.Error(fmt.Sprintf("国库账户%s不能是业主", treasury))
+		}
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	schedule := &lib.FeeSchedule{
+		DeedTaxRate:        rates[0],
+		TransactionFeeRate: rates[1],
+		GiftTaxPerArea:     giftTaxPerArea,
+		Treasury:           treasury,
+		Operator:           accountId,
+		UpdateTime:         utils.FormatTime(txTime),
+	}
+	if err := utils.WriteLedger(schedule, stub, lib.FeeScheduleKey, []string{}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	scheduleByte, err := json.Marshal(schedule)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化税费标准出错: %s", err))
+	}
+	return shim.Success(scheduleByte)
+}
+
+// QueryFeeSchedule 查询当前的税费标准，未设置时税率全部为0
+func QueryFeeSchedule(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 0 {
+		return shim.Error("参数个数不满足")
+	}
+	schedule, err := getFeeSchedule(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	scheduleByte, err := json.Marshal(schedule)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化税费标准出错: %s", err))
+	}
+	return shim.Success(scheduleByte)
+}
+
+// QueryReceipt 根据完成交易的交易ID查询税费收据，只有当事人和可以查看DealCollection的角色可以查询
+func QueryReceipt(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("参数个数不满足")
+	}
+	if args[0] == "" {
+		return shim.Error("参数存在空值")
+	}
+	receiptByte, err := utils.GetPrivateData(stub, lib.DealCollection, lib.ReceiptKey, []string{args[0]})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if receiptByte == nil {
+		return shim.Error(fmt.Sprintf("交易%s没有税费收据", args[0]))
+	}
+	var receipt lib.Receipt
+	if err := json.Unmarshal(receiptByte, &receipt); err != nil {
+		return shim.Error(fmt.Sprintf("QueryReceipt-反序列化出错: %s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.DealCollection, receipt.Parties...) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询交易%s的税费收据", args[0]))
+	}
+	return shim.Success(receiptByte)
+}
+
+// getFeeSchedule 获取当前的税费标准，未设置时返回全部为0的标准
+func getFeeSchedule(stub shim.ChaincodeStubInterface) (lib.FeeSchedule, error) {
+	var schedule lib.FeeSchedule
+	results, err := utils.GetStateByPartialCompositeKeys(stub, lib.FeeScheduleKey, []string{})
+	if err != nil {
+		return schedule, err
+	}
+	if len(results) == 0 {
+		return schedule, nil
+	}
+	if err := json.Unmarshal(results[0], &schedule); err != nil {
+		return schedule, errors.New(fmt.Sprintf("FeeSchedule-反序列化出错: %s", err))
+	}
+	return schedule, nil
+}
+
+// getTreasury 获取收取税费的国库账户，未设置时返回nil，此时所有税费都为0
+func getTreasury(stub shim.ChaincodeStubInterface, schedule lib.FeeSchedule) (*lib.Account, error) {
+	if schedule.Treasury == "" {
+		return nil, nil
+	}
+	account, err := getAccount(stub, schedule.Treasury)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// rateFee 按税率计算税费，不足一分的部分舍去，同时返回收据中的计税依据
+func rateFee(price lib.Amount, rate int) (lib.Amount, string) {
+	return price * lib.Amount(rate) / lib.RateTotal, fmt.Sprintf("成交价%s元，费率%d.%02d%%", price, rate/100, rate%100)
+}
+
+// giftTax 按房地产总面积计算赠与税，四舍五入到分，同时返回收据中的计税依据
+func giftTax(schedule lib.FeeSchedule, realEstate lib.RealEstate) (lib.Amount, string) {
+	return lib.Amount(math.Round(float64(schedule.GiftTaxPerArea) * realEstate.TotalArea)), fmt.Sprintf("总面积%.2f平方米，每平方米%s元", realEstate.TotalArea, schedule.GiftTaxPerArea)
+}
+
+// payFee 从payer余额中扣除税费并转入国库账户，payer为nil时税费已从托管资金中扣除，只转入国库账户
+// 返回收据中的一项，税费为0时不变动余额
+func payFee(stub shim.ChaincodeStubInterface, item string, payerId string, payer *lib.Account, treasury *lib.Account, amount lib.Amount, basis string, reference string) (lib.ReceiptItem, error) {
+	receiptItem := lib.ReceiptItem{
+		Item:   lib.FeeItemConstant()[item],
+		Payer:  payerId,
+		Basis:  basis,
+		Amount: amount,
+	}
+	if treasury != nil {
+		receiptItem.Payee = treasury.AccountId
+	}
+	if amount == 0 {
+		return receiptItem, nil
+	}
+	if treasury == nil {
+		return receiptItem, errors.New(fmt.Sprintf("未设置国库账户，不能收取%s", receiptItem.Item))
+	}
+	if payer != nil {
+		if err := changeBalance(stub, payer, -amount, item, treasury.AccountId, reference); err != nil {
+			return receiptItem, errors.New(fmt.Sprintf("缴纳%s失败: %s", receiptItem.Item, err))
+		}
+	}
+	//每项税费的收入流水类型不同，同一交易中收取多项税费时流水的复合键不会重复
+	if err := changeBalance(stub, treasury, amount, item+"In", payerId, reference); err != nil {
+		return receiptItem, err
+	}
+	return receiptItem, nil
+}
+
+// settleSale 销售确认收款或拍卖成交时结算托管的售房资金，accounts见releaseEscrowToOwners
+// 按税费标准从售房资金中扣除卖家的交易手续费，和托管的契税一起转入国库账户，其余资金按份额支付给共有人
+func settleSale(stub shim.ChaincodeStubInterface, escrow *lib.Escrow, realEstate lib.RealEstate, objectType string, objectKey []string, accounts map[string]*lib.Account) error {
+	schedule, err := getFeeSchedule(stub)
+	if err != nil {
+		return err
+	}
+	treasury, err := getTreasury(stub, schedule)
+	if err != nil {
+		return err
+	}
+	seller, err := getAccountOnce(stub, accounts, escrow.Seller)
+	if err != nil {
+		return err
+	}
+	transactionFee, feeBasis := rateFee(escrow.Amount, schedule.TransactionFeeRate)
+	if err := releaseEscrowToOwners(stub, escrow, realEstate, seller, transactionFee, accounts); err != nil {
+		return err
+	}
+	feeItem, err := payFee(stub, "transactionFee", escrow.Seller, nil, treasury, transactionFee, feeBasis, escrow.ObjectOfSale)
+	if err != nil {
+		return err
+	}
+	//契税在购买或出价时已经按当时的税费标准转入托管，之后取消了国库账户时退还买家
+	deedTax, deedTaxBasis := escrow.DeedTax, fmt.Sprintf("成交价%s元，购买或出价时托管", escrow.Amount)
+	if treasury == nil && deedTax != 0 {
+		buyer, err := getAccountOnce(stub, accounts, escrow.Buyer)
+		if err != nil {
+			return err
+		}
+		if err := changeBalance(stub, buyer, deedTax, "refund", escrow.Seller, escrow.ObjectOfSale); err != nil {
+			return err
+		}
+		deedTax, deedTaxBasis = 0, "未设置国库账户，托管的契税退还买家"
+	}
+	deedTaxItem, err := payFee(stub, "deedTax", escrow.Buyer, nil, treasury, deedTax, deedTaxBasis, escrow.ObjectOfSale)
+	if err != nil {
+		return err
+	}
+	return writeReceipt(stub, &lib.Receipt{
+		ObjectType:   objectType,
+		ObjectKey:    objectKey,
+		RealEstateID: escrow.ObjectOfSale,
+		Parties:      []string{escrow.Seller, escrow.Buyer},
+		Price:        escrow.Amount,
+		Items:        []lib.ReceiptItem{deedTaxItem, feeItem},
+		Proceeds:     escrow.Amount - transactionFee,
+	})
+}
+
+// escrowDeedTax 按当前税费标准计算成交价price应缴纳的契税，购买或出价时随购房资金一起转入托管
+func escrowDeedTax(stub shim.ChaincodeStubInterface, price lib.Amount) (lib.Amount, error) {
+	schedule, err := getFeeSchedule(stub)
+	if err != nil {
+		return 0, err
+	}
+	deedTax, _ := rateFee(price, schedule.DeedTaxRate)
+	return deedTax, nil
+}
+
+// writeReceipt 汇总税费合计并将收据写入DealCollection，TxID为当前交易ID
+func writeReceipt(stub shim.ChaincodeStubInterface, receipt *lib.Receipt) error {
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	receipt.TxID = stub.GetTxID()
+	receipt.CreateTime = utils.FormatTime(txTime)
+	receipt.Total = 0
+	for _, v := range receipt.Items {
+		receipt.Total += v.Amount
+	}
+	return utils.WritePrivateData(receipt, stub, lib.DealCollection, lib.ReceiptKey, []string{receipt.TxID})
+}
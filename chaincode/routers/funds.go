@@ -0,0 +1,200 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// Deposit 银行为账户存入资金
+func Deposit(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return bankOperation(stub, args, "deposit")
+}
+
+// Withdraw 银行为账户办理取款
+func Withdraw(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	return bankOperation(stub, args, "withdraw")
+}
+
+// bankOperation 存取款，金额可以置空并通过transient的amount传入
+func bankOperation(stub shim.ChaincodeStubInterface, args []string, journalType string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	targetAccountId := args[1]
+	amount, err := privateArg(stub, args, 2, "amount")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if accountId == "" || targetAccountId == "" || amount == "" {
+		return shim.Error("参数存在空值")
+	}
+	formattedAmount, err := parseAmount(amount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	account, err := getAccount(stub, targetAccountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if journalType == "withdraw" {
+		formattedAmount = -formattedAmount
+	}
+	if err := changeBalance(stub, &account, formattedAmount, journalType, accountId, ""); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(account))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化账户信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// Transfer 账户之间直接转账，金额可以置空并通过transient的amount传入
+func Transfer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	from := args[0]
+	to := args[1]
+	amount, err := privateArg(stub, args, 2, "amount")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if from == "" || to == "" || amount == "" {
+		return shim.Error("参数存在空值")
+	}
+	if from == to {
+		return shim.Error("转出和转入账户不能相同")
+	}
+	formattedAmount, err := parseAmount(amount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountFrom, err := checkAccountOwner(stub, from)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountFrom); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountTo, err := getAccount(stub, to)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountTo); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := changeBalance(stub, &accountFrom, -formattedAmount, "transferOut", to, ""); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := changeBalance(stub, &accountTo, formattedAmount, "transferIn", from, ""); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accountByte, err := json.Marshal(publicAccount(accountFrom))
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化账户信息出错: %s", err))
+	}
+	return shim.Success(accountByte)
+}
+
+// QueryAccountStatement 查询账户的余额变动流水，只有账户持有人、银行和审计员可以查询
+func QueryAccountStatement(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("必须指定AccountId查询")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.AccountCollection, args[0]) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询账户%s的流水", args[0]))
+	}
+	var journalList []lib.Journal
+	results, err := utils.GetPrivateDataByPartialCompositeKeys(stub, lib.AccountCollection, lib.JournalKey, args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	for _, v := range results {
+		if v != nil {
+			var journal lib.Journal
+			err := json.Unmarshal(v, &journal)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("QueryAccountStatement-反序列化出错: %s", err))
+			}
+			journalList = append(journalList, journal)
+		}
+	}
+	journalListByte, err := json.Marshal(journalList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryAccountStatement-序列化出错: %s", err))
+	}
+	return shim.Success(journalListByte)
+}
+
+// changeBalance 变动账户余额并记录流水，amount收入为正、支出为负
+// 账本不支持在同一交易中读到自己的写入，同一交易中多次变动同一账户时需要传入同一个account
+func changeBalance(stub shim.ChaincodeStubInterface, account *lib.Account, amount lib.Amount, journalType string, counterparty string, reference string) error {
+	if account.Balance+amount < 0 {
+		return errors.New(fmt.Sprintf("账户%s余额为%s,不足%s", account.AccountId, account.Balance, -amount))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	account.Balance += amount
+	if err := writeAccount(stub, *account); err != nil {
+		return err
+	}
+	journal := &lib.Journal{
+		AccountId:    account.AccountId,
+		TxID:         stub.GetTxID(),
+		JournalType:  lib.JournalTypeConstant()[journalType],
+		Amount:       amount,
+		Balance:      account.Balance,
+		Counterparty: counterparty,
+		Reference:    reference,
+		CreateTime:   utils.FormatTime(txTime),
+	}
+	createTimeKey, err := utils.TimeKey(journal.CreateTime)
+	if err != nil {
+		return err
+	}
+	return utils.WritePrivateData(journal, stub, lib.AccountCollection, lib.JournalKey, []string{journal.AccountId, createTimeKey, journal.TxID, journalType, journal.Reference})
+}
+
+// getAccountOnce 获取账户，同一交易中多次变动同一账户时从accounts中取出第一次读取的账户，保证多次变动累计生效
+func getAccountOnce(stub shim.ChaincodeStubInterface, accounts map[string]*lib.Account, accountId string) (*lib.Account, error) {
+	if account, ok := accounts[accountId]; ok {
+		return account, nil
+	}
+	account, err := getAccount(stub, accountId)
+	if err != nil {
+		return nil, err
+	}
+	accounts[accountId] = &account
+	return &account, nil
+}
+
+// parseAmount 解析以元为单位的金额参数，最多两位小数且必须大于0
+func parseAmount(amount string) (lib.Amount, error) {
+	val, err := lib.ParseAmount(amount)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("金额格式转换出错: %s", err))
+	}
+	if val <= 0 {
+		return 0, errors.New("金额必须大于0")
+	}
+	return val, nil
+}
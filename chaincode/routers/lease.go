@@ -0,0 +1,628 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"strconv"
+	"time"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// CreateLease 业主发起租约，等待承租人签署，期间房地产处于担保状态
+// 每期租金和押金可以置空并通过transient的rent和deposit传入
+func CreateLease(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 7 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfLease := args[0]
+	landlord := args[1]
+	tenant := args[2]
+	rent, err := privateArg(stub, args, 3, "rent")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	rentPeriod := args[4]
+	deposit, err := privateArg(stub, args, 5, "deposit")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	endTime := args[6]
+	if objectOfLease == "" || landlord == "" || tenant == "" || rent == "" || rentPeriod == "" || deposit == "" || endTime == "" {
+		return shim.Error("参数存在空值")
+	}
+	if landlord == tenant {
+		return shim.Error("出租人和承租人不能同一人")
+	}
+	formattedRent, err := parseAmount(rent)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("rent参数%s", err))
+	}
+	formattedDeposit, err := parseAmount(deposit)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("deposit参数%s", err))
+	}
+	var formattedRentPeriod int
+	if val, err := strconv.Atoi(rentPeriod); err != nil {
+		return shim.Error(fmt.Sprintf("rentPeriod参数格式转换出错: %s", err))
+	} else {
+		formattedRentPeriod = val
+	}
+	if formattedRentPeriod <= 0 {
+		return shim.Error("rentPeriod每期天数必须大于0天")
+	}
+	formattedEndTime, err := parseFutureTime(stub, endTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("endTime参数%s", err))
+	}
+	landlordAccount, err := checkAccountOwner(stub, landlord)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(landlordAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	tenantAccount, err := getAccount(stub, tenant)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("tenant承租人信息验证失败%s", err))
+	}
+	if err := checkAccountActive(tenantAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate, err := getRealEstate(stub, objectOfLease)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if realEstate.Proprietor != landlord {
+		return shim.Error(fmt.Sprintf("验证%s属于%s失败", objectOfLease, landlord))
+	}
+	if realEstate.Encumbrance {
+		return shim.Error("此房地产已经作为担保状态，不能出租")
+	}
+	if err := useOwnerConsents(stub, realEstate, "lease"); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	lease := &lib.Lease{
+		LeaseID:       utils.GenerateID(stub, 0),
+		ObjectOfLease: objectOfLease,
+		Landlord:      landlord,
+		Tenant:        tenant,
+		Rent:          formattedRent,
+		RentPeriod:    formattedRentPeriod,
+		Deposit:       formattedDeposit,
+		EndTime:       formattedEndTime,
+		CreateTime:    utils.FormatTime(txTime),
+		LeaseStatus:   lib.LeaseStatusConstant()["pending"],
+	}
+	if err := writeLease(stub, lease); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	leaseTenant := &lib.LeaseTenant{Tenant: tenant, Landlord: landlord, ObjectOfLease: objectOfLease, LeaseID: lease.LeaseID}
+	if err := utils.WriteLedger(leaseTenant, stub, lib.LeaseTenantKey, []string{tenant, landlord, objectOfLease, lease.LeaseID}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate.Encumbrance = true
+	if err := writeRealEstate(stub, &realEstate, "lease", leaseKeys(lease)); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return leaseResponse(stub, "leaseCreated", lease)
+}
+
+// SignLease 承租人签署租约，押金转入托管并支付第一期租金
+func SignLease(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfLease := args[0]
+	landlord := args[1]
+	leaseId := args[2]
+	if objectOfLease == "" || landlord == "" || leaseId == "" {
+		return shim.Error("参数存在空值")
+	}
+	lease, err := getLease(stub, landlord, objectOfLease, leaseId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if lease.LeaseStatus != lib.LeaseStatusConstant()["pending"] {
+		return shim.Error("此租约不处于待签署状态，不能签署")
+	}
+	if ended, err := isTimePassed(stub, lease.EndTime); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if ended {
+		return shim.Error("此租约的租期已经结束，不能签署")
+	}
+	tenantAccount, err := checkAccountOwner(stub, lease.Tenant)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(tenantAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	escrow, err := holdEscrow(stub, lease.Landlord, lease.ObjectOfLease, lease.Deposit, 0, "leaseDeposit", &tenantAccount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	lease.SignTime = utils.FormatTime(txTime)
+	lease.PaidUntil = lease.SignTime
+	lease.DepositStatus = escrow.EscrowStatus
+	lease.LeaseStatus = lib.LeaseStatusConstant()["active"]
+	if err := payLeaseRent(stub, &lease, &tenantAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeLease(stub, &lease); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return leaseResponse(stub, "leaseSigned", &lease)
+}
+
+// PayRent 承租人支付一期租金，可以提前支付，但不能超过租期
+func PayRent(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfLease := args[0]
+	landlord := args[1]
+	leaseId := args[2]
+	if objectOfLease == "" || landlord == "" || leaseId == "" {
+		return shim.Error("参数存在空值")
+	}
+	lease, err := getLease(stub, landlord, objectOfLease, leaseId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if lease.LeaseStatus != lib.LeaseStatusConstant()["active"] {
+		return shim.Error("此租约不处于租赁中状态，不能支付租金")
+	}
+	if paidUp, err := isRentPaidUp(lease); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if paidUp {
+		return shim.Error("此租约的租金已经付清到租期结束")
+	}
+	tenantAccount, err := checkAccountOwner(stub, lease.Tenant)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(tenantAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := payLeaseRent(stub, &lease, &tenantAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeLease(stub, &lease); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return leaseResponse(stub, "leaseRentPaid", &lease)
+}
+
+// UpdateLease 更新租约状态，status取值为 取消"cancelled"、终止"terminated"
+// 签署前出租人或承租人可以取消；终止时需要指定押金退还"refunded"或没收"forfeited"
+// 出租人可以随时终止并退还押金，欠租时可以没收押金；承租人在租期结束且不欠租时终止并取回押金，提前终止则放弃押金
+func UpdateLease(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 4 || len(args) > 5 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfLease := args[0]
+	landlord := args[1]
+	leaseId := args[2]
+	status := args[3]
+	if objectOfLease == "" || landlord == "" || leaseId == "" || status == "" {
+		return shim.Error("参数存在空值")
+	}
+	lease, err := getLease(stub, landlord, objectOfLease, leaseId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if caller.AccountId != lease.Landlord && caller.AccountId != lease.Tenant {
+		return shim.Error("只有出租人或承租人可以更新租约")
+	}
+	var eventName string
+	switch status {
+	case "cancelled":
+		if len(args) != 4 {
+			return shim.Error("取消租约不需要指定押金处理方式")
+		}
+		if lease.LeaseStatus != lib.LeaseStatusConstant()["pending"] {
+			return shim.Error("只能取消待签署的租约")
+		}
+		lease.LeaseStatus = lib.LeaseStatusConstant()["cancelled"]
+		eventName = "leaseCancelled"
+	case "terminated":
+		if len(args) != 5 {
+			return shim.Error("终止租约需要指定押金退还refunded或没收forfeited")
+		}
+		if lease.LeaseStatus != lib.LeaseStatusConstant()["active"] {
+			return shim.Error("此租约不处于租赁中状态，不能终止")
+		}
+		deposit := args[4]
+		ended, err := isTimePassed(stub, lease.EndTime)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		overdue, err := isRentOverdue(stub, lease)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		switch {
+		case deposit != "refunded" && deposit != "forfeited":
+			return shim.Error(fmt.Sprintf("押金处理方式%s不支持，只能为refunded或forfeited", deposit))
+		case caller.AccountId == lease.Landlord && deposit == "forfeited" && !overdue:
+			return shim.Error("承租人没有欠租，出租人不能没收押金")
+		case caller.AccountId == lease.Tenant && deposit == "refunded" && (!ended || overdue):
+			return shim.Error("租期未结束或存在欠租，承租人终止租约时只能放弃押金")
+		}
+		if err := settleLeaseDeposit(stub, &lease, deposit); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		lease.LeaseStatus = lib.LeaseStatusConstant()["terminated"]
+		eventName = "leaseTerminated"
+	default:
+		return shim.Error(fmt.Sprintf("%s状态不支持", status))
+	}
+	if err := closeLease(stub, &lease); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return leaseResponse(stub, eventName, &lease)
+}
+
+// FlagOverdueLeases 按交易时间标记所有欠租的租赁中租约，并关闭租期已经结束的租约，由应用的定时任务触发
+// 承租人补交租金后标记自动清除
+// 租期结束时未签署的租约直接关闭；租赁中的租约付清租金的押金退还承租人，欠租的押金没收给出租人，房地产解除担保
+// 返回本次新标记欠租和关闭的租约
+func FlagOverdueLeases(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 0 {
+		return shim.Error("参数个数不满足")
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.LeaseKey, []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var flaggedList, expiredList []lib.Lease
+	for _, v := range results {
+		var lease lib.Lease
+		if err := json.Unmarshal(v, &lease); err != nil {
+			return shim.Error(fmt.Sprintf("FlagOverdueLeases-反序列化出错: %s", err))
+		}
+		if lease.LeaseStatus != lib.LeaseStatusConstant()["pending"] && lease.LeaseStatus != lib.LeaseStatusConstant()["active"] {
+			continue
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.LeaseKey, leaseKeys(&lease), &lease); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		overdue, err := isRentOverdue(stub, lease)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		ended, err := isTimePassed(stub, lease.EndTime)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if ended {
+			if lease.LeaseStatus == lib.LeaseStatusConstant()["active"] {
+				deposit := "refunded"
+				if overdue {
+					deposit = "forfeited"
+				}
+				if err := settleLeaseDeposit(stub, &lease, deposit); err != nil {
+					return shim.Error(fmt.Sprintf("租约%s处理押金失败%s", lease.LeaseID, err))
+				}
+			}
+			lease.RentOverdue = overdue
+			lease.LeaseStatus = lib.LeaseStatusConstant()["expired"]
+			if err := closeLease(stub, &lease); err != nil {
+				return shim.Error(fmt.Sprintf("租约%s到期关闭失败%s", lease.LeaseID, err))
+			}
+			expiredList = append(expiredList, publicLease(lease))
+			continue
+		}
+		if lease.RentOverdue || !overdue {
+			continue
+		}
+		lease.RentOverdue = true
+		if err := writeLease(stub, &lease); err != nil {
+			return shim.Error(fmt.Sprintf("租约%s标记欠租失败%s", lease.LeaseID, err))
+		}
+		flaggedList = append(flaggedList, publicLease(lease))
+	}
+	//一笔交易只能设置一个事件，有到期关闭的租约时事件中同时包含新标记欠租的租约，按LeaseStatus区分
+	changedList := append(flaggedList, expiredList...)
+	if len(expiredList) != 0 {
+		if err := utils.SetEvent(stub, "leasesExpired", &lib.LeaseListEvent{TxID: stub.GetTxID(), Leases: changedList}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	} else if len(flaggedList) != 0 {
+		if err := utils.SetEvent(stub, "leasesOverdue", &lib.LeaseListEvent{TxID: stub.GetTxID(), Leases: flaggedList}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	flaggedListByte, err := json.Marshal(changedList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("FlagOverdueLeases-序列化出错: %s", err))
+	}
+	return shim.Success(flaggedListByte)
+}
+
+// QueryLeaseList 分页查询租约(可查询所有，也可根据出租人或出租人和出租对象查询)
+// 只有出租人、承租人、登记员、银行和审计员可以看到租金和押金
+func QueryLeaseList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) > 2 {
+		return shim.Error("最多指定出租人和出租对象两个查询条件")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.LeaseKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var leaseList []lib.Lease
+	for _, v := range results {
+		var lease lib.Lease
+		if err := json.Unmarshal(v, &lease); err != nil {
+			return shim.Error(fmt.Sprintf("QueryLeaseList-反序列化出错: %s", err))
+		}
+		if canReadPrivate(caller, lib.DealCollection, lease.Landlord, lease.Tenant) {
+			if err := readPrivate(stub, lib.DealCollection, lib.LeaseKey, leaseKeys(&lease), &lease); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		}
+		leaseList = append(leaseList, lease)
+	}
+	return pageResponse(leaseList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryLeaseListByTenant 根据承租人分页查询租约，租金和押金的可见范围与QueryLeaseList一致
+func QueryLeaseListByTenant(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 1 {
+		return shim.Error("必须指定承租人AccountId查询")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.LeaseTenantKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var leaseList []lib.Lease
+	for _, v := range results {
+		var leaseTenant lib.LeaseTenant
+		if err := json.Unmarshal(v, &leaseTenant); err != nil {
+			return shim.Error(fmt.Sprintf("QueryLeaseListByTenant-反序列化出错: %s", err))
+		}
+		lease, err := getLease(stub, leaseTenant.Landlord, leaseTenant.ObjectOfLease, leaseTenant.LeaseID)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if !canReadPrivate(caller, lib.DealCollection, lease.Landlord, lease.Tenant) {
+			lease = publicLease(lease)
+		}
+		leaseList = append(leaseList, lease)
+	}
+	return pageResponse(leaseList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryLeasePayments 按时间分页查询一份租约的所有租金支付，keys为[landlord, objectOfLease, leaseId]
+// 租金支付只保存在私有数据集合中，只有出租人、承租人、登记员、银行和审计员可以查询
+func QueryLeasePayments(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 3 {
+		return shim.Error("必须指定出租人、出租对象和LeaseID查询")
+	}
+	lease, err := getLease(stub, keys[0], keys[1], keys[2])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.DealCollection, lease.Landlord, lease.Tenant) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询租约%s的租金支付", lease.LeaseID))
+	}
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.LeasePaymentKey, []string{lease.LeaseID}, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var paymentList []lib.LeasePayment
+	for _, v := range results {
+		var payment lib.LeasePayment
+		if err := json.Unmarshal(v, &payment); err != nil {
+			return shim.Error(fmt.Sprintf("QueryLeasePayments-反序列化出错: %s", err))
+		}
+		paymentList = append(paymentList, payment)
+	}
+	return pageResponse(paymentList, pageSize, nextBookmark, hasMore)
+}
+
+// payLeaseRent 承租人支付一期租金给出租人，PaidUntil顺延一期，并记录租金支付
+// 租期不是整期时，最后一期只顺延到租期结束，租金按剩余时间折算，四舍五入到分
+func payLeaseRent(stub shim.ChaincodeStubInterface, lease *lib.Lease, tenant *lib.Account) error {
+	paidUntil, err := utils.ParseTime(lease.PaidUntil)
+	if err != nil {
+		return err
+	}
+	endTime, err := utils.ParseTime(lease.EndTime)
+	if err != nil {
+		return err
+	}
+	period := time.Duration(lease.RentPeriod) * 24 * time.Hour
+	rent := lease.Rent
+	nextPaidUntil := paidUntil.Add(period)
+	if nextPaidUntil.After(endTime) {
+		remaining, total := lib.Amount(endTime.Sub(paidUntil)/time.Second), lib.Amount(period/time.Second)
+		rent = (lease.Rent*remaining*2 + total) / (total * 2)
+		nextPaidUntil = endTime
+	}
+	landlordAccount, err := getAccount(stub, lease.Landlord)
+	if err != nil {
+		return err
+	}
+	if err := changeBalance(stub, tenant, -rent, "rentOut", lease.Landlord, lease.ObjectOfLease); err != nil {
+		return err
+	}
+	if err := changeBalance(stub, &landlordAccount, rent, "rentIn", lease.Tenant, lease.ObjectOfLease); err != nil {
+		return err
+	}
+	lease.PaidUntil = utils.FormatTime(nextPaidUntil)
+	overdue, err := isRentOverdue(stub, *lease)
+	if err != nil {
+		return err
+	}
+	lease.RentOverdue = overdue
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	payment := &lib.LeasePayment{
+		LeaseID:    lease.LeaseID,
+		TxID:       stub.GetTxID(),
+		Amount:     rent,
+		PaidUntil:  lease.PaidUntil,
+		CreateTime: utils.FormatTime(txTime),
+	}
+	createTimeKey, err := utils.TimeKey(payment.CreateTime)
+	if err != nil {
+		return err
+	}
+	return utils.WritePrivateData(payment, stub, lib.DealCollection, lib.LeasePaymentKey, []string{payment.LeaseID, createTimeKey, payment.TxID})
+}
+
+// isRentOverdue 按交易时间判断租赁中的租约是否欠租，即租期内已付清的时间已过
+func isRentOverdue(stub shim.ChaincodeStubInterface, lease lib.Lease) (bool, error) {
+	if lease.LeaseStatus != lib.LeaseStatusConstant()["active"] {
+		return false, nil
+	}
+	if paidUp, err := isRentPaidUp(lease); err != nil || paidUp {
+		return false, err
+	}
+	return isTimePassed(stub, lease.PaidUntil)
+}
+
+// isRentPaidUp 判断租金是否已经付清到租期结束
+func isRentPaidUp(lease lib.Lease) (bool, error) {
+	paidUntil, err := utils.ParseTime(lease.PaidUntil)
+	if err != nil {
+		return false, err
+	}
+	endTime, err := utils.ParseTime(lease.EndTime)
+	if err != nil {
+		return false, err
+	}
+	return !paidUntil.Before(endTime), nil
+}
+
+// settleLeaseDeposit 终止租约时处理托管的押金，refunded退还承租人，forfeited没收给出租人
+func settleLeaseDeposit(stub shim.ChaincodeStubInterface, lease *lib.Lease, deposit string) error {
+	payee := lease.Tenant
+	if deposit == "forfeited" {
+		payee = lease.Landlord
+	}
+	payeeAccount, err := getAccount(stub, payee)
+	if err != nil {
+		return err
+	}
+	escrow, err := getHeldEscrow(stub, lease.Landlord, lease.ObjectOfLease, lease.Tenant)
+	if err != nil {
+		return err
+	}
+	if err := settleEscrow(stub, &escrow, deposit, &payeeAccount); err != nil {
+		return err
+	}
+	lease.DepositStatus = escrow.EscrowStatus
+	return nil
+}
+
+// closeLease 写入已取消、终止或到期的租约，并解除房地产的担保
+func closeLease(stub shim.ChaincodeStubInterface, lease *lib.Lease) error {
+	realEstate, err := getRealEstate(stub, lease.ObjectOfLease)
+	if err != nil {
+		return err
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	lease.CloseTime = utils.FormatTime(txTime)
+	if err := writeLease(stub, lease); err != nil {
+		return err
+	}
+	realEstate.Encumbrance = false
+	return writeRealEstate(stub, &realEstate, "lease", leaseKeys(lease))
+}
+
+func getLease(stub shim.ChaincodeStubInterface, landlord string, objectOfLease string, leaseId string) (lib.Lease, error) {
+	var lease lib.Lease
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.LeaseKey, []string{landlord, objectOfLease, leaseId})
+	if err != nil {
+		return lease, err
+	}
+	if len(results) != 1 {
+		return lease, errors.New(fmt.Sprintf("租约%s不存在", leaseId))
+	}
+	if err := json.Unmarshal(results[0], &lease); err != nil {
+		return lease, errors.New(fmt.Sprintf("Lease-反序列化出错: %s", err))
+	}
+	if err := readPrivate(stub, lib.DealCollection, lib.LeaseKey, leaseKeys(&lease), &lease); err != nil {
+		return lease, err
+	}
+	return lease, nil
+}
+
+// writeLease 完整的租约写入私有数据集合，账本中公开的租约不含租金和押金
+func writeLease(stub shim.ChaincodeStubInterface, lease *lib.Lease) error {
+	public := publicLease(*lease)
+	return writeWithPrivate(stub, lib.DealCollection, lib.LeaseKey, leaseKeys(lease), lease, &public)
+}
+
+// publicLease 去掉租金和押金的租约，用于写入账本、事件和交易的返回值
+func publicLease(lease lib.Lease) lib.Lease {
+	lease.Rent = 0
+	lease.Deposit = 0
+	return lease
+}
+
+func leaseKeys(lease *lib.Lease) []string {
+	return []string{lease.Landlord, lease.ObjectOfLease, lease.LeaseID}
+}
+
+// leaseResponse 设置租约事件并返回租约信息，事件和返回值随交易写入区块，只包含公开的租约
+func leaseResponse(stub shim.ChaincodeStubInterface, eventName string, lease *lib.Lease) peer.Response {
+	public := publicLease(*lease)
+	if err := utils.SetEvent(stub, eventName, &lib.LeaseEvent{TxID: stub.GetTxID(), Lease: public}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	leaseByte, err := json.Marshal(public)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化租约信息出错: %s", err))
+	}
+	return shim.Success(leaseByte)
+}
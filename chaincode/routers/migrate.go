@@ -0,0 +1,165 @@
+package routers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// MigrateAmounts 将账本中以浮点数(元)存储的金额迁移为以分为单位的定点数，只有登记员可以调用
+// lib.Amount读取时兼容旧的数字格式，迁移只是把含有金额的记录按新格式重新写入，可以重复执行
+// 房地产和销售重新写入时同时补齐CouchDB富查询需要的docType等字段，房地产需要先执行MigrateRealEstateKeys
+func MigrateAmounts(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("参数个数不满足")
+	}
+	if _, err := checkAccountOwner(stub, args[0]); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	legacy, err := legacyRealEstateKeys(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(legacy) != 0 {
+		return shim.Error(fmt.Sprintf("账本中还有%d条以[Proprietor, RealEstateID]为复合键的房地产，请先执行migrateRealEstateKeys", len(legacy)))
+	}
+	//按固定顺序迁移，各背书节点的范围查询和写入顺序一致，读写集才能相同
+	migrations := []struct {
+		objectType string
+		newObject  func() interface{}
+	}{
+		{lib.AccountKey, func() interface{} { return &lib.Account{} }},
+		{lib.JournalKey, func() interface{} { return &lib.Journal{} }},
+		{lib.RealEstateKey, func() interface{} { return &lib.RealEstate{} }},
+		{lib.SellingKey, func() interface{} { return &lib.Selling{} }},
+		{lib.SellingBuyKey, func() interface{} { return &lib.SellingBuy{} }},
+	}
+	migrated := make(map[string]int)
+	for _, v := range migrations {
+		count, err := migrateObjects(stub, v.objectType, v.newObject)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		migrated[v.objectType] = count
+	}
+	migratedByte, err := json.Marshal(migrated)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("MigrateAmounts-序列化出错: %s", err))
+	}
+	return shim.Success(migratedByte)
+}
+
+// migrateObjects 重新序列化objectType下的所有记录，返回格式发生变化而被重新写入的条数
+func migrateObjects(stub shim.ChaincodeStubInterface, objectType string, newObject func() interface{}) (int, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+	count := 0
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		obj := newObject()
+		if err := json.Unmarshal(val.GetValue(), obj); err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-反序列化出错: %s", objectType, err))
+		}
+		objByte, err := json.Marshal(obj)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-序列化出错: %s", objectType, err))
+		}
+		if bytes.Equal(objByte, val.GetValue()) {
+			continue
+		}
+		if err := stub.PutState(val.GetKey(), objByte); err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-写入区块链账本出错: %s", objectType, err))
+		}
+		count++
+	}
+	return count, nil
+}
+
+// MigrateRealEstateKeys 将升级前以[Proprietor, RealEstateID]为复合键的房地产迁移到[RealEstateID]，只有登记员可以调用
+// 每条记录按新的复合键重新写入并补建所有者索引，然后删除旧的复合键，可以重复执行，需要在MigrateAmounts之前执行
+func MigrateRealEstateKeys(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("参数个数不满足")
+	}
+	if _, err := checkAccountOwner(stub, args[0]); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	legacy, err := legacyRealEstateKeys(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var realEstateList []lib.RealEstate
+	migrated := make(map[string]bool)
+	for _, kv := range legacy {
+		_, attributes, err := stub.SplitCompositeKey(kv.GetKey())
+		if err != nil {
+			return shim.Error(fmt.Sprintf("MigrateRealEstateKeys-解析复合键出错: %s", err))
+		}
+		var realEstate lib.RealEstate
+		if err := json.Unmarshal(kv.GetValue(), &realEstate); err != nil {
+			return shim.Error(fmt.Sprintf("MigrateRealEstateKeys-反序列化出错: %s", err))
+		}
+		if realEstate.RealEstateID != attributes[1] || realEstate.Proprietor != attributes[0] {
+			return shim.Error(fmt.Sprintf("房地产%s的记录与复合键%v不一致", realEstate.RealEstateID, attributes))
+		}
+		results, err := utils.GetStateByPartialCompositeKeys(stub, lib.RealEstateKey, []string{realEstate.RealEstateID})
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if len(results) != 0 || migrated[realEstate.RealEstateID] {
+			return shim.Error(fmt.Sprintf("房地产%s已存在于新的复合键下，不能迁移", realEstate.RealEstateID))
+		}
+		if err := writeRealEstate(stub, &realEstate, "migrate", attributes); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := writeOwnerIndex(stub, &realEstate); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := stub.DelState(kv.GetKey()); err != nil {
+			return shim.Error(fmt.Sprintf("MigrateRealEstateKeys-删除旧的复合键出错: %s", err))
+		}
+		migrated[realEstate.RealEstateID] = true
+		realEstateList = append(realEstateList, realEstate)
+	}
+	realEstateListByte, err := json.Marshal(realEstateList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("MigrateRealEstateKeys-序列化出错: %s", err))
+	}
+	return shim.Success(realEstateListByte)
+}
+
+// legacyRealEstateKeys 按复合键顺序返回升级前以[Proprietor, RealEstateID]为复合键的房地产
+func legacyRealEstateKeys(stub shim.ChaincodeStubInterface) ([]*queryresult.KV, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(lib.RealEstateKey, []string{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", lib.RealEstateKey, err))
+	}
+	defer resultIterator.Close()
+	var legacy []*queryresult.KV
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", lib.RealEstateKey, err))
+		}
+		_, attributes, err := stub.SplitCompositeKey(val.GetKey())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-解析复合键出错: %s", lib.RealEstateKey, err))
+		}
+		if len(attributes) == 2 {
+			legacy = append(legacy, val)
+		}
+	}
+	return legacy, nil
+}
@@ -0,0 +1,384 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// CreateOffer 买家对销售中的房产报价，报价时不扣款，接受时才转入托管
+// 报价可以置空并通过transient的price传入
+func CreateOffer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 5 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	buyer := args[2]
+	price, err := privateArg(stub, args, 3, "price")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	expireTime := args[4]
+	if objectOfSale == "" || seller == "" || buyer == "" || price == "" || expireTime == "" {
+		return shim.Error("参数存在空值")
+	}
+	if seller == buyer {
+		return shim.Error("买家和卖家不能同一人")
+	}
+	formattedPrice, err := parseAmount(price)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("price参数%s", err))
+	}
+	formattedExpireTime, err := parseFutureTime(stub, expireTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("expireTime参数%s", err))
+	}
+	buyerAccount, err := checkAccountOwner(stub, buyer)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("buyer买家信息验证失败%s", err))
+	}
+	if err := checkAccountActive(buyerAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if buyerAccount.Balance < formattedPrice {
+		return shim.Error(fmt.Sprintf("报价为%s,您的当前余额为%s,报价失败", formattedPrice, buyerAccount.Balance))
+	}
+	if _, err := getOpenSelling(stub, seller, objectOfSale); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	offer := &lib.Offer{
+		OfferID:      utils.GenerateID(stub, 0),
+		ObjectOfSale: objectOfSale,
+		Seller:       seller,
+		Buyer:        buyer,
+		Price:        formattedPrice,
+		OfferPrice:   formattedPrice,
+		ExpireTime:   formattedExpireTime,
+		CreateTime:   utils.FormatTime(txTime),
+		UpdateTime:   utils.FormatTime(txTime),
+		OfferStatus:  lib.OfferStatusConstant()["pending"],
+	}
+	if err := writeOffer(stub, offer); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	offerBuyer := &lib.OfferBuyer{Buyer: buyer, Seller: seller, ObjectOfSale: objectOfSale, OfferID: offer.OfferID}
+	if err := utils.WritePrivateData(offerBuyer, stub, lib.DealCollection, lib.OfferBuyerKey, []string{buyer, seller, objectOfSale, offer.OfferID}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return offerResponse(stub, "offerCreated", offer, nil)
+}
+
+// UpdateOffer 答复报价，status取值为 还价"countered"、接受"accepted"、拒绝"rejected"、撤回"withdrawn"
+// 待答复的报价由卖家还价、接受或拒绝，已还价的报价由买家接受或拒绝，买家在接受之前可以撤回
+// 还价时需要第五个参数price，可以置空并通过transient的price传入
+func UpdateOffer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 && len(args) != 5 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	offerId := args[2]
+	status := args[3]
+	if objectOfSale == "" || seller == "" || offerId == "" || status == "" {
+		return shim.Error("参数存在空值")
+	}
+	offer, err := getOffer(stub, seller, objectOfSale, offerId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	pending := offer.OfferStatus == lib.OfferStatusConstant()["pending"]
+	if !pending && offer.OfferStatus != lib.OfferStatusConstant()["countered"] {
+		return shim.Error(fmt.Sprintf("此报价%s，不能再答复", offer.OfferStatus))
+	}
+	//待答复时由卖家答复，已还价时由买家答复
+	responder := offer.Seller
+	if !pending {
+		responder = offer.Buyer
+	}
+	expired, err := isTimePassed(stub, offer.ExpireTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var selling *lib.Selling
+	switch status {
+	case "countered":
+		if !pending {
+			return shim.Error("只能对待答复的报价还价")
+		}
+		if len(args) != 5 {
+			return shim.Error("还价时必须指定价格")
+		}
+		if expired {
+			return shim.Error("此报价已过有效期，不能还价")
+		}
+		price, err := privateArg(stub, args, 4, "price")
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		counterPrice, err := parseAmount(price)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("price参数%s", err))
+		}
+		if _, err := checkAccountOwner(stub, responder); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		offer.Price = counterPrice
+	case "accepted":
+		if expired {
+			return shim.Error("此报价已过有效期，不能接受")
+		}
+		if _, err := checkAccountOwner(stub, responder); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		openSelling, err := getOpenSelling(stub, seller, objectOfSale)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		buyerAccount, err := getAccount(stub, offer.Buyer)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("buyer买家信息验证失败%s", err))
+		}
+		if err := checkAccountActive(buyerAccount); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if _, err := purchaseSelling(stub, &openSelling, &buyerAccount, offer.Price); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := rejectOtherOffers(stub, offer); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		selling = &openSelling
+	case "rejected":
+		if _, err := checkAccountOwner(stub, responder); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	case "withdrawn":
+		if _, err := checkAccountOwner(stub, offer.Buyer); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	default:
+		return shim.Error(fmt.Sprintf("%s状态不支持", status))
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	offer.OfferStatus = lib.OfferStatusConstant()[status]
+	offer.UpdateTime = utils.FormatTime(txTime)
+	if err := writeOffer(stub, &offer); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	eventName := map[string]string{
+		"countered": "offerCountered",
+		"accepted":  "offerAccepted",
+		"rejected":  "offerRejected",
+		"withdrawn": "offerWithdrawn",
+	}[status]
+	return offerResponse(stub, eventName, &offer, selling)
+}
+
+// QueryOfferList 分页查询报价(可查询所有，也可根据卖家，或卖家和销售对象查询)
+// 只有买卖双方、登记员、银行和审计员可以看到买家和价格
+func QueryOfferList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) > 2 {
+		return shim.Error("最多指定卖家和销售对象两个查询条件")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.OfferKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var offerList []lib.Offer
+	for _, v := range results {
+		var offer lib.Offer
+		if err := json.Unmarshal(v, &offer); err != nil {
+			return shim.Error(fmt.Sprintf("QueryOfferList-反序列化出错: %s", err))
+		}
+		full := offer
+		if err := readPrivateOffer(stub, &full); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if canReadPrivate(caller, lib.DealCollection, full.Seller, full.Buyer) {
+			offer = full
+		}
+		offerList = append(offerList, offer)
+	}
+	return pageResponse(offerList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryOfferListByBuyer 根据买家分页查询发出的报价，只有买家本人、登记员、银行和审计员可以查询
+func QueryOfferListByBuyer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 1 {
+		return shim.Error("必须指定买家AccountId查询")
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.DealCollection, keys[0]) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询买家%s的报价", keys[0]))
+	}
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.OfferBuyerKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var offerList []lib.Offer
+	for _, v := range results {
+		var offerBuyer lib.OfferBuyer
+		if err := json.Unmarshal(v, &offerBuyer); err != nil {
+			return shim.Error(fmt.Sprintf("QueryOfferListByBuyer-反序列化出错: %s", err))
+		}
+		offer, err := getOffer(stub, offerBuyer.Seller, offerBuyer.ObjectOfSale, offerBuyer.OfferID)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		offerList = append(offerList, offer)
+	}
+	return pageResponse(offerList, pageSize, nextBookmark, hasMore)
+}
+
+// getOpenSelling 获取可以购买的销售，必须处于销售中状态、未过有效期，且房产仍属于卖家
+func getOpenSelling(stub shim.ChaincodeStubInterface, seller string, objectOfSale string) (lib.Selling, error) {
+	var selling lib.Selling
+	realEstate, err := getRealEstate(stub, objectOfSale)
+	if err != nil {
+		return selling, err
+	}
+	if realEstate.Proprietor != seller {
+		return selling, errors.New(fmt.Sprintf("房产%s不属于%s", objectOfSale, seller))
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, []string{seller, objectOfSale})
+	if err != nil {
+		return selling, err
+	}
+	if len(results) != 1 {
+		return selling, errors.New(fmt.Sprintf("根据%s和%s获取销售信息失败", objectOfSale, seller))
+	}
+	if err := json.Unmarshal(results[0], &selling); err != nil {
+		return selling, errors.New(fmt.Sprintf("Selling-反序列化出错: %s", err))
+	}
+	if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, []string{seller, objectOfSale}, &selling); err != nil {
+		return selling, err
+	}
+	if selling.SellingStatus != lib.SellingStatusConstant()["saleStart"] {
+		return selling, errors.New("此交易不属于销售中状态，已经无法购买")
+	}
+	if overdue, err := isSellingOverdue(stub, selling); err != nil {
+		return selling, err
+	} else if overdue {
+		return selling, errors.New("此交易已超过有效期，已经无法购买")
+	}
+	return selling, nil
+}
+
+func getOffer(stub shim.ChaincodeStubInterface, seller string, objectOfSale string, offerId string) (lib.Offer, error) {
+	var offer lib.Offer
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.OfferKey, []string{seller, objectOfSale, offerId})
+	if err != nil {
+		return offer, err
+	}
+	if len(results) != 1 {
+		return offer, errors.New(fmt.Sprintf("报价%s不存在", offerId))
+	}
+	if err := json.Unmarshal(results[0], &offer); err != nil {
+		return offer, errors.New(fmt.Sprintf("Offer-反序列化出错: %s", err))
+	}
+	if err := readPrivateOffer(stub, &offer); err != nil {
+		return offer, err
+	}
+	return offer, nil
+}
+
+// rejectOtherOffers 接受报价后拒绝同一销售的其他待答复或已还价的报价
+func rejectOtherOffers(stub shim.ChaincodeStubInterface, accepted lib.Offer) error {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.OfferKey, []string{accepted.Seller, accepted.ObjectOfSale})
+	if err != nil {
+		return err
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	for _, v := range results {
+		var offer lib.Offer
+		if err := json.Unmarshal(v, &offer); err != nil {
+			return errors.New(fmt.Sprintf("Offer-反序列化出错: %s", err))
+		}
+		if offer.OfferID == accepted.OfferID {
+			continue
+		}
+		if err := readPrivateOffer(stub, &offer); err != nil {
+			return err
+		}
+		if offer.OfferStatus != lib.OfferStatusConstant()["pending"] && offer.OfferStatus != lib.OfferStatusConstant()["countered"] {
+			continue
+		}
+		offer.OfferStatus = lib.OfferStatusConstant()["rejected"]
+		offer.UpdateTime = utils.FormatTime(txTime)
+		if err := writeOffer(stub, &offer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeOffer 完整的报价写入私有数据集合，账本中公开的报价不含买家和价格
+func writeOffer(stub shim.ChaincodeStubInterface, offer *lib.Offer) error {
+	public := publicOffer(*offer)
+	return writeWithPrivate(stub, lib.DealCollection, lib.OfferKey, []string{offer.Seller, offer.ObjectOfSale, offer.OfferID}, offer, &public)
+}
+
+// publicOffer 去掉买家和价格的报价，用于写入账本、事件和交易的返回值
+func publicOffer(offer lib.Offer) lib.Offer {
+	offer.Buyer = ""
+	offer.Price = 0
+	offer.OfferPrice = 0
+	return offer
+}
+
+// readPrivateOffer 从私有数据集合读取完整的报价
+func readPrivateOffer(stub shim.ChaincodeStubInterface, offer *lib.Offer) error {
+	return readPrivate(stub, lib.DealCollection, lib.OfferKey, []string{offer.Seller, offer.ObjectOfSale, offer.OfferID}, offer)
+}
+
+// offerResponse 设置报价事件并返回报价信息，事件和返回值都会写入区块，只包含公开的报价和销售
+func offerResponse(stub shim.ChaincodeStubInterface, eventName string, offer *lib.Offer, selling *lib.Selling) peer.Response {
+	public := publicOffer(*offer)
+	if selling != nil {
+		sale, err := publicSelling(stub, *selling)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		selling = &sale
+	}
+	if err := utils.SetEvent(stub, eventName, &lib.OfferEvent{TxID: stub.GetTxID(), Offer: public, Selling: selling}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	offerByte, err := json.Marshal(public)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化报价信息出错: %s", err))
+	}
+	return shim.Success(offerByte)
+}
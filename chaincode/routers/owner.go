@@ -0,0 +1,206 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"strconv"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// ConsentRealEstate 共有人同意代表业主对房地产的一项处分，如出售selling、捐赠donating
+// 代表业主发起处分时检查同意的份额并删除已使用的同意
+func ConsentRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	realEstateId := args[0]
+	accountId := args[1]
+	action := args[2]
+	if realEstateId == "" || accountId == "" || action == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, ok := lib.RealEstateCauseConstant()[action]; !ok || action == "create" || action == "split" || action == "merge" || action == "update" || action == "migrate" {
+		return shim.Error(fmt.Sprintf("%s处分方式不支持", action))
+	}
+	account, err := checkAccountOwner(stub, accountId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(account); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate, err := getRealEstate(stub, realEstateId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if realEstate.Proprietor == accountId {
+		return shim.Error("代表业主发起处分即视为同意，不需要单独同意")
+	}
+	if !isOwnerOf(realEstate, accountId) {
+		return shim.Error(fmt.Sprintf("%s不是%s的共有人", accountId, realEstateId))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	consent := &lib.RealEstateConsent{
+		RealEstateID: realEstateId,
+		Action:       action,
+		AccountId:    accountId,
+		CreateTime:   utils.FormatTime(txTime),
+	}
+	if err := utils.WriteLedger(consent, stub, lib.RealEstateConsentKey, []string{realEstateId, action, accountId}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	consentByte, err := json.Marshal(consent)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	}
+	return shim.Success(consentByte)
+}
+
+// QueryRealEstateConsents 查询共有人对房地产尚未使用的同意，可以再指定处分方式
+func QueryRealEstateConsents(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 1 || len(args) > 2 {
+		return shim.Error("必须指定RealEstateID，可以再指定处分方式")
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateConsentKey, args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var consentList []lib.RealEstateConsent
+	for _, v := range results {
+		var consent lib.RealEstateConsent
+		if err := json.Unmarshal(v, &consent); err != nil {
+			return shim.Error(fmt.Sprintf("QueryRealEstateConsents-反序列化出错: %s", err))
+		}
+		consentList = append(consentList, consent)
+	}
+	consentListByte, err := json.Marshal(consentList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryRealEstateConsents-序列化出错: %s", err))
+	}
+	return shim.Success(consentListByte)
+}
+
+// parseOwners 解析共有人及份额，必须包含代表业主，份额都大于0且合计为ShareTotal，共有人都是正常状态的业主
+func parseOwners(stub shim.ChaincodeStubInterface, proprietor string, value string) ([]lib.Owner, error) {
+	var owners []lib.Owner
+	if err := json.Unmarshal([]byte(value), &owners); err != nil {
+		return nil, errors.New(fmt.Sprintf("共有人格式错误: %s", err))
+	}
+	total := 0
+	seen := make(map[string]bool)
+	for _, v := range owners {
+		if v.AccountId == "" || v.Share <= 0 {
+			return nil, errors.New("共有人AccountId不能为空，份额必须大于0")
+		}
+		if seen[v.AccountId] {
+			return nil, errors.New(fmt.Sprintf("共有人%s重复", v.AccountId))
+		}
+		seen[v.AccountId] = true
+		total += v.Share
+		account, err := getAccount(stub, v.AccountId)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("共有人%s信息验证失败%s", v.AccountId, err))
+		}
+		if !hasRole(account, "owner") {
+			return nil, errors.New(fmt.Sprintf("%s不是业主，不能持有房地产", v.AccountId))
+		}
+		if err := checkAccountActive(account); err != nil {
+			return nil, err
+		}
+	}
+	if !seen[proprietor] {
+		return nil, errors.New(fmt.Sprintf("代表业主%s必须是共有人之一", proprietor))
+	}
+	if total != lib.ShareTotal {
+		return nil, errors.New(fmt.Sprintf("共有人份额合计为%d，必须为%d", total, lib.ShareTotal))
+	}
+	return owners, nil
+}
+
+// parseConsentThreshold 解析处分需要同意的份额合计，取值为1到ShareTotal
+func parseConsentThreshold(value string) (int, error) {
+	val, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("格式转换出错: %s", err))
+	}
+	if val <= 0 || val > lib.ShareTotal {
+		return 0, errors.New(fmt.Sprintf("必须在1到%d之间", lib.ShareTotal))
+	}
+	return val, nil
+}
+
+// realEstateOwners 房地产的共有人，没有记录共有人的房地产由Proprietor单独所有
+func realEstateOwners(realEstate lib.RealEstate) []lib.Owner {
+	if len(realEstate.Owners) == 0 {
+		return []lib.Owner{{AccountId: realEstate.Proprietor, Share: lib.ShareTotal}}
+	}
+	return realEstate.Owners
+}
+
+func isOwnerOf(realEstate lib.RealEstate, accountId string) bool {
+	for _, v := range realEstateOwners(realEstate) {
+		if v.AccountId == accountId {
+			return true
+		}
+	}
+	return false
+}
+
+// writeOwnerIndex 为每个共有人写入所有者索引
+func writeOwnerIndex(stub shim.ChaincodeStubInterface, realEstate *lib.RealEstate) error {
+	for _, v := range realEstateOwners(*realEstate) {
+		if err := utils.WriteLedger(&lib.RealEstateProprietor{Proprietor: v.AccountId, RealEstateID: realEstate.RealEstateID}, stub, lib.RealEstateProprietorKey, []string{v.AccountId, realEstate.RealEstateID}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// useOwnerConsents 检查同意action的共有人份额合计是否达到ConsentThreshold，代表业主发起即视为同意
+// 达到后删除本次使用的同意，单独所有时不需要检查
+func useOwnerConsents(stub shim.ChaincodeStubInterface, realEstate lib.RealEstate, action string) error {
+	owners := realEstateOwners(realEstate)
+	if len(owners) == 1 {
+		return nil
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateConsentKey, []string{realEstate.RealEstateID, action})
+	if err != nil {
+		return err
+	}
+	consented := map[string]bool{realEstate.Proprietor: true}
+	var consents []lib.RealEstateConsent
+	for _, v := range results {
+		var consent lib.RealEstateConsent
+		if err := json.Unmarshal(v, &consent); err != nil {
+			return errors.New(fmt.Sprintf("RealEstateConsent-反序列化出错: %s", err))
+		}
+		consented[consent.AccountId] = true
+		consents = append(consents, consent)
+	}
+	share := 0
+	for _, v := range owners {
+		if consented[v.AccountId] {
+			share += v.Share
+		}
+	}
+	threshold := realEstate.ConsentThreshold
+	if threshold == 0 {
+		threshold = lib.ShareTotal
+	}
+	if share < threshold {
+		return errors.New(fmt.Sprintf("同意%s的共有人份额合计%d未达到%d", lib.RealEstateCauseConstant()[action], share, threshold))
+	}
+	for _, v := range consents {
+		if err := utils.DelLedger(stub, lib.RealEstateConsentKey, []string{v.RealEstateID, v.Action, v.AccountId}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"strconv"
+	"transaction/chaincode/lib"
+)
+
+// 列表查询每页最多返回的条数
+const maxPageSize = 100
+
+// parsePage 解析列表查询的分页参数
+// args[0]为每页条数pageSize，args[1]为上一页返回的书签bookmark(第一页为空)，其余为查询条件
+func parsePage(args []string) (int32, string, []string, error) {
+	if len(args) < 2 {
+		return 0, "", nil, errors.New("必须指定pageSize和bookmark分页参数")
+	}
+	pageSize, err := strconv.Atoi(args[0])
+	if err != nil {
+		return 0, "", nil, errors.New(fmt.Sprintf("pageSize参数格式转换出错: %s", err))
+	}
+	if pageSize <= 0 || pageSize > maxPageSize {
+		return 0, "", nil, errors.New(fmt.Sprintf("pageSize必须在1到%d之间", maxPageSize))
+	}
+	return int32(pageSize), args[1], args[2:], nil
+}
+
+// pageResponse 序列化一页记录和分页信息
+func pageResponse(records interface{}, pageSize int32, bookmark string, hasMore bool) peer.Response {
+	pageByte, err := json.Marshal(&lib.Page{
+		Records:  records,
+		PageSize: pageSize,
+		Bookmark: bookmark,
+		HasMore:  hasMore,
+	})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化分页查询结果出错: %s", err))
+	}
+	return shim.Success(pageByte)
+}
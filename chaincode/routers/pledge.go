@@ -0,0 +1,432 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// CreatePledge 业主以房地产作为担保向出借人发起质押，等待出借人放款，期间房地产处于担保状态
+// 贷款金额可以置空并通过transient的loanAmount传入
+func CreatePledge(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 5 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfPledge := args[0]
+	pledgor := args[1]
+	lender := args[2]
+	loanAmount, err := privateArg(stub, args, 3, "loanAmount")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	dueDate := args[4]
+	if objectOfPledge == "" || pledgor == "" || lender == "" || loanAmount == "" || dueDate == "" {
+		return shim.Error("参数存在空值")
+	}
+	if pledgor == lender {
+		return shim.Error("出质人和出借人不能同一人")
+	}
+	formattedLoanAmount, err := parseAmount(loanAmount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("loanAmount参数%s", err))
+	}
+	formattedDueDate, err := parseFutureTime(stub, dueDate)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("dueDate参数%s", err))
+	}
+	pledgorAccount, err := checkAccountOwner(stub, pledgor)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(pledgorAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	lenderAccount, err := getAccount(stub, lender)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("lender出借人信息验证失败%s", err))
+	}
+	if err := checkAccountActive(lenderAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate, err := getRealEstate(stub, objectOfPledge)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if realEstate.Proprietor != pledgor {
+		return shim.Error(fmt.Sprintf("验证%s属于%s失败", objectOfPledge, pledgor))
+	}
+	if realEstate.Encumbrance {
+		return shim.Error("此房地产已经作为担保状态，不能质押")
+	}
+	if err := useOwnerConsents(stub, realEstate, "pledge"); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	pledge := &lib.Pledge{
+		PledgeID:       utils.GenerateID(stub, 0),
+		ObjectOfPledge: objectOfPledge,
+		Pledgor:        pledgor,
+		Lender:         lender,
+		LoanAmount:     formattedLoanAmount,
+		DueDate:        formattedDueDate,
+		CreateTime:     utils.FormatTime(txTime),
+		PledgeStatus:   lib.PledgeStatusConstant()["pending"],
+	}
+	if err := writePledge(stub, pledge, ""); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate.Encumbrance = true
+	if err := writeRealEstate(stub, &realEstate, "pledge", pledgeKeys(pledge)); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return pledgeResponse(stub, "pledgeCreated", pledge)
+}
+
+// UpdatePledge 更新质押状态，status取值为 放款"active"、取消"cancelled"、违约处置"defaulted"
+// 放款由出借人操作，贷款从出借人转给出质人；待放款时出质人或出借人可以取消；到期未还清时出借人可以违约处置
+func UpdatePledge(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfPledge := args[0]
+	pledgor := args[1]
+	pledgeId := args[2]
+	status := args[3]
+	if objectOfPledge == "" || pledgor == "" || pledgeId == "" || status == "" {
+		return shim.Error("参数存在空值")
+	}
+	pledge, err := getPledge(stub, pledgor, objectOfPledge, pledgeId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	previous := pledge.PledgeStatus
+	var eventName string
+	switch status {
+	case "active":
+		if pledge.PledgeStatus != lib.PledgeStatusConstant()["pending"] {
+			return shim.Error("此质押不处于待放款状态，不能放款")
+		}
+		if overdue, err := isTimePassed(stub, pledge.DueDate); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		} else if overdue {
+			return shim.Error("此质押已经到期，不能放款")
+		}
+		lenderAccount, err := checkAccountOwner(stub, pledge.Lender)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := checkAccountActive(lenderAccount); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		pledgorAccount, err := getAccount(stub, pledge.Pledgor)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := changeBalance(stub, &lenderAccount, -pledge.LoanAmount, "loanOut", pledge.Pledgor, pledge.ObjectOfPledge); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := changeBalance(stub, &pledgorAccount, pledge.LoanAmount, "loanIn", pledge.Lender, pledge.ObjectOfPledge); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		pledge.PledgeStatus = lib.PledgeStatusConstant()["active"]
+		eventName = "pledgeActivated"
+	case "cancelled":
+		if pledge.PledgeStatus != lib.PledgeStatusConstant()["pending"] {
+			return shim.Error("只能取消待放款的质押")
+		}
+		caller, err := getCallerAccount(stub)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if caller.AccountId != pledge.Pledgor && caller.AccountId != pledge.Lender {
+			return shim.Error("只有出质人或出借人可以取消质押")
+		}
+		pledge.PledgeStatus = lib.PledgeStatusConstant()["cancelled"]
+		eventName = "pledgeCancelled"
+	case "defaulted":
+		if pledge.PledgeStatus != lib.PledgeStatusConstant()["active"] {
+			return shim.Error("此质押不处于质押中状态，不能违约处置")
+		}
+		if overdue, err := isTimePassed(stub, pledge.DueDate); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		} else if !overdue {
+			return shim.Error("此质押尚未到期，不能违约处置")
+		}
+		if _, err := checkAccountOwner(stub, pledge.Lender); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		pledge.PledgeStatus = lib.PledgeStatusConstant()["defaulted"]
+		eventName = "pledgeDefaulted"
+	default:
+		return shim.Error(fmt.Sprintf("%s状态不支持", status))
+	}
+
+	if status != "active" {
+		if err := releasePledge(stub, &pledge, status == "defaulted"); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	if err := writePledge(stub, &pledge, previous); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return pledgeResponse(stub, eventName, &pledge)
+}
+
+// RepayPledge 出质人偿还质押贷款，可以分多次偿还，还清后解除质押，还款金额可以置空并通过transient的amount传入
+func RepayPledge(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfPledge := args[0]
+	pledgor := args[1]
+	pledgeId := args[2]
+	amount, err := privateArg(stub, args, 3, "amount")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if objectOfPledge == "" || pledgor == "" || pledgeId == "" || amount == "" {
+		return shim.Error("参数存在空值")
+	}
+	formattedAmount, err := parseAmount(amount)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("amount参数%s", err))
+	}
+	pledgorAccount, err := checkAccountOwner(stub, pledgor)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(pledgorAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	pledge, err := getPledge(stub, pledgor, objectOfPledge, pledgeId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if pledge.PledgeStatus != lib.PledgeStatusConstant()["active"] {
+		return shim.Error("此质押不处于质押中状态，不能还款")
+	}
+	if outstanding := pledge.LoanAmount - pledge.Repaid; formattedAmount > outstanding {
+		return shim.Error(fmt.Sprintf("还款金额不能超过未还金额%s", outstanding))
+	}
+	lenderAccount, err := getAccount(stub, pledge.Lender)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := changeBalance(stub, &pledgorAccount, -formattedAmount, "repayOut", pledge.Lender, pledge.ObjectOfPledge); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := changeBalance(stub, &lenderAccount, formattedAmount, "repayIn", pledge.Pledgor, pledge.ObjectOfPledge); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	pledge.Repaid += formattedAmount
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	repayment := &lib.PledgeRepayment{
+		PledgeID:   pledge.PledgeID,
+		TxID:       stub.GetTxID(),
+		Amount:     formattedAmount,
+		Repaid:     pledge.Repaid,
+		CreateTime: utils.FormatTime(txTime),
+	}
+	createTimeKey, err := utils.TimeKey(repayment.CreateTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.WritePrivateData(repayment, stub, lib.DealCollection, lib.PledgeRepaymentKey, []string{repayment.PledgeID, createTimeKey, repayment.TxID}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+
+	previous := pledge.PledgeStatus
+	eventName := "pledgeRepaid"
+	if pledge.Repaid == pledge.LoanAmount {
+		pledge.PledgeStatus = lib.PledgeStatusConstant()["repaid"]
+		if err := releasePledge(stub, &pledge, false); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		eventName = "pledgeReleased"
+	}
+	if err := writePledge(stub, &pledge, previous); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return pledgeResponse(stub, eventName, &pledge)
+}
+
+// QueryPledgeList 分页查询进行中(active)或已结束(closed)的质押，可以再指定出质人
+// 只有出质人、出借人、登记员、银行和审计员可以看到贷款金额和已还金额
+func QueryPledgeList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) < 1 || len(keys) > 2 {
+		return shim.Error("必须指定active或closed，可以再指定出质人")
+	}
+	if _, ok := lib.PledgeGroupConstant()[keys[0]]; !ok {
+		return shim.Error(fmt.Sprintf("%s分组不支持，只能为active或closed", keys[0]))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.PledgeIndexKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var pledgeList []lib.Pledge
+	for _, v := range results {
+		var index lib.PledgeIndex
+		if err := json.Unmarshal(v, &index); err != nil {
+			return shim.Error(fmt.Sprintf("QueryPledgeList-反序列化出错: %s", err))
+		}
+		pledge, err := getPledge(stub, index.Pledgor, index.ObjectOfPledge, index.PledgeID)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if !canReadPrivate(caller, lib.DealCollection, pledge.Pledgor, pledge.Lender) {
+			pledge = publicPledge(pledge)
+		}
+		pledgeList = append(pledgeList, pledge)
+	}
+	return pageResponse(pledgeList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryPledgeRepayments 按时间分页查询一笔质押的所有还款，keys为[pledgor, objectOfPledge, pledgeId]
+// 还款只保存在私有数据集合中，只有出质人、出借人、登记员、银行和审计员可以查询
+func QueryPledgeRepayments(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 3 {
+		return shim.Error("必须指定出质人、质押对象和PledgeID查询")
+	}
+	pledge, err := getPledge(stub, keys[0], keys[1], keys[2])
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.DealCollection, pledge.Pledgor, pledge.Lender) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询质押%s的还款", pledge.PledgeID))
+	}
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.PledgeRepaymentKey, []string{pledge.PledgeID}, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var repaymentList []lib.PledgeRepayment
+	for _, v := range results {
+		var repayment lib.PledgeRepayment
+		if err := json.Unmarshal(v, &repayment); err != nil {
+			return shim.Error(fmt.Sprintf("QueryPledgeRepayments-反序列化出错: %s", err))
+		}
+		repaymentList = append(repaymentList, repayment)
+	}
+	return pageResponse(repaymentList, pageSize, nextBookmark, hasMore)
+}
+
+// releasePledge 结束质押，解除房地产的担保状态，违约时过户给出借人
+func releasePledge(stub shim.ChaincodeStubInterface, pledge *lib.Pledge, foreclose bool) error {
+	realEstate, err := getRealEstate(stub, pledge.ObjectOfPledge)
+	if err != nil {
+		return err
+	}
+	if foreclose {
+		if err := changeProprietor(stub, &realEstate, pledge.Lender); err != nil {
+			return err
+		}
+	}
+	realEstate.Encumbrance = false
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return err
+	}
+	pledge.CloseTime = utils.FormatTime(txTime)
+	return writeRealEstate(stub, &realEstate, "pledge", pledgeKeys(pledge))
+}
+
+func getPledge(stub shim.ChaincodeStubInterface, pledgor string, objectOfPledge string, pledgeId string) (lib.Pledge, error) {
+	var pledge lib.Pledge
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.PledgeKey, []string{pledgor, objectOfPledge, pledgeId})
+	if err != nil {
+		return pledge, err
+	}
+	if len(results) != 1 {
+		return pledge, errors.New(fmt.Sprintf("质押%s不存在", pledgeId))
+	}
+	if err := json.Unmarshal(results[0], &pledge); err != nil {
+		return pledge, errors.New(fmt.Sprintf("Pledge-反序列化出错: %s", err))
+	}
+	if err := readPrivate(stub, lib.DealCollection, lib.PledgeKey, pledgeKeys(&pledge), &pledge); err != nil {
+		return pledge, err
+	}
+	return pledge, nil
+}
+
+// writePledge 完整的质押写入私有数据集合并维护分组索引，previous为变更前的状态，新建时为空
+func writePledge(stub shim.ChaincodeStubInterface, pledge *lib.Pledge, previous string) error {
+	public := publicPledge(*pledge)
+	if err := writeWithPrivate(stub, lib.DealCollection, lib.PledgeKey, pledgeKeys(pledge), pledge, &public); err != nil {
+		return err
+	}
+	group := pledgeGroup(pledge.PledgeStatus)
+	if previous != "" {
+		previousGroup := pledgeGroup(previous)
+		if previousGroup == group {
+			return nil
+		}
+		if err := utils.DelLedger(stub, lib.PledgeIndexKey, []string{previousGroup, pledge.Pledgor, pledge.PledgeID}); err != nil {
+			return err
+		}
+	}
+	index := &lib.PledgeIndex{Group: group, Pledgor: pledge.Pledgor, ObjectOfPledge: pledge.ObjectOfPledge, PledgeID: pledge.PledgeID}
+	return utils.WriteLedger(index, stub, lib.PledgeIndexKey, []string{group, pledge.Pledgor, pledge.PledgeID})
+}
+
+// pledgeGroup 质押状态所属的分组
+func pledgeGroup(status string) string {
+	for group, statuses := range lib.PledgeGroupConstant() {
+		for _, v := range statuses {
+			if lib.PledgeStatusConstant()[v] == status {
+				return group
+			}
+		}
+	}
+	return ""
+}
+
+func pledgeKeys(pledge *lib.Pledge) []string {
+	return []string{pledge.Pledgor, pledge.ObjectOfPledge, pledge.PledgeID}
+}
+
+// publicPledge 去掉贷款金额和已还金额的质押，用于写入账本、事件和交易的返回值
+func publicPledge(pledge lib.Pledge) lib.Pledge {
+	pledge.LoanAmount = 0
+	pledge.Repaid = 0
+	return pledge
+}
+
+// pledgeResponse 设置质押事件并返回质押信息，事件和返回值随交易写入区块，只包含公开的质押
+func pledgeResponse(stub shim.ChaincodeStubInterface, eventName string, pledge *lib.Pledge) peer.Response {
+	public := publicPledge(*pledge)
+	if err := utils.SetEvent(stub, eventName, &lib.PledgeEvent{TxID: stub.GetTxID(), Pledge: public}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	pledgeByte, err := json.Marshal(public)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化质押信息出错: %s", err))
+	}
+	return shim.Success(pledgeByte)
+}
@@ -0,0 +1,310 @@
+package routers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// MigratePrivateData 将升级前写入账本的敏感信息迁移到私有数据集合，只有登记员可以调用
+// 账户、销售、报价、托管、拍卖、质押和租约在私有数据集合中写入完整记录，账本中改写为公开记录
+// 流水、买家购买、买家报价、受赠人索引、出价、还款和租金支付只保存在私有数据集合中，从账本中删除
+// 捐赠改为以捐赠对象和创建时间作为复合键重新写入，见migrateDonatings
+// 私有数据集合中已有完整记录的不再处理，可以重复执行；账本的历史版本中仍然保留升级前的内容
+func MigratePrivateData(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("参数个数不满足")
+	}
+	if _, err := checkAccountOwner(stub, args[0]); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	migrations := []struct {
+		collection string
+		objectType string
+		migrate    func(keys []string, value []byte) error
+	}{
+		{lib.AccountCollection, lib.AccountKey, func(keys []string, value []byte) error {
+			var account lib.Account
+			if err := json.Unmarshal(value, &account); err != nil {
+				return err
+			}
+			return writeAccount(stub, account)
+		}},
+		{lib.DealCollection, lib.SellingKey, func(keys []string, value []byte) error {
+			var selling lib.Selling
+			if err := json.Unmarshal(value, &selling); err != nil {
+				return err
+			}
+			return writeSelling(stub, &selling)
+		}},
+		{lib.DealCollection, lib.OfferKey, func(keys []string, value []byte) error {
+			var offer lib.Offer
+			if err := json.Unmarshal(value, &offer); err != nil {
+				return err
+			}
+			return writeOffer(stub, &offer)
+		}},
+		{lib.DealCollection, lib.EscrowKey, func(keys []string, value []byte) error {
+			var escrow lib.Escrow
+			if err := json.Unmarshal(value, &escrow); err != nil {
+				return err
+			}
+			return writeEscrow(stub, &escrow)
+		}},
+		{lib.DealCollection, lib.AuctionKey, func(keys []string, value []byte) error {
+			var auction lib.Auction
+			if err := json.Unmarshal(value, &auction); err != nil {
+				return err
+			}
+			return writeAuction(stub, &auction)
+		}},
+		{lib.DealCollection, lib.PledgeKey, func(keys []string, value []byte) error {
+			var pledge lib.Pledge
+			if err := json.Unmarshal(value, &pledge); err != nil {
+				return err
+			}
+			return writePledge(stub, &pledge, pledge.PledgeStatus)
+		}},
+		{lib.DealCollection, lib.LeaseKey, func(keys []string, value []byte) error {
+			var lease lib.Lease
+			if err := json.Unmarshal(value, &lease); err != nil {
+				return err
+			}
+			return writeLease(stub, &lease)
+		}},
+		{lib.AccountCollection, lib.JournalKey, movePrivateData(stub, lib.AccountCollection, lib.JournalKey)},
+		{lib.DealCollection, lib.SellingBuyKey, movePrivateData(stub, lib.DealCollection, lib.SellingBuyKey)},
+		{lib.DealCollection, lib.OfferBuyerKey, movePrivateData(stub, lib.DealCollection, lib.OfferBuyerKey)},
+		{lib.DealCollection, lib.DonatingGranteeKey, movePrivateData(stub, lib.DealCollection, lib.DonatingGranteeKey)},
+		{lib.DealCollection, lib.AuctionBidKey, movePrivateData(stub, lib.DealCollection, lib.AuctionBidKey)},
+		{lib.DealCollection, lib.PledgeRepaymentKey, movePrivateData(stub, lib.DealCollection, lib.PledgeRepaymentKey)},
+		{lib.DealCollection, lib.LeasePaymentKey, movePrivateData(stub, lib.DealCollection, lib.LeasePaymentKey)},
+	}
+	migrated := make(map[string]int)
+	for _, v := range migrations {
+		count, err := migrateRecords(stub, v.collection, v.objectType, v.migrate)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		migrated[v.objectType] = count
+	}
+	count, err := migrateDonatings(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	migrated[lib.DonatingKey] = count
+	migratedByte, err := json.Marshal(migrated)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("MigratePrivateData-序列化出错: %s", err))
+	}
+	return shim.Success(migratedByte)
+}
+
+// migrateRecords 对objectType下私有数据集合中还没有完整记录的公开记录调用migrate，返回迁移的条数
+// 先取出全部记录再迁移，迁移中会删除或重新写入遍历到的记录
+func migrateRecords(stub shim.ChaincodeStubInterface, collection string, objectType string, migrate func(keys []string, value []byte) error) (int, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+	var records []*queryresult.KV
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		records = append(records, val)
+	}
+	count := 0
+	for _, v := range records {
+		_, keys, err := stub.SplitCompositeKey(v.GetKey())
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-拆分复合键出错: %s", objectType, err))
+		}
+		private, err := utils.GetPrivateData(stub, collection, objectType, keys)
+		if err != nil {
+			return 0, err
+		}
+		if private != nil {
+			continue
+		}
+		if err := migrate(keys, v.GetValue()); err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-迁移%v出错: %s", objectType, keys, err))
+		}
+		count++
+	}
+	return count, nil
+}
+
+// migrateDonatings 将复合键包含捐赠人的捐赠改为以捐赠对象和创建时间作为复合键重新写入，返回迁移的条数
+// 升级前的复合键为[Donor, ObjectOfDonating, Grantee]，受赠人在账本中；之前迁移过的为[Donor, ObjectOfDonating, CreateTime]，完整记录在私有数据集合中
+// 同时写入捐赠人索引，并将关联的文档改为新的复合键
+func migrateDonatings(stub shim.ChaincodeStubInterface) (int, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DonatingKey, []string{})
+	if err != nil {
+		return 0, err
+	}
+	var donatings []lib.Donating
+	for _, v := range results {
+		var donating lib.Donating
+		if err := json.Unmarshal(v, &donating); err != nil {
+			return 0, errors.New(fmt.Sprintf("Donating-反序列化出错: %s", err))
+		}
+		donatings = append(donatings, donating)
+	}
+	count := 0
+	for _, donating := range donatings {
+		if donating.Donor == "" {
+			continue
+		}
+		oldKeys := []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee}
+		if donating.Grantee == "" {
+			createTimeKey, err := utils.TimeKey(donating.CreateTime)
+			if err != nil {
+				return 0, err
+			}
+			oldKeys[2] = createTimeKey
+			if err := readPrivate(stub, lib.DealCollection, lib.DonatingKey, oldKeys, &donating); err != nil {
+				return 0, err
+			}
+			key, err := stub.CreateCompositeKey(lib.DonatingKey, oldKeys)
+			if err != nil {
+				return 0, err
+			}
+			if err := stub.DelPrivateData(lib.DealCollection, key); err != nil {
+				return 0, errors.New(fmt.Sprintf("Donating-删除私有数据出错: %s", err))
+			}
+		}
+		if err := utils.DelLedger(stub, lib.DonatingKey, oldKeys); err != nil {
+			return 0, err
+		}
+		if err := writeDonating(stub, &donating); err != nil {
+			return 0, err
+		}
+		if err := writeDonatingDonor(stub, &donating); err != nil {
+			return 0, err
+		}
+		donatingKey, err := donatingKeys(donating)
+		if err != nil {
+			return 0, err
+		}
+		if err := moveDocuments(stub, "donating", oldKeys, donatingKey); err != nil {
+			return 0, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// moveDocuments 将关联对象的文档从旧的复合键改为新的复合键
+func moveDocuments(stub shim.ChaincodeStubInterface, objectType string, oldKeys []string, newKeys []string) error {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.DocumentKey, append([]string{objectType}, oldKeys...))
+	if err != nil {
+		return err
+	}
+	for _, v := range results {
+		var document lib.Document
+		if err := json.Unmarshal(v, &document); err != nil {
+			return errors.New(fmt.Sprintf("Document-反序列化出错: %s", err))
+		}
+		if err := utils.DelLedger(stub, lib.DocumentKey, append(append([]string{objectType}, oldKeys...), document.Hash)); err != nil {
+			return err
+		}
+		document.ObjectKey = newKeys
+		if err := utils.WriteLedger(document, stub, lib.DocumentKey, append(append([]string{objectType}, newKeys...), document.Hash)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// movePrivateData 将只保存在私有数据集合中的记录从账本移到私有数据集合，复合键不变
+func movePrivateData(stub shim.ChaincodeStubInterface, collection string, objectType string) func(keys []string, value []byte) error {
+	return func(keys []string, value []byte) error {
+		key, err := stub.CreateCompositeKey(objectType, keys)
+		if err != nil {
+			return err
+		}
+		if err := stub.PutPrivateData(collection, key, value); err != nil {
+			return err
+		}
+		return utils.DelLedger(stub, objectType, keys)
+	}
+}
+
+// privateArg 读取敏感参数，args[i]为空时从transient的name中读取
+// 交易参数会随交易写入区块，应用应当将金额、受赠人等参数置空并通过transient传入
+// 交易提交者的身份同样写入区块，无法隐藏，例如购买时的买家和确认受赠时的受赠人
+func privateArg(stub shim.ChaincodeStubInterface, args []string, i int, name string) (string, error) {
+	if args[i] != "" {
+		return args[i], nil
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("获取transient出错: %s", err))
+	}
+	return string(transient[name]), nil
+}
+
+// canReadPrivate 交易提交者是否可以查看collection中的完整记录，parties为记录的当事人
+// 当事人本人和PrivateReaderConstant中的角色可以查看
+func canReadPrivate(caller lib.Account, collection string, parties ...string) bool {
+	for _, role := range lib.PrivateReaderConstant()[collection] {
+		if hasRole(caller, role) {
+			return true
+		}
+	}
+	for _, v := range parties {
+		if v != "" && v == caller.AccountId {
+			return true
+		}
+	}
+	return false
+}
+
+// readPrivate 从私有数据集合读取与公开记录同一复合键的完整记录到obj
+// 升级前写入的记录在私有数据集合中没有，obj保留账本中的记录，见MigratePrivateData
+func readPrivate(stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string, obj interface{}) error {
+	bytes, err := utils.GetPrivateData(stub, collection, objectType, keys)
+	if err != nil {
+		return err
+	}
+	if bytes == nil {
+		return nil
+	}
+	if err := json.Unmarshal(bytes, obj); err != nil {
+		return errors.New(fmt.Sprintf("%s-私有数据反序列化出错: %s", objectType, err))
+	}
+	return nil
+}
+
+// hashedRecord 嵌入lib.Hashed的记录，公开记录中保存完整记录的哈希
+type hashedRecord interface {
+	SetPrivateHash(hash string)
+}
+
+// writeWithPrivate 完整记录写入私有数据集合，去掉敏感字段的公开记录以同一复合键写入账本
+// 公开记录的PrivateHash设置为完整记录的哈希，写入私有数据集合的完整记录中PrivateHash为空
+// 写入后obj的PrivateHash也设置为该哈希，由obj生成的事件和返回值可以直接携带
+func writeWithPrivate(stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string, obj hashedRecord, public hashedRecord) error {
+	obj.SetPrivateHash("")
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-序列化json数据失败出错: %s", objectType, err))
+	}
+	if err := utils.WritePrivateData(obj, stub, collection, objectType, keys); err != nil {
+		return err
+	}
+	hash := sha256.Sum256(bytes)
+	obj.SetPrivateHash(hex.EncodeToString(hash[:]))
+	public.SetPrivateHash(hex.EncodeToString(hash[:]))
+	return utils.WriteLedger(public, stub, objectType, keys)
+}
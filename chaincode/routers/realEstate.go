@@ -2,17 +2,23 @@ package routers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
+	"math"
 	"strconv"
 	"time"
 	"transaction/chaincode/lib"
 	"transaction/chaincode/utils"
 )
 
+// 登记信息中建成年份的下限
+const minBuildYear = 1800
+
+// CreateRealEstate 登记员登记房地产，可以再依次指定登记信息、共有人及份额、处分需要同意的份额合计
 func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 4 {
+	if len(args) < 4 || len(args) > 7 {
 		return shim.Error("参数个数不满足")
 	}
 	accountId := args[0]
@@ -23,7 +29,7 @@ func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Resp
 		return shim.Error("参数存在空值")
 	}
 	if accountId == proprietor {
-		return shim.Error("操作人应为管理员且与所有人不能相同")
+		return shim.Error("操作人与所有人不能相同")
 	}
 	// 参数数据格式转换
 	var formattedTotalArea float64
@@ -39,31 +45,58 @@ func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Resp
 		formattedLivingSpace = val
 	}
 
-	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{accountId})
-	if err != nil {
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
 		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
 	}
-	var account lib.Account
-	if err = json.Unmarshal(resultsAccount[0], &account); err != nil {
-		return shim.Error(fmt.Sprintf("查询操作人信息-反序列化出错: %s", err))
-	}
-	if account.UserName != "管理员" {
-		return shim.Error(fmt.Sprintf("操作人权限不足%s", err))
-	}
 
-	resultsProprietor, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{proprietor})
-	if err != nil || len(resultsProprietor) != 1 {
+	accountProprietor, err := getAccount(stub, proprietor)
+	if err != nil {
 		return shim.Error(fmt.Sprintf("业主proprietor信息验证失败%s", err))
 	}
+	if !hasRole(accountProprietor, "owner") {
+		return shim.Error(fmt.Sprintf("%s不是业主，不能持有房地产", proprietor))
+	}
+	if err := checkAccountActive(accountProprietor); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	//可选的登记信息
+	var attributes lib.RealEstateAttributes
+	if len(args) > 4 {
+		if attributes, err = parseRealEstateAttributes(stub, args[4]); err != nil {
+			return shim.Error(fmt.Sprintf("attributes参数%s", err))
+		}
+	}
+	//可选的共有人及份额，以及处分需要同意的份额合计，默认由proprietor单独所有
+	owners := []lib.Owner{{AccountId: proprietor, Share: lib.ShareTotal}}
+	if len(args) > 5 {
+		if owners, err = parseOwners(stub, proprietor, args[5]); err != nil {
+			return shim.Error(fmt.Sprintf("owners参数%s", err))
+		}
+	}
+	consentThreshold := lib.ShareTotal
+	if len(args) > 6 {
+		if consentThreshold, err = parseConsentThreshold(args[6]); err != nil {
+			return shim.Error(fmt.Sprintf("consentThreshold参数%s", err))
+		}
+	}
 	realEstate := &lib.RealEstate{
-		RealEstateID: fmt.Sprintf("%d", time.Now().Local().Unix()),
-		Proprietor:   proprietor,
-		Encumbrance:  false,
-		TotalArea:    formattedTotalArea,
-		LivingSpace:  formattedLivingSpace,
+		RealEstateID:     utils.GenerateID(stub, 0),
+		Proprietor:       proprietor,
+		Owners:           owners,
+		ConsentThreshold: consentThreshold,
+		Encumbrance:      false,
+		TotalArea:        formattedTotalArea,
+		LivingSpace:      formattedLivingSpace,
 	}
+	realEstate.RealEstateAttributes = attributes
 
-	if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+	if err := writeRealEstate(stub, realEstate, "create", nil); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeOwnerIndex(stub, realEstate); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.SetEvent(stub, "realEstateCreated", &lib.RealEstateEvent{TxID: stub.GetTxID(), RealEstate: *realEstate}); err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
 	realEstateByte, err := json.Marshal(realEstate)
@@ -74,25 +107,501 @@ func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Resp
 
 }
 
+// UpdateRealEstate 登记员变更房地产的登记信息，必须说明原因，变更前后的信息记录在RealEstateUpdate中供审计
+func UpdateRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 4 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	realEstateId := args[1]
+	attributes := args[2]
+	reason := args[3]
+	if accountId == "" || realEstateId == "" || attributes == "" || reason == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
+	}
+	formattedAttributes, err := parseRealEstateAttributes(stub, attributes)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("attributes参数%s", err))
+	}
+	realEstate, err := getRealEstate(stub, realEstateId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if realEstate.RealEstateAttributes == formattedAttributes {
+		return shim.Error("登记信息没有变化")
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	update := &lib.RealEstateUpdate{
+		RealEstateID: realEstateId,
+		TxID:         stub.GetTxID(),
+		Operator:     accountId,
+		Reason:       reason,
+		Before:       realEstate.RealEstateAttributes,
+		After:        formattedAttributes,
+		UpdateTime:   utils.FormatTime(txTime),
+	}
+	updateTimeKey, err := utils.TimeKey(update.UpdateTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	updateKey := []string{update.RealEstateID, updateTimeKey, update.TxID}
+	if err := utils.WriteLedger(update, stub, lib.RealEstateUpdateKey, updateKey); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate.RealEstateAttributes = formattedAttributes
+	if err := writeRealEstate(stub, &realEstate, "update", updateKey); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.SetEvent(stub, "realEstateUpdated", &lib.RealEstateEvent{TxID: stub.GetTxID(), RealEstate: realEstate}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstateByte, err := json.Marshal(realEstate)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功变更的信息出错: %s", err))
+	}
+	return shim.Success(realEstateByte)
+}
+
+// QueryRealEstateUpdates 按时间分页查询一个房地产登记信息的所有变更
+func QueryRealEstateUpdates(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 1 {
+		return shim.Error("必须指定RealEstateID查询")
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.RealEstateUpdateKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var updateList []lib.RealEstateUpdate
+	for _, v := range results {
+		var update lib.RealEstateUpdate
+		if err := json.Unmarshal(v, &update); err != nil {
+			return shim.Error(fmt.Sprintf("QueryRealEstateUpdates-反序列化出错: %s", err))
+		}
+		updateList = append(updateList, update)
+	}
+	return pageResponse(updateList, pageSize, nextBookmark, hasMore)
+}
+
+// SplitRealEstate 登记员将一个房地产拆分为多个，拆分后的面积合计与原房地产一致，所有权不变，原房地产不再有效
+func SplitRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	accountId := args[0]
+	realEstateId := args[1]
+	parts := args[2]
+	if accountId == "" || realEstateId == "" || parts == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
+	}
+	var formattedParts []lib.RealEstatePart
+	if err := json.Unmarshal([]byte(parts), &formattedParts); err != nil {
+		return shim.Error(fmt.Sprintf("parts参数格式错误: %s", err))
+	}
+	if len(formattedParts) < 2 {
+		return shim.Error("至少拆分为2个房地产")
+	}
+	parent, err := getRealEstate(stub, realEstateId)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if parent.Encumbrance {
+		return shim.Error("此房地产已经作为担保状态，不能拆分")
+	}
+	var totalArea, livingSpace float64
+	for _, v := range formattedParts {
+		if v.TotalArea <= 0 || v.LivingSpace <= 0 || v.LivingSpace > v.TotalArea {
+			return shim.Error("拆分后的总面积和生活空间必须大于0，且生活空间小于等于总面积")
+		}
+		totalArea += v.TotalArea
+		livingSpace += v.LivingSpace
+	}
+	if !isAreaEqual(totalArea, parent.TotalArea) || !isAreaEqual(livingSpace, parent.LivingSpace) {
+		return shim.Error(fmt.Sprintf("拆分后的总面积合计%v和生活空间合计%v必须与原房地产的%v和%v一致", totalArea, livingSpace, parent.TotalArea, parent.LivingSpace))
+	}
+
+	var children []lib.RealEstate
+	var childIds []string
+	for i, v := range formattedParts {
+		child := inheritRealEstate(stub, parent, i, v.TotalArea, v.LivingSpace, []string{parent.RealEstateID})
+		children = append(children, child)
+		childIds = append(childIds, child.RealEstateID)
+	}
+	causeKey := append([]string{parent.RealEstateID}, childIds...)
+	if err := retireRealEstate(stub, &parent, childIds, "split", causeKey); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	for i := range children {
+		if err := writeRealEstate(stub, &children[i], "split", causeKey); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := writeOwnerIndex(stub, &children[i]); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	return realEstateListResponse(stub, "realEstatesSplit", append([]lib.RealEstate{parent}, children...))
+}
+
+// MergeRealEstate 登记员将多个所有权相同的房地产合并为一个，合并后的面积为原房地产的合计，原房地产不再有效
+func MergeRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) < 3 {
+		return shim.Error("参数个数不满足，至少合并2个房地产")
+	}
+	accountId := args[0]
+	realEstateIds := args[1:]
+	if accountId == "" {
+		return shim.Error("参数存在空值")
+	}
+	if _, err := checkAccountOwner(stub, accountId); err != nil {
+		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
+	}
+	seen := make(map[string]bool)
+	var parents []lib.RealEstate
+	var totalArea, livingSpace float64
+	for _, v := range realEstateIds {
+		if v == "" || seen[v] {
+			return shim.Error("RealEstateID不能为空或重复")
+		}
+		seen[v] = true
+		realEstate, err := getRealEstate(stub, v)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if realEstate.Encumbrance {
+			return shim.Error(fmt.Sprintf("房地产%s已经作为担保状态，不能合并", v))
+		}
+		if len(parents) != 0 && !isSameOwnership(parents[0], realEstate) {
+			return shim.Error("只能合并所有权相同的房地产")
+		}
+		parents = append(parents, realEstate)
+		totalArea += realEstate.TotalArea
+		livingSpace += realEstate.LivingSpace
+	}
+
+	child := inheritRealEstate(stub, parents[0], 0, totalArea, livingSpace, realEstateIds)
+	causeKey := append(append([]string{}, realEstateIds...), child.RealEstateID)
+	for i := range parents {
+		if err := retireRealEstate(stub, &parents[i], []string{child.RealEstateID}, "merge", causeKey); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	if err := writeRealEstate(stub, &child, "merge", causeKey); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := writeOwnerIndex(stub, &child); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return realEstateListResponse(stub, "realEstatesMerged", append(parents, child))
+}
+
+// QueryRealEstateList 分页查询房地产列表，指定Proprietor时通过所有者索引查询其名下的房地产
 func QueryRealEstateList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	var realEstateList []lib.RealEstate
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateKey, args)
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) == 0 {
+		results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.RealEstateKey, keys, pageSize, bookmark)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		realEstateList, err := unmarshalRealEstateList(results)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		return pageResponse(realEstateList, pageSize, nextBookmark, hasMore)
+	}
+	resultsProprietor, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.RealEstateProprietorKey, keys, pageSize, bookmark)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
+	var realEstateIds []string
+	for _, v := range resultsProprietor {
+		var realEstateProprietor lib.RealEstateProprietor
+		if err := json.Unmarshal(v, &realEstateProprietor); err != nil {
+			return shim.Error(fmt.Sprintf("QueryRealEstateList-反序列化出错: %s", err))
+		}
+		realEstateIds = append(realEstateIds, realEstateProprietor.RealEstateID)
+	}
+	var results [][]byte
+	if len(realEstateIds) != 0 {
+		results, err = utils.GetStateByPartialCompositeKeys(stub, lib.RealEstateKey, realEstateIds)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	realEstateList, err := unmarshalRealEstateList(results)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return pageResponse(realEstateList, pageSize, nextBookmark, hasMore)
+}
+
+// QueryRealEstate 根据一个或多个RealEstateID查询房地产，不需要指定所有者
+func QueryRealEstate(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) == 0 {
+		return shim.Error("必须指定RealEstateID查询")
+	}
+	results, err := utils.GetStateByPartialCompositeKeys(stub, lib.RealEstateKey, args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstateList, err := unmarshalRealEstateList(results)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstateListByte, err := json.Marshal(realEstateList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryRealEstate-序列化出错: %s", err))
+	}
+	return shim.Success(realEstateListByte)
+}
+
+func unmarshalRealEstateList(results [][]byte) ([]lib.RealEstate, error) {
+	var realEstateList []lib.RealEstate
 	for _, v := range results {
 		if v != nil {
 			var realEstate lib.RealEstate
 			err := json.Unmarshal(v, &realEstate)
 			if err != nil {
-				return shim.Error(fmt.Sprintf("QueryRealEstateList-反序列化出错: %s", err))
+				return nil, errors.New(fmt.Sprintf("QueryRealEstateList-反序列化出错: %s", err))
 			}
 			realEstateList = append(realEstateList, realEstate)
 		}
 	}
+	return realEstateList, nil
+}
+
+// QueryRealEstateHistory 查询房地产的所有历史版本，按时间先后返回完整的所有权链
+func QueryRealEstateHistory(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 1 {
+		return shim.Error("必须指定RealEstateID查询")
+	}
+	modifications, err := utils.GetHistoryByCompositeKey(stub, lib.RealEstateKey, args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var historyList []lib.RealEstateHistory
+	for _, v := range modifications {
+		if v.GetIsDelete() {
+			continue
+		}
+		history := lib.RealEstateHistory{
+			TxID:      v.GetTxId(),
+			Timestamp: utils.FormatTime(time.Unix(v.GetTimestamp().GetSeconds(), int64(v.GetTimestamp().GetNanos()))),
+		}
+		if err := json.Unmarshal(v.GetValue(), &history.RealEstate); err != nil {
+			return shim.Error(fmt.Sprintf("QueryRealEstateHistory-反序列化出错: %s", err))
+		}
+		switch history.RealEstate.CauseType {
+		case lib.RealEstateCauseConstant()["selling"]:
+			history.Selling = new(lib.Selling)
+			if err := getVersionAt(stub, lib.SellingKey, history.RealEstate.CauseKey, history.TxID, history.Selling); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		case lib.RealEstateCauseConstant()["donating"]:
+			history.Donating = new(lib.Donating)
+			if err := getVersionAt(stub, lib.DonatingKey, history.RealEstate.CauseKey, history.TxID, history.Donating); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		case lib.RealEstateCauseConstant()["auction"]:
+			history.Auction = new(lib.Auction)
+			if err := getVersionAt(stub, lib.AuctionKey, history.RealEstate.CauseKey, history.TxID, history.Auction); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		case lib.RealEstateCauseConstant()["pledge"]:
+			history.Pledge = new(lib.Pledge)
+			if err := getVersionAt(stub, lib.PledgeKey, history.RealEstate.CauseKey, history.TxID, history.Pledge); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		case lib.RealEstateCauseConstant()["lease"]:
+			history.Lease = new(lib.Lease)
+			if err := getVersionAt(stub, lib.LeaseKey, history.RealEstate.CauseKey, history.TxID, history.Lease); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		case lib.RealEstateCauseConstant()["update"]:
+			history.Update = new(lib.RealEstateUpdate)
+			if err := getVersionAt(stub, lib.RealEstateUpdateKey, history.RealEstate.CauseKey, history.TxID, history.Update); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		}
+		historyList = append(historyList, history)
+	}
+	if len(historyList) == 0 {
+		return shim.Error(fmt.Sprintf("房地产%s不存在", args[0]))
+	}
+	historyListByte, err := json.Marshal(historyList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("QueryRealEstateHistory-序列化出错: %s", err))
+	}
+	return shim.Success(historyListByte)
+}
+
+// getVersionAt 获取复合键在交易txID中写入的版本
+func getVersionAt(stub shim.ChaincodeStubInterface, objectType string, keys []string, txID string, obj interface{}) error {
+	modifications, err := utils.GetHistoryByCompositeKey(stub, objectType, keys)
+	if err != nil {
+		return err
+	}
+	for _, v := range modifications {
+		if v.GetTxId() == txID && !v.GetIsDelete() {
+			if err := json.Unmarshal(v.GetValue(), obj); err != nil {
+				return errors.New(fmt.Sprintf("%s-反序列化出错: %s", objectType, err))
+			}
+			return nil
+		}
+	}
+	return errors.New(fmt.Sprintf("%s-交易%s中没有写入%v", objectType, txID, keys))
+}
+
+// getRealEstate 根据RealEstateID获取房地产信息
+func getRealEstate(stub shim.ChaincodeStubInterface, realEstateId string) (lib.RealEstate, error) {
+	var realEstate lib.RealEstate
+	results, err := utils.GetStateByPartialCompositeKeys(stub, lib.RealEstateKey, []string{realEstateId})
+	if err != nil {
+		return realEstate, err
+	}
+	if len(results) != 1 {
+		return realEstate, errors.New(fmt.Sprintf("房地产%s不存在", realEstateId))
+	}
+	if err := json.Unmarshal(results[0], &realEstate); err != nil {
+		return realEstate, errors.New(fmt.Sprintf("房地产%s-反序列化出错: %s", realEstateId, err))
+	}
+	if realEstate.Retired {
+		return realEstate, errors.New(fmt.Sprintf("房地产%s已拆分或合并为%v，不再有效", realEstateId, realEstate.Children))
+	}
+	return realEstate, nil
+}
+
+// writeRealEstate 写入房地产并记录引起本次变更的业务
+func writeRealEstate(stub shim.ChaincodeStubInterface, realEstate *lib.RealEstate, causeType string, causeKey []string) error {
+	realEstate.CauseType = lib.RealEstateCauseConstant()[causeType]
+	realEstate.CauseKey = causeKey
+	return utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.RealEstateID})
+}
+
+// changeProprietor 过户，RealEstateID不变，所有共有人的份额都转给新的所有者，只更新所有者索引，房地产本身由调用方写入
+func changeProprietor(stub shim.ChaincodeStubInterface, realEstate *lib.RealEstate, proprietor string) error {
+	for _, v := range realEstateOwners(*realEstate) {
+		if err := utils.DelLedger(stub, lib.RealEstateProprietorKey, []string{v.AccountId, realEstate.RealEstateID}); err != nil {
+			return err
+		}
+	}
+	realEstate.Proprietor = proprietor
+	realEstate.Owners = []lib.Owner{{AccountId: proprietor, Share: lib.ShareTotal}}
+	realEstate.ConsentThreshold = lib.ShareTotal
+	return writeOwnerIndex(stub, realEstate)
+}
+
+// inheritRealEstate 根据拆分或合并前的房地产生成新的房地产，所有权与原房地产相同，seq用于在同一交易中生成不同的ID
+func inheritRealEstate(stub shim.ChaincodeStubInterface, parent lib.RealEstate, seq int, totalArea float64, livingSpace float64, parents []string) lib.RealEstate {
+	consentThreshold := parent.ConsentThreshold
+	if consentThreshold == 0 {
+		consentThreshold = lib.ShareTotal
+	}
+	return lib.RealEstate{
+		RealEstateID:         utils.GenerateID(stub, seq),
+		Proprietor:           parent.Proprietor,
+		Owners:               realEstateOwners(parent),
+		ConsentThreshold:     consentThreshold,
+		Encumbrance:          false,
+		TotalArea:            totalArea,
+		LivingSpace:          livingSpace,
+		RealEstateAttributes: parent.RealEstateAttributes,
+		Parents:              parents,
+	}
+}
+
+// parseRealEstateAttributes 解析登记信息，地址、行政区和证书号不能为空，房屋用途必须有效
+// 楼层不能为0，建成年份不能晚于交易时间，土地使用权年限不能超过该用途的最高年限
+func parseRealEstateAttributes(stub shim.ChaincodeStubInterface, value string) (lib.RealEstateAttributes, error) {
+	var attributes lib.RealEstateAttributes
+	if err := json.Unmarshal([]byte(value), &attributes); err != nil {
+		return attributes, errors.New(fmt.Sprintf("登记信息格式错误: %s", err))
+	}
+	if attributes.Address == "" || attributes.District == "" || attributes.CertificateNo == "" {
+		return attributes, errors.New("地址、行政区和不动产权证书号不能为空")
+	}
+	limit, ok := lib.UsageTermLimitConstant()[attributes.PropertyType]
+	if !ok {
+		return attributes, errors.New(fmt.Sprintf("房屋用途%s不支持", attributes.PropertyType))
+	}
+	if attributes.Floor == 0 {
+		return attributes, errors.New("楼层不能为0，地下为负数")
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return attributes, err
+	}
+	if attributes.BuildYear < minBuildYear || attributes.BuildYear > txTime.Year() {
+		return attributes, errors.New(fmt.Sprintf("建成年份必须在%d到%d之间", minBuildYear, txTime.Year()))
+	}
+	if attributes.UsageTerm <= 0 || attributes.UsageTerm > limit {
+		return attributes, errors.New(fmt.Sprintf("%s土地使用权年限必须在1到%d年之间", lib.PropertyTypeConstant()[attributes.PropertyType], limit))
+	}
+	return attributes, nil
+}
+
+// retireRealEstate 拆分或合并后原房地产不再有效，删除所有共有人的所有者索引并记录拆分合并后的房地产
+func retireRealEstate(stub shim.ChaincodeStubInterface, realEstate *lib.RealEstate, children []string, causeType string, causeKey []string) error {
+	for _, v := range realEstateOwners(*realEstate) {
+		if err := utils.DelLedger(stub, lib.RealEstateProprietorKey, []string{v.AccountId, realEstate.RealEstateID}); err != nil {
+			return err
+		}
+	}
+	realEstate.Children = children
+	realEstate.Retired = true
+	return writeRealEstate(stub, realEstate, causeType, causeKey)
+}
+
+// isSameOwnership 判断两个房地产的代表业主、共有人份额和处分同意门槛是否都相同
+func isSameOwnership(a lib.RealEstate, b lib.RealEstate) bool {
+	if a.Proprietor != b.Proprietor || len(realEstateOwners(a)) != len(realEstateOwners(b)) {
+		return false
+	}
+	shares := make(map[string]int)
+	for _, v := range realEstateOwners(a) {
+		shares[v.AccountId] = v.Share
+	}
+	for _, v := range realEstateOwners(b) {
+		if shares[v.AccountId] != v.Share {
+			return false
+		}
+	}
+	threshold := func(realEstate lib.RealEstate) int {
+		if realEstate.ConsentThreshold == 0 {
+			return lib.ShareTotal
+		}
+		return realEstate.ConsentThreshold
+	}
+	return threshold(a) == threshold(b)
+}
+
+// isAreaEqual 比较面积，忽略浮点数累加的舍入误差
+func isAreaEqual(a float64, b float64) bool {
+	return math.Abs(a-b) < 1e-6
+}
+
+// realEstateListResponse 设置批量变更房地产的事件并返回变更后的房地产
+func realEstateListResponse(stub shim.ChaincodeStubInterface, eventName string, realEstateList []lib.RealEstate) peer.Response {
+	if err := utils.SetEvent(stub, eventName, &lib.RealEstateListEvent{TxID: stub.GetTxID(), RealEstates: realEstateList}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	realEstateListByte, err := json.Marshal(realEstateList)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("QueryRealEstateList-序列化出错: %s", err))
+		return shim.Error(fmt.Sprintf("序列化房地产信息出错: %s", err))
 	}
 	return shim.Success(realEstateListByte)
 }
@@ -0,0 +1,312 @@
+package routers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// CreateSealedAuction 卖家发起密封拍卖，结束前只能提交出价承诺，结束后到揭示截止时间前揭示出价
+func CreateSealedAuction(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 5 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	reservePrice := args[2]
+	endTime := args[3]
+	revealEndTime := args[4]
+	if objectOfSale == "" || seller == "" || reservePrice == "" || endTime == "" || revealEndTime == "" {
+		return shim.Error("参数存在空值")
+	}
+	formattedReservePrice, err := parseAmount(reservePrice)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("reservePrice参数%s", err))
+	}
+	formattedEndTime, err := parseFutureTime(stub, endTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("endTime参数%s", err))
+	}
+	formattedRevealEndTime, err := parseFutureTime(stub, revealEndTime)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("revealEndTime参数%s", err))
+	}
+	if formattedRevealEndTime <= formattedEndTime {
+		return shim.Error("揭示截止时间必须晚于结束时间")
+	}
+	return startAuction(stub, &lib.Auction{
+		AuctionType:   lib.AuctionTypeConstant()["sealed"],
+		ObjectOfSale:  objectOfSale,
+		Seller:        seller,
+		ReservePrice:  formattedReservePrice,
+		EndTime:       formattedEndTime,
+		RevealEndTime: formattedRevealEndTime,
+	})
+}
+
+// CommitBid 竞拍人提交密封出价，出价和盐值通过transient的bid传入({"amount":"100","salt":"..."})
+// 出价保存在私有数据集合中，账本中只公开出价的哈希，结束前可以重新提交覆盖之前的出价
+func CommitBid(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	bidder := args[2]
+	if objectOfSale == "" || seller == "" || bidder == "" {
+		return shim.Error("参数存在空值")
+	}
+	if seller == bidder {
+		return shim.Error("竞拍人和卖家不能同一人")
+	}
+	accountBidder, err := checkAccountOwner(stub, bidder)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountBidder); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction, err := getSealedAuction(stub, seller, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if ended, err := isTimePassed(stub, auction.EndTime); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if ended {
+		return shim.Error("此拍卖已经结束，不能提交出价")
+	}
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return shim.Error(fmt.Sprintf("获取transient出错: %s", err))
+	}
+	bidByte, ok := transient["bid"]
+	if !ok || len(bidByte) == 0 {
+		return shim.Error("出价必须通过transient的bid传入")
+	}
+	var private lib.SealedBidPrivate
+	if err := json.Unmarshal(bidByte, &private); err != nil {
+		return shim.Error(fmt.Sprintf("transient的bid反序列化出错: %s", err))
+	}
+	if private.Salt == "" {
+		return shim.Error("盐值不能为空")
+	}
+	if private.Amount < auction.ReservePrice {
+		return shim.Error(fmt.Sprintf("出价不能低于保留价%s", auction.ReservePrice))
+	}
+	private.AuctionID = auction.AuctionID
+	private.Bidder = bidder
+	if err := utils.WritePrivateData(&private, stub, lib.SealedBidCollection, lib.SealedBidPrivateKey, []string{private.AuctionID, bidder}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	sealedBid := &lib.SealedBid{
+		AuctionID:  auction.AuctionID,
+		Bidder:     bidder,
+		BidHash:    sealedBidHash(private),
+		CreateTime: utils.FormatTime(txTime),
+	}
+	if err := writeSealedBid(stub, sealedBid); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return sealedBidResponse(stub, "auctionCommitted", &auction, sealedBid)
+}
+
+// RevealBid 拍卖结束后竞拍人揭示出价，从私有数据集合中取出出价并与公开的哈希比对
+// 揭示的出价高于当前最高出价时转入托管，之前的最高出价退还，出价相同时先揭示的优先
+func RevealBid(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 3 {
+		return shim.Error("参数个数不满足")
+	}
+	objectOfSale := args[0]
+	seller := args[1]
+	bidder := args[2]
+	if objectOfSale == "" || seller == "" || bidder == "" {
+		return shim.Error("参数存在空值")
+	}
+	accountBidder, err := checkAccountOwner(stub, bidder)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountBidder); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	auction, err := getSealedAuction(stub, seller, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if ended, err := isTimePassed(stub, auction.EndTime); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if !ended {
+		return shim.Error("此拍卖尚未结束，不能揭示出价")
+	}
+	if closed, err := isTimePassed(stub, auction.RevealEndTime); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if closed {
+		return shim.Error("已经超过揭示截止时间，不能揭示出价")
+	}
+	sealedBid, err := getSealedBid(stub, auction.AuctionID, bidder)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if sealedBid.Revealed {
+		return shim.Error("此出价已经揭示")
+	}
+	privateByte, err := utils.GetPrivateData(stub, lib.SealedBidCollection, lib.SealedBidPrivateKey, []string{auction.AuctionID, bidder})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if privateByte == nil {
+		return shim.Error(fmt.Sprintf("私有数据集合%s中没有%s的出价", lib.SealedBidCollection, bidder))
+	}
+	var private lib.SealedBidPrivate
+	if err := json.Unmarshal(privateByte, &private); err != nil {
+		return shim.Error(fmt.Sprintf("SealedBidPrivate-反序列化出错: %s", err))
+	}
+	if sealedBidHash(private) != sealedBid.BidHash {
+		return shim.Error("出价与提交的哈希不一致")
+	}
+
+	if private.Amount > auction.HighestBid {
+		accounts := map[string]*lib.Account{bidder: &accountBidder}
+		if auction.HighestBidder != "" {
+			escrow, err := getHeldEscrow(stub, seller, objectOfSale, auction.HighestBidder)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+			accountOutbid, err := getAccountOnce(stub, accounts, auction.HighestBidder)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+			if err := settleEscrow(stub, &escrow, "refunded", accountOutbid); err != nil {
+				return shim.Error(fmt.Sprintf("退还之前的最高出价失败%s", err))
+			}
+		}
+		deedTax, err := escrowDeedTax(stub, private.Amount)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if _, err := holdEscrow(stub, seller, objectOfSale, private.Amount, deedTax, "bid", &accountBidder); err != nil {
+			return shim.Error(fmt.Sprintf("出价转入托管失败%s", err))
+		}
+		auction.HighestBid = private.Amount
+		auction.HighestBidder = bidder
+		if err := writeAuction(stub, &auction); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	sealedBid.Amount = private.Amount
+	sealedBid.Revealed = true
+	sealedBid.RevealTime = utils.FormatTime(txTime)
+	if err := writeSealedBid(stub, &sealedBid); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return sealedBidResponse(stub, "auctionRevealed", &auction, &sealedBid)
+}
+
+// QuerySealedBids 分页查询一次密封拍卖的所有出价承诺，揭示前只有哈希
+func QuerySealedBids(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 1 {
+		return shim.Error("必须指定AuctionID查询")
+	}
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.SealedBidKey, keys, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var sealedBidList []lib.SealedBid
+	for _, v := range results {
+		var sealedBid lib.SealedBid
+		if err := json.Unmarshal(v, &sealedBid); err != nil {
+			return shim.Error(fmt.Sprintf("QuerySealedBids-反序列化出错: %s", err))
+		}
+		sealedBidList = append(sealedBidList, sealedBid)
+	}
+	return pageResponse(sealedBidList, pageSize, nextBookmark, hasMore)
+}
+
+// getSealedAuction 获取竞拍中的密封拍卖
+func getSealedAuction(stub shim.ChaincodeStubInterface, seller string, objectOfSale string) (lib.Auction, error) {
+	auction, err := getAuction(stub, seller, objectOfSale)
+	if err != nil {
+		return auction, err
+	}
+	if auction.AuctionType != lib.AuctionTypeConstant()["sealed"] {
+		return auction, errors.New("此拍卖不是密封拍卖")
+	}
+	if auction.AuctionStatus != lib.AuctionStatusConstant()["bidding"] {
+		return auction, errors.New("此拍卖不处于竞拍中状态")
+	}
+	return auction, nil
+}
+
+func getSealedBid(stub shim.ChaincodeStubInterface, auctionID string, bidder string) (lib.SealedBid, error) {
+	var sealedBid lib.SealedBid
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SealedBidKey, []string{auctionID, bidder})
+	if err != nil {
+		return sealedBid, err
+	}
+	if len(results) != 1 {
+		return sealedBid, errors.New(fmt.Sprintf("%s没有提交密封出价", bidder))
+	}
+	if err := json.Unmarshal(results[0], &sealedBid); err != nil {
+		return sealedBid, errors.New(fmt.Sprintf("SealedBid-反序列化出错: %s", err))
+	}
+	return sealedBid, nil
+}
+
+// getSealedBids 获取一次密封拍卖的所有出价承诺
+func getSealedBids(stub shim.ChaincodeStubInterface, auctionID string) ([]lib.SealedBid, error) {
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SealedBidKey, []string{auctionID})
+	if err != nil {
+		return nil, err
+	}
+	var sealedBidList []lib.SealedBid
+	for _, v := range results {
+		var sealedBid lib.SealedBid
+		if err := json.Unmarshal(v, &sealedBid); err != nil {
+			return nil, errors.New(fmt.Sprintf("SealedBid-反序列化出错: %s", err))
+		}
+		sealedBidList = append(sealedBidList, sealedBid)
+	}
+	return sealedBidList, nil
+}
+
+func writeSealedBid(stub shim.ChaincodeStubInterface, sealedBid *lib.SealedBid) error {
+	return utils.WriteLedger(sealedBid, stub, lib.SealedBidKey, []string{sealedBid.AuctionID, sealedBid.Bidder})
+}
+
+// sealedBidHash 出价承诺的哈希，包含拍卖ID和竞拍人，防止照抄他人的承诺
+func sealedBidHash(private lib.SealedBidPrivate) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s", private.AuctionID, private.Bidder, private.Amount, private.Salt)))
+	return hex.EncodeToString(sum[:])
+}
+
+// sealedBidResponse 设置拍卖事件并返回出价承诺，事件中只包含公开的拍卖
+func sealedBidResponse(stub shim.ChaincodeStubInterface, eventName string, auction *lib.Auction, sealedBid *lib.SealedBid) peer.Response {
+	if err := utils.SetEvent(stub, eventName, &lib.AuctionEvent{TxID: stub.GetTxID(), Auction: publicAuction(*auction)}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	sealedBidByte, err := json.Marshal(sealedBid)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化出价承诺出错: %s", err))
+	}
+	return shim.Success(sealedBidByte)
+}
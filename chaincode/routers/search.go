@@ -0,0 +1,211 @@
+package routers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/peer"
+	"strings"
+	"transaction/chaincode/lib"
+	"transaction/chaincode/utils"
+)
+
+// SearchSellings 按结构化条件富查询销售，需要状态数据库为CouchDB
+// args[0]、args[1]为分页参数，args[2]为JSON格式的lib.SellingFilter(可以为空)
+func SearchSellings(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, rest, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var filter lib.SellingFilter
+	if err := parseFilter(rest, &filter); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	query, err := sellingQuery(filter)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetQueryResultWithPagination(stub, query, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var sellingList []lib.Selling
+	for _, v := range results {
+		var selling lib.Selling
+		if err := json.Unmarshal(v, &selling); err != nil {
+			return shim.Error(fmt.Sprintf("SearchSellings-反序列化出错: %s", err))
+		}
+		sellingList = append(sellingList, selling)
+	}
+	return pageResponse(sellingList, pageSize, nextBookmark, hasMore)
+}
+
+// SearchRealEstates 按结构化条件富查询房地产，需要状态数据库为CouchDB
+// args[0]、args[1]为分页参数，args[2]为JSON格式的lib.RealEstateFilter(可以为空)
+func SearchRealEstates(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, rest, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	var filter lib.RealEstateFilter
+	if err := parseFilter(rest, &filter); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	query, err := realEstateQuery(filter)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	results, nextBookmark, hasMore, err := utils.GetQueryResultWithPagination(stub, query, pageSize, bookmark)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstateList, err := unmarshalRealEstateList(results)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	return pageResponse(realEstateList, pageSize, nextBookmark, hasMore)
+}
+
+// parseFilter 解析JSON格式的查询条件，不允许出现未定义的字段
+func parseFilter(rest []string, filter interface{}) error {
+	if len(rest) != 1 {
+		return errors.New("必须指定查询条件filter(可以为空)")
+	}
+	if rest[0] == "" {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(rest[0]))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(filter); err != nil {
+		return errors.New(fmt.Sprintf("filter参数格式转换出错: %s", err))
+	}
+	return nil
+}
+
+// sellingQuery 根据查询条件构造CouchDB查询语句
+func sellingQuery(filter lib.SellingFilter) (string, error) {
+	selector := map[string]interface{}{"docType": lib.SellingKey}
+	if filter.Seller != "" {
+		selector["seller"] = filter.Seller
+	}
+	if filter.SellingStatus != "" {
+		status, ok := lib.SellingStatusConstant()[filter.SellingStatus]
+		if !ok {
+			return "", errors.New(fmt.Sprintf("销售状态%s不存在", filter.SellingStatus))
+		}
+		selector["sellingStatus"] = status
+	}
+	if (filter.MinPrice != nil && *filter.MinPrice < 0) || (filter.MaxPrice != nil && *filter.MaxPrice < 0) {
+		return "", errors.New("价格不能为负数")
+	}
+	if filter.MinPrice != nil && filter.MaxPrice != nil && *filter.MinPrice > *filter.MaxPrice {
+		return "", errors.New("最低价格不能高于最高价格")
+	}
+	var minPrice, maxPrice interface{}
+	if filter.MinPrice != nil {
+		minPrice = int64(*filter.MinPrice)
+	}
+	if filter.MaxPrice != nil {
+		maxPrice = int64(*filter.MaxPrice)
+	}
+	addRange(selector, "priceValue", minPrice, maxPrice)
+	var createdFrom, createdTo interface{}
+	if filter.CreatedFrom != "" {
+		t, err := utils.ParseTime(filter.CreatedFrom)
+		if err != nil {
+			return "", err
+		}
+		createdFrom = utils.FormatTime(t)
+	}
+	if filter.CreatedTo != "" {
+		t, err := utils.ParseTime(filter.CreatedTo)
+		if err != nil {
+			return "", err
+		}
+		createdTo = utils.FormatTime(t)
+	}
+	addRange(selector, "createTime", createdFrom, createdTo)
+	return buildQuery(selector, lib.SellingSortConstant(), filter.SortBy, "createTime", filter.SortOrder)
+}
+
+// realEstateQuery 根据查询条件构造CouchDB查询语句
+func realEstateQuery(filter lib.RealEstateFilter) (string, error) {
+	//不包括已拆分或合并的房地产，旧的房地产没有retired字段，$ne同样可以匹配
+	selector := map[string]interface{}{"docType": lib.RealEstateKey, "retired": map[string]interface{}{"$ne": true}}
+	if filter.Proprietor != "" {
+		//持有任意份额的共有人都能查询到，没有记录共有人的房地产按proprietor查询
+		selector["$or"] = []interface{}{
+			map[string]interface{}{"proprietor": filter.Proprietor},
+			map[string]interface{}{"owners": map[string]interface{}{"$elemMatch": map[string]interface{}{"accountId": filter.Proprietor}}},
+		}
+	}
+	if filter.Encumbrance != nil {
+		selector["encumbrance"] = *filter.Encumbrance
+	}
+	if filter.District != "" {
+		selector["district"] = filter.District
+	}
+	if filter.PropertyType != "" {
+		selector["propertyType"] = filter.PropertyType
+	}
+	var minTotalArea, maxTotalArea, minLivingSpace, maxLivingSpace interface{}
+	if filter.MinTotalArea != nil {
+		minTotalArea = *filter.MinTotalArea
+	}
+	if filter.MaxTotalArea != nil {
+		maxTotalArea = *filter.MaxTotalArea
+	}
+	if filter.MinLivingSpace != nil {
+		minLivingSpace = *filter.MinLivingSpace
+	}
+	if filter.MaxLivingSpace != nil {
+		maxLivingSpace = *filter.MaxLivingSpace
+	}
+	addRange(selector, "totalArea", minTotalArea, maxTotalArea)
+	addRange(selector, "livingSpace", minLivingSpace, maxLivingSpace)
+	return buildQuery(selector, lib.RealEstateSortConstant(), filter.SortBy, "totalArea", filter.SortOrder)
+}
+
+// addRange 为字段添加闭区间条件，min和max为nil时不限制
+func addRange(selector map[string]interface{}, field string, min, max interface{}) {
+	condition := make(map[string]interface{})
+	if min != nil {
+		condition["$gte"] = min
+	}
+	if max != nil {
+		condition["$lte"] = max
+	}
+	if len(condition) != 0 {
+		selector[field] = condition
+	}
+}
+
+// buildQuery 添加排序并序列化查询语句
+// CouchDB要求排序字段出现在selector中且有对应的索引(docType+排序字段，见META-INF/statedb/couchdb/indexes)
+func buildQuery(selector map[string]interface{}, sortFields map[string]string, sortBy, defaultSortBy, sortOrder string) (string, error) {
+	if sortBy == "" {
+		sortBy = defaultSortBy
+	}
+	field, ok := sortFields[sortBy]
+	if !ok {
+		return "", errors.New(fmt.Sprintf("不支持按%s排序", sortBy))
+	}
+	if sortOrder == "" {
+		sortOrder = "asc"
+	}
+	if sortOrder != "asc" && sortOrder != "desc" {
+		return "", errors.New(fmt.Sprintf("排序方向%s有误，只能为asc或desc", sortOrder))
+	}
+	if _, ok := selector[field]; !ok {
+		selector[field] = map[string]interface{}{"$gt": nil}
+	}
+	queryByte, err := json.Marshal(map[string]interface{}{
+		"selector": selector,
+		"sort":     []map[string]string{{"docType": sortOrder}, {field: sortOrder}},
+	})
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("序列化查询语句出错: %s", err))
+	}
+	return string(queryByte), nil
+}
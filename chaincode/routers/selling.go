@@ -2,6 +2,7 @@ package routers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	"github.com/hyperledger/fabric/protos/peer"
@@ -22,11 +23,9 @@ func CreateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 	if objectOfSale == "" || seller == "" || price == "" || salePeriod == "" {
 		return shim.Error("参数存在空值")
 	}
-	var formattedPrice float64
-	if val, err := strconv.ParseFloat(price, 64); err != nil {
-		return shim.Error(fmt.Sprintf("price参数格式转换出错: %s", err))
-	} else {
-		formattedPrice = val
+	formattedPrice, err := parseAmount(price)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("price参数%s", err))
 	}
 
 	var formattedSalePeriod int
@@ -35,37 +34,55 @@ func CreateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 	} else {
 		formattedSalePeriod = val
 	}
-
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("验证%s属于%s失败: %s", objectOfSale, seller, err))
+	if formattedSalePeriod <= 0 {
+		return shim.Error("salePeriod有效期必须大于0天")
 	}
 
-	var realEstate lib.RealEstate
-	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
-		return shim.Error(fmt.Sprintf("CreateSelling-反序列化出错: %s", err))
+	accountSeller, err := checkAccountOwner(stub, seller)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := checkAccountActive(accountSeller); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	realEstate, err := getRealEstate(stub, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if realEstate.Proprietor != seller {
+		return shim.Error(fmt.Sprintf("验证%s属于%s失败", objectOfSale, seller))
 	}
 
 	if realEstate.Encumbrance {
 		return shim.Error("此房地产已经作为担保状态，不能重复发起销售")
 	}
+	if err := useOwnerConsents(stub, realEstate, "selling"); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	selling := &lib.Selling{
 		ObjectOfSale:  objectOfSale,
 		Seller:        seller,
 		Buyer:         "",
 		Price:         formattedPrice,
-		CreateTime:    time.Now().Local().Format("2006-01-02 15:04:05"),
+		CreateTime:    utils.FormatTime(txTime),
 		SalePeriod:    formattedSalePeriod,
 		SellingStatus: lib.SellingStatusConstant()["saleStart"],
 	}
 
-	if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+	if err := writeSelling(stub, selling); err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
 
 	realEstate.Encumbrance = true
-	if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+	if err := writeRealEstate(stub, &realEstate, "selling", []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if err := utils.SetEvent(stub, "sellingCreated", &lib.SellingEvent{TxID: stub.GetTxID(), Selling: *selling}); err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
 	sellingByte, err := json.Marshal(selling)
@@ -76,13 +93,17 @@ func CreateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 
 }
 
+// CreateSellingByBuy 买家以售价购买，买家可以置空并通过transient的buyer传入
 func CreateSellingByBuy(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	if len(args) != 3 {
 		return shim.Error("参数个数不满足")
 	}
 	objectOfSale := args[0]
 	seller := args[1]
-	buyer := args[2]
+	buyer, err := privateArg(stub, args, 2, "buyer")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	if objectOfSale == "" || seller == "" || buyer == "" {
 		return shim.Error("参数存在空值")
 	}
@@ -90,9 +111,12 @@ func CreateSellingByBuy(stub shim.ChaincodeStubInterface, args []string) peer.Re
 		return shim.Error("买家和卖家不能同一人")
 	}
 
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败: %s", objectOfSale, seller, err))
+	realEstate, err := getRealEstate(stub, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("获取想要购买的房产信息失败: %s", err))
+	}
+	if realEstate.Proprietor != seller {
+		return shim.Error(fmt.Sprintf("房产%s不属于%s", objectOfSale, seller))
 	}
 	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, []string{seller, objectOfSale})
 	if err != nil || len(resultsSelling) != 1 {
@@ -103,59 +127,97 @@ func CreateSellingByBuy(stub shim.ChaincodeStubInterface, args []string) peer.Re
 	if err := json.Unmarshal(resultsSelling[0], &selling); err != nil {
 		return shim.Error(fmt.Sprintf("CreateSellingBuy-反序列化出错: %s", err))
 	}
+	if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, []string{seller, objectOfSale}, &selling); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 
 	if selling.SellingStatus != lib.SellingStatusConstant()["saleStart"] {
 		return shim.Error("此交易不属于销售中状态，已经无法购买")
 	}
+	if overdue, err := isSellingOverdue(stub, selling); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	} else if overdue {
+		return shim.Error("此交易已超过有效期，已经无法购买")
+	}
 
-	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{buyer})
-	if err != nil || len(resultsAccount) != 1 {
+	buyerAccount, err := checkAccountOwner(stub, buyer)
+	if err != nil {
 		return shim.Error(fmt.Sprintf("buyer买家信息验证失败%s", err))
 	}
+	if err := checkAccountActive(buyerAccount); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 
-	var buyerAccount lib.Account
-	if err = json.Unmarshal(resultsAccount[0], &buyerAccount); err != nil {
-		return shim.Error(fmt.Sprintf("查询buyer买家信息-反序列化出错: %s", err))
+	sellingBuy, err := purchaseSelling(stub, &selling, &buyerAccount, selling.Price)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
-	if buyerAccount.UserName == "管理员" {
-		return shim.Error(fmt.Sprintf("管理员不能购买%s", err))
+	public, err := publicSelling(stub, selling)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
-
-	if buyerAccount.Balance < selling.Price {
-		return shim.Error(fmt.Sprintf("房产售价为%f,您的当前余额为%f,购买失败", selling.Price, buyerAccount.Balance))
+	sellingBuy.Selling = public
+	sellingBuyByte, err := json.Marshal(sellingBuy)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	}
+	if err := utils.SetEvent(stub, "sellingPurchased", &lib.SellingEvent{TxID: stub.GetTxID(), Selling: public}); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
 	}
+	// 成功返回
+	return shim.Success(sellingBuyByte)
+}
 
-	selling.Buyer = buyer
+// purchaseSelling 买家以price买下销售中的房产，销售进入交付中，购房资金和契税转入托管
+// price为成交价，直接购买时为售价，接受报价时为双方商定的价格
+func purchaseSelling(stub shim.ChaincodeStubInterface, selling *lib.Selling, buyerAccount *lib.Account, price lib.Amount) (*lib.SellingBuy, error) {
+	deedTax, err := escrowDeedTax(stub, price)
+	if err != nil {
+		return nil, err
+	}
+	if buyerAccount.Balance < price+deedTax {
+		return nil, errors.New(fmt.Sprintf("房产售价为%s,契税为%s,您的当前余额为%s,购买失败", price, deedTax, buyerAccount.Balance))
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return nil, err
+	}
+	selling.Buyer = buyerAccount.AccountId
+	selling.Price = price
 	selling.SellingStatus = lib.SellingStatusConstant()["delivery"]
-	if err := utils.WriteLedger(selling, stub, lib.AccountKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
-		return shim.Error(fmt.Sprintf("将buyer写入交易selling,修改交易状态 失败%s", err))
+	if err := writeSelling(stub, selling); err != nil {
+		return nil, errors.New(fmt.Sprintf("将buyer写入交易selling,修改交易状态 失败%s", err))
 	}
-
 	sellingBuy := &lib.SellingBuy{
-		Buyer:      buyer,
-		CreateTime: time.Now().Local().Format("2006-01-02 15:04:05"),
-		Selling:    selling,
+		Buyer:      selling.Buyer,
+		CreateTime: utils.FormatTime(txTime),
+		Selling:    *selling,
 	}
-	local, _ := time.LoadLocation("Local")
-	createTimeUnixNano, _ := time.ParseInLocation("2006-01-02 15:04:05", sellingBuy.CreateTime, local)
-	if err := utils.WriteLedger(sellingBuy, stub, lib.SellingBuyKey, []string{sellingBuy.Buyer, fmt.Sprintf("%d", createTimeUnixNano.UnixNano())}); err != nil {
-		return shim.Error(fmt.Sprintf("将本次购买交易写入账本失败%s", err))
-	}
-	sellingBuyByte, err := json.Marshal(sellingBuy)
+	createTimeKey, err := utils.TimeKey(sellingBuy.CreateTime)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+		return nil, err
 	}
-	buyerAccount.Balance -= selling.Price
-	if err := utils.WriteLedger(buyerAccount, stub, lib.AccountKey, []string{buyerAccount.AccountId}); err != nil {
-		return shim.Error(fmt.Sprintf("扣取买家余额失败%s", err))
+	if err := utils.WritePrivateData(sellingBuy, stub, lib.DealCollection, lib.SellingBuyKey, []string{sellingBuy.Buyer, createTimeKey}); err != nil {
+		return nil, errors.New(fmt.Sprintf("将本次购买交易写入账本失败%s", err))
 	}
-	// 成功返回
-	return shim.Success(sellingBuyByte)
+	if _, err := holdEscrow(stub, selling.Seller, selling.ObjectOfSale, price, deedTax, "purchase", buyerAccount); err != nil {
+		return nil, errors.New(fmt.Sprintf("购房资金转入托管失败%s", err))
+	}
+	return sellingBuy, nil
 }
 
+// QuerySellingList 分页查询销售，只有买卖双方、登记员、银行和审计员可以看到买家和成交价
 func QuerySellingList(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	var sellingList []lib.Selling
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, args)
+	results, nextBookmark, hasMore, err := utils.GetStateByPartialCompositeKeysWithPagination(stub, lib.SellingKey, keys, pageSize, bookmark)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
@@ -166,22 +228,37 @@ func QuerySellingList(stub shim.ChaincodeStubInterface, args []string) peer.Resp
 			if err != nil {
 				return shim.Error(fmt.Sprintf("QuerySellingList-反序列化出错: %s", err))
 			}
+			full := selling
+			if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, []string{selling.Seller, selling.ObjectOfSale}, &full); err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+			if canReadPrivate(caller, lib.DealCollection, full.Seller, full.Buyer) {
+				selling = full
+			}
 			sellingList = append(sellingList, selling)
 		}
 	}
-	sellingListByte, err := json.Marshal(sellingList)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("QuerySellingList-序列化出错: %s", err))
-	}
-	return shim.Success(sellingListByte)
+	return pageResponse(sellingList, pageSize, nextBookmark, hasMore)
 }
 
+// QuerySellingListByBuyer 分页查询买家参与的销售，只有买家本人、登记员、银行和审计员可以查询
 func QuerySellingListByBuyer(stub shim.ChaincodeStubInterface, args []string) peer.Response {
-	if len(args) != 1 {
+	pageSize, bookmark, keys, err := parsePage(args)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if len(keys) != 1 {
 		return shim.Error(fmt.Sprintf("必须指定买家AccountId查询"))
 	}
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if !canReadPrivate(caller, lib.DealCollection, keys[0]) {
+		return shim.Error(fmt.Sprintf("权限不足(permission denied): 不能查询买家%s参与的销售", keys[0]))
+	}
 	var sellingBuyList []lib.SellingBuy
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingBuyKey, args)
+	results, nextBookmark, hasMore, err := utils.GetPrivateDataByPartialCompositeKeysWithPagination(stub, lib.DealCollection, lib.SellingBuyKey, keys, pageSize, bookmark)
 	if err != nil {
 		return shim.Error(fmt.Sprintf("%s", err))
 	}
@@ -195,21 +272,21 @@ func QuerySellingListByBuyer(stub shim.ChaincodeStubInterface, args []string) pe
 			sellingBuyList = append(sellingBuyList, sellingBuy)
 		}
 	}
-	sellingBuyListByte, err := json.Marshal(sellingBuyList)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("QuerySellingListByBuyer-序列化出错: %s", err))
-	}
-	return shim.Success(sellingBuyListByte)
-
+	return pageResponse(sellingBuyList, pageSize, nextBookmark, hasMore)
 }
 
+// UpdateSelling 确认收款、取消或过期销售，买家可以置空并通过transient的buyer传入
+// 确认收款时按税费标准收取契税和交易手续费，见settleSale，税费收据可以用本交易ID通过queryReceipt查询
 func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Response {
 	if len(args) != 4 {
 		return shim.Error("参数个数不满足")
 	}
 	objectOfSale := args[0]
 	seller := args[1]
-	buyer := args[2]
+	buyer, err := privateArg(stub, args, 2, "buyer")
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
 	status := args[3]
 	if objectOfSale == "" || seller == "" || status == "" {
 		return shim.Error("参数存在空值")
@@ -217,13 +294,12 @@ func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 	if buyer == seller {
 		return shim.Error("买家和卖家不能同一人")
 	}
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, lib.RealEstateKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败: %s", objectOfSale, seller, err))
+	realEstate, err := getRealEstate(stub, objectOfSale)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("获取想要购买的房产信息失败: %s", err))
 	}
-	var realEstate lib.RealEstate
-	if err := json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
-		return shim.Error(fmt.Sprintf("UpdateSellingBySeller-反序列化出错: %s", err))
+	if realEstate.Proprietor != seller {
+		return shim.Error(fmt.Sprintf("房产%s不属于%s", objectOfSale, seller))
 	}
 	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, []string{seller, objectOfSale})
 	if err != nil || len(resultsSelling) != 1 {
@@ -233,29 +309,52 @@ func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 	if err := json.Unmarshal(resultsSelling[0], &selling); err != nil {
 		return shim.Error(fmt.Sprintf("UpdateSellingBySeller-反序列化出错: %s", err))
 	}
+	if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, []string{seller, objectOfSale}, &selling); err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	//buyer必须是账本中记录的买家，销售中还没有买家时必须为空
+	if buyer != selling.Buyer {
+		return shim.Error(fmt.Sprintf("buyer买家%s与销售记录不一致", buyer))
+	}
+	//只有卖家可以确认收款，卖家或买家可以取消，过期还可以由登记员操作，都以账本中的销售为准
+	caller, err := getCallerAccount(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	isParty := caller.AccountId == selling.Seller || caller.AccountId == selling.Buyer
+	switch status {
+	case "done":
+		if caller.AccountId != selling.Seller {
+			return shim.Error("只有卖家可以确认收款")
+		}
+	case "cancelled":
+		if !isParty {
+			return shim.Error("只有卖家或买家可以取消交易")
+		}
+	case "expired":
+		if !isParty && !hasRole(caller, "registrar") {
+			return shim.Error("只有卖家、买家或登记员可以将交易设置为过期")
+		}
+	}
 
 	var sellingBuy lib.SellingBuy
-	if selling.SellingStatus != lib.SellingStatusConstant()["saleStart"] {
-		resultsSellingByBuyer, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, []string{buyer})
-		if err != nil || len(resultsSellingByBuyer) == 0 {
-			return shim.Error(fmt.Sprintf("根据%s获取买家购买信息失败: %s", buyer, err))
-		}
-		for _, v := range resultsSellingByBuyer {
-			if v != nil {
-				var s lib.SellingBuy
-				err := json.Unmarshal(v, &s)
-				if err != nil {
-					return shim.Error(fmt.Sprintf("UpdateSellingBySeller-反序列化出错: %s", err))
-				}
-				if s.Selling.ObjectOfSale == objectOfSale && s.Selling.Seller == seller && s.Buyer == buyer {
-					if s.Selling.SellingStatus == lib.SellingStatusConstant()["delivery"] {
-						sellingBuy = s
-						break
-					}
-				}
-			}
+	if selling.SellingStatus == lib.SellingStatusConstant()["delivery"] {
+		sellingBuy, err = getDeliverySellingBuy(stub, selling, buyer)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
 		}
 	}
+	//超过有效期后只能设置为过期，不能再确认收款
+	overdue, err := isSellingOverdue(stub, selling)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	if status == "done" && overdue {
+		return shim.Error("此交易已超过有效期，不能确认收款")
+	}
+	if status == "expired" && !overdue {
+		return shim.Error("此交易尚未超过有效期，不能设置为过期")
+	}
 	var data []byte
 
 	switch status {
@@ -263,51 +362,79 @@ func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 		if selling.SellingStatus != lib.SellingStatusConstant()["delivery"] {
 			return shim.Error("此交易并不处于交付中，确认收款失败")
 		}
-		resultsSellerAccount, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{seller})
-		if err != nil || len(resultsSellerAccount) != 1 {
+		//买家也可能是共有人，先分配售房款再缴纳契税，同一账户需要使用同一个对象
+		accounts := make(map[string]*lib.Account)
+		accountSeller, err := getAccountOnce(stub, accounts, seller)
+		if err != nil {
 			return shim.Error(fmt.Sprintf("seller卖家信息验证失败%s", err))
 		}
-		var accountSeller lib.Account
-		if err := json.Unmarshal(resultsSellerAccount[0], &accountSeller); err != nil {
-			return shim.Error(fmt.Sprintf("查询seller卖家信息-反序列化出错: %s", err))
+		if err := checkAccountActive(*accountSeller); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		accountBuyer, err := getAccountOnce(stub, accounts, buyer)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("buyer买家信息验证失败%s", err))
 		}
-		accountSeller.Balance += selling.Price
-		if err := utils.WriteLedger(accountSeller, stub, lib.AccountKey, []string{accountSeller.AccountId}); err != nil {
+		if err := checkAccountActive(*accountBuyer); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		escrow, err := getHeldEscrow(stub, selling.Seller, selling.ObjectOfSale, selling.Buyer)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := settleSale(stub, &escrow, realEstate, "selling", []string{selling.Seller, selling.ObjectOfSale}, accounts); err != nil {
 			return shim.Error(fmt.Sprintf("卖家确认接收资金失败%s", err))
 		}
-		realEstate.Proprietor = buyer
 		realEstate.Encumbrance = false
-		realEstate.RealEstateID = fmt.Sprintf("%d", time.Now().Local().UnixNano())
-		if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		if err := changeProprietor(stub, &realEstate, buyer); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := writeRealEstate(stub, &realEstate, "selling", []string{selling.Seller, selling.ObjectOfSale}); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
 
-		if err := utils.DelLedger(stub, lib.RealEstateKey, []string{seller, objectOfSale}); err != nil {
+		selling.SellingStatus = lib.SellingStatusConstant()["done"]
+		if err := writeSelling(stub, &selling); err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
 
 		sellingBuy.Selling = selling
-		local, _ := time.LoadLocation("Local")
-		sellingBuyCreateTimeUnixNano, _ := time.ParseInLocation("2006-01-02 15:04:05", sellingBuy.CreateTime, local)
-		if err := utils.WriteLedger(sellingBuy, stub, lib.SellingBuyKey, []string{sellingBuy.Buyer, fmt.Sprintf("%d", sellingBuyCreateTimeUnixNano.UnixNano())}); err != nil {
+		sellingBuyCreateTimeKey, err := utils.TimeKey(sellingBuy.CreateTime)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := utils.WritePrivateData(sellingBuy, stub, lib.DealCollection, lib.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuyCreateTimeKey}); err != nil {
 			return shim.Error(fmt.Sprintf("将本次购买交易写入账本失败%s", err))
 		}
+		public, err := publicSelling(stub, selling)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if err := utils.SetEvent(stub, "sellingDone", &lib.SellingEvent{TxID: stub.GetTxID(), Selling: public}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		sellingBuy.Selling = public
 		data, err = json.Marshal(sellingBuy)
 		if err != nil {
 			return shim.Error(fmt.Sprintf("序列化购买交易的信息出错: %s", err))
 		}
 		break
-	case "cancelled":
-		data, err = closeSelling("cancelled", selling, realEstate, sellingBuy, buyer, stub)
+	case "cancelled", "expired":
+		data, err = closeSelling(stub, status, &selling, realEstate, sellingBuy, make(map[string]*lib.Account))
 		if err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
-		break
-	case "expired":
-		data, err = closeSelling("expired", selling, realEstate, sellingBuy, buyer, stub)
+		if data == nil {
+			break
+		}
+		public, err := publicSelling(stub, selling)
 		if err != nil {
 			return shim.Error(fmt.Sprintf("%s", err))
 		}
+		eventName := map[string]string{"cancelled": "sellingCancelled", "expired": "sellingExpired"}[status]
+		if err := utils.SetEvent(stub, eventName, &lib.SellingEvent{TxID: stub.GetTxID(), Selling: public}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
 		break
 	default:
 		return shim.Error(fmt.Sprintf("%s状态不支持", status))
@@ -316,15 +443,114 @@ func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) peer.Respons
 
 }
 
-func closeSelling(closeStart string, selling lib.Selling, realEstate lib.RealEstate, sellingBuy lib.SellingBuy, buyer string, stub shim.ChaincodeStubInterface) ([]byte, error) {
+// ExpireSellings 按交易时间关闭所有超过有效期的销售中和交付中的销售，交付中的托管资金退还买家
+// 在一笔交易中完成，由应用的定时任务触发，返回本次过期的销售
+func ExpireSellings(stub shim.ChaincodeStubInterface, args []string) peer.Response {
+	if len(args) != 0 {
+		return shim.Error("参数个数不满足")
+	}
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, []string{})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("%s", err))
+	}
+	accounts := make(map[string]*lib.Account)
+	var expiredList []lib.Selling
+	for _, v := range results {
+		var selling lib.Selling
+		if err := json.Unmarshal(v, &selling); err != nil {
+			return shim.Error(fmt.Sprintf("ExpireSellings-反序列化出错: %s", err))
+		}
+		if selling.SellingStatus != lib.SellingStatusConstant()["saleStart"] &&
+			selling.SellingStatus != lib.SellingStatusConstant()["delivery"] {
+			continue
+		}
+		if err := readPrivate(stub, lib.DealCollection, lib.SellingKey, []string{selling.Seller, selling.ObjectOfSale}, &selling); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		overdue, err := isSellingOverdue(stub, selling)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		if !overdue {
+			continue
+		}
+		realEstate, err := getRealEstate(stub, selling.ObjectOfSale)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		var sellingBuy lib.SellingBuy
+		if selling.SellingStatus == lib.SellingStatusConstant()["delivery"] {
+			sellingBuy, err = getDeliverySellingBuy(stub, selling, selling.Buyer)
+			if err != nil {
+				return shim.Error(fmt.Sprintf("%s", err))
+			}
+		}
+		if _, err := closeSelling(stub, "expired", &selling, realEstate, sellingBuy, accounts); err != nil {
+			return shim.Error(fmt.Sprintf("销售%s设置为过期失败%s", selling.ObjectOfSale, err))
+		}
+		public, err := publicSelling(stub, selling)
+		if err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+		expiredList = append(expiredList, public)
+	}
+	if len(expiredList) != 0 {
+		if err := utils.SetEvent(stub, "sellingsExpired", &lib.SellingListEvent{TxID: stub.GetTxID(), Sellings: expiredList}); err != nil {
+			return shim.Error(fmt.Sprintf("%s", err))
+		}
+	}
+	expiredListByte, err := json.Marshal(expiredList)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("ExpireSellings-序列化出错: %s", err))
+	}
+	return shim.Success(expiredListByte)
+}
+
+// isSellingOverdue 按交易时间判断销售是否已超过有效期(创建时间加SalePeriod天)
+func isSellingOverdue(stub shim.ChaincodeStubInterface, selling lib.Selling) (bool, error) {
+	createTime, err := utils.ParseTime(selling.CreateTime)
+	if err != nil {
+		return false, err
+	}
+	txTime, err := utils.GetTxTime(stub)
+	if err != nil {
+		return false, err
+	}
+	deadline := createTime.Add(time.Duration(selling.SalePeriod) * 24 * time.Hour)
+	return !txTime.Before(deadline), nil
+}
+
+// getDeliverySellingBuy 获取交付中的销售对应的买家购买记录
+func getDeliverySellingBuy(stub shim.ChaincodeStubInterface, selling lib.Selling, buyer string) (lib.SellingBuy, error) {
+	results, err := utils.GetPrivateDataByPartialCompositeKeys(stub, lib.DealCollection, lib.SellingBuyKey, []string{buyer})
+	if err != nil {
+		return lib.SellingBuy{}, errors.New(fmt.Sprintf("根据%s获取买家购买信息失败: %s", buyer, err))
+	}
+	for _, v := range results {
+		var sellingBuy lib.SellingBuy
+		if err := json.Unmarshal(v, &sellingBuy); err != nil {
+			return lib.SellingBuy{}, errors.New(fmt.Sprintf("SellingBuy-反序列化出错: %s", err))
+		}
+		if sellingBuy.Selling.ObjectOfSale == selling.ObjectOfSale && sellingBuy.Selling.Seller == selling.Seller &&
+			sellingBuy.Selling.SellingStatus == lib.SellingStatusConstant()["delivery"] {
+			return sellingBuy, nil
+		}
+	}
+	return lib.SellingBuy{}, errors.New(fmt.Sprintf("没有找到%s购买%s的记录", buyer, selling.ObjectOfSale))
+}
+
+// closeSelling 取消或过期销售，销售中直接解除担保，交付中还要将托管资金退还买家
+// selling更新为关闭后的状态，不处于销售中或交付中时不做任何变更，返回nil
+// accounts缓存本交易中已经读取的账户，见getAccountOnce
+func closeSelling(stub shim.ChaincodeStubInterface, closeStart string, selling *lib.Selling, realEstate lib.RealEstate, sellingBuy lib.SellingBuy, accounts map[string]*lib.Account) ([]byte, error) {
 	switch selling.SellingStatus {
 	case lib.SellingStatusConstant()["saleStart"]:
 		selling.SellingStatus = lib.SellingStatusConstant()[closeStart]
 		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		if err := writeRealEstate(stub, &realEstate, "selling", []string{selling.Seller, selling.ObjectOfSale}); err != nil {
 			return nil, err
 		}
-		if err := utils.WriteLedger(selling, stub, lib.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		if err := writeSelling(stub, selling); err != nil {
 			return nil, err
 		}
 		data, err := json.Marshal(selling)
@@ -333,31 +559,34 @@ func closeSelling(closeStart string, selling lib.Selling, realEstate lib.RealEst
 		}
 		return data, nil
 	case lib.SellingStatusConstant()["delivery"]:
-		resultsBuyerAccount, err := utils.GetStateByPartialCompositeKeys(stub, lib.AccountKey, []string{buyer})
-		if err != nil || len(resultsBuyerAccount) != 1 {
+		accountBuyer, err := getAccountOnce(stub, accounts, selling.Buyer)
+		if err != nil {
 			return nil, err
 		}
-		var accountBuyer lib.Account
-		if err = json.Unmarshal(resultsBuyerAccount[0], &accountBuyer); err != nil {
+		escrow, err := getHeldEscrow(stub, selling.Seller, selling.ObjectOfSale, selling.Buyer)
+		if err != nil {
 			return nil, err
 		}
-
-		accountBuyer.Balance += selling.Price
-		if err := utils.WriteLedger(accountBuyer, stub, lib.AccountKey, []string{accountBuyer.AccountId}); err != nil {
+		if err := settleEscrow(stub, &escrow, "refunded", accountBuyer); err != nil {
 			return nil, err
 		}
 		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, lib.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		if err := writeRealEstate(stub, &realEstate, "selling", []string{selling.Seller, selling.ObjectOfSale}); err != nil {
 			return nil, err
 		}
 		selling.SellingStatus = lib.SellingStatusConstant()[closeStart]
-		if err := utils.WriteLedger(selling, stub, lib.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		if err := writeSelling(stub, selling); err != nil {
 			return nil, err
 		}
-		sellingBuy.Selling = selling
-		local, _ := time.LoadLocation("Local")
-		sellingBuyCreateTimeUnixNano, _ := time.ParseInLocation("2006-01-02 15:04:05", sellingBuy.CreateTime, local)
-		if err := utils.WriteLedger(sellingBuy, stub, lib.SellingBuyKey, []string{sellingBuy.Buyer, fmt.Sprintf("%d", sellingBuyCreateTimeUnixNano.UnixNano())}); err != nil {
+		sellingBuy.Selling = *selling
+		sellingBuyCreateTimeKey, err := utils.TimeKey(sellingBuy.CreateTime)
+		if err != nil {
+			return nil, err
+		}
+		if err := utils.WritePrivateData(sellingBuy, stub, lib.DealCollection, lib.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuyCreateTimeKey}); err != nil {
+			return nil, err
+		}
+		if sellingBuy.Selling, err = publicSelling(stub, *selling); err != nil {
 			return nil, err
 		}
 		data, err := json.Marshal(sellingBuy)
@@ -370,3 +599,63 @@ func closeSelling(closeStart string, selling lib.Selling, realEstate lib.RealEst
 
 	}
 }
+
+// writeSelling 完整的销售写入私有数据集合，账本中公开的销售不含买家和成交价
+func writeSelling(stub shim.ChaincodeStubInterface, selling *lib.Selling) error {
+	public, err := publicSelling(stub, *selling)
+	if err != nil {
+		return err
+	}
+	return writeWithPrivate(stub, lib.DealCollection, lib.SellingKey, []string{selling.Seller, selling.ObjectOfSale}, selling, &public)
+}
+
+// publicSelling 去掉买家和成交价的销售，用于写入账本、事件和交易的返回值
+// 有买家后价格取账本中公开的售价，成交价只保存在私有数据集合中
+func publicSelling(stub shim.ChaincodeStubInterface, selling lib.Selling) (lib.Selling, error) {
+	if selling.Buyer == "" {
+		return selling, nil
+	}
+	listed, err := getListedSelling(stub, []string{selling.Seller, selling.ObjectOfSale})
+	if err != nil {
+		return selling, err
+	}
+	selling.Buyer = ""
+	selling.Price = listed.Price
+	return selling, nil
+}
+
+// getListedSelling 获取账本中公开的销售，买家购买之前写入，价格为卖家的售价
+// 升级前写入的销售在买家购买后价格为成交价，从历史版本中取买家购买之前的版本
+func getListedSelling(stub shim.ChaincodeStubInterface, keys []string) (lib.Selling, error) {
+	var listed lib.Selling
+	results, err := utils.GetStateByPartialCompositeKeys2(stub, lib.SellingKey, keys)
+	if err != nil {
+		return listed, err
+	}
+	if len(results) != 1 {
+		return listed, errors.New(fmt.Sprintf("销售%v不存在", keys))
+	}
+	if err := json.Unmarshal(results[0], &listed); err != nil {
+		return listed, errors.New(fmt.Sprintf("Selling-反序列化出错: %s", err))
+	}
+	if listed.Buyer == "" {
+		return listed, nil
+	}
+	modifications, err := utils.GetHistoryByCompositeKey(stub, lib.SellingKey, keys)
+	if err != nil {
+		return listed, err
+	}
+	for i := len(modifications) - 1; i >= 0; i-- {
+		if modifications[i].GetIsDelete() {
+			continue
+		}
+		var version lib.Selling
+		if err := json.Unmarshal(modifications[i].GetValue(), &version); err != nil {
+			return listed, errors.New(fmt.Sprintf("Selling-反序列化出错: %s", err))
+		}
+		if version.Buyer == "" {
+			return version, nil
+		}
+	}
+	return listed, errors.New(fmt.Sprintf("没有找到销售%v买家购买之前的版本", keys))
+}
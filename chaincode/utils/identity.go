@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/msp"
+)
+
+// 交易提交者身份，由所属组织的MSP ID和X.509证书主题共同确定
+type Identity struct {
+	MspId       string
+	Subject     string
+	Certificate *x509.Certificate
+}
+
+// GetCreatorIdentity 解析stub.GetCreator()返回的序列化身份，与客户端身份库(cid)的解析方式一致
+func GetCreatorIdentity(stub shim.ChaincodeStubInterface) (*Identity, error) {
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("获取交易提交者身份出错: %s", err))
+	}
+	if len(creator) == 0 {
+		return nil, errors.New("交易提交者身份为空")
+	}
+	serializedIdentity := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, serializedIdentity); err != nil {
+		return nil, errors.New(fmt.Sprintf("反序列化交易提交者身份出错: %s", err))
+	}
+	block, _ := pem.Decode(serializedIdentity.IdBytes)
+	if block == nil {
+		return nil, errors.New("交易提交者身份中没有PEM格式的证书")
+	}
+	certificate, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("解析交易提交者X.509证书出错: %s", err))
+	}
+	return &Identity{
+		MspId:       serializedIdentity.Mspid,
+		Subject:     certificate.Subject.String(),
+		Certificate: certificate,
+	}, nil
+}
@@ -1,12 +1,20 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/protos/ledger/queryresult"
+	"time"
 )
 
+// 账本中时间统一使用UTC的RFC3339格式存储，与节点所在时区无关
+// 小数部分固定为9位，保证字符串的字典序与时间先后一致，CouchDB可以直接按字符串比较和排序
+const TimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
 func WriteLedger(obj interface{}, stub shim.ChaincodeStubInterface, objectType string, keys []string) error {
 	var key string
 	// 账本数据是以Key-Value形式进行存储的。一般使用主键ID（唯一）为Key，数据为Value进行存储。
@@ -43,6 +51,78 @@ func DelLedger(stub shim.ChaincodeStubInterface, objectType string, keys []strin
 	return nil
 }
 
+// WritePrivateData 以复合键写入私有数据集合，数据只保存在集合成员的节点上，账本中只记录哈希
+func WritePrivateData(obj interface{}, stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string) error {
+	key, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-创建复合主键出错 %s", objectType, err))
+	}
+	bytes, err := json.Marshal(obj)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-序列化json数据失败出错: %s", objectType, err))
+	}
+	if err := stub.PutPrivateData(collection, key, bytes); err != nil {
+		return errors.New(fmt.Sprintf("%s-写入私有数据集合%s出错: %s", objectType, collection, err))
+	}
+	return nil
+}
+
+// GetPrivateData 以复合键读取私有数据集合，不存在时返回nil
+func GetPrivateData(stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string) ([]byte, error) {
+	key, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-创建复合主键出错 %s", objectType, err))
+	}
+	bytes, err := stub.GetPrivateData(collection, key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-读取私有数据集合%s出错: %s", objectType, collection, err))
+	}
+	return bytes, nil
+}
+
+// GetPrivateDataByPartialCompositeKeys 获取私有数据集合中部分复合键下的全部数据
+func GetPrivateDataByPartialCompositeKeys(stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string) (results [][]byte, err error) {
+	resultIterator, err := stub.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-获取私有数据集合%s的数据出错: %s", objectType, collection, err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		results = append(results, val.GetValue())
+	}
+	return results, nil
+}
+
+// GetPrivateDataByPartialCompositeKeysWithPagination 分页获取私有数据集合中部分复合键下的数据
+// 私有数据不支持分页查询，这里按复合键顺序遍历，书签为下一页第一条记录的键
+func GetPrivateDataByPartialCompositeKeysWithPagination(stub shim.ChaincodeStubInterface, collection string, objectType string, keys []string, pageSize int32, bookmark string) (results [][]byte, nextBookmark string, hasMore bool, err error) {
+	resultIterator, err := stub.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+	if err != nil {
+		return nil, "", false, errors.New(fmt.Sprintf("%s-获取私有数据集合%s的数据出错: %s", objectType, collection, err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, "", false, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		if val.GetKey() < bookmark {
+			continue
+		}
+		if int32(len(results)) == pageSize {
+			return results, val.GetKey(), true, nil
+		}
+		results = append(results, val.GetValue())
+	}
+	return results, "", false, nil
+}
+
 func GetStateByPartialCompositeKeys(stub shim.ChaincodeStubInterface, objectType string, keys []string) (results [][]byte, err error) {
 	if len(keys) == 0 {
 		//GetStateByPartialCompositeKey方法获取有keys的集合迭代器
@@ -96,3 +176,140 @@ func GetStateByPartialCompositeKeys2(stub shim.ChaincodeStubInterface, objectTyp
 	}
 	return results, nil
 }
+
+// GetStateByPartialCompositeKeysWithPagination 分页获取部分复合键下的数据，返回下一页的书签
+// 取满一页时再用下一页的书签取一条，判断是否还有下一页
+func GetStateByPartialCompositeKeysWithPagination(stub shim.ChaincodeStubInterface, objectType string, keys []string, pageSize int32, bookmark string) (results [][]byte, nextBookmark string, hasMore bool, err error) {
+	resultIterator, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookmark)
+	if err != nil {
+		return nil, "", false, errors.New(fmt.Sprintf("%s-分页获取数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, "", false, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		results = append(results, val.GetValue())
+	}
+	if int32(len(results)) < pageSize || metadata.GetBookmark() == "" {
+		return results, "", false, nil
+	}
+	nextIterator, _, err := stub.GetStateByPartialCompositeKeyWithPagination(objectType, keys, 1, metadata.GetBookmark())
+	if err != nil {
+		return nil, "", false, errors.New(fmt.Sprintf("%s-分页获取数据出错: %s", objectType, err))
+	}
+	defer nextIterator.Close()
+	return results, metadata.GetBookmark(), nextIterator.HasNext(), nil
+}
+
+// GetQueryResultWithPagination 分页执行CouchDB富查询，返回下一页的书签
+// 取满一页时再用下一页的书签取一条，判断是否还有下一页
+func GetQueryResultWithPagination(stub shim.ChaincodeStubInterface, query string, pageSize int32, bookmark string) (results [][]byte, nextBookmark string, hasMore bool, err error) {
+	resultIterator, metadata, err := stub.GetQueryResultWithPagination(query, pageSize, bookmark)
+	if err != nil {
+		return nil, "", false, errors.New(fmt.Sprintf("富查询出错: %s", err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, "", false, errors.New(fmt.Sprintf("富查询返回的数据出错: %s", err))
+		}
+		results = append(results, val.GetValue())
+	}
+	if int32(len(results)) < pageSize || metadata.GetBookmark() == "" {
+		return results, "", false, nil
+	}
+	nextIterator, _, err := stub.GetQueryResultWithPagination(query, 1, metadata.GetBookmark())
+	if err != nil {
+		return nil, "", false, errors.New(fmt.Sprintf("富查询出错: %s", err))
+	}
+	defer nextIterator.Close()
+	return results, metadata.GetBookmark(), nextIterator.HasNext(), nil
+}
+
+// GetHistoryByCompositeKey 获取复合键的所有历史版本(包括删除)，按区块顺序排列
+func GetHistoryByCompositeKey(stub shim.ChaincodeStubInterface, objectType string, keys []string) (results []*queryresult.KeyModification, err error) {
+	key, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-创建复合主键出错 %s", objectType, err))
+	}
+	resultIterator, err := stub.GetHistoryForKey(key)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-获取历史数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-返回的历史数据出错: %s", objectType, err))
+		}
+		results = append(results, val)
+	}
+	return results, nil
+}
+
+// SetEvent 设置链码事件，payload序列化为JSON
+// 每笔交易只保留最后设置的一个事件，应在状态全部写入成功后调用
+func SetEvent(stub shim.ChaincodeStubInterface, eventName string, payload interface{}) error {
+	bytes, err := json.Marshal(payload)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-序列化事件出错: %s", eventName, err))
+	}
+	if err := stub.SetEvent(eventName, bytes); err != nil {
+		return errors.New(fmt.Sprintf("%s-设置事件出错: %s", eventName, err))
+	}
+	return nil
+}
+
+// GetTxTime 获取交易时间
+// 交易时间戳由客户端写入交易提案，所有背书节点获取到的值一致，链码中不能使用time.Now()
+func GetTxTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	timestamp, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, errors.New(fmt.Sprintf("获取交易时间戳出错: %s", err))
+	}
+	return time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).UTC(), nil
+}
+
+func FormatTime(t time.Time) string {
+	return t.UTC().Format(TimeLayout)
+}
+
+// 升级前的记录以节点本地时间(Asia/Shanghai)的"2006-01-02 15:04:05"格式存储，没有迁移，读取时按该时区解析
+// 链码容器中不一定有时区数据库，上海自1991年起不再实行夏令时，使用固定的东八区与LoadLocation结果一致
+const legacyTimeLayout = "2006-01-02 15:04:05"
+
+var legacyLocation = time.FixedZone("CST", 8*60*60)
+
+func ParseTime(value string) (time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, value) //兼容小数部分不定长的旧数据
+	if err != nil {
+		legacy, legacyErr := time.ParseInLocation(legacyTimeLayout, value, legacyLocation)
+		if legacyErr != nil {
+			return time.Time{}, errors.New(fmt.Sprintf("时间%s格式转换出错: %s", value, err))
+		}
+		t = legacy
+	}
+	return t.UTC(), nil
+}
+
+// TimeKey 将账本中存储的时间转换为复合键后缀(纳秒时间戳)，保证同一前缀下按时间排序
+func TimeKey(value string) (string, error) {
+	t, err := ParseTime(value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", t.UnixNano()), nil
+}
+
+// GenerateID 根据交易ID生成ID，所有背书节点生成的结果一致
+// 同一笔交易需要生成多个ID时，使用不同的seq区分
+func GenerateID(stub shim.ChaincodeStubInterface, seq int) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s-%d", stub.GetTxID(), seq)))
+	return hex.EncodeToString(sum[:])[:16]
+}
@@ -51,8 +51,10 @@ docker exec cli peer chaincode install -n blockchain-real-estate -v 1.0.0 -l gol
 #-v 为版本号，相当于composer network start bna名字@版本号
 #-C 是通道，在fabric的世界，一个通道就是一条不同的链，composer并没有很多提现这点，composer提现channel也就在于多组织时候的数据隔离和沟通使用
 #-c 为传参，传入init参数
+#--collections-config 为私有数据集合配置，密封拍卖的出价保存在collectionSealedBids中，账户余额和流水保存在collectionAccounts中，成交价、买家、捐赠双方、出价、贷款和租金等交易金额保存在collectionDeals中
+#集合的成员包含通道中所有背书组织，每次背书都要读取私有数据，只允许部分组织会导致其他组织的节点无法背书；账本中的公开记录保存完整记录的哈希(privateHash)供核对
 echo "八、实例化链码"
-docker exec cli peer chaincode instantiate -o orderer.blockchainrealestate.com:7050 -C assetschannel -n blockchain-real-estate -l golang -v 1.0.0 -c '{"Args":["init"]}'
+docker exec cli peer chaincode instantiate -o orderer.blockchainrealestate.com:7050 -C assetschannel -n blockchain-real-estate -l golang -v 1.0.0 -c '{"Args":["init"]}' --collections-config /etc/hyperledger/collections_config.json
 
 # 进行链码交互，验证链码是否正确安装及区块链网络能否正常工作
 # docker exec cli peer chaincode invoke -C assetschannel -n blockchain-real-estate -c '{"Args":[""]}'
\ No newline at end of file
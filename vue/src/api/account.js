@@ -1,15 +1,33 @@
 import request from '@/utils/request'
 
-// 获取登录界面角色选择列表
+// 获取账户选择列表(最多100个账户)
 export function queryAccountList() {
   return request({
     url: '/queryAccountList',
-    method: 'post'
+    method: 'post',
+    data: { pageSize: 100 }
   })
 }
 
-// 登录
+// 登录 data为accountId和password，返回会话令牌token
 export function login(data) {
+  return request({
+    url: '/login',
+    method: 'post',
+    data
+  })
+}
+
+// 退出登录，删除会话
+export function logout() {
+  return request({
+    url: '/logout',
+    method: 'post'
+  })
+}
+
+// 查询账户信息 data为{ args: [{ accountId }] }
+export function queryAccount(data) {
   return request({
     url: '/queryAccountList',
     method: 'post',
@@ -0,0 +1,91 @@
+import request from '@/utils/request'
+
+// 查询拍卖(可查询所有，也可根据卖家查询)
+export function queryAuctionList(data) {
+  return request({
+    url: '/queryAuctionList',
+    method: 'post',
+    data
+  })
+}
+
+// 查询一次拍卖的所有出价 seller和objectOfSale必填，竞拍人只能看到自己的出价金额
+export function queryAuctionBids(data) {
+  return request({
+    url: '/queryAuctionBids',
+    method: 'post',
+    data
+  })
+}
+
+// 发起拍卖
+export function createAuction(data) {
+  return request({
+    url: '/createAuction',
+    method: 'post',
+    data
+  })
+}
+
+// 竞拍出价
+export function placeBid(data) {
+  return request({
+    url: '/placeBid',
+    method: 'post',
+    data
+  })
+}
+
+// 拍卖结束后结算(成交或流拍)
+export function settleAuction(data) {
+  return request({
+    url: '/settleAuction',
+    method: 'post',
+    data
+  })
+}
+
+// 无人出价前取消拍卖
+export function cancelAuction(data) {
+  return request({
+    url: '/cancelAuction',
+    method: 'post',
+    data
+  })
+}
+
+// 发起密封拍卖
+export function createSealedAuction(data) {
+  return request({
+    url: '/createSealedAuction',
+    method: 'post',
+    data
+  })
+}
+
+// 提交密封出价(出价通过transient传给链码，账本中只记录哈希)
+export function commitBid(data) {
+  return request({
+    url: '/commitBid',
+    method: 'post',
+    data
+  })
+}
+
+// 拍卖结束后揭示密封出价
+export function revealBid(data) {
+  return request({
+    url: '/revealBid',
+    method: 'post',
+    data
+  })
+}
+
+// 查询一次密封拍卖的所有出价承诺
+export function querySealedBids(data) {
+  return request({
+    url: '/querySealedBids',
+    method: 'post',
+    data
+  })
+}
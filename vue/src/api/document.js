@@ -0,0 +1,40 @@
+import request from '@/utils/request'
+import { getToken } from '@/utils/auth'
+
+// 上传文档并在链上登记哈希 data为FormData，字段为file、accountId、objectType("realEstate"、"selling"、"donating")、objectKey(可以重复)，可选mediaType
+export function uploadDocument(data) {
+  return request({
+    url: '/uploadDocument',
+    method: 'post',
+    headers: { 'Content-Type': 'multipart/form-data' },
+    timeout: 60000,
+    data
+  })
+}
+
+// 查询对象关联的文档 objectType和objectKey必填，可以再指定hash
+export function queryDocuments(data) {
+  return request({
+    url: '/queryDocuments',
+    method: 'post',
+    data
+  })
+}
+
+// 校验文档 data为FormData，上传file时校验该文件，否则校验本地保存的hash对应的文件
+export function verifyDocument(data) {
+  return request({
+    url: '/verifyDocument',
+    method: 'post',
+    headers: { 'Content-Type': 'multipart/form-data' },
+    timeout: 60000,
+    data
+  })
+}
+
+// 下载文档的地址，直接在浏览器中打开，浏览器不会携带请求头，令牌放在查询参数中 accountId必须是关联对象的当事人、登记员或审计员
+export function downloadDocumentUrl(accountId, objectType, objectKey, hash) {
+  const params = new URLSearchParams({ accountId, objectType, hash, token: getToken() })
+  objectKey.forEach(key => params.append('objectKey', key))
+  return process.env.VUE_APP_BASE_API + '/downloadDocument?' + params.toString()
+}
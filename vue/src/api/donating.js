@@ -18,7 +18,7 @@ export function queryDonatingListByGrantee(data) {
   })
 }
 
-// 更新捐赠状态（确认受赠、取消） Status取值为 完成"done"、取消"cancelled"
+// 更新捐赠状态（确认受赠、取消、过期） Status取值为 完成"done"、取消"cancelled"、过期"expired"
 export function updateDonating(data) {
   return request({
     url: '/updateDonating',
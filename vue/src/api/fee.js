@@ -0,0 +1,28 @@
+import request from '@/utils/request'
+
+// 设置税费标准(登记员)，税率单位为万分之一
+export function setFeeSchedule(data) {
+  return request({
+    url: '/setFeeSchedule',
+    method: 'post',
+    data
+  })
+}
+
+// 查询当前的税费标准
+export function queryFeeSchedule(data) {
+  return request({
+    url: '/queryFeeSchedule',
+    method: 'post',
+    data
+  })
+}
+
+// 根据交易ID查询销售或捐赠完成时的税费收据
+export function queryReceipt(data) {
+  return request({
+    url: '/queryReceipt',
+    method: 'post',
+    data
+  })
+}
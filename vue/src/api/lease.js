@@ -0,0 +1,64 @@
+import request from '@/utils/request'
+
+// 查询租约(可查询所有，也可根据出租人或出租人和出租对象查询)
+export function queryLeaseList(data) {
+  return request({
+    url: '/queryLeaseList',
+    method: 'post',
+    data
+  })
+}
+
+// 根据承租人(承租人AccountId)查询租约
+export function queryLeaseListByTenant(data) {
+  return request({
+    url: '/queryLeaseListByTenant',
+    method: 'post',
+    data
+  })
+}
+
+// 查询一份租约的租金支付记录 landlord、objectOfLease和leaseId必填，只有出租人、承租人、登记员、银行和审计员可以查询
+export function queryLeasePayments(data) {
+  return request({
+    url: '/queryLeasePayments',
+    method: 'post',
+    data
+  })
+}
+
+// 业主发起租约
+export function createLease(data) {
+  return request({
+    url: '/createLease',
+    method: 'post',
+    data
+  })
+}
+
+// 承租人签署租约，支付押金和第一期租金
+export function signLease(data) {
+  return request({
+    url: '/signLease',
+    method: 'post',
+    data
+  })
+}
+
+// 承租人支付一期租金
+export function payRent(data) {
+  return request({
+    url: '/payRent',
+    method: 'post',
+    data
+  })
+}
+
+// 取消或终止租约 Status取值为 取消"cancelled"、终止"terminated"(需要deposit，退还"refunded"或没收"forfeited")
+export function updateLease(data) {
+  return request({
+    url: '/updateLease',
+    method: 'post',
+    data
+  })
+}
@@ -0,0 +1,37 @@
+import request from '@/utils/request'
+
+// 查询报价(可查询所有，也可根据卖家或卖家和销售对象查询)
+export function queryOfferList(data) {
+  return request({
+    url: '/queryOfferList',
+    method: 'post',
+    data
+  })
+}
+
+// 根据买家(买家AccountId)查询发出的报价
+export function queryOfferListByBuyer(data) {
+  return request({
+    url: '/queryOfferListByBuyer',
+    method: 'post',
+    data
+  })
+}
+
+// 买家报价
+export function createOffer(data) {
+  return request({
+    url: '/createOffer',
+    method: 'post',
+    data
+  })
+}
+
+// 答复报价 Status取值为 还价"countered"(需要price)、接受"accepted"、拒绝"rejected"、撤回"withdrawn"
+export function updateOffer(data) {
+  return request({
+    url: '/updateOffer',
+    method: 'post',
+    data
+  })
+}
@@ -0,0 +1,46 @@
+import request from '@/utils/request'
+
+// 查询质押 group取值为 进行中"active"、已结束"closed"，可以再指定出质人pledgor
+export function queryPledgeList(data) {
+  return request({
+    url: '/queryPledgeList',
+    method: 'post',
+    data
+  })
+}
+
+// 查询一笔质押的还款记录 pledgor、objectOfPledge和pledgeId必填，只有出质人、出借人、登记员、银行和审计员可以查询
+export function queryPledgeRepayments(data) {
+  return request({
+    url: '/queryPledgeRepayments',
+    method: 'post',
+    data
+  })
+}
+
+// 业主发起质押
+export function createPledge(data) {
+  return request({
+    url: '/createPledge',
+    method: 'post',
+    data
+  })
+}
+
+// 更新质押状态 Status取值为 放款"active"、取消"cancelled"、违约处置"defaulted"
+export function updatePledge(data) {
+  return request({
+    url: '/updatePledge',
+    method: 'post',
+    data
+  })
+}
+
+// 出质人还款
+export function repayPledge(data) {
+  return request({
+    url: '/repayPledge',
+    method: 'post',
+    data
+  })
+}
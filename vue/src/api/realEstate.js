@@ -1,6 +1,7 @@
 import request from '@/utils/request'
 
-// 新建房地产(管理员)
+// 新建房地产(管理员)，需要指定登记信息address、district、propertyType、floor、buildYear、certificateNo、usageTerm
+// 共有时指定owners(共有人accountId及份额share，单位为万分之一)和consentThreshold
 export function createRealEstate(data) {
   return request({
     url: '/createRealEstate',
@@ -9,6 +10,60 @@ export function createRealEstate(data) {
   })
 }
 
+// 变更房地产登记信息(登记员)，需要指定全部登记信息和变更原因reason
+export function updateRealEstate(data) {
+  return request({
+    url: '/updateRealEstate',
+    method: 'post',
+    data
+  })
+}
+
+// 查询房地产登记信息的变更记录
+export function queryRealEstateUpdates(data) {
+  return request({
+    url: '/queryRealEstateUpdates',
+    method: 'post',
+    data
+  })
+}
+
+// 拆分房地产(登记员)，parts为拆分后各部分的totalArea和livingSpace，合计必须等于原房地产
+export function splitRealEstate(data) {
+  return request({
+    url: '/splitRealEstate',
+    method: 'post',
+    data
+  })
+}
+
+// 合并所有权相同的房地产(登记员)，realEstateIds为合并的房地产ID列表
+export function mergeRealEstate(data) {
+  return request({
+    url: '/mergeRealEstate',
+    method: 'post',
+    data
+  })
+}
+
+// 共有人同意代表业主的处分 action取值为 出售"selling"、捐赠"donating"、拍卖"auction"、质押"pledge"、出租"lease"
+export function consentRealEstate(data) {
+  return request({
+    url: '/consentRealEstate',
+    method: 'post',
+    data
+  })
+}
+
+// 查询共有人尚未使用的同意
+export function queryRealEstateConsents(data) {
+  return request({
+    url: '/queryRealEstateConsents',
+    method: 'post',
+    data
+  })
+}
+
 // 获取房地产信息(空json{}可以查询所有，指定proprietor可以查询指定业主名下房产)
 export function queryRealEstateList(data) {
   return request({
@@ -17,3 +72,12 @@ export function queryRealEstateList(data) {
     data
   })
 }
+
+// 按所有者、总面积、生活空间范围等条件查询房地产，可指定排序sortBy(totalArea/livingSpace)和sortOrder(asc/desc)
+export function searchRealEstates(data) {
+  return request({
+    url: '/searchRealEstates',
+    method: 'post',
+    data
+  })
+}
@@ -44,3 +44,21 @@ export function createSelling(data) {
     data
   })
 }
+
+// 按状态、价格范围、创建时间等条件查询销售，可指定排序sortBy(createTime/price)和sortOrder(asc/desc)
+export function searchSellings(data) {
+  return request({
+    url: '/searchSellings',
+    method: 'post',
+    data
+  })
+}
+
+// 查询购房资金托管记录(可查询所有，也可根据卖家seller或卖家和销售对象objectOfSale查询)
+export function queryEscrowList(data) {
+  return request({
+    url: '/queryEscrowList',
+    method: 'post',
+    data
+  })
+}
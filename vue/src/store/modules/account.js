@@ -1,10 +1,14 @@
 import {
-  login
+  login,
+  logout,
+  queryAccount
 } from '@/api/account'
 import {
   getToken,
   setToken,
-  removeToken
+  removeToken,
+  getAccountId,
+  setAccountId
 } from '@/utils/auth'
 import {
   resetRouter
@@ -46,15 +50,12 @@ const mutations = {
 const actions = {
   login({
     commit
-  }, accountId) {
+  }, loginForm) {
     return new Promise((resolve, reject) => {
-      login({
-        args: [{
-          accountId: accountId
-        }]
-      }).then(response => {
-        commit('SET_TOKEN', response[0].accountId)
-        setToken(response[0].accountId)
+      login(loginForm).then(response => {
+        commit('SET_TOKEN', response.token)
+        setToken(response.token)
+        setAccountId(response.accountId)
         resolve()
       }).catch(error => {
         reject(error)
@@ -67,21 +68,21 @@ const actions = {
     state
   }) {
     return new Promise((resolve, reject) => {
-      login({
+      queryAccount({
         args: [{
-          accountId: state.token
+          accountId: getAccountId()
         }]
       }).then(response => {
         var roles
-        if (response[0].userName === '管理员') {
+        if (response.records[0].userName === '管理员') {
           roles = ['admin']
         } else {
           roles = ['editor']
         }
         commit('SET_ROLES', roles)
-        commit('SET_ACCOUNTID', response[0].accountId)
-        commit('SET_USERNAME', response[0].userName)
-        commit('SET_BALANCE', response[0].balance)
+        commit('SET_ACCOUNTID', response.records[0].accountId)
+        commit('SET_USERNAME', response.records[0].userName)
+        commit('SET_BALANCE', response.records[0].balance)
         resolve(roles)
       }).catch(error => {
         reject(error)
@@ -92,10 +93,13 @@ const actions = {
     commit
   }) {
     return new Promise(resolve => {
-      removeToken()
-      resetRouter()
-      commit('RESET_STATE')
-      resolve()
+      const reset = () => {
+        removeToken()
+        resetRouter()
+        commit('RESET_STATE')
+        resolve()
+      }
+      logout().then(reset).catch(reset)
     })
   },
 
@@ -1,6 +1,7 @@
 import Cookies from 'js-cookie'
 
-const TokenKey = 'account_id_token'
+const TokenKey = 'session_token'
+const AccountIdKey = 'account_id'
 
 export function getToken() {
   return Cookies.get(TokenKey)
@@ -11,5 +12,14 @@ export function setToken(token) {
 }
 
 export function removeToken() {
+  Cookies.remove(AccountIdKey)
   return Cookies.remove(TokenKey)
 }
+
+export function getAccountId() {
+  return Cookies.get(AccountIdKey)
+}
+
+export function setAccountId(accountId) {
+  return Cookies.set(AccountIdKey, accountId)
+}
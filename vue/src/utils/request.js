@@ -3,12 +3,23 @@ import {
   MessageBox,
   Message
 } from 'element-ui'
+import store from '@/store'
+import { getToken } from '@/utils/auth'
 
 const service = axios.create({
   baseURL: process.env.VUE_APP_BASE_API,
   timeout: 5000
 })
 
+// 携带登录会话的令牌，服务端以会话绑定的身份签名交易
+service.interceptors.request.use(config => {
+  const token = getToken()
+  if (token) {
+    config.headers['Authorization'] = 'Bearer ' + token
+  }
+  return config
+})
+
 service.interceptors.response.use(
   response => {
     const res = response.data
@@ -31,6 +42,12 @@ service.interceptors.response.use(
       })
       return Promise.reject(error)
     } else {
+      //会话过期后重新登录
+      if (error.response.status === 401 && getToken()) {
+        store.dispatch('account/resetToken').then(() => {
+          location.reload()
+        })
+      }
       Message({
         message: '失败 ' + error.response.data.data,
         type: 'error',